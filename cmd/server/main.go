@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/livrasand/gitGost/internal/config"
+	"github.com/livrasand/gitGost/internal/githubapp"
 	handler "github.com/livrasand/gitGost/internal/http"
 	"github.com/livrasand/gitGost/internal/utils"
 
@@ -93,12 +94,58 @@ func main() {
 		utils.Log("Warning: Supabase not configured, stats will not be persisted")
 	}
 
+	// Initialize GitHub App auth (PATs in GITHUB_TOKEN* remain the fallback)
+	if err := githubapp.Init(cfg.GitHubAppID, cfg.GitHubAppPrivateKey, cfg.GitHubAppPrivateKeyPath, cfg.GitHubAppForkOrg); err != nil {
+		utils.LogError("GitHub App disabled: %v", err)
+	} else if cfg.GitHubAppID != "" {
+		utils.Log("GitHub App authentication enabled")
+	}
+
+	// Persist bans, karma, reports, appeals and HMAC keys (in memory if unset)
+	if err := handler.InitModeration(cfg.ModerationDB); err != nil {
+		log.Fatalf("moderation store: %v", err)
+	} else if cfg.ModerationDB != "" {
+		utils.Log("Moderation state persisted in %s", cfg.ModerationDB)
+	}
+
+	// Share rate limits, tokens, jobs and the panic flag between instances
+	if err := handler.InitState(cfg.State, cfg.StateToken); err != nil {
+		log.Fatalf("shared state: %v", err)
+	} else if cfg.State != "" {
+		utils.Log("Shared state backend: %s", cfg.State)
+	}
+
+	// Keep PR records until their PR closes plus GITGOST_PR_RETENTION (0 keeps them forever)
+	handler.InitPRRetention(cfg.PRRetention)
+
+	// Forge webhooks are verified per repo; the GitHub App signs its own
+	handler.InitWebhooks(cfg.GitHubAppWebhookSecret)
+
+	// PR notifications are served by this instance; ntfy forwarding is optional
+	handler.InitNotifications(cfg.NtfyForward)
+
 	// Initialize panic button
 	handler.InitPanicConfig(cfg.PanicPassword, cfg.NtfyAdminTopic)
 
+	// Named admin credentials with moderator/operator roles
+	if err := handler.InitAdmins(cfg.Admins); err != nil {
+		log.Fatalf("admin credentials: %v", err)
+	}
+
 	// Initialize Menta CAPTCHA verification (no-op if MENTA_API_ENDPOINT is unset)
 	handler.InitMentaConfig(cfg.MentaAPIEndpoint, cfg.MentaAPIKey)
 
+	// Start the stale fork/branch reaper (GITGOST_REAPER_INTERVAL=0 disables it)
+	handler.InitReaper(cfg.ReaperInterval, cfg.ReaperGrace, cfg.ReaperDryRun)
+
+	// Proof-of-work gate for pushes and API writes (GITGOST_POW=off|burst|always)
+	handler.InitPow(cfg.PowMode, cfg.PowBits)
+
+	// Heuristic spam/malware screening of pushes (GITGOST_SCREEN=off disables it)
+	if err := handler.InitScreening(cfg.ScreenMode, cfg.ScreenRules); err != nil {
+		log.Fatalf("push screening: %v", err)
+	}
+
 	// Setup router
 	router := handler.SetupRouter(cfg)
 
@@ -57,7 +57,7 @@ CREATE POLICY "Allow public read karma" ON karma
 CREATE TABLE IF NOT EXISTS reports (
     id BIGSERIAL PRIMARY KEY,
     hash TEXT NOT NULL,
-    reason TEXT NOT NULL,
+    reason TEXT NOT NULL, -- categoría, opcionalmente seguida de ": " y la nota del reporte
     ip TEXT,
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
@@ -0,0 +1,519 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const adminUsage = `uso: git gost admin <comando> [--json] [--reason <motivo>]
+
+  login [nombre:secreto]          Guardar la credencial de admin (sin argumento: leer de stdin)
+  logout                          Borrar la credencial guardada
+  status                          Estado del servicio y de la credencial
+  panic on|off                    Activar o desactivar el modo pánico
+  rollback [selectores] [--preview]
+                                  Cerrar los PRs de la última ráfaga o, con selectores,
+                                  los abiertos que coincidan: --repo owner[/repo],
+                                  --provider gh|gl|cb, --since/--until (RFC 3339 o 6h),
+                                  --hash <prefijo>
+  appeals [list] [--all]          Listar apelaciones abiertas (--all: también resueltas)
+  appeals resolve <ticket> unban|dismiss
+                                  Resolver una apelación (vale un prefijo único del ticket)
+  block <hash>                    Bloquear un hash
+  unblock <hash>                  Desbloquear un hash
+`
+
+func adminCredentialPath() string {
+	return filepath.Join(dataDir(), "admin.key")
+}
+
+// adminCredential devuelve la credencial de GITGOST_ADMIN_PASSWORD o, si no
+// está definida, la guardada con 'git gost admin login'.
+func adminCredential() (string, error) {
+	if v := os.Getenv("GITGOST_ADMIN_PASSWORD"); v != "" {
+		return v, nil
+	}
+	data, err := os.ReadFile(adminCredentialPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return "", errors.New("no hay credencial de admin; ejecuta 'git gost admin login'")
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+type adminOpts struct {
+	json   bool
+	all    bool
+	reason string
+}
+
+func parseAdminFlags(args []string) (adminOpts, []string, error) {
+	var opts adminOpts
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--json":
+			opts.json = true
+		case a == "--all":
+			opts.all = true
+		case a == "--reason" || a == "-r":
+			if i+1 >= len(args) {
+				return opts, nil, fmt.Errorf("%s requiere un motivo", a)
+			}
+			i++
+			opts.reason = args[i]
+		case strings.HasPrefix(a, "--reason="):
+			opts.reason = strings.TrimPrefix(a, "--reason=")
+		default:
+			rest = append(rest, a)
+		}
+	}
+	return opts, rest, nil
+}
+
+// adminAPI llama a un endpoint /admin con la credencial guardada y devuelve
+// el JSON de la respuesta. Un body url.Values se envía como formulario.
+func adminAPI(method, path, credential string, body any) ([]byte, error) {
+	var payload io.Reader
+	contentType := ""
+	switch b := body.(type) {
+	case nil:
+	case url.Values:
+		payload = strings.NewReader(b.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, err
+		}
+		payload = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+	req, err := http.NewRequest(method, ServerBase()+path, payload)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Admin-Password", credential)
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		_ = json.Unmarshal(data, &e)
+		if e.Error == "" {
+			e.Error = resp.Status
+		}
+		if resp.StatusCode == http.StatusUnauthorized {
+			return nil, fmt.Errorf("servidor: %s (revisa 'git gost admin login')", e.Error)
+		}
+		return nil, fmt.Errorf("servidor: %s", e.Error)
+	}
+	return data, nil
+}
+
+// cmdAdmin agrupa los comandos de operación de una instancia gitGost. Con
+// --json imprime la respuesta del servidor tal cual, para scripts.
+func cmdAdmin(args []string) int {
+	opts, rest, err := parseAdminFlags(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	if len(rest) == 0 {
+		fmt.Fprint(os.Stderr, adminUsage)
+		return 1
+	}
+	cmd, rest := rest[0], rest[1:]
+	switch cmd {
+	case "login":
+		err = adminLogin(rest)
+	case "logout":
+		err = os.Remove(adminCredentialPath())
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		if err == nil {
+			fmt.Println("Credencial de admin borrada.")
+		}
+	case "status":
+		err = adminStatus(opts)
+	case "panic":
+		err = adminPanic(opts, rest)
+	case "rollback":
+		err = adminRollback(opts, rest)
+	case "appeals":
+		err = adminAppeals(opts, rest)
+	case "block", "unblock":
+		err = adminBlock(opts, cmd, rest)
+	case "help", "-h", "--help":
+		fmt.Print(adminUsage)
+		return 0
+	default:
+		fmt.Fprint(os.Stderr, adminUsage)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// adminCall resuelve la credencial, llama al servidor y, con --json, imprime
+// la respuesta. out se rellena siempre para que el llamante pueda decidir.
+func adminCall(opts adminOpts, method, path string, body, out any) error {
+	credential, err := adminCredential()
+	if err != nil {
+		return err
+	}
+	data, err := adminAPI(method, path, credential, body)
+	if err != nil {
+		return err
+	}
+	if opts.json {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, data, "", "  "); err != nil {
+			return err
+		}
+		fmt.Println(buf.String())
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+type adminStatusResponse struct {
+	Admin       string `json:"admin"`
+	Role        string `json:"role"`
+	PanicMode   bool   `json:"panic_mode"`
+	BurstAlert  bool   `json:"burst_alert"`
+	RollbackPRs int    `json:"rollback_prs"`
+	OpenAppeals int    `json:"open_appeals"`
+}
+
+func adminLogin(args []string) error {
+	credential := ""
+	if len(args) > 0 && args[0] != "-" {
+		credential = args[0]
+	} else {
+		fmt.Fprint(os.Stderr, "Credencial (nombre:secreto): ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return err
+		}
+		credential = strings.TrimSpace(line)
+	}
+	if credential == "" {
+		return errors.New("credencial vacía")
+	}
+	data, err := adminAPI("GET", "/admin/status", credential, nil)
+	if err != nil {
+		return err
+	}
+	var st adminStatusResponse
+	if err := json.Unmarshal(data, &st); err != nil {
+		return err
+	}
+	path := adminCredentialPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(credential+"\n"), 0o600); err != nil {
+		return err
+	}
+	fmt.Printf("Credencial de %s (%s) guardada en %s\n", st.Admin, st.Role, path)
+	return nil
+}
+
+func adminStatus(opts adminOpts) error {
+	var st adminStatusResponse
+	if err := adminCall(opts, "GET", "/admin/status", nil, &st); err != nil || opts.json {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "SERVIDOR\t%s\n", ServerBase())
+	fmt.Fprintf(w, "ADMIN\t%s (%s)\n", st.Admin, st.Role)
+	fmt.Fprintf(w, "PÁNICO\t%s\n", onOff(st.PanicMode))
+	fmt.Fprintf(w, "ALERTA DE RÁFAGA\t%s\n", onOff(st.BurstAlert))
+	fmt.Fprintf(w, "PRS PARA ROLLBACK\t%d\n", st.RollbackPRs)
+	fmt.Fprintf(w, "APELACIONES ABIERTAS\t%d\n", st.OpenAppeals)
+	return w.Flush()
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+func adminPanic(opts adminOpts, args []string) error {
+	if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+		return errors.New("uso: git gost admin panic on|off")
+	}
+	var out struct {
+		PanicMode bool `json:"panic_mode"`
+	}
+	req := map[string]any{"active": args[0] == "on", "reason": opts.reason}
+	if err := adminCall(opts, "POST", "/admin/panic", req, &out); err != nil || opts.json {
+		return err
+	}
+	fmt.Printf("Modo pánico: %s\n", onOff(out.PanicMode))
+	return nil
+}
+
+// parseRollbackArgs lee los selectores de rollback. --since y --until
+// aceptan RFC 3339 o una duración hacia atrás desde ahora ("6h", "90m").
+func parseRollbackArgs(args []string, now time.Time) (map[string]any, error) {
+	req := map[string]any{}
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--preview" {
+			req["preview"] = true
+			continue
+		}
+		key, ok := map[string]string{
+			"--repo": "repo", "--provider": "provider", "--since": "since", "--until": "until", "--hash": "hash_prefix",
+		}[a]
+		if !ok {
+			return nil, fmt.Errorf("opción desconocida: %s", a)
+		}
+		if i+1 >= len(args) {
+			return nil, fmt.Errorf("%s requiere un valor", a)
+		}
+		i++
+		v := args[i]
+		if key == "since" || key == "until" {
+			if d, err := time.ParseDuration(v); err == nil {
+				v = now.Add(-d).UTC().Format(time.RFC3339)
+			} else if _, err := time.Parse(time.RFC3339, v); err != nil {
+				return nil, fmt.Errorf("%s: usa RFC 3339 o una duración como 6h", a)
+			}
+		}
+		req[key] = v
+	}
+	return req, nil
+}
+
+func adminRollback(opts adminOpts, args []string) error {
+	req, err := parseRollbackArgs(args, time.Now())
+	if err != nil {
+		return fmt.Errorf("%v\nuso: git gost admin rollback [--repo owner[/repo]] [--provider gh|gl|cb] [--since t] [--until t] [--hash prefijo] [--preview]", err)
+	}
+	req["reason"] = opts.reason
+	var out struct {
+		Preview   bool `json:"preview"`
+		Truncated bool `json:"truncated"`
+		Closed    int  `json:"closed"`
+		Failed    int  `json:"failed"`
+		Results   []struct {
+			Hash      string    `json:"hash"`
+			Provider  string    `json:"provider"`
+			Owner     string    `json:"owner"`
+			Repo      string    `json:"repo"`
+			URL       string    `json:"url"`
+			CreatedAt time.Time `json:"created_at"`
+			Status    string    `json:"status"`
+			Error     string    `json:"error"`
+		} `json:"results"`
+	}
+	if err := adminCall(opts, "POST", "/admin/rollback", req, &out); err != nil {
+		return err
+	}
+	if !opts.json {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RESULTADO\tFORJA\tREPO\tHASH\tCREADO\tPR\tERROR")
+		for _, r := range out.Results {
+			repo, created := "-", "-"
+			if r.Owner != "" {
+				repo = r.Owner + "/" + r.Repo
+			}
+			if !r.CreatedAt.IsZero() {
+				created = r.CreatedAt.Local().Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.Status, r.Provider, repo, r.Hash, created, r.URL, r.Error)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		switch {
+		case out.Preview && out.Truncated:
+			fmt.Printf("\n%d PRs mostrados; hay más. Acota los selectores antes de cerrar.\n", len(out.Results))
+		case out.Preview:
+			fmt.Printf("\n%d PRs se cerrarían. Repite sin --preview para cerrarlos.\n", len(out.Results))
+		default:
+			fmt.Printf("\n%d cerrados, %d fallidos\n", out.Closed, out.Failed)
+		}
+	}
+	if out.Failed > 0 {
+		return fmt.Errorf("%d PRs no se pudieron cerrar", out.Failed)
+	}
+	return nil
+}
+
+type adminAppeal struct {
+	Ticket    string    `json:"ticket"`
+	Hash      string    `json:"hash"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+	Resolved  bool      `json:"resolved"`
+	Unbanned  bool      `json:"unbanned"`
+	Reports   *struct {
+		Score      int            `json:"score"`
+		Categories map[string]int `json:"categories"`
+	} `json:"reports"`
+}
+
+func adminAppeals(opts adminOpts, args []string) error {
+	if len(args) == 0 || args[0] == "list" {
+		return adminAppealsList(opts)
+	}
+	if args[0] != "resolve" || len(args) != 3 || (args[2] != "unban" && args[2] != "dismiss") {
+		return errors.New("uso: git gost admin appeals [list] [--all] | appeals resolve <ticket> unban|dismiss")
+	}
+	ticket, err := resolveAppealTicket(args[1])
+	if err != nil {
+		return err
+	}
+	var out struct {
+		Hash    string `json:"hash"`
+		Outcome string `json:"outcome"`
+	}
+	form := url.Values{"outcome": {args[2]}, "reason": {opts.reason}}
+	if err := adminCall(opts, "POST", "/admin/appeals/"+url.PathEscape(ticket)+"/resolve", form, &out); err != nil || opts.json {
+		return err
+	}
+	fmt.Printf("Apelación %s (%s): %s\n", shortTicket(ticket), out.Hash, out.Outcome)
+	return nil
+}
+
+func fetchAppeals() ([]adminAppeal, error) {
+	var out struct {
+		Appeals []adminAppeal `json:"appeals"`
+	}
+	if err := adminCall(adminOpts{}, "GET", "/admin/appeals", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Appeals, nil
+}
+
+// resolveAppealTicket completa un prefijo de ticket, como los que muestra
+// 'appeals list', entre las apelaciones abiertas.
+func resolveAppealTicket(prefix string) (string, error) {
+	appeals, err := fetchAppeals()
+	if err != nil {
+		return "", err
+	}
+	var match []string
+	for _, a := range appeals {
+		if a.Ticket == prefix {
+			return prefix, nil
+		}
+		if !a.Resolved && strings.HasPrefix(a.Ticket, prefix) {
+			match = append(match, a.Ticket)
+		}
+	}
+	switch len(match) {
+	case 0:
+		return "", fmt.Errorf("ninguna apelación abierta empieza por %q", prefix)
+	case 1:
+		return match[0], nil
+	default:
+		return "", fmt.Errorf("el prefijo %q es ambiguo (%d apelaciones)", prefix, len(match))
+	}
+}
+
+func adminAppealsList(opts adminOpts) error {
+	var out struct {
+		Appeals []adminAppeal `json:"appeals"`
+	}
+	if err := adminCall(opts, "GET", "/admin/appeals", nil, &out); err != nil || opts.json {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TICKET\tHASH\tESTADO\tREPORTES\tEDAD\tMENSAJE")
+	shown := 0
+	for _, a := range out.Appeals {
+		if a.Resolved && !opts.all {
+			continue
+		}
+		state, reports := "open", "-"
+		switch {
+		case a.Resolved && a.Unbanned:
+			state = "unbanned"
+		case a.Resolved:
+			state = "upheld"
+		}
+		if a.Reports != nil {
+			reports = fmt.Sprintf("%d", a.Reports.Score)
+		}
+		msg := strings.Join(strings.Fields(a.Message), " ")
+		if r := []rune(msg); len(r) > 50 {
+			msg = string(r[:50]) + "..."
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", shortTicket(a.Ticket), a.Hash, state, reports,
+			time.Since(a.CreatedAt).Round(time.Minute), msg)
+		shown++
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if shown == 0 {
+		fmt.Println("No hay apelaciones abiertas.")
+	}
+	return nil
+}
+
+func shortTicket(t string) string {
+	if len(t) > 8 {
+		return t[:8]
+	}
+	return t
+}
+
+func adminBlock(opts adminOpts, action string, args []string) error {
+	if len(args) != 1 || args[0] == "" {
+		return fmt.Errorf("uso: git gost admin %s <hash>", action)
+	}
+	var out struct {
+		Hash    string `json:"hash"`
+		Blocked bool   `json:"blocked"`
+	}
+	form := url.Values{"reason": {opts.reason}}
+	if err := adminCall(opts, "POST", "/admin/hashes/"+url.PathEscape(args[0])+"/"+action, form, &out); err != nil || opts.json {
+		return err
+	}
+	state := "desbloqueado"
+	if out.Blocked {
+		state = "bloqueado"
+	}
+	fmt.Printf("Hash %s: %s\n", out.Hash, state)
+	return nil
+}
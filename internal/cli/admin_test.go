@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestAdminCommands(t *testing.T) {
+	var resolved, reason string
+	var panicReq map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Admin-Password") != "alice:s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid credentials"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/admin/status":
+			_, _ = w.Write([]byte(`{"admin":"alice","role":"operator"}`))
+		case "/admin/panic":
+			_ = json.NewDecoder(r.Body).Decode(&panicReq)
+			_, _ = w.Write([]byte(`{"panic_mode":true}`))
+		case "/admin/appeals":
+			_, _ = w.Write([]byte(`{"appeals":[{"ticket":"abcdef0123","hash":"h1"},{"ticket":"abd000","hash":"h2"}]}`))
+		case "/admin/appeals/abcdef0123/resolve":
+			resolved = r.PostFormValue("outcome")
+			reason = r.PostFormValue("reason")
+			_, _ = w.Write([]byte(`{"hash":"h1","outcome":"unbanned"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("GITGOST_SERVER", srv.URL)
+	t.Setenv("GITGOST_HOME", t.TempDir())
+	t.Setenv("GITGOST_ADMIN_PASSWORD", "")
+
+	if code := cmdAdmin([]string{"status"}); code == 0 {
+		t.Fatal("status without a credential succeeded")
+	}
+	if code := cmdAdmin([]string{"login", "alice:wrong"}); code == 0 {
+		t.Fatal("login with a bad credential succeeded")
+	}
+	if code := cmdAdmin([]string{"login", "alice:s3cret"}); code != 0 {
+		t.Fatalf("login = %d", code)
+	}
+	if fi, err := os.Stat(adminCredentialPath()); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Fatalf("stored credential: %v %v", fi, err)
+	}
+
+	if code := cmdAdmin([]string{"panic", "on", "--reason", "spam wave", "--json"}); code != 0 {
+		t.Fatalf("panic on = %d", code)
+	}
+	if panicReq["active"] != true || panicReq["reason"] != "spam wave" {
+		t.Errorf("panic request = %v", panicReq)
+	}
+
+	if code := cmdAdmin([]string{"appeals", "resolve", "ab", "unban"}); code == 0 {
+		t.Error("ambiguous prefix accepted")
+	}
+	if code := cmdAdmin([]string{"appeals", "resolve", "abc", "unban", "-r", "false positive"}); code != 0 {
+		t.Fatalf("appeals resolve = %d", code)
+	}
+	if resolved != "unban" || reason != "false positive" {
+		t.Errorf("resolve form = %q, %q", resolved, reason)
+	}
+}
+
+func TestParseRollbackArgs(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	req, err := parseRollbackArgs([]string{"--repo", "acme/site", "--since", "6h", "--until", "2026-10-18T11:00:00Z", "--hash", "ab12", "--preview"}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req["repo"] != "acme/site" || req["since"] != "2026-10-18T06:00:00Z" || req["until"] != "2026-10-18T11:00:00Z" || req["hash_prefix"] != "ab12" || req["preview"] != true {
+		t.Errorf("req = %v", req)
+	}
+	for _, bad := range [][]string{{"--since", "ayer"}, {"--repo"}, {"--owner", "x"}} {
+		if _, err := parseRollbackArgs(bad, now); err == nil {
+			t.Errorf("%v accepted", bad)
+		}
+	}
+}
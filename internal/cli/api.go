@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/livrasand/gitGost/internal/pow"
+)
+
+var apiClient = &http.Client{Timeout: 30 * time.Second}
+
+// powSolveTimeout bounds the work spent on one challenge.
+const powSolveTimeout = 5 * time.Minute
+
+// apiJSON calls the gitGost API and decodes the response into out.
+func apiJSON(method, path string, body, out any) error {
+	return apiJSONHeader(method, path, nil, body, out)
+}
+
+// apiJSONHeader is apiJSON with arbitrary request headers. When the server
+// asks for a proof of work (428), it is solved and the request retried once.
+func apiJSONHeader(method, path string, header map[string]string, body, out any) error {
+	var payload []byte
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = b
+	}
+
+	var solution string
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(method, ServerBase()+path, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		if solution != "" {
+			req.Header.Set("X-Gitgost-Pow", solution)
+		}
+		resp, err := apiClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			var e struct {
+				Error     string `json:"error"`
+				Challenge string `json:"challenge"`
+			}
+			_ = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&e)
+			if resp.StatusCode == http.StatusPreconditionRequired && e.Challenge != "" && attempt == 0 {
+				if solution, err = solvePow(e.Challenge); err != nil {
+					return err
+				}
+				continue
+			}
+			if e.Error == "" {
+				e.Error = resp.Status
+			}
+			return fmt.Errorf("servidor: %s", e.Error)
+		}
+		if out == nil {
+			return nil
+		}
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+}
+
+func solvePow(challenge string) (string, error) {
+	bits, _ := pow.Bits(challenge)
+	fmt.Fprintf(os.Stderr, "git-gost: resolviendo prueba de trabajo (%d bits)...\n", bits)
+	ctx, cancel := context.WithTimeout(context.Background(), powSolveTimeout)
+	defer cancel()
+	return pow.Solve(ctx, challenge)
+}
@@ -45,6 +45,24 @@ func Run(args []string) int {
 		return cmdCancel(args[1:])
 	case "run":
 		return cmdRun(args[1:])
+	case "keygen":
+		return cmdKeygen(args[1:])
+	case "reply":
+		return cmdReply(args[1:])
+	case "inbox":
+		return cmdInbox(args[1:])
+	case "send":
+		return cmdSend(args[1:])
+	case "mailbox":
+		return cmdMailbox(args[1:])
+	case "webhook":
+		return cmdWebhook(args[1:])
+	case "notifications":
+		return cmdNotifications(args[1:])
+	case "tokens":
+		return cmdTokens(args[1:])
+	case "admin":
+		return cmdAdmin(args[1:])
 	case "help", "-h", "--help":
 		printUsage()
 		return 0
@@ -539,6 +557,23 @@ Uso:
   git gost pause <id>                 Pausar un job
   git gost resume <id>                Reanudar un job
   git gost cancel <id>                Cancelar un job
+  git gost inbox <hash> [secreto]     Leer el buzón privado de un PR (sin secreto: como mantenedor)
+  git gost send <hash> <secreto> <m>  Responder al mantenedor de forma anónima
+  git gost notifications <hash> <suscripción> <clave> [-f]
+                                      Leer (o seguir con -f) las notificaciones cifradas de un PR
+  git gost notifications open <hash> <clave> <texto|->
+                                      Descifrar una notificación recibida por ntfy
+  git gost notifications url <hash> <suscripción>
+                                      Enlaces temporales al feed Atom y al stream de un PR
+  git gost notifications key          Crear una clave propia para -o notify-key
+  git gost mailbox init [archivo]     Crear el buzón de mantenedor para .gitgost.yml
+  git gost keygen [archivo]           Crear la clave de firma de mantenedor para .gitgost.yml
+  git gost webhook init [archivo]     Crear el secreto del webhook para .gitgost.yml
+  git gost webhook secret <forja> <owner>/<repo>
+                                      Obtener la URL y el secreto de firma del webhook
+  git gost reply <hash> <mensaje|->   Escribir en privado al autor anónimo de un PR
+  git gost tokens [n|count]           Obtener tokens anónimos para push (o contarlos)
+  git gost admin <comando>            Operar una instancia (panic, rollback, appeals, block, status)
   git gost install                    Preparar el entorno del cliente
   git gost version                    Mostrar versión
 
@@ -550,5 +585,8 @@ Opciones de jobs:
 Variables de entorno:
   GITGOST_SERVER   URL base del servidor gitGost (por defecto https://gitgost.fly.dev)
   GITGOST_HOME     Directorio de datos (por defecto ~/.gitgost)
+  GITGOST_MAINTAINER_KEY  Clave de mantenedor (por defecto ~/.gitgost/maintainer.key)
+  GITGOST_MAILBOX_KEY     Secreto del buzón de mantenedor (por defecto ~/.gitgost/mailbox.key)
+  GITGOST_ADMIN_PASSWORD  Credencial de admin (por defecto la guardada en ~/.gitgost/admin.key)
 `)
 }
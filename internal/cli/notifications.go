@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/livrasand/gitGost/internal/prmsg"
+)
+
+const (
+	// notificationWait es lo que cada long-poll espera en el servidor, por
+	// debajo del timeout de apiClient.
+	notificationWait   = 25
+	subscriptionHeader = "X-Gitgost-Subscription"
+)
+
+type notification struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	Title     string    `json:"title"`
+	Message   string    `json:"message"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	Sealed    string    `json:"sealed"`
+}
+
+// cmdNotifications lee las notificaciones cifradas de un PR: las descarga
+// del servidor, o descifra una recibida por ntfy con "open".
+func cmdNotifications(args []string) int {
+	if len(args) > 0 && args[0] == "key" {
+		return cmdNotificationKey()
+	}
+	if len(args) > 0 && args[0] == "open" {
+		return cmdNotificationOpen(args[1:])
+	}
+	if len(args) > 0 && args[0] == "url" {
+		return cmdNotificationURL(args[1:])
+	}
+	follow := false
+	var rest []string
+	for _, a := range args {
+		if a == "-f" || a == "--follow" {
+			follow = true
+			continue
+		}
+		rest = append(rest, a)
+	}
+	if len(rest) < 3 {
+		fmt.Fprintln(os.Stderr, "uso: git gost notifications <pr-hash> <suscripción> <clave> [-f]")
+		fmt.Fprintln(os.Stderr, "     git gost notifications open <pr-hash> <clave> <texto|->")
+		fmt.Fprintln(os.Stderr, "     git gost notifications url <pr-hash> <suscripción>")
+		fmt.Fprintln(os.Stderr, "     git gost notifications key")
+		return 1
+	}
+	hash, sub, key := strings.ToLower(rest[0]), rest[1], rest[2]
+
+	var after int64
+	for {
+		wait := 0
+		if follow {
+			wait = notificationWait
+		}
+		var resp struct {
+			Events []notification `json:"events"`
+			LastID int64          `json:"last_id"`
+		}
+		path := fmt.Sprintf("/api/pr/%s/events?after=%d&wait=%d", url.PathEscape(hash), after, wait)
+		if err := apiJSONHeader("GET", path, map[string]string{subscriptionHeader: sub}, nil, &resp); err != nil {
+			fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+			return 1
+		}
+		if !follow && len(resp.Events) == 0 {
+			fmt.Println("No hay notificaciones.")
+		}
+		for _, n := range resp.Events {
+			printNotification(hash, key, n)
+		}
+		after = resp.LastID
+		if !follow {
+			return 0
+		}
+	}
+}
+
+// cmdNotificationURL pide enlaces temporales al feed Atom y al stream del PR,
+// para lectores de feeds y EventSource, que no pueden enviar cabeceras.
+func cmdNotificationURL(args []string) int {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "uso: git gost notifications url <pr-hash> <suscripción>")
+		return 1
+	}
+	hash := strings.ToLower(args[0])
+	var resp struct {
+		ExpiresAt string `json:"expires_at"`
+		StreamURL string `json:"stream_url"`
+		FeedURL   string `json:"feed_url"`
+	}
+	path := fmt.Sprintf("/api/pr/%s/events/token", url.PathEscape(hash))
+	if err := apiJSONHeader("POST", path, map[string]string{subscriptionHeader: args[1]}, nil, &resp); err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Enlaces válidos hasta %s:\n", resp.ExpiresAt)
+	fmt.Printf("  Feed Atom: %s\n", resp.FeedURL)
+	fmt.Printf("  Stream:    %s\n", resp.StreamURL)
+	return 0
+}
+
+// cmdNotificationKey crea una clave para cifrar las notificaciones de un PR
+// sin que el servidor vea nunca la mitad privada.
+func cmdNotificationKey() int {
+	secret, public, err := prmsg.NewSecret()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Clave de notificaciones (guárdala; no se vuelve a mostrar):\n  %s\n\n", secret)
+	fmt.Println("Úsala en el primer push del PR:")
+	fmt.Printf("  git push gost <rama>:main -o notify-key=%s\n\n", public)
+	fmt.Println("Crea una clave por PR: la misma clave en varios PRs permite relacionarlos.")
+	return 0
+}
+
+// cmdNotificationOpen descifra una notificación recibida por ntfy.
+func cmdNotificationOpen(args []string) int {
+	if len(args) < 3 {
+		fmt.Fprintln(os.Stderr, "uso: git gost notifications open <pr-hash> <clave> <texto|->")
+		return 1
+	}
+	hash, key := strings.ToLower(args[0]), args[1]
+	text := strings.Join(args[2:], " ")
+	if text == "-" {
+		b, err := io.ReadAll(io.LimitReader(os.Stdin, 64<<10))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+			return 1
+		}
+		text = string(b)
+	}
+	if !printNotification(hash, key, notification{Sealed: strings.TrimSpace(text)}) {
+		return 1
+	}
+	return 0
+}
+
+func printNotification(hash, key string, n notification) bool {
+	if n.Sealed != "" {
+		plain, err := prmsg.OpenNotification(key, hash, n.Sealed)
+		if err == nil {
+			err = json.Unmarshal(plain, &n)
+		}
+		if err != nil {
+			if n.ID > 0 {
+				fmt.Fprintf(os.Stderr, "git-gost: notificación %d: %v\n", n.ID, err)
+			} else {
+				fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+			}
+			return false
+		}
+	}
+	fmt.Printf("--- %s · %s ---\n%s\n", n.Title, n.CreatedAt.Format(time.RFC3339), n.Message)
+	if n.Body != "" {
+		fmt.Printf("\n%s\n", n.Body)
+	}
+	fmt.Println()
+	return true
+}
@@ -0,0 +1,274 @@
+package cli
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/livrasand/gitGost/internal/prmsg"
+)
+
+func maintainerKeyPath() string {
+	if v := os.Getenv("GITGOST_MAINTAINER_KEY"); v != "" {
+		return v
+	}
+	return filepath.Join(dataDir(), "maintainer.key")
+}
+
+// cmdKeygen crea la clave del mantenedor para firmar respuestas privadas.
+func cmdKeygen(args []string) int {
+	path := maintainerKeyPath()
+	if len(args) > 0 {
+		path = args[0]
+	}
+	if _, err := os.Stat(path); err == nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %s ya existe; no se sobrescribe\n", path)
+		return 1
+	}
+	private, public, err := prmsg.GenerateMaintainerKey()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	if err := os.WriteFile(path, []byte(private+"\n"), 0o600); err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Clave privada guardada en %s\n\n", path)
+	fmt.Println("Añade esta línea a .gitgost.yml (version: 1):")
+	fmt.Printf("  public_key: %q\n", public)
+	return 0
+}
+
+func mailboxKeyPath() string {
+	if v := os.Getenv("GITGOST_MAILBOX_KEY"); v != "" {
+		return v
+	}
+	return filepath.Join(dataDir(), "mailbox.key")
+}
+
+// cmdMailbox gestiona el buzón del mantenedor.
+func cmdMailbox(args []string) int {
+	if len(args) == 0 || args[0] != "init" {
+		fmt.Fprintln(os.Stderr, "uso: git gost mailbox init [archivo]")
+		return 1
+	}
+	path := mailboxKeyPath()
+	if len(args) > 1 {
+		path = args[1]
+	}
+	if _, err := os.Stat(path); err == nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %s ya existe; no se sobrescribe\n", path)
+		return 1
+	}
+	secret, public, err := prmsg.NewSecret()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	token, err := prmsg.AuthToken(secret)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	if err := os.WriteFile(path, []byte(secret+"\n"), 0o600); err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Secreto del buzón guardado en %s\n\n", path)
+	fmt.Println("Añade este bloque a .gitgost.yml (version: 1):")
+	fmt.Println("  mailbox:")
+	fmt.Printf("    key: %q\n", public)
+	fmt.Printf("    token_sha256: %q\n", prmsg.TokenHash(token))
+	return 0
+}
+
+func readMessageArg(args []string) (string, error) {
+	text := strings.Join(args, " ")
+	if text != "-" {
+		return text, nil
+	}
+	b, err := io.ReadAll(io.LimitReader(os.Stdin, prmsg.MaxPlaintext+1))
+	return string(b), err
+}
+
+type replyKeys struct {
+	ContributorKey string `json:"contributor_key"`
+	MailboxKey     string `json:"mailbox_key"`
+	Verified       bool   `json:"verified"`
+}
+
+func fetchReplyKeys(hash string) (*replyKeys, error) {
+	var keys replyKeys
+	if err := apiJSON("GET", "/api/pr/"+url.PathEscape(hash)+"/replies/key", nil, &keys); err != nil {
+		return nil, err
+	}
+	return &keys, nil
+}
+
+// mailboxHeader envía el token del buzón, si lo hay.
+func mailboxHeader(token string) map[string]string {
+	if token == "" {
+		return nil
+	}
+	return map[string]string{"X-Gitgost-Mailbox-Token": token}
+}
+
+// cmdReply envía un mensaje cifrado al autor anónimo de un PR. Se autentica
+// con el buzón del mantenedor y, si existe, firma con su clave pública.
+func cmdReply(args []string) int {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "uso: git gost reply <pr-hash> <mensaje|->")
+		return 1
+	}
+	hash := strings.ToLower(args[0])
+	text, err := readMessageArg(args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+
+	var token string
+	if raw, err := os.ReadFile(mailboxKeyPath()); err == nil {
+		if token, err = prmsg.AuthToken(string(raw)); err != nil {
+			fmt.Fprintf(os.Stderr, "git-gost: %s: %v\n", mailboxKeyPath(), err)
+			return 1
+		}
+	}
+	var signer ed25519.PrivateKey
+	if raw, err := os.ReadFile(maintainerKeyPath()); err == nil {
+		if signer, err = prmsg.ParsePrivateKey(string(raw)); err != nil {
+			fmt.Fprintf(os.Stderr, "git-gost: %s: %v\n", maintainerKeyPath(), err)
+			return 1
+		}
+	}
+	if token == "" && signer == nil {
+		fmt.Fprintln(os.Stderr, "git-gost: no hay buzón ni clave de mantenedor (git gost mailbox init / git gost keygen)")
+		return 1
+	}
+
+	keys, err := fetchReplyKeys(hash)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	var msg *prmsg.Message
+	if signer != nil {
+		msg, err = prmsg.Seal(signer, keys.ContributorKey, hash, []byte(text), time.Now().Unix())
+	} else {
+		msg, err = prmsg.Encrypt(keys.ContributorKey, hash, []byte(text), time.Now().Unix())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	if err := apiJSONHeader("POST", "/api/pr/"+url.PathEscape(hash)+"/replies", mailboxHeader(token), msg, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Mensaje cifrado enviado al autor de %s\n", hash)
+	return 0
+}
+
+// cmdSend envía una respuesta anónima del contribuidor al mantenedor.
+func cmdSend(args []string) int {
+	if len(args) < 3 {
+		fmt.Fprintln(os.Stderr, "uso: git gost send <pr-hash> <secreto> <mensaje|->")
+		return 1
+	}
+	hash, secret := strings.ToLower(args[0]), args[1]
+	text, err := readMessageArg(args[2:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	token, err := prmsg.AuthToken(secret)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: secreto inválido: %v\n", err)
+		return 1
+	}
+	keys, err := fetchReplyKeys(hash)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	if keys.MailboxKey == "" {
+		fmt.Fprintln(os.Stderr, "git-gost: el mantenedor no tiene buzón en .gitgost.yml")
+		return 1
+	}
+	msg, err := prmsg.Encrypt(keys.MailboxKey, hash, []byte(text), time.Now().Unix())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	if err := apiJSONHeader("POST", "/api/pr/"+url.PathEscape(hash)+"/replies", mailboxHeader(token), msg, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Respuesta anónima enviada al mantenedor de %s\n", hash)
+	return 0
+}
+
+// cmdInbox descifra los mensajes del buzón: con secreto, los del
+// contribuidor; sin él, los del mantenedor usando su buzón local.
+func cmdInbox(args []string) int {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "uso: git gost inbox <pr-hash> [secreto]")
+		return 1
+	}
+	hash := strings.ToLower(args[0])
+	var secret string
+	if len(args) > 1 {
+		secret = args[1]
+	} else {
+		raw, err := os.ReadFile(mailboxKeyPath())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "git-gost: falta el secreto o %s: %v\n", mailboxKeyPath(), err)
+			return 1
+		}
+		secret = string(raw)
+	}
+	token, err := prmsg.AuthToken(secret)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: secreto inválido: %v\n", err)
+		return 1
+	}
+
+	var resp struct {
+		Messages []struct {
+			prmsg.Message
+			From string `json:"from"`
+		} `json:"messages"`
+	}
+	if err := apiJSONHeader("GET", "/api/pr/"+url.PathEscape(hash)+"/replies", mailboxHeader(token), nil, &resp); err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	if len(resp.Messages) == 0 {
+		fmt.Println("No hay mensajes.")
+		return 0
+	}
+	for i := range resp.Messages {
+		m := &resp.Messages[i]
+		plain, err := prmsg.Open(secret, hash, &m.Message)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "git-gost: mensaje %d: %v\n", i+1, err)
+			continue
+		}
+		fmt.Printf("--- %s · %s ---\n%s\n\n", m.From, time.Unix(m.SentAt, 0).Format(time.RFC3339), plain)
+	}
+	return 0
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/livrasand/gitGost/internal/privpass"
+)
+
+// cmdTokens obtiene un lote de tokens anónimos firmados a ciegas. Cada push
+// con git gost gasta uno en lugar de contar contra el límite por IP.
+func cmdTokens(args []string) int {
+	s, err := openStore()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	defer s.Close()
+
+	if len(args) > 0 && args[0] == "count" {
+		n, err := s.CountTokens(ServerBase())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+			return 1
+		}
+		fmt.Printf("%d tokens anónimos disponibles para %s\n", n, ServerBase())
+		return 0
+	}
+
+	var keys struct {
+		Keys []struct {
+			ID string `json:"id"`
+			N  string `json:"n"`
+			E  int    `json:"e"`
+		} `json:"keys"`
+		Issuing  string `json:"issuing"`
+		BatchMax int    `json:"batch_max"`
+	}
+	if err := apiJSON("GET", "/api/tokens/keys", nil, &keys); err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	n := keys.BatchMax
+	if len(args) > 0 {
+		v, err := strconv.Atoi(args[0])
+		if err != nil || v <= 0 {
+			fmt.Fprintln(os.Stderr, "uso: git gost tokens [n|count]")
+			return 1
+		}
+		if v < n {
+			n = v
+		}
+	}
+
+	var modulus string
+	var exponent int
+	for _, k := range keys.Keys {
+		if k.ID == keys.Issuing {
+			modulus, exponent = k.N, k.E
+		}
+	}
+	key, err := privpass.DecodePublicKey(modulus, exponent)
+	if err != nil || privpass.KeyID(key) != keys.Issuing {
+		fmt.Fprintln(os.Stderr, "git-gost: el servidor publicó una clave de emisión inválida")
+		return 1
+	}
+
+	pending := make([]*privpass.Pending, 0, n)
+	blinded := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		b, p, err := privpass.Blind(key)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+			return 1
+		}
+		pending = append(pending, p)
+		blinded = append(blinded, base64.RawURLEncoding.EncodeToString(b))
+	}
+
+	var issued struct {
+		Signatures []string `json:"signatures"`
+	}
+	req := map[string]any{"key_id": keys.Issuing, "blinded": blinded}
+	if err := apiJSON("POST", "/api/tokens", req, &issued); err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	if len(issued.Signatures) != n {
+		fmt.Fprintln(os.Stderr, "git-gost: respuesta de emisión incompleta")
+		return 1
+	}
+
+	tokens := make([]string, 0, n)
+	for i, sig := range issued.Signatures {
+		raw, err := base64.RawURLEncoding.DecodeString(sig)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "git-gost: firma ilegible en la respuesta")
+			return 1
+		}
+		tok, err := pending[i].Finish(raw)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "git-gost: token rechazado: %v\n", err)
+			return 1
+		}
+		tokens = append(tokens, tok.String())
+	}
+	if err := s.AddTokens(ServerBase(), tokens); err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	total, _ := s.CountTokens(ServerBase())
+	fmt.Printf("%d tokens anónimos nuevos (%d disponibles). git gost push usará uno por push.\n", len(tokens), total)
+	return 0
+}
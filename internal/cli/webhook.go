@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/livrasand/gitGost/internal/prmsg"
+)
+
+func webhookKeyPath() string {
+	if v := os.Getenv("GITGOST_WEBHOOK_KEY"); v != "" {
+		return v
+	}
+	return filepath.Join(dataDir(), "webhook.key")
+}
+
+// cmdWebhook prepara el webhook con el que la forja avisa a gitGost de las
+// revisiones, comentarios y cierres de los PRs anónimos de un repo.
+func cmdWebhook(args []string) int {
+	if len(args) > 0 && args[0] == "init" {
+		return cmdWebhookInit(args[1:])
+	}
+	if len(args) > 0 && args[0] == "secret" {
+		return cmdWebhookSecret(args[1:])
+	}
+	fmt.Fprintln(os.Stderr, "uso: git gost webhook init [archivo]")
+	fmt.Fprintln(os.Stderr, "     git gost webhook secret <github|gitlab|codeberg> <owner>/<repo>")
+	return 1
+}
+
+// cmdWebhookInit crea el secreto del webhook; .gitgost.yml publica solo su hash.
+func cmdWebhookInit(args []string) int {
+	path := webhookKeyPath()
+	if len(args) > 0 {
+		path = args[0]
+	}
+	if _, err := os.Stat(path); err == nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %s ya existe; no se sobrescribe\n", path)
+		return 1
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	secret := hex.EncodeToString(b)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	if err := os.WriteFile(path, []byte(secret+"\n"), 0o600); err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Secreto del webhook guardado en %s\n\n", path)
+	fmt.Println("Añade este bloque a .gitgost.yml (version: 1):")
+	fmt.Println("  webhook:")
+	fmt.Printf("    secret_sha256: %q\n\n", prmsg.TokenHash(secret))
+	fmt.Println("Después, obtén la URL y el secreto de firma para la forja con:")
+	fmt.Println("  git gost webhook secret <github|gitlab|codeberg> <owner>/<repo>")
+	return 0
+}
+
+// cmdWebhookSecret cambia el secreto del webhook por el secreto de firma que
+// se configura en la forja. El servidor lo deriva del hash de .gitgost.yml.
+func cmdWebhookSecret(args []string) int {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "uso: git gost webhook secret <github|gitlab|codeberg> <owner>/<repo>")
+		return 1
+	}
+	raw, err := os.ReadFile(webhookKeyPath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v (ejecuta git gost webhook init)\n", err)
+		return 1
+	}
+	var resp struct {
+		URL    string `json:"url"`
+		Secret string `json:"secret"`
+	}
+	body := map[string]string{"provider": args[0], "repo": args[1]}
+	header := map[string]string{"X-Gitgost-Webhook-Secret": strings.TrimSpace(string(raw))}
+	if err := apiJSONHeader("POST", "/api/webhook-secret", header, body, &resp); err != nil {
+		fmt.Fprintf(os.Stderr, "git-gost: %v\n", err)
+		return 1
+	}
+	fmt.Println("Configura el webhook del repositorio en la forja con:")
+	fmt.Printf("  URL:     %s\n", resp.URL)
+	fmt.Printf("  Secreto: %s\n\n", resp.Secret)
+	fmt.Println("Eventos: pull requests, revisiones y comentarios (en GitLab, merge requests y comentarios).")
+	fmt.Println("Si cambias el secreto en .gitgost.yml, vuelve a ejecutar este comando.")
+	return 0
+}
@@ -18,9 +18,35 @@ type Config struct {
 	SupabaseURL      string
 	SupabaseKey      string
 	PanicPassword    string
+	Admins           string
 	NtfyAdminTopic   string
 	MentaAPIEndpoint string
 	MentaAPIKey      string
+
+	GitHubAppID             string
+	GitHubAppPrivateKey     string
+	GitHubAppPrivateKeyPath string
+	GitHubAppForkOrg        string
+
+	ReaperInterval time.Duration
+	ReaperGrace    time.Duration
+	ReaperDryRun   bool
+
+	PowMode string
+	PowBits int
+
+	ModerationDB string
+	PRRetention  time.Duration
+
+	State      string
+	StateToken string
+
+	GitHubAppWebhookSecret string
+
+	NtfyForward bool
+
+	ScreenMode  string
+	ScreenRules string
 }
 
 func Load() *Config {
@@ -36,9 +62,35 @@ func Load() *Config {
 		SupabaseURL:      getEnv("SUPABASE_URL", ""),
 		SupabaseKey:      getEnv("SUPABASE_KEY", ""),
 		PanicPassword:    getEnv("PANIC_PASSWORD", ""),
+		Admins:           getEnv("GITGOST_ADMINS", ""),
 		NtfyAdminTopic:   getEnv("NTFY_ADMIN_TOPIC", ""),
 		MentaAPIEndpoint: getEnv("MENTA_API_ENDPOINT", ""),
 		MentaAPIKey:      getEnv("MENTA_API_KEY", ""),
+
+		GitHubAppID:             getEnv("GITHUB_APP_ID", ""),
+		GitHubAppPrivateKey:     getEnv("GITHUB_APP_PRIVATE_KEY", ""),
+		GitHubAppPrivateKeyPath: getEnv("GITHUB_APP_PRIVATE_KEY_PATH", ""),
+		GitHubAppForkOrg:        getEnv("GITHUB_APP_FORK_ORG", ""),
+
+		ReaperInterval: getDurationEnv("GITGOST_REAPER_INTERVAL", 6*time.Hour),
+		ReaperGrace:    getDurationEnv("GITGOST_REAPER_GRACE", 72*time.Hour),
+		ReaperDryRun:   getEnv("GITGOST_REAPER_DRY_RUN", "") == "true",
+
+		PowMode: getEnv("GITGOST_POW", "off"),
+		PowBits: getIntEnv("GITGOST_POW_BITS", 18),
+
+		ModerationDB: getEnv("GITGOST_MODERATION_DB", ""),
+		PRRetention:  getDurationEnv("GITGOST_PR_RETENTION", 30*24*time.Hour),
+
+		State:      getEnv("GITGOST_STATE", ""),
+		StateToken: getEnv("GITGOST_STATE_TOKEN", ""),
+
+		GitHubAppWebhookSecret: getEnv("GITHUB_APP_WEBHOOK_SECRET", ""),
+
+		NtfyForward: getEnv("GITGOST_NTFY_FORWARD", "on") != "off",
+
+		ScreenMode:  getEnv("GITGOST_SCREEN", "on"),
+		ScreenRules: getEnv("GITGOST_SCREEN_RULES", ""),
 	}
 
 	return cfg
@@ -89,12 +89,14 @@ func (c *SupabaseClient) getJSON(ctx context.Context, path string, target interf
 	return json.NewDecoder(resp.Body).Decode(target)
 }
 
-func (c *SupabaseClient) HasReportFromIP(ctx context.Context, hash, ip string) (bool, error) {
-	if ip == "" {
+// HasReportFromIP reports whether ipHash already reported hash. Callers pass
+// the daily HMAC of the reporter's IP, never the address itself.
+func (c *SupabaseClient) HasReportFromIP(ctx context.Context, hash, ipHash string) (bool, error) {
+	if ipHash == "" {
 		return false, nil
 	}
 
-	url := fmt.Sprintf("%s/rest/v1/reports?hash=eq.%s&ip=eq.%s&select=id", c.URL, hash, ip)
+	url := fmt.Sprintf("%s/rest/v1/reports?hash=eq.%s&ip=eq.%s&select=id", c.URL, hash, ipHash)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return false, err
@@ -435,11 +437,14 @@ func (c *SupabaseClient) GetKarma(ctx context.Context, hash string) (int, error)
 	return rows[0].Karma, nil
 }
 
-func (c *SupabaseClient) InsertReport(ctx context.Context, hash, ip string) error {
+// InsertReport stores a report. ipHash is the daily HMAC of the reporter's
+// IP; it goes in the "ip" column so existing tables keep working. reason is
+// the report category, optionally followed by ": " and the reporter's note.
+func (c *SupabaseClient) InsertReport(ctx context.Context, hash, ipHash, reason string) error {
 	record := ReportRecord{
 		Hash:      hash,
-		Reason:    "report",
-		IP:        ip,
+		Reason:    reason,
+		IP:        ipHash,
 		CreatedAt: time.Now(),
 	}
 
@@ -473,6 +478,38 @@ func (c *SupabaseClient) InsertReport(ctx context.Context, hash, ip string) erro
 	return nil
 }
 
+// GetReports lists the reason and time of every report against hash. The
+// reporter's IP hash is not returned.
+func (c *SupabaseClient) GetReports(ctx context.Context, hash string) ([]ReportRecord, error) {
+	url := fmt.Sprintf("%s/rest/v1/reports?hash=eq.%s&select=hash,reason,created_at&order=created_at.asc", c.URL, hash)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("apikey", c.key)
+	req.Header.Set("Authorization", "Bearer "+c.key)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get reports: status %d", resp.StatusCode)
+	}
+
+	var reports []ReportRecord
+	if err := json.NewDecoder(resp.Body).Decode(&reports); err != nil {
+		return nil, err
+	}
+
+	return reports, nil
+}
+
 func (c *SupabaseClient) GetPRCountByRepo(ctx context.Context, owner, repo string) (int, error) {
 	url := fmt.Sprintf("%s/rest/v1/prs?owner=eq.%s&repo=eq.%s&select=id", c.URL, owner, repo)
 	req, err := http.NewRequest("GET", url, nil)
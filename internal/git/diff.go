@@ -0,0 +1,118 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// maxAddedLines bounds how many added lines DiffAgainstBase keeps per file.
+const maxAddedLines = 2000
+
+// FileDiff describes how one file changed between the base and HEAD.
+type FileDiff struct {
+	Path       string
+	Created    bool
+	Deleted    bool
+	Binary     bool
+	Executable bool
+	Added      int
+	Removed    int
+	// AddedLines holds the text of added lines, up to maxAddedLines.
+	AddedLines []string
+}
+
+// DiffAgainstBase lists the files that differ between baseBranch (the
+// target's default branch when empty) and HEAD. It fails when that branch
+// can't be resolved.
+func DiffAgainstBase(tempDir, baseBranch string) ([]FileDiff, error) {
+	r, err := git.PlainOpen(tempDir)
+	if err != nil {
+		return nil, err
+	}
+	head, err := r.Reference(plumbing.HEAD, true)
+	if err != nil {
+		return nil, err
+	}
+	headCommit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	// Diffing against anything but the real base would hide what the push
+	// changes, so a base that can't be found is an error.
+	var base *plumbing.Reference
+	if baseBranch != "" {
+		base, err = r.Reference(plumbing.NewRemoteReferenceName("origin", baseBranch), true)
+		if err != nil {
+			return nil, fmt.Errorf("base branch %s: %w", baseBranch, err)
+		}
+	} else if base = resolveBaseReference(r); base == nil {
+		return nil, errors.New("no default branch to diff against")
+	}
+	baseCommit, err := r.CommitObject(base.Hash())
+	if err != nil {
+		return nil, err
+	}
+	baseTree, err := baseCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTree(baseTree, headTree)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]FileDiff, 0, len(changes))
+	for _, ch := range changes {
+		d := FileDiff{
+			Path:       ch.To.Name,
+			Created:    ch.From.Name == "",
+			Deleted:    ch.To.Name == "",
+			Executable: ch.To.TreeEntry.Mode == filemode.Executable,
+		}
+		if d.Deleted {
+			d.Path = ch.From.Name
+		}
+		patch, err := ch.Patch()
+		if err != nil {
+			return nil, err
+		}
+		for _, fp := range patch.FilePatches() {
+			if fp.IsBinary() {
+				d.Binary = true
+				continue
+			}
+			for _, chunk := range fp.Chunks() {
+				lines := strings.SplitAfter(chunk.Content(), "\n")
+				if n := len(lines); n > 0 && lines[n-1] == "" {
+					lines = lines[:n-1]
+				}
+				switch chunk.Type() {
+				case fdiff.Add:
+					d.Added += len(lines)
+					for _, l := range lines {
+						if len(d.AddedLines) >= maxAddedLines {
+							break
+						}
+						d.AddedLines = append(d.AddedLines, strings.TrimSuffix(l, "\n"))
+					}
+				case fdiff.Delete:
+					d.Removed += len(lines)
+				}
+			}
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
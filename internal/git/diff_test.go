@@ -0,0 +1,79 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	goGit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestDiffAgainstBase(t *testing.T) {
+	dir := t.TempDir()
+	r, err := goGit.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := &object.Signature{Name: "a", Email: "a@a", When: time.Now()}
+	write := func(name, content string, mode os.FileMode) {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := wt.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("keep.txt", "one\ntwo\n", 0o644)
+	write("gone.txt", "a\nb\nc\n", 0o644)
+	base, err := wt.Commit("base", &goGit.CommitOptions{Author: sig})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Storer.SetReference(plumbing.NewHashReference(plumbing.NewRemoteReferenceName("origin", "main"), base)); err != nil {
+		t.Fatal(err)
+	}
+
+	write("keep.txt", "one\nTWO\nthree\n", 0o644)
+	if _, err := wt.Remove("gone.txt"); err != nil {
+		t.Fatal(err)
+	}
+	write("run.bin", "\x00\x01\x02", 0o755)
+	if _, err := wt.Commit("change", &goGit.CommitOptions{Author: sig}); err != nil {
+		t.Fatal(err)
+	}
+
+	diffs, err := DiffAgainstBase(dir, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	byPath := map[string]FileDiff{}
+	for _, d := range diffs {
+		byPath[d.Path] = d
+	}
+	if d := byPath["keep.txt"]; d.Added != 2 || d.Removed != 1 || len(d.AddedLines) != 2 || d.AddedLines[0] != "TWO" {
+		t.Errorf("keep.txt = %+v", d)
+	}
+	if d := byPath["gone.txt"]; !d.Deleted || d.Removed != 3 {
+		t.Errorf("gone.txt = %+v", d)
+	}
+	if d := byPath["run.bin"]; !d.Created || !d.Binary || !d.Executable {
+		t.Errorf("run.bin = %+v", d)
+	}
+
+	if _, err := DiffAgainstBase(dir, "develop"); err == nil {
+		t.Error("diffed against a base branch that doesn't exist")
+	}
+}
@@ -2,6 +2,7 @@ package git
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"strings"
 	"testing"
@@ -18,7 +19,7 @@ func TestPushToGitHub_NoToken(t *testing.T) {
 
 	os.Unsetenv("GITHUB_TOKEN")
 
-	_, err := PushToGitHub("owner", "repo", "/tmp/nonexistent", "forkowner", "", "", "", "")
+	_, err := PushToGitHub("owner", "repo", "/tmp/nonexistent", "forkowner", "", false, "", "", "")
 	if err == nil {
 		t.Error("Expected error when GITHUB_TOKEN is not set")
 	}
@@ -200,3 +201,26 @@ func TestGitClone_InvalidURLRejected(t *testing.T) {
 		t.Fatalf("expected URL validation error, got: %v", err)
 	}
 }
+
+func TestPushedRef(t *testing.T) {
+	var body strings.Builder
+	pkt := func(s string) {
+		body.WriteString(fmt.Sprintf("%04x%s", len(s)+4, s))
+	}
+	pkt("push-option=pr-hash=abcd\n")
+	pkt("0000000000000000000000000000000000000000 1111111111111111111111111111111111111111 refs/heads/develop\x00report-status side-band-64k\n")
+	body.WriteString("0000PACK")
+
+	if got := PushedRef([]byte(body.String())); got != "refs/heads/develop" {
+		t.Errorf("PushedRef = %q, want refs/heads/develop", got)
+	}
+	if got := PushedRef([]byte("0000")); got != "" {
+		t.Errorf("PushedRef of empty request = %q", got)
+	}
+	if got := PushOption([]byte(body.String()), "pr-hash"); got != "abcd" {
+		t.Errorf("PushOption(pr-hash) = %q, want abcd", got)
+	}
+	if got := PushOption([]byte(body.String()), "pow"); got != "" {
+		t.Errorf("PushOption(pow) = %q, want empty", got)
+	}
+}
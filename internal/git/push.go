@@ -18,7 +18,10 @@ func min(a, b int) int {
 	return b
 }
 
-func PushToGitHub(owner, repo, tempDir, forkOwner, targetBranch string, pushURL string, tokenEnvVar string, tokenOverride string) (string, error) {
+// PushToGitHub pushes HEAD to targetBranch, or to a new gitgost-<unix>
+// branch when targetBranch is empty. Only force rewrites an existing
+// branch, so a new branch never replaces one that is already there.
+func PushToGitHub(owner, repo, tempDir, forkOwner, targetBranch string, force bool, pushURL string, tokenEnvVar string, tokenOverride string) (string, error) {
 	if tokenEnvVar == "" {
 		tokenEnvVar = "GITHUB_TOKEN"
 	}
@@ -61,7 +64,7 @@ func PushToGitHub(owner, repo, tempDir, forkOwner, targetBranch string, pushURL
 	fmt.Printf("DEBUG: Remote 'fork' is ready\n")
 
 	refSpecStr := fmt.Sprintf("HEAD:refs/heads/%s", branch)
-	if targetBranch != "" {
+	if force {
 		refSpecStr = "+" + refSpecStr
 	}
 	refSpec := config.RefSpec(refSpecStr)
@@ -73,7 +76,7 @@ func PushToGitHub(owner, repo, tempDir, forkOwner, targetBranch string, pushURL
 			Username: "x-access-token",
 			Password: token,
 		},
-		Force: targetBranch != "",
+		Force: force,
 	})
 	if err != nil {
 		fmt.Printf("DEBUG: Push error: %v\n", err)
@@ -147,6 +147,44 @@ func ExtractPackfile(body []byte) ([]byte, *RefUpdate, string, string, error) {
 	return packfile, refUpdate, prHash, githubToken, nil
 }
 
+// PushOption returns the value of push option name ("-o name=value"), or
+// "" if the push didn't send it.
+func PushOption(body []byte, name string) string {
+	reader := bytes.NewReader(body)
+	prefix := "push-option=" + name + "="
+	for {
+		line, err := ParsePktLine(reader)
+		if err != nil || line == nil {
+			return ""
+		}
+		if lineStr := string(line); strings.HasPrefix(lineStr, prefix) {
+			return strings.TrimRight(strings.TrimPrefix(lineStr, prefix), "\n")
+		}
+	}
+}
+
+// PushedRef returns the ref named in the first update command of a
+// receive-pack request (e.g. "refs/heads/main"), or "" if there is none.
+func PushedRef(body []byte) string {
+	reader := bytes.NewReader(body)
+	for {
+		line, err := ParsePktLine(reader)
+		if err != nil || line == nil {
+			return ""
+		}
+		lineStr := string(line)
+		if strings.HasPrefix(lineStr, "push-option=") {
+			continue
+		}
+		if i := strings.IndexByte(lineStr, 0); i >= 0 {
+			lineStr = lineStr[:i]
+		}
+		if parts := strings.Fields(lineStr); len(parts) >= 3 {
+			return parts[2]
+		}
+	}
+}
+
 func ReceivePack(tempDir string, body []byte, owner string, repo string, cloneURL string, tokenEnvVar string, tokenOverride string) (string, string, string, string, error) {
 	if cloneURL == "" {
 		cloneURL = fmt.Sprintf("https://github.com/%s/%s.git", owner, repo)
@@ -86,3 +86,105 @@ func SquashCommits(tempDir string) (string, error) {
 
 	return hash.String(), nil
 }
+
+// baseReference resolves branch on the cloned target, falling back to the
+// target's default branch when branch is empty or unknown.
+func baseReference(r *git.Repository, branch string) *plumbing.Reference {
+	if branch != "" {
+		if ref, err := r.Reference(plumbing.NewRemoteReferenceName("origin", branch), true); err == nil {
+			return ref
+		}
+	}
+	return resolveBaseReference(r)
+}
+
+// SquashOntoBase replaces the anonymized commits on HEAD with a single commit
+// on top of baseBranch (the target's default branch when empty) carrying
+// HEAD's tree and message.
+func SquashOntoBase(tempDir, baseBranch, message string) (string, error) {
+	r, err := git.PlainOpen(tempDir)
+	if err != nil {
+		return "", err
+	}
+
+	head, err := r.Reference(plumbing.HEAD, true)
+	if err != nil {
+		return "", err
+	}
+	headCommit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return "", err
+	}
+
+	var parents []plumbing.Hash
+	if base := baseReference(r, baseBranch); base != nil {
+		if base.Hash() == headCommit.Hash {
+			return headCommit.Hash.String(), nil
+		}
+		parents = []plumbing.Hash{base.Hash()}
+	}
+
+	anonSignature := object.Signature{
+		Name:  "@gitgost-anonymous",
+		Email: "anonymous@gitgost.local",
+		When:  time.Now(),
+	}
+	newCommit := &object.Commit{
+		Author:       anonSignature,
+		Committer:    anonSignature,
+		Message:      message,
+		TreeHash:     headCommit.TreeHash,
+		ParentHashes: parents,
+	}
+
+	obj := r.Storer.NewEncodedObject()
+	if err := newCommit.Encode(obj); err != nil {
+		return "", err
+	}
+	hash, err := r.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return "", err
+	}
+	if err := r.Storer.SetReference(plumbing.NewHashReference(plumbing.HEAD, hash)); err != nil {
+		return "", err
+	}
+	return hash.String(), nil
+}
+
+// ChangedFiles counts the files that differ between baseBranch (the
+// target's default branch when empty) and HEAD. Without a base every file
+// in HEAD counts.
+func ChangedFiles(tempDir, baseBranch string) (int, error) {
+	r, err := git.PlainOpen(tempDir)
+	if err != nil {
+		return 0, err
+	}
+	head, err := r.Reference(plumbing.HEAD, true)
+	if err != nil {
+		return 0, err
+	}
+	headCommit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return 0, err
+	}
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return 0, err
+	}
+
+	var baseTree *object.Tree
+	if base := baseReference(r, baseBranch); base != nil {
+		if baseCommit, err := r.CommitObject(base.Hash()); err == nil {
+			baseTree, _ = baseCommit.Tree()
+		}
+	}
+	if baseTree == nil {
+		baseTree = &object.Tree{}
+	}
+
+	changes, err := object.DiffTree(baseTree, headTree)
+	if err != nil {
+		return 0, err
+	}
+	return len(changes), nil
+}
@@ -1,8 +1,17 @@
 package github
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/livrasand/gitGost/internal/provider"
 )
 
 func TestCreatePR_NoToken(t *testing.T) {
@@ -11,7 +20,7 @@ func TestCreatePR_NoToken(t *testing.T) {
 
 	os.Unsetenv("GITHUB_TOKEN")
 
-	_, err := CreatePR("owner", "repo", "branch", "forkowner", "test commit message")
+	_, err := CreatePR(context.Background(), "owner", "repo", "branch", "forkowner", "test commit message", provider.MROptions{})
 	if err == nil {
 		t.Error("Expected error when GITHUB_TOKEN is not set")
 	}
@@ -19,3 +28,66 @@ func TestCreatePR_NoToken(t *testing.T) {
 		t.Errorf("Expected 'GITHUB_TOKEN not set', got '%s'", err.Error())
 	}
 }
+
+// rewriteTransport sends every request to srv, keeping its path.
+type rewriteTransport struct{ srv *httptest.Server }
+
+func (rt rewriteTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme, r.URL.Host = "http", strings.TrimPrefix(rt.srv.URL, "http://")
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestListForksUsesOneQueryPerPage(t *testing.T) {
+	var queries int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/graphql" {
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		queries++
+		var req struct {
+			Variables struct {
+				Login string  `json:"login"`
+				After *string `json:"after"`
+			} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Variables.Login != "ghost" {
+			t.Errorf("login = %q", req.Variables.Login)
+		}
+		if req.Variables.After == nil {
+			w.Write([]byte(`{"data":{"repositoryOwner":{"repositories":{
+				"nodes":[{"name":"site","pushedAt":"2026-10-01T00:00:00Z","parent":{"name":"site","owner":{"login":"acme"}}},
+				         {"name":"orphan","pushedAt":"2026-10-01T00:00:00Z","parent":null}],
+				"pageInfo":{"hasNextPage":true,"endCursor":"c1"}}}}}`))
+			return
+		}
+		if *req.Variables.After != "c1" {
+			t.Errorf("after = %q", *req.Variables.After)
+		}
+		w.Write([]byte(`{"data":{"repositoryOwner":{"repositories":{
+			"nodes":[{"name":"api","pushedAt":"2026-10-02T00:00:00Z","parent":{"name":"api","owner":{"login":"corp"}}}],
+			"pageInfo":{"hasNextPage":false,"endCursor":"c2"}}}}}`))
+	}))
+	defer srv.Close()
+	oldTransport := httpClient.Transport
+	httpClient.Transport = rewriteTransport{srv}
+	defer func() { httpClient.Transport = oldTransport }()
+
+	forks, err := ListForks(context.Background(), provider.Account{Token: "tok", Owner: "ghost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []provider.Fork{
+		{Owner: "ghost", Repo: "site", ParentOwner: "acme", ParentRepo: "site", PushedAt: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{Owner: "ghost", Repo: "api", ParentOwner: "corp", ParentRepo: "api", PushedAt: time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	if !reflect.DeepEqual(forks, want) {
+		t.Errorf("ListForks = %+v", forks)
+	}
+	if queries != 2 {
+		t.Errorf("%d queries for two pages", queries)
+	}
+}
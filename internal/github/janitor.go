@@ -0,0 +1,241 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/livrasand/gitGost/internal/githubapp"
+	"github.com/livrasand/gitGost/internal/provider"
+	"github.com/livrasand/gitGost/internal/tokenpool"
+	"github.com/livrasand/gitGost/internal/utils"
+)
+
+// ServiceAccounts lists the accounts that own gitGost forks on GitHub: every
+// pooled PAT and, when configured, the app's fork organization.
+func ServiceAccounts(ctx context.Context) []provider.Account {
+	var accounts []provider.Account
+	for _, t := range tokenpool.Tokens(tokenpool.GitHub) {
+		login, err := githubLogin(ctx, t)
+		if err != nil {
+			utils.Log("Skipping service account %s: %v", tokenpool.Fingerprint(t), err)
+			continue
+		}
+		accounts = append(accounts, provider.Account{Token: t, Owner: login})
+	}
+	if app := githubapp.Default(); app != nil && app.ForkOrg != "" {
+		if t := githubapp.TokenFor(ctx, app.ForkOrg); t != "" {
+			accounts = append(accounts, provider.Account{Token: t, Owner: app.ForkOrg})
+		}
+	}
+	return accounts
+}
+
+// githubGetJSON decodes one page of a GET into out and returns the URL of
+// the next page, if any.
+func githubGetJSON(ctx context.Context, token, u, op string, out interface{}) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "gitGost")
+
+	resp, err := githubDo(req, token)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", provider.ErrorFromResponse(resp, op)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return "", err
+	}
+	return nextPageURL(resp.Header.Get("Link")), nil
+}
+
+// forksQuery lists an account's forks with their parents in one page of
+// 100; the REST repo list omits the parent.
+const forksQuery = `query($login: String!, $after: String) {
+	repositoryOwner(login: $login) {
+		repositories(first: 100, after: $after, isFork: true, ownerAffiliations: OWNER) {
+			nodes { name pushedAt parent { name owner { login } } }
+			pageInfo { hasNextPage endCursor }
+		}
+	}
+}`
+
+// ListForks returns the forks owned by acct.
+func ListForks(ctx context.Context, acct provider.Account) ([]provider.Fork, error) {
+	var forks []provider.Fork
+	var after *string
+	for {
+		payload, err := json.Marshal(map[string]interface{}{
+			"query":     forksQuery,
+			"variables": map[string]interface{}{"login": acct.Owner, "after": after},
+		})
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, "POST", "https://api.github.com/graphql", bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "gitGost")
+
+		resp, err := githubDo(req, acct.Token)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Data struct {
+				RepositoryOwner *struct {
+					Repositories struct {
+						Nodes []struct {
+							Name     string    `json:"name"`
+							PushedAt time.Time `json:"pushedAt"`
+							Parent   *struct {
+								Name  string `json:"name"`
+								Owner struct {
+									Login string `json:"login"`
+								} `json:"owner"`
+							} `json:"parent"`
+						} `json:"nodes"`
+						PageInfo struct {
+							HasNextPage bool   `json:"hasNextPage"`
+							EndCursor   string `json:"endCursor"`
+						} `json:"pageInfo"`
+					} `json:"repositories"`
+				} `json:"repositoryOwner"`
+			} `json:"data"`
+			Errors []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			err = provider.ErrorFromResponse(resp, "failed to list forks")
+		} else {
+			err = json.NewDecoder(resp.Body).Decode(&page)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(page.Errors) > 0 {
+			return nil, fmt.Errorf("graphql: %s", page.Errors[0].Message)
+		}
+		owner := page.Data.RepositoryOwner
+		if owner == nil {
+			return nil, fmt.Errorf("account %s: %w", acct.Owner, provider.ErrNotFound)
+		}
+		for _, r := range owner.Repositories.Nodes {
+			// A fork whose parent was deleted has nothing to open PRs in.
+			if r.Parent == nil {
+				continue
+			}
+			forks = append(forks, provider.Fork{
+				Owner:       acct.Owner,
+				Repo:        r.Name,
+				ParentOwner: r.Parent.Owner.Login,
+				ParentRepo:  r.Parent.Name,
+				PushedAt:    r.PushedAt,
+			})
+		}
+		if !owner.Repositories.PageInfo.HasNextPage {
+			return forks, nil
+		}
+		cursor := owner.Repositories.PageInfo.EndCursor
+		after = &cursor
+	}
+}
+
+// ListBranches returns the branch names of owner/repo.
+func ListBranches(ctx context.Context, acct provider.Account, owner, repo string) ([]string, error) {
+	var names []string
+	next := fmt.Sprintf("https://api.github.com/repos/%s/%s/branches?per_page=100", owner, repo)
+	for next != "" {
+		var page []struct {
+			Name string `json:"name"`
+		}
+		var err error
+		next, err = githubGetJSON(ctx, acct.Token, next, "failed to list branches", &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range page {
+			names = append(names, b.Name)
+		}
+	}
+	return names, nil
+}
+
+// BranchPR returns the most recent PR from forkOwner:branch into owner/repo,
+// or nil when none exists.
+func BranchPR(ctx context.Context, acct provider.Account, owner, repo, forkOwner, branch string) (*provider.BranchPR, error) {
+	head := fmt.Sprintf("%s:%s", forkOwner, branch)
+	prListURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls?state=all&head=%s&per_page=1",
+		owner, repo, url.QueryEscape(head))
+	var prs []struct {
+		Number   int        `json:"number"`
+		State    string     `json:"state"`
+		MergedAt *time.Time `json:"merged_at"`
+		ClosedAt *time.Time `json:"closed_at"`
+	}
+	if _, err := githubGetJSON(ctx, acct.Token, prListURL, "failed to list PRs", &prs); err != nil {
+		return nil, err
+	}
+	if len(prs) == 0 {
+		return nil, nil
+	}
+	pr := &provider.BranchPR{Number: prs[0].Number, State: provider.PRStateOpen}
+	switch {
+	case prs[0].MergedAt != nil:
+		pr.State = provider.PRStateMerged
+		pr.ClosedAt = *prs[0].MergedAt
+	case prs[0].State == "closed":
+		pr.State = provider.PRStateClosed
+		if prs[0].ClosedAt != nil {
+			pr.ClosedAt = *prs[0].ClosedAt
+		}
+	}
+	return pr, nil
+}
+
+func githubDelete(ctx context.Context, token, u, op string) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "gitGost")
+
+	resp, err := githubDo(req, token)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return provider.ErrorFromResponse(resp, op)
+	}
+	return nil
+}
+
+// DeleteBranch removes branch from owner/repo. A branch that is already gone
+// counts as deleted.
+func DeleteBranch(ctx context.Context, acct provider.Account, owner, repo, branch string) error {
+	u := fmt.Sprintf("https://api.github.com/repos/%s/%s/git/refs/heads/%s", owner, repo, url.PathEscape(branch))
+	return githubDelete(ctx, acct.Token, u, "failed to delete branch")
+}
+
+// DeleteRepo deletes owner/repo. The token needs the delete_repo scope.
+func DeleteRepo(ctx context.Context, acct provider.Account, owner, repo string) error {
+	return githubDelete(ctx, acct.Token, fmt.Sprintf("https://api.github.com/repos/%s/%s", owner, repo), "failed to delete repo")
+}
@@ -2,10 +2,12 @@ package github
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -15,7 +17,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/livrasand/gitGost/internal/githubapp"
+	"github.com/livrasand/gitGost/internal/provider"
 	"github.com/livrasand/gitGost/internal/tokenpool"
+	"github.com/livrasand/gitGost/internal/utils"
 	"gopkg.in/yaml.v3"
 )
 
@@ -28,15 +33,51 @@ func resolveGitHubToken(token string) string {
 	return tokenpool.NextGitHubToken()
 }
 
-// githubDo executes a request with the given token and marks the token as
-// rate-limited when GitHub returns 403/429 (rate limit exceeded).
+// resolveGitHubTokenFor picks the credential for a call against a repository
+// owned by owner: an explicit user token wins, then the GitHub App
+// installation on owner, then the PAT pool.
+func resolveGitHubTokenFor(ctx context.Context, owner, token string) string {
+	if strings.TrimSpace(token) != "" {
+		return strings.TrimSpace(token)
+	}
+	if t := githubapp.TokenFor(ctx, owner); t != "" {
+		return t
+	}
+	if t := tokenpool.TokenForLogin(tokenpool.GitHub, owner); t != "" {
+		return t
+	}
+	return tokenpool.NextGitHubToken()
+}
+
+// resolveGitHubForkToken picks the credential for acting on a PR whose head
+// lives on forkOwner's fork: an explicit token, the app installation on the
+// target owner, then the service account that owns the fork.
+func resolveGitHubForkToken(ctx context.Context, owner, forkOwner, token string) string {
+	if strings.TrimSpace(token) != "" {
+		return strings.TrimSpace(token)
+	}
+	if t := githubapp.TokenFor(ctx, owner); t != "" {
+		return t
+	}
+	return resolveGitHubTokenFor(ctx, forkOwner, "")
+}
+
+// GitToken returns the credential used for git clone/push against
+// repositories under owner, or "" when none is configured.
+func GitToken(ctx context.Context, owner string) string {
+	return resolveGitHubTokenFor(ctx, owner, "")
+}
+
+// githubDo executes a request with the given token and feeds the response's
+// rate-limit headers to the token pool, which cools the token down when
+// GitHub throttled it.
 func githubDo(req *http.Request, token string) (*http.Response, error) {
 	if token != "" {
 		req.Header.Set("Authorization", "token "+token)
 	}
 	resp, err := httpClient.Do(req)
-	if err == nil && resp != nil && (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests) {
-		tokenpool.MarkGitHubRateLimited(token, resp.Header.Get("X-RateLimit-Reset"))
+	if err == nil {
+		tokenpool.Observe(tokenpool.GitHub, token, resp)
 	}
 	return resp, err
 }
@@ -291,12 +332,12 @@ func DeleteCommentsByHash(hash string) error {
 	return nil
 }
 
-func CreateAnonymousIssue(owner, repo, title, body string, labels []string) (string, int, error) {
-	return CreateAnonymousIssueWithToken(owner, repo, title, body, labels, "")
+func CreateAnonymousIssue(ctx context.Context, owner, repo, title, body string, labels []string) (string, int, error) {
+	return CreateAnonymousIssueWithToken(ctx, owner, repo, title, body, labels, "")
 }
 
-func CreateAnonymousIssueWithToken(owner, repo, title, body string, labels []string, token string) (string, int, error) {
-	token = resolveGitHubToken(token)
+func CreateAnonymousIssueWithToken(ctx context.Context, owner, repo, title, body string, labels []string, token string) (string, int, error) {
+	token = resolveGitHubTokenFor(ctx, owner, token)
 	if token == "" {
 		return "", 0, fmt.Errorf("GITHUB_TOKEN not set")
 	}
@@ -316,7 +357,7 @@ func CreateAnonymousIssueWithToken(owner, repo, title, body string, labels []str
 		return "", 0, err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", 0, err
 	}
@@ -332,7 +373,7 @@ func CreateAnonymousIssueWithToken(owner, repo, title, body string, labels []str
 	if resp.StatusCode != http.StatusCreated {
 		var errResp map[string]interface{}
 		_ = json.NewDecoder(resp.Body).Decode(&errResp)
-		return "", 0, fmt.Errorf("failed to create issue: %s", resp.Status)
+		return "", 0, provider.ErrorFromResponse(resp, "failed to create issue")
 	}
 
 	var result struct {
@@ -346,12 +387,12 @@ func CreateAnonymousIssueWithToken(owner, repo, title, body string, labels []str
 	return result.HTMLURL, result.Number, nil
 }
 
-func CreateAnonymousComment(owner, repo string, number int, body string) (string, error) {
-	return CreateAnonymousCommentWithToken(owner, repo, number, body, "")
+func CreateAnonymousComment(ctx context.Context, owner, repo string, number int, body string) (string, error) {
+	return CreateAnonymousCommentWithToken(ctx, owner, repo, number, body, "")
 }
 
-func CreateAnonymousCommentWithToken(owner, repo string, number int, body string, token string) (string, error) {
-	token = resolveGitHubToken(token)
+func CreateAnonymousCommentWithToken(ctx context.Context, owner, repo string, number int, body string, token string) (string, error) {
+	token = resolveGitHubTokenFor(ctx, owner, token)
 	if token == "" {
 		return "", fmt.Errorf("GITHUB_TOKEN not set")
 	}
@@ -364,7 +405,7 @@ func CreateAnonymousCommentWithToken(owner, repo string, number int, body string
 		return "", err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", err
 	}
@@ -380,7 +421,7 @@ func CreateAnonymousCommentWithToken(owner, repo string, number int, body string
 	if resp.StatusCode != http.StatusCreated {
 		var errResp map[string]interface{}
 		_ = json.NewDecoder(resp.Body).Decode(&errResp)
-		return "", fmt.Errorf("failed to create comment: %s", resp.Status)
+		return "", provider.ErrorFromResponse(resp, "failed to create comment")
 	}
 
 	var result struct {
@@ -393,12 +434,12 @@ func CreateAnonymousCommentWithToken(owner, repo string, number int, body string
 	return result.HTMLURL, nil
 }
 
-func CreateAnonymousPRComment(owner, repo string, number int, body string) (string, error) {
-	return CreateAnonymousPRCommentWithToken(owner, repo, number, body, "")
+func CreateAnonymousPRComment(ctx context.Context, owner, repo string, number int, body string) (string, error) {
+	return CreateAnonymousPRCommentWithToken(ctx, owner, repo, number, body, "")
 }
 
-func CreateAnonymousPRCommentWithToken(owner, repo string, number int, body string, token string) (string, error) {
-	token = resolveGitHubToken(token)
+func CreateAnonymousPRCommentWithToken(ctx context.Context, owner, repo string, number int, body string, token string) (string, error) {
+	token = resolveGitHubTokenFor(ctx, owner, token)
 	if token == "" {
 		return "", fmt.Errorf("GITHUB_TOKEN not set")
 	}
@@ -411,7 +452,7 @@ func CreateAnonymousPRCommentWithToken(owner, repo string, number int, body stri
 		return "", err
 	}
 
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", err
 	}
@@ -429,7 +470,7 @@ func CreateAnonymousPRCommentWithToken(owner, repo string, number int, body stri
 	if resp.StatusCode != http.StatusCreated {
 		var errResp map[string]interface{}
 		_ = json.NewDecoder(resp.Body).Decode(&errResp)
-		return "", fmt.Errorf("failed to create PR comment: %s", resp.Status)
+		return "", provider.ErrorFromResponse(resp, "failed to create PR comment")
 	}
 
 	var result struct {
@@ -442,12 +483,12 @@ func CreateAnonymousPRCommentWithToken(owner, repo string, number int, body stri
 	return result.HTMLURL, nil
 }
 
-func CreateAnonymousDiscussionComment(owner, repo string, number int, body string) (string, error) {
-	return CreateAnonymousDiscussionCommentWithToken(owner, repo, number, body, "")
+func CreateAnonymousDiscussionComment(ctx context.Context, owner, repo string, number int, body string) (string, error) {
+	return CreateAnonymousDiscussionCommentWithToken(ctx, owner, repo, number, body, "")
 }
 
-func CreateAnonymousDiscussionCommentWithToken(owner, repo string, number int, body string, token string) (string, error) {
-	token = resolveGitHubToken(token)
+func CreateAnonymousDiscussionCommentWithToken(ctx context.Context, owner, repo string, number int, body string, token string) (string, error) {
+	token = resolveGitHubTokenFor(ctx, owner, token)
 	if token == "" {
 		return "", fmt.Errorf("GITHUB_TOKEN not set")
 	}
@@ -463,7 +504,7 @@ func CreateAnonymousDiscussionCommentWithToken(owner, repo string, number int, b
 		return "", err
 	}
 
-	req, err := http.NewRequest("POST", "https://api.github.com/graphql", bytes.NewBuffer(idJSON))
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.github.com/graphql", bytes.NewBuffer(idJSON))
 	if err != nil {
 		return "", err
 	}
@@ -477,7 +518,7 @@ func CreateAnonymousDiscussionCommentWithToken(owner, repo string, number int, b
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("failed to resolve discussion id: %s", resp.Status)
+		return "", provider.ErrorFromResponse(resp, "failed to resolve discussion id")
 	}
 
 	var idResp struct {
@@ -500,7 +541,7 @@ func CreateAnonymousDiscussionCommentWithToken(owner, repo string, number int, b
 	}
 	discussionID := idResp.Data.Repository.Discussion.ID
 	if discussionID == "" {
-		return "", fmt.Errorf("discussion not found")
+		return "", fmt.Errorf("discussion %d: %w", number, provider.ErrNotFound)
 	}
 
 	mutation := fmt.Sprintf(`mutation {
@@ -514,7 +555,7 @@ func CreateAnonymousDiscussionCommentWithToken(owner, repo string, number int, b
 		return "", err
 	}
 
-	mreq, err := http.NewRequest("POST", "https://api.github.com/graphql", bytes.NewBuffer(mutJSON))
+	mreq, err := http.NewRequestWithContext(ctx, "POST", "https://api.github.com/graphql", bytes.NewBuffer(mutJSON))
 	if err != nil {
 		return "", err
 	}
@@ -528,7 +569,7 @@ func CreateAnonymousDiscussionCommentWithToken(owner, repo string, number int, b
 	defer mresp.Body.Close()
 
 	if mresp.StatusCode != 200 {
-		return "", fmt.Errorf("failed to create discussion comment: %s", mresp.Status)
+		return "", provider.ErrorFromResponse(mresp, "failed to create discussion comment")
 	}
 
 	var mutResp struct {
@@ -557,22 +598,60 @@ func (r *Ref) GetSha() string {
 	return r.Object.Sha
 }
 
-func ForkRepo(owner, repo string) (string, error) {
-	return ForkRepoWithToken(owner, repo, "")
+func ForkRepo(ctx context.Context, owner, repo string) (string, error) {
+	return ForkRepoWithToken(ctx, owner, repo, "")
 }
 
-func ForkRepoWithToken(owner, repo string, token string) (string, error) {
-	token = resolveGitHubToken(token)
-	if token == "" {
+func ForkRepoWithToken(ctx context.Context, owner, repo string, token string) (string, error) {
+	if strings.TrimSpace(token) == "" {
+		if app := githubapp.Default(); app != nil && app.ForkOrg != "" {
+			appToken, err := app.InstallationToken(ctx, app.ForkOrg)
+			if err == nil {
+				return forkRepoAsApp(ctx, owner, repo, app.ForkOrg, appToken)
+			}
+			utils.Log("GitHub App token for %s unavailable, falling back to PAT: %v", app.ForkOrg, err)
+		}
+	}
+
+	if strings.TrimSpace(token) != "" {
+		return forkRepoAs(ctx, owner, repo, strings.TrimSpace(token))
+	}
+
+	candidates := tokenpool.Candidates(tokenpool.GitHub, owner, repo)
+	if len(candidates) == 0 {
 		return "", fmt.Errorf("GITHUB_TOKEN not set")
 	}
+	var lastErr error
+	for _, t := range candidates {
+		forkOwner, err := forkRepoAs(ctx, owner, repo, t)
+		if err == nil {
+			tokenpool.Assign(tokenpool.GitHub, owner, repo, t)
+			return forkOwner, nil
+		}
+		lastErr = err
+		switch {
+		case errors.Is(err, provider.ErrForbidden):
+			utils.Log("Service account %s refused for %s/%s, failing over", tokenpool.Fingerprint(t), owner, repo)
+			tokenpool.MarkBlocked(tokenpool.GitHub, owner, repo, t)
+		case errors.Is(err, provider.ErrRateLimited):
+		default:
+			return "", err
+		}
+	}
+	return "", lastErr
+}
+
+// githubLogin returns the login token authenticates as, cached for pooled
+// service accounts so sharding can map fork owners back to their token.
+func githubLogin(ctx context.Context, token string) (string, error) {
+	if login := tokenpool.Login(tokenpool.GitHub, token); login != "" {
+		return login, nil
+	}
 
-	userURL := "https://api.github.com/user"
-	req, err := http.NewRequest("GET", userURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.github.com/user", nil)
 	if err != nil {
 		return "", err
 	}
-	req.Header.Set("Authorization", "token "+token)
 
 	resp, err := githubDo(req, token)
 	if err != nil {
@@ -580,24 +659,39 @@ func ForkRepoWithToken(owner, repo string, token string) (string, error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return "", provider.ErrorFromResponse(resp, "failed to get user")
+	}
+
 	var user map[string]interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
 		return "", err
 	}
 
-	forkOwner, ok := user["login"].(string)
+	login, ok := user["login"].(string)
 	if !ok {
 		return "", fmt.Errorf("could not get user login")
 	}
+	tokenpool.SetLogin(tokenpool.GitHub, token, login)
+	return login, nil
+}
+
+// forkRepoAs finds or creates the fork of owner/repo under the account that
+// token authenticates as.
+func forkRepoAs(ctx context.Context, owner, repo, token string) (string, error) {
+	forkOwner, err := githubLogin(ctx, token)
+	if err != nil {
+		return "", err
+	}
 
 	forkURL := fmt.Sprintf("https://api.github.com/repos/%s/%s", forkOwner, repo)
-	req, err = http.NewRequest("GET", forkURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", forkURL, nil)
 	if err != nil {
 		return "", err
 	}
 	req.Header.Set("Authorization", "token "+token)
 
-	resp, err = githubDo(req, token)
+	resp, err := githubDo(req, token)
 	if err != nil {
 		return "", err
 	}
@@ -609,7 +703,7 @@ func ForkRepoWithToken(owner, repo string, token string) (string, error) {
 	}
 
 	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/forks", owner, repo)
-	req, err = http.NewRequest("POST", url, nil)
+	req, err = http.NewRequestWithContext(ctx, "POST", url, nil)
 	if err != nil {
 		return "", err
 	}
@@ -624,19 +718,53 @@ func ForkRepoWithToken(owner, repo string, token string) (string, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 202 {
-		return "", fmt.Errorf("failed to create fork: %s", resp.Status)
+		return "", provider.ErrorFromResponse(resp, "failed to create fork")
 	}
 
 	fmt.Printf("DEBUG: Fork created: %s/%s\n", forkOwner, repo)
 	return forkOwner, nil
 }
 
-func ClosePRByURL(prURL string) error {
-	token := tokenpool.NextGitHubToken()
-	if token == "" {
-		return fmt.Errorf("GITHUB_TOKEN not set")
+// forkRepoAsApp forks owner/repo into the app's fork organization using the
+// installation token of that organization. Installation tokens can't call
+// /user, so the fork owner is the configured organization.
+func forkRepoAsApp(ctx context.Context, owner, repo, forkOrg, token string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://api.github.com/repos/%s/%s", forkOrg, repo), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := githubDo(req, token)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return forkOrg, nil
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{"organization": forkOrg})
+	if err != nil {
+		return "", err
+	}
+	req, err = http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("https://api.github.com/repos/%s/%s/forks", owner, repo), bytes.NewBuffer(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err = githubDo(req, token)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return "", provider.ErrorFromResponse(resp, "failed to create fork")
 	}
+	return forkOrg, nil
+}
 
+func ClosePRByURL(ctx context.Context, prURL string) error {
 	parts := strings.Split(strings.TrimPrefix(prURL, "https://github.com/"), "/")
 	if len(parts) < 4 || parts[2] != "pull" {
 		return fmt.Errorf("invalid PR URL: %s", prURL)
@@ -645,13 +773,18 @@ func ClosePRByURL(prURL string) error {
 	repo := parts[1]
 	number := parts[3]
 
+	token := resolveGitHubTokenFor(ctx, owner, "")
+	if token == "" {
+		return fmt.Errorf("GITHUB_TOKEN not set")
+	}
+
 	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%s", owner, repo, number)
 	payload, err := json.Marshal(map[string]string{"state": "closed"})
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest("PATCH", apiURL, bytes.NewBuffer(payload))
+	req, err := http.NewRequestWithContext(ctx, "PATCH", apiURL, bytes.NewBuffer(payload))
 	if err != nil {
 		return err
 	}
@@ -666,17 +799,17 @@ func ClosePRByURL(prURL string) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to close PR %s: status %s", prURL, resp.Status)
+		return provider.ErrorFromResponse(resp, "failed to close PR "+prURL)
 	}
 	return nil
 }
 
-func CreatePR(owner, repo, branch, forkOwner, commitMessage string) (string, error) {
-	return CreatePRWithToken(owner, repo, branch, forkOwner, commitMessage, "")
+func CreatePR(ctx context.Context, owner, repo, branch, forkOwner, commitMessage string, opts provider.MROptions) (string, error) {
+	return CreatePRWithToken(ctx, owner, repo, branch, forkOwner, commitMessage, "", opts)
 }
 
-func CreatePRWithToken(owner, repo, branch, forkOwner, commitMessage, token string) (string, error) {
-	token = resolveGitHubToken(token)
+func CreatePRWithToken(ctx context.Context, owner, repo, branch, forkOwner, commitMessage, token string, opts provider.MROptions) (string, error) {
+	token = resolveGitHubForkToken(ctx, owner, forkOwner, token)
 	if token == "" {
 		return "", fmt.Errorf("GITHUB_TOKEN not set")
 	}
@@ -684,20 +817,31 @@ func CreatePRWithToken(owner, repo, branch, forkOwner, commitMessage, token stri
 	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls", owner, repo)
 
 	prBody := fmt.Sprintf("%s\n\n---\n\n*This is an anonymous contribution made via [gitGost](https://gitgost.livrasand.com).\n\n*The original author's identity has been anonymized to protect their privacy. This is a service account that allows real humans to contribute anonymously.*", commitMessage)
+	if opts.Body != "" {
+		prBody = opts.Body
+	}
+	prBody = opts.AppendReportLink(prBody)
+	base := opts.Base
+	if base == "" {
+		base = "main"
+	}
 
 	data := map[string]interface{}{
 		"title": "Anonymous contribution via gitGost",
 		"head":  fmt.Sprintf("%s:%s", forkOwner, branch),
-		"base":  "main",
+		"base":  base,
 		"body":  prBody,
 	}
+	if opts.Draft {
+		data["draft"] = true
+	}
 
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return "", err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", err
 	}
@@ -714,7 +858,7 @@ func CreatePRWithToken(owner, repo, branch, forkOwner, commitMessage, token stri
 		var errResp map[string]interface{}
 		json.NewDecoder(resp.Body).Decode(&errResp)
 		fmt.Printf("DEBUG: PR creation failed: %s, response: %+v\n", resp.Status, errResp)
-		return "", fmt.Errorf("Failed to create PR: %s", resp.Status)
+		return "", provider.ErrorFromResponse(resp, "Failed to create PR")
 	}
 
 	var result map[string]interface{}
@@ -728,17 +872,50 @@ func CreatePRWithToken(owner, repo, branch, forkOwner, commitMessage, token stri
 		return "", fmt.Errorf("Invalid response from GitHub")
 	}
 
+	if len(opts.Labels) > 0 {
+		// Labeling needs triage access on the target; the PR stands without it.
+		if number, ok := result["number"].(float64); ok {
+			if err := addLabels(ctx, owner, repo, int(number), opts.Labels, token); err != nil {
+				utils.Log("Could not label %s: %v", prURL, err)
+			}
+		}
+	}
+
 	return prURL, nil
 }
 
-func GetRefs(owner, repo string) ([]Ref, error) {
-	token := tokenpool.NextGitHubToken()
+func addLabels(ctx context.Context, owner, repo string, number int, labels []string, token string) error {
+	payload, err := json.Marshal(map[string]interface{}{"labels": labels})
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/issues/%d/labels", owner, repo, number)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := githubDo(req, token)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return provider.ErrorFromResponse(resp, "failed to add labels")
+	}
+	return nil
+}
+
+func GetRefs(ctx context.Context, owner, repo string) ([]Ref, error) {
+	token := resolveGitHubTokenFor(ctx, owner, "")
 	if token == "" {
 		return nil, fmt.Errorf("GITHUB_TOKEN not set")
 	}
 
 	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/git/refs", owner, repo)
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -754,7 +931,7 @@ func GetRefs(owner, repo string) ([]Ref, error) {
 	}
 
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("Failed to get refs: %s", resp.Status)
+		return nil, provider.ErrorFromResponse(resp, "Failed to get refs")
 	}
 
 	var refs []Ref
@@ -766,69 +943,49 @@ func GetRefs(owner, repo string) ([]Ref, error) {
 	return refs, nil
 }
 
-type RepoPolicy struct {
-	DenyAll bool `yaml:"DENY_ALL"`
-}
-
-func GetRepoPolicy(owner, repo string) (*RepoPolicy, error) {
-	token := tokenpool.NextGitHubToken()
-	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/contents/.gitgost.yml", owner, repo)
-
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		return &RepoPolicy{}, nil
-	}
-	if token != "" {
-		req.Header.Set("Authorization", "token "+token)
-	}
-	req.Header.Set("Accept", "application/vnd.github+json")
-	req.Header.Set("User-Agent", "gitGost")
-
-	resp, err := githubDo(req, token)
-	if err != nil {
-		return &RepoPolicy{}, nil
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return &RepoPolicy{}, nil
-	}
-
-	var fileResp struct {
-		Content  string `json:"content"`
-		Encoding string `json:"encoding"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&fileResp); err != nil {
-		return &RepoPolicy{}, nil
-	}
+// apiBase is the GitHub REST root for policy lookups; tests point it at a
+// fake server.
+var apiBase = "https://api.github.com"
+
+// fetchPolicyFile fetches the raw .gitgost.yml of owner/repo, revalidating
+// with etag. Conditional requests answered 304 don't count against the
+// token's rate limit.
+func fetchPolicyFile(owner, repo string) provider.PolicyFetcher {
+	return func(ctx context.Context, etag string) (provider.PolicyFileResult, error) {
+		token := resolveGitHubTokenFor(ctx, owner, "")
+		apiURL := fmt.Sprintf("%s/repos/%s/%s/contents/%s", apiBase, owner, repo, provider.PolicyFile)
+		req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+		if err != nil {
+			return provider.PolicyFileResult{}, err
+		}
+		req.Header.Set("Accept", "application/vnd.github.raw+json")
+		req.Header.Set("User-Agent", "gitGost")
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
 
-	var raw []byte
-	if fileResp.Encoding == "base64" {
-		cleaned := strings.ReplaceAll(fileResp.Content, "\n", "")
-		raw, err = base64.StdEncoding.DecodeString(cleaned)
+		resp, err := githubDo(req, token)
 		if err != nil {
-			return &RepoPolicy{}, nil
+			return provider.PolicyFileResult{}, err
 		}
-	} else {
-		raw = []byte(fileResp.Content)
-	}
+		defer resp.Body.Close()
 
-	var policy RepoPolicy
-	if err := yaml.Unmarshal(raw, &policy); err != nil {
-		return &RepoPolicy{}, nil
+		return provider.ReadPolicyResponse(resp)
 	}
+}
 
-	return &policy, nil
+// GetRepoPolicy returns the cached, validated .gitgost.yml of owner/repo. A
+// missing file is the permissive default; an invalid one returns a
+// *provider.PolicyError.
+func GetRepoPolicy(ctx context.Context, owner, repo string) (*provider.RepoPolicy, error) {
+	policy, _, err := provider.Policies.Load(ctx, provider.PolicyKey(tokenpool.GitHub, owner, repo), fetchPolicyFile(owner, repo))
+	return policy, err
 }
 
-func IsRepoVerified(owner, repo string) bool {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/contents/.gitgost.yml", owner, repo)
-	resp, err := http.Get(url)
-	if err != nil {
-		return false
-	}
-	defer resp.Body.Close()
-	return resp.StatusCode == 200
+// IsRepoVerified reports whether owner/repo has a .gitgost.yml.
+func IsRepoVerified(ctx context.Context, owner, repo string) bool {
+	_, exists, _ := provider.Policies.Load(ctx, provider.PolicyKey(tokenpool.GitHub, owner, repo), fetchPolicyFile(owner, repo))
+	return exists
 }
 
 func GeneratePRHash(owner, repo, branch string) string {
@@ -879,8 +1036,8 @@ func nextPageURL(linkHeader string) string {
 	return ""
 }
 
-func FetchPRTimeline(owner, repo string, number int, etag string) (events []PRTimelineEvent, newETag string, changed bool, err error) {
-	token := tokenpool.NextGitHubToken()
+func FetchPRTimeline(ctx context.Context, owner, repo string, number int, etag string) (events []PRTimelineEvent, newETag string, changed bool, err error) {
+	token := resolveGitHubTokenFor(ctx, owner, "")
 	if token == "" {
 		return nil, "", false, fmt.Errorf("GITHUB_TOKEN not set")
 	}
@@ -888,7 +1045,7 @@ func FetchPRTimeline(owner, repo string, number int, etag string) (events []PRTi
 	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/issues/%d/timeline?per_page=100", owner, repo, number)
 
 	for apiURL != "" {
-		req, err := http.NewRequest("GET", apiURL, nil)
+		req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 		if err != nil {
 			return nil, "", false, err
 		}
@@ -914,7 +1071,7 @@ func FetchPRTimeline(owner, repo string, number int, etag string) (events []PRTi
 
 		if resp.StatusCode != http.StatusOK {
 			resp.Body.Close()
-			return nil, "", false, fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
+			return nil, "", false, provider.ErrorFromResponse(resp, "GitHub timeline")
 		}
 
 		var page []PRTimelineEvent
@@ -938,15 +1095,15 @@ func FetchPRTimeline(owner, repo string, number int, etag string) (events []PRTi
 	return events, newETag, true, nil
 }
 
-func FetchPRInfo(owner, repo string, number int) (state, title string, comments int, updatedAt string, err error) {
-	token := tokenpool.NextGitHubToken()
+func FetchPRInfo(ctx context.Context, owner, repo string, number int) (state, title string, comments int, updatedAt string, err error) {
+	token := resolveGitHubTokenFor(ctx, owner, "")
 	if token == "" {
 		return "", "", 0, "", fmt.Errorf("GITHUB_TOKEN not set")
 	}
 
 	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%d", owner, repo, number)
 
-	req, err := http.NewRequest("GET", apiURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
 		return "", "", 0, "", err
 	}
@@ -960,7 +1117,7 @@ func FetchPRInfo(owner, repo string, number int) (state, title string, comments
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", "", 0, "", fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
+		return "", "", 0, "", provider.ErrorFromResponse(resp, "GitHub pull request")
 	}
 
 	var result struct {
@@ -983,14 +1140,14 @@ func FetchPRInfo(owner, repo string, number int) (state, title string, comments
 	return state, result.Title, result.ReviewComments, result.UpdatedAt, nil
 }
 
-func GetExistingPR(owner, repo, forkOwner, branchName string) (string, bool, error) {
-	token := tokenpool.NextGitHubToken()
+func GetExistingPR(ctx context.Context, owner, repo, forkOwner, branchName string) (string, bool, error) {
+	token := resolveGitHubForkToken(ctx, owner, forkOwner, "")
 	if token == "" {
 		return "", false, fmt.Errorf("GITHUB_TOKEN not set")
 	}
 
 	branchURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/branches/%s", forkOwner, repo, branchName)
-	req, err := http.NewRequest("GET", branchURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", branchURL, nil)
 	if err != nil {
 		return "", false, err
 	}
@@ -1011,7 +1168,7 @@ func GetExistingPR(owner, repo, forkOwner, branchName string) (string, bool, err
 	prListURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls?state=open&head=%s&per_page=1",
 		owner, repo, url.QueryEscape(head))
 
-	req, err = http.NewRequest("GET", prListURL, nil)
+	req, err = http.NewRequestWithContext(ctx, "GET", prListURL, nil)
 	if err != nil {
 		return "", true, err
 	}
@@ -1025,7 +1182,7 @@ func GetExistingPR(owner, repo, forkOwner, branchName string) (string, bool, err
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", true, fmt.Errorf("failed to list PRs: %s", resp.Status)
+		return "", true, provider.ErrorFromResponse(resp, "failed to list PRs")
 	}
 
 	var prs []struct {
@@ -0,0 +1,291 @@
+package githubapp
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotInstalled is returned when the app has no installation for an owner.
+var ErrNotInstalled = errors.New("github app not installed")
+
+const (
+	// jwtLifetime stays under GitHub's 10 minute ceiling.
+	jwtLifetime = 9 * time.Minute
+	// refreshMargin renews installation tokens before GitHub expires them
+	// so a long push never starts with a token about to die.
+	refreshMargin = 5 * time.Minute
+	// notInstalledTTL caches "no installation" answers so PAT fallback
+	// doesn't cost an extra API round-trip on every request.
+	notInstalledTTL = 10 * time.Minute
+)
+
+type cachedToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// App holds the credentials of one GitHub App and its token cache.
+type App struct {
+	ID      string
+	ForkOrg string
+	APIBase string
+
+	key        *rsa.PrivateKey
+	httpClient *http.Client
+
+	mu            sync.Mutex
+	tokens        map[string]cachedToken
+	installations map[string]int64
+	notInstalled  map[string]time.Time
+}
+
+// New parses a PEM encoded RSA private key (PKCS#1 or PKCS#8) and returns an
+// App ready to mint tokens.
+func New(appID string, privateKeyPEM []byte, forkOrg string) (*App, error) {
+	if strings.TrimSpace(appID) == "" {
+		return nil, fmt.Errorf("github app id is empty")
+	}
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("github app private key is not PEM encoded")
+	}
+	var key *rsa.PrivateKey
+	if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		key = k
+	} else {
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse github app private key: %w", err)
+		}
+		rk, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("github app private key is not RSA")
+		}
+		key = rk
+	}
+	return &App{
+		ID:            strings.TrimSpace(appID),
+		ForkOrg:       strings.TrimSpace(forkOrg),
+		APIBase:       "https://api.github.com",
+		key:           key,
+		httpClient:    &http.Client{Timeout: 30 * time.Second},
+		tokens:        map[string]cachedToken{},
+		installations: map[string]int64{},
+		notInstalled:  map[string]time.Time{},
+	}, nil
+}
+
+// JWT returns a freshly signed RS256 app token.
+func (a *App) JWT(now time.Time) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		// Backdated to tolerate clock drift between us and GitHub.
+		"iat": now.Add(-60 * time.Second).Unix(),
+		"exp": now.Add(jwtLifetime).Unix(),
+		"iss": a.ID,
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// InstallationToken returns a valid installation token for owner, minting a
+// new one when the cached token is missing or about to expire.
+func (a *App) InstallationToken(ctx context.Context, owner string) (string, error) {
+	key := strings.ToLower(owner)
+	now := time.Now()
+
+	a.mu.Lock()
+	if t, ok := a.tokens[key]; ok && now.Add(refreshMargin).Before(t.expiresAt) {
+		a.mu.Unlock()
+		return t.token, nil
+	}
+	if until, ok := a.notInstalled[key]; ok && now.Before(until) {
+		a.mu.Unlock()
+		return "", ErrNotInstalled
+	}
+	installationID := a.installations[key]
+	a.mu.Unlock()
+
+	jwt, err := a.JWT(now)
+	if err != nil {
+		return "", err
+	}
+
+	if installationID == 0 {
+		installationID, err = a.installationID(ctx, jwt, owner)
+		if errors.Is(err, ErrNotInstalled) {
+			a.mu.Lock()
+			a.notInstalled[key] = now.Add(notInstalledTTL)
+			a.mu.Unlock()
+			return "", err
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	token, expiresAt, err := a.mintToken(ctx, jwt, installationID)
+	if err != nil {
+		// The app may have been uninstalled; look the installation up again next time.
+		a.mu.Lock()
+		delete(a.installations, key)
+		a.mu.Unlock()
+		return "", err
+	}
+
+	a.mu.Lock()
+	a.tokens[key] = cachedToken{token: token, expiresAt: expiresAt}
+	a.installations[key] = installationID
+	delete(a.notInstalled, key)
+	a.mu.Unlock()
+	return token, nil
+}
+
+func (a *App) installationID(ctx context.Context, jwt, owner string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/users/%s/installation", a.APIBase, owner), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "gitGost")
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, ErrNotInstalled
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to look up installation for %s: %s", owner, resp.Status)
+	}
+
+	var result struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	if result.ID == 0 {
+		return 0, ErrNotInstalled
+	}
+	return result.ID, nil
+}
+
+func (a *App) mintToken(ctx context.Context, jwt string, installationID int64) (string, time.Time, error) {
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", a.APIBase, installationID)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(nil))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "gitGost")
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", time.Time{}, fmt.Errorf("failed to mint installation token: %s", resp.Status)
+	}
+
+	var result struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", time.Time{}, err
+	}
+	if result.Token == "" {
+		return "", time.Time{}, fmt.Errorf("empty installation token")
+	}
+	return result.Token, result.ExpiresAt, nil
+}
+
+var (
+	defaultMu  sync.RWMutex
+	defaultApp *App
+)
+
+// Init configures the process-wide app. privateKey may be the PEM content
+// itself (with literal "\n" escapes, as env vars usually carry it) or empty
+// when privateKeyPath points to a PEM file. An empty appID disables app auth.
+func Init(appID, privateKey, privateKeyPath, forkOrg string) error {
+	if strings.TrimSpace(appID) == "" {
+		return nil
+	}
+	pemBytes := []byte(strings.ReplaceAll(privateKey, `\n`, "\n"))
+	if strings.TrimSpace(privateKey) == "" {
+		b, err := os.ReadFile(privateKeyPath)
+		if err != nil {
+			return fmt.Errorf("read github app private key: %w", err)
+		}
+		pemBytes = b
+	}
+	app, err := New(appID, pemBytes, forkOrg)
+	if err != nil {
+		return err
+	}
+	defaultMu.Lock()
+	defaultApp = app
+	defaultMu.Unlock()
+	return nil
+}
+
+// Default returns the configured app, or nil when app auth is disabled.
+func Default() *App {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultApp
+}
+
+// SetDefault replaces the process-wide app. Intended for tests.
+func SetDefault(a *App) {
+	defaultMu.Lock()
+	defaultApp = a
+	defaultMu.Unlock()
+}
+
+// TokenFor returns an installation token for owner from the default app. It
+// returns "" when app auth is disabled or the app isn't installed there, so
+// callers can fall back to the PAT pool.
+func TokenFor(ctx context.Context, owner string) string {
+	a := Default()
+	if a == nil || owner == "" {
+		return ""
+	}
+	t, err := a.InstallationToken(ctx, owner)
+	if err != nil {
+		return ""
+	}
+	return t
+}
@@ -0,0 +1,124 @@
+package githubapp
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testKey(t *testing.T) (*rsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return key, pemBytes
+}
+
+func verifyJWT(t *testing.T, pub *rsa.PublicKey, jwt string) map[string]interface{} {
+	t.Helper()
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("malformed jwt %q", jwt)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig); err != nil {
+		t.Fatalf("bad jwt signature: %v", err)
+	}
+	raw, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claims map[string]interface{}
+	if err := json.Unmarshal(raw, &claims); err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+func TestInstallationTokenMintsAndCaches(t *testing.T) {
+	key, pemBytes := testKey(t)
+	var mints, lookups int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jwt := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if claims := verifyJWT(t, &key.PublicKey, jwt); claims["iss"] != "42" {
+			t.Errorf("iss = %v", claims["iss"])
+		}
+		switch {
+		case strings.EqualFold(r.URL.Path, "/users/acme/installation"):
+			atomic.AddInt32(&lookups, 1)
+			fmt.Fprint(w, `{"id": 7}`)
+		case r.URL.Path == "/users/nobody/installation":
+			atomic.AddInt32(&lookups, 1)
+			http.NotFound(w, r)
+		case r.URL.Path == "/app/installations/7/access_tokens" && r.Method == "POST":
+			n := atomic.AddInt32(&mints, 1)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"token": "ghs_%d", "expires_at": %q}`, n, time.Now().Add(time.Hour).Format(time.RFC3339))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	app, err := New("42", pemBytes, "gitgost-forks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.APIBase = srv.URL
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		tok, err := app.InstallationToken(ctx, "Acme")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tok != "ghs_1" {
+			t.Fatalf("token = %q, want cached ghs_1", tok)
+		}
+	}
+	if mints != 1 {
+		t.Errorf("minted %d tokens, want 1", mints)
+	}
+
+	// A token inside the refresh margin is renewed.
+	app.mu.Lock()
+	app.tokens["acme"] = cachedToken{token: "ghs_1", expiresAt: time.Now().Add(time.Minute)}
+	app.mu.Unlock()
+	if tok, _ := app.InstallationToken(ctx, "acme"); tok != "ghs_2" {
+		t.Errorf("token = %q, want refreshed ghs_2", tok)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := app.InstallationToken(ctx, "nobody"); !errors.Is(err, ErrNotInstalled) {
+			t.Fatalf("err = %v, want ErrNotInstalled", err)
+		}
+	}
+	if lookups != 2 {
+		t.Errorf("installation lookups = %d, want 2 (one per owner, negative answer cached)", lookups)
+	}
+}
+
+func TestTokenForDisabled(t *testing.T) {
+	SetDefault(nil)
+	if tok := TokenFor(context.Background(), "acme"); tok != "" {
+		t.Errorf("TokenFor without app = %q, want empty", tok)
+	}
+}
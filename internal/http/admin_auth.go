@@ -0,0 +1,276 @@
+package http
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/moderation"
+	"github.com/livrasand/gitGost/internal/utils"
+)
+
+const (
+	// roleModerator handles appeals, reports, blocks and unblocks.
+	roleModerator = "moderator"
+	// roleOperator runs the instance: panic mode, rollbacks, token pools,
+	// key rotation and the audit log. Operators can also moderate.
+	roleOperator = "operator"
+
+	auditMaxLimit = 1000
+)
+
+type adminIdentity struct {
+	Name string
+	Role string
+}
+
+// named reports whether a is one of the admins in GITGOST_ADMINS rather
+// than the shared PANIC_PASSWORD or an ntfy action token.
+func (a adminIdentity) named() bool {
+	return a != panicPasswordIdentity && a != actionTokenIdentity
+}
+
+// can reports whether a may act with role.
+func (a adminIdentity) can(role string) bool {
+	return a.Role == roleOperator || a.Role == role
+}
+
+var (
+	// panicPasswordIdentity is who the shared PANIC_PASSWORD acts as.
+	panicPasswordIdentity = adminIdentity{Name: "panic-password", Role: roleOperator}
+	// actionTokenIdentity is who a one-shot ntfy action button acts as.
+	actionTokenIdentity = adminIdentity{Name: "ntfy-action", Role: roleOperator}
+)
+
+type adminCredential struct {
+	adminIdentity
+	secretHash []byte
+}
+
+var (
+	adminsMu sync.RWMutex
+	admins   = map[string]adminCredential{}
+)
+
+// InitAdmins loads named admin credentials from spec, a comma-separated
+// list of name:role:sha256 entries where sha256 is the hex SHA-256 of the
+// admin's secret. Admins then authenticate with "name:secret".
+func InitAdmins(spec string) error {
+	parsed := map[string]adminCredential{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 || parts[0] == "" {
+			return fmt.Errorf("admin entry %q: want name:role:sha256", entry)
+		}
+		name, role := parts[0], parts[1]
+		if role != roleModerator && role != roleOperator {
+			return fmt.Errorf("admin %s: unknown role %q", name, role)
+		}
+		hash, err := hex.DecodeString(parts[2])
+		if err != nil || len(hash) != sha256.Size {
+			return fmt.Errorf("admin %s: secret must be a hex SHA-256 digest", name)
+		}
+		if _, dup := parsed[name]; dup {
+			return fmt.Errorf("admin %s listed twice", name)
+		}
+		parsed[name] = adminCredential{adminIdentity{Name: name, Role: role}, hash}
+	}
+	adminsMu.Lock()
+	admins = parsed
+	adminsMu.Unlock()
+	if len(parsed) > 0 && !modPersistent {
+		utils.Log("Warning: GITGOST_ADMINS is set without GITGOST_MODERATION_DB; the admin audit log will be lost on restart")
+	}
+	return nil
+}
+
+// adminPasswordFromRequest accepts the admin credential from the
+// X-Admin-Password header, the password query parameter or the admin_pass
+// cookie, in that order.
+func adminPasswordFromRequest(c *gin.Context) string {
+	if p := c.GetHeader("X-Admin-Password"); p != "" {
+		return p
+	}
+	if p := c.Query("password"); p != "" {
+		return p
+	}
+	p, _ := c.Cookie("admin_pass")
+	return p
+}
+
+// authenticateAdmin resolves a "name:secret" credential, or the shared
+// PANIC_PASSWORD, to an admin identity.
+func authenticateAdmin(secret string) (adminIdentity, bool) {
+	if secret == "" {
+		return adminIdentity{}, false
+	}
+	if name, pass, ok := strings.Cut(secret, ":"); ok {
+		adminsMu.RLock()
+		cred, known := admins[name]
+		adminsMu.RUnlock()
+		if known {
+			sum := sha256.Sum256([]byte(pass))
+			if subtle.ConstantTimeCompare(sum[:], cred.secretHash) == 1 {
+				return cred.adminIdentity, true
+			}
+			return adminIdentity{}, false
+		}
+	}
+	if panicPassword != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(panicPassword)) == 1 {
+		return panicPasswordIdentity, true
+	}
+	return adminIdentity{}, false
+}
+
+// requireAdmin authenticates secret, or the request's credential when secret
+// is empty, and checks it may act as role. It answers 401 or 403 itself.
+func requireAdmin(c *gin.Context, role, secret string) (adminIdentity, bool) {
+	if secret == "" {
+		secret = adminPasswordFromRequest(c)
+	}
+	who, ok := authenticateAdmin(secret)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		return adminIdentity{}, false
+	}
+	if !who.can(role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "requires the " + role + " role"})
+		return adminIdentity{}, false
+	}
+	return who, true
+}
+
+// adminFromActionToken accepts a one-shot ntfy action token in place of an
+// operator credential, as the panic and rollback buttons send.
+func adminFromActionToken(c *gin.Context, token, secret string) (adminIdentity, bool) {
+	if token != "" && consumeActionToken(token) {
+		return actionTokenIdentity, true
+	}
+	return requireAdmin(c, roleOperator, secret)
+}
+
+// adminReason takes the reason for an admin action from fromBody, the
+// X-Admin-Reason header or a reason form/query field.
+func adminReason(c *gin.Context, fromBody string) string {
+	reason := fromBody
+	if reason == "" {
+		reason = c.GetHeader("X-Admin-Reason")
+	}
+	if reason == "" {
+		reason = c.PostForm("reason")
+	}
+	if reason == "" {
+		reason = c.Query("reason")
+	}
+	return cleanReportDetail(reason)
+}
+
+// audit appends an admin action to the audit log. It is called before the
+// action takes effect: when the entry can't be written it answers 503 and
+// returns false, and the action must not go ahead unrecorded.
+func audit(c *gin.Context, who adminIdentity, action, target, reason, detail string) bool {
+	e := moderation.AuditEntry{
+		At:     time.Now(),
+		Actor:  who.Name,
+		Role:   who.Role,
+		Action: action,
+		Target: target,
+		Reason: reason,
+		Detail: detail,
+	}
+	if err := modStore.AppendAudit(c.Request.Context(), e); err != nil {
+		utils.Log("Error writing audit entry %s by %s: %v", action, who.Name, err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "audit log unavailable; nothing was changed"})
+		return false
+	}
+	return true
+}
+
+// AdminAuditHandler queries the audit log. Filters: since and until
+// (RFC 3339), actor, action and limit.
+func AdminAuditHandler(c *gin.Context) {
+	if _, ok := requireAdmin(c, roleOperator, ""); !ok {
+		return
+	}
+	var q moderation.AuditQuery
+	for _, f := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &q.Since}, {"until", &q.Until}} {
+		if v := c.Query(f.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + f.name + ", want RFC 3339"})
+				return
+			}
+			*f.dst = t
+		}
+	}
+	q.Actor = c.Query("actor")
+	q.Action = c.Query("action")
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		q.Limit = min(n, auditMaxLimit)
+	}
+	entries, err := modStore.AuditLog(c.Request.Context(), q)
+	if err != nil {
+		utils.Log("Error reading audit log: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "audit log unavailable"})
+		return
+	}
+	if entries == nil {
+		entries = []moderation.AuditEntry{}
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// AdminBlockHashHandler bans a hash by hand.
+func AdminBlockHashHandler(c *gin.Context) {
+	setHashBlock(c, true)
+}
+
+// AdminUnblockHashHandler lifts a ban without an appeal.
+func AdminUnblockHashHandler(c *gin.Context) {
+	setHashBlock(c, false)
+}
+
+func setHashBlock(c *gin.Context, blocked bool) {
+	who, ok := requireAdmin(c, roleModerator, "")
+	if !ok {
+		return
+	}
+	hash := strings.TrimSpace(c.Param("hash"))
+	if hash == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hash required"})
+		return
+	}
+	action := "unblock"
+	if blocked {
+		action = "block"
+	}
+	if !audit(c, who, action, hash, adminReason(c, ""), "") {
+		return
+	}
+	if err := modStore.SetBlocked(c.Request.Context(), hash, blocked); err != nil {
+		utils.Log("Error setting block for hash %s: %v", hash, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not update block"})
+		return
+	}
+	utils.Log("Hash %s: %s by %s", hash, action, who.Name)
+	c.JSON(http.StatusOK, gin.H{"hash": hash, "blocked": blocked})
+}
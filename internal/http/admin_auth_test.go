@@ -0,0 +1,191 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/moderation"
+)
+
+func digest(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestInitAdminsRejectsBadEntries(t *testing.T) {
+	t.Cleanup(func() { _ = InitAdmins("") })
+	for _, spec := range []string{
+		"alice:root:" + digest("x"),
+		"alice:operator:nothex",
+		"alice:operator",
+		"alice:operator:" + digest("x") + ",alice:moderator:" + digest("y"),
+	} {
+		if err := InitAdmins(spec); err == nil {
+			t.Errorf("InitAdmins(%q) accepted", spec)
+		}
+	}
+}
+
+func TestAdminRolesAndAuditLog(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	old := modStore
+	setModerationStore(moderation.NewMemory())
+	t.Cleanup(func() { setModerationStore(old) })
+	if err := InitAdmins("alice:operator:" + digest("s3cret") + ", bob:moderator:" + digest("hunter2")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = InitAdmins("") })
+	oldPanic := isPanicMode()
+	t.Cleanup(func() { setPanicMode(oldPanic) })
+
+	r := gin.New()
+	r.POST("/admin/panic", PanicHandler)
+	r.POST("/admin/hashes/:hash/unblock", AdminUnblockHashHandler)
+	r.GET("/admin/audit", AdminAuditHandler)
+	do := func(method, path, cred, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if cred != "" {
+			req.Header.Set("X-Admin-Password", cred)
+		}
+		req.Header.Set("X-Admin-Reason", "test run")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodPost, "/admin/panic", "bob:wrong", `{"active":true}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("bad secret = %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/admin/panic", "bob:hunter2", `{"active":true}`); w.Code != http.StatusForbidden {
+		t.Errorf("moderator panic = %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/admin/hashes/abc/unblock", "bob:hunter2", ""); w.Code != http.StatusOK {
+		t.Errorf("moderator unblock = %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/admin/panic", "", `{"password":"alice:s3cret","active":false,"reason":"drill"}`); w.Code != http.StatusOK {
+		t.Errorf("operator panic = %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodGet, "/admin/audit", "bob:hunter2", ""); w.Code != http.StatusForbidden {
+		t.Errorf("moderator audit = %d", w.Code)
+	}
+
+	w := do(http.MethodGet, "/admin/audit?actor=bob", "alice:s3cret", "")
+	var body struct {
+		Entries []moderation.AuditEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Entries) != 1 {
+		t.Fatalf("entries = %+v", body.Entries)
+	}
+	if e := body.Entries[0]; e.Action != "unblock" || e.Target != "abc" || e.Role != roleModerator || e.Reason != "test run" || e.At.IsZero() {
+		t.Errorf("entry = %+v", e)
+	}
+
+	entries, _ := modStore.AuditLog(t.Context(), moderation.AuditQuery{Action: "panic"})
+	if len(entries) != 1 || entries[0].Actor != "alice" || entries[0].Reason != "drill" || entries[0].Detail != "deactivated" {
+		t.Errorf("panic entries = %+v", entries)
+	}
+}
+
+// failingAudit is a moderation store whose audit log can't be written.
+type failingAudit struct{ moderation.Store }
+
+func (failingAudit) AppendAudit(context.Context, moderation.AuditEntry) error {
+	return errors.New("disk full")
+}
+
+func TestAdminActionsNeedAnAuditEntry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	old := modStore
+	setModerationStore(failingAudit{moderation.NewMemory()})
+	t.Cleanup(func() { setModerationStore(old) })
+	if err := InitAdmins("alice:operator:" + digest("s3cret")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = InitAdmins("") })
+	oldPanic := isPanicMode()
+	setPanicMode(false)
+	t.Cleanup(func() { setPanicMode(oldPanic) })
+
+	r := gin.New()
+	r.POST("/admin/panic", PanicHandler)
+	r.POST("/admin/hashes/:hash/block", AdminBlockHashHandler)
+	for path, body := range map[string]string{
+		"/admin/panic":            `{"active":true}`,
+		"/admin/hashes/abc/block": "",
+	} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Admin-Password", "alice:s3cret")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s without an audit log = %d", path, w.Code)
+		}
+	}
+	if isPanicMode() {
+		t.Error("panic mode activated unaudited")
+	}
+	if blocked, _ := modStore.IsBlocked(t.Context(), "abc"); blocked {
+		t.Error("hash blocked unaudited")
+	}
+}
+
+func TestAdminAppealsJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	old := modStore
+	setModerationStore(moderation.NewMemory())
+	t.Cleanup(func() { setModerationStore(old) })
+	if err := InitAdmins("bob:moderator:" + digest("hunter2")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = InitAdmins("") })
+	ctx := t.Context()
+	_ = modStore.SetBlocked(ctx, "h1", true)
+	_ = modStore.SaveAppeal(ctx, moderation.Appeal{ID: "0123456789abcdef", Hash: "h1", Message: "not me", CreatedAt: time.Now()})
+
+	r := gin.New()
+	r.GET("/admin/status", AdminStatusHandler)
+	r.GET("/admin/appeals", AdminAppealsHandler)
+	r.POST("/admin/appeals/:ticket/resolve", AdminAppealResolveHandler)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("X-Admin-Password", "bob:hunter2")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodGet, "/admin/status", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"open_appeals":1`) {
+		t.Fatalf("status = %d %s", w.Code, w.Body.String())
+	}
+	w = do(http.MethodGet, "/admin/appeals", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"ticket":"0123456789abcdef"`) {
+		t.Fatalf("appeals = %d %s", w.Code, w.Body.String())
+	}
+	if w = do(http.MethodPost, "/admin/appeals/0123456789abcdef/resolve", "outcome=maybe"); w.Code != http.StatusBadRequest {
+		t.Errorf("bad outcome = %d", w.Code)
+	}
+	w = do(http.MethodPost, "/admin/appeals/0123456789abcdef/resolve", "outcome=unban&reason=cleared")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"outcome":"unbanned"`) {
+		t.Fatalf("resolve = %d %s", w.Code, w.Body.String())
+	}
+	if blocked, _ := modStore.IsBlocked(ctx, "h1"); blocked {
+		t.Error("hash still blocked after unban")
+	}
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/moderation"
+	"github.com/livrasand/gitGost/internal/provider"
+	cbprovider "github.com/livrasand/gitGost/internal/provider/codeberg"
+	ghprovider "github.com/livrasand/gitGost/internal/provider/github"
+	glprovider "github.com/livrasand/gitGost/internal/provider/gitlab"
+	"github.com/livrasand/gitGost/internal/reaper"
+	"github.com/livrasand/gitGost/internal/utils"
+)
+
+// reaperTargetScan bounds how many recorded PRs are scanned for forkless
+// repositories on each pass.
+const reaperTargetScan = 10000
+
+var (
+	reaperGrace    = 72 * time.Hour
+	reaperJanitors = func() []provider.ForkJanitor {
+		return []provider.ForkJanitor{ghprovider.New(), glprovider.New(), cbprovider.New()}
+	}
+)
+
+// InitReaper starts the background reaper of merged/closed gitGost branches
+// and empty forks. A zero interval disables it; the admin dry-run report
+// stays available either way.
+func InitReaper(interval, grace time.Duration, dryRun bool) {
+	if grace > 0 {
+		reaperGrace = grace
+	}
+	reaper.Start(reaperJanitors(), interval, reaper.Options{Grace: reaperGrace, DryRun: dryRun, Targets: reaperTargets})
+}
+
+// reaperTargets lists the repositories this instance opened PRs in that
+// opt into forkless mode, with the account that pushes their gitgost/*
+// branches.
+func reaperTargets(ctx context.Context) []reaper.Target {
+	prs, err := modStore.PRs(ctx, moderation.PRQuery{Limit: reaperTargetScan})
+	if err != nil {
+		utils.Log("Error listing PRs for the reaper: %v", err)
+		return nil
+	}
+	seen := make(map[string]bool)
+	var targets []reaper.Target
+	for _, pr := range prs {
+		key := pr.Provider + ":" + strings.ToLower(pr.Owner+"/"+pr.Repo)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		prov := providerFromName(pr.Provider)
+		j, ok := prov.(provider.ForkJanitor)
+		if !ok {
+			continue
+		}
+		if policy, err := loadRepoPolicy(ctx, prov, pr.Owner, pr.Repo); err != nil || !policy.Forkless {
+			continue
+		}
+		targets = append(targets, reaper.Target{
+			Janitor: j,
+			Account: provider.Account{Token: prov.GitToken(ctx, pr.Owner), Owner: pr.Owner},
+			Owner:   pr.Owner,
+			Repo:    pr.Repo,
+		})
+	}
+	return targets
+}
+
+// AdminReaperHandler runs a dry-run reaper pass and reports what would be
+// deleted, alongside the last scheduled pass.
+func AdminReaperHandler(c *gin.Context) {
+	if _, ok := requireAdmin(c, roleOperator, ""); !ok {
+		return
+	}
+	report := reaper.Run(c.Request.Context(), reaperJanitors(), reaper.Options{Grace: reaperGrace, DryRun: true, Targets: reaperTargets}, time.Now())
+	c.JSON(http.StatusOK, gin.H{"dry_run": report, "last": reaper.Last()})
+}
@@ -0,0 +1,21 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/tokenpool"
+)
+
+// AdminTokenPoolHandler reports per-token quota and cooldown for every forge
+// pool. Tokens are identified by slot and a SHA-256 fingerprint only.
+func AdminTokenPoolHandler(c *gin.Context) {
+	if _, ok := requireAdmin(c, roleOperator, ""); !ok {
+		return
+	}
+	pools := gin.H{}
+	for _, p := range tokenpool.Providers() {
+		pools[p] = tokenpool.Stats(p)
+	}
+	c.JSON(http.StatusOK, gin.H{"pools": pools})
+}
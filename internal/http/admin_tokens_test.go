@@ -0,0 +1,51 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminTokenPoolHandler(t *testing.T) {
+	t.Setenv("GITLAB_TOKEN", "glpat-secret-value")
+	oldPassword := panicPassword
+	panicPassword = "hunter2"
+	defer func() { panicPassword = oldPassword }()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/admin/tokens", AdminTokenPoolHandler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/tokens", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated status = %d, want 401", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/tokens", nil)
+	req.Header.Set("X-Admin-Password", "hunter2")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "glpat-secret-value") {
+		t.Fatal("response leaks the raw token")
+	}
+	var body struct {
+		Pools map[string][]struct {
+			Slot        int    `json:"slot"`
+			Fingerprint string `json:"fingerprint"`
+		} `json:"pools"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if gl := body.Pools["gitlab"]; len(gl) != 1 || gl[0].Slot != 1 || gl[0].Fingerprint == "" {
+		t.Errorf("gitlab pool = %+v", gl)
+	}
+}
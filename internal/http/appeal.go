@@ -1,35 +1,23 @@
 package http
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
-	"crypto/subtle"
 	"encoding/hex"
 	"fmt"
 	"html/template"
 	"net/http"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/moderation"
 	"github.com/livrasand/gitGost/internal/utils"
 )
 
-type appealTicket struct {
-	Hash      string
-	Message   string
-	CreatedAt time.Time
-	Resolved  bool
-	Unbanned  bool
-}
-
-var (
-	appealTicketsMu sync.Mutex
-	appealTickets   = make(map[string]*appealTicket)
-	appealTicketTTL = 7 * 24 * time.Hour
-)
+var appealTicketTTL = 7 * 24 * time.Hour
 
 var appealStartTmpl = template.Must(template.New("appealStart").Parse(appealHTML))
 
@@ -82,7 +70,7 @@ textarea{width:100%;min-height:120px;padding:12px;border-radius:10px;border:1px
 </form>
 <div class="muted">Your identity stays anonymous. No personal data is collected.</div>
 {{else}}
-<div class="info">Your hash <strong>{{.Hash}}</strong> is <strong>blocked</strong> (6+ reports).<br>To appeal, enter your <strong>appeal token</strong> — you received it when you created the content that produced this hash.</div>
+<div class="info">Your hash <strong>{{.Hash}}</strong> is <strong>blocked</strong> (report score 6+).<br>To appeal, enter your <strong>appeal token</strong> — you received it when you created the content that produced this hash.</div>
 <form method="POST" action="/appeal">
 <label for="hash">Hash</label>
 <input type="text" id="hash" name="hash" value="{{.Hash}}" readonly />
@@ -100,17 +88,27 @@ func generateAppealToken(hash string) string {
 	if hash == "" {
 		return ""
 	}
-	h := hmac.New(sha256.New, getSecretKey())
+	return appealTokenWithKey(getSecretKey(), hash)
+}
+
+func appealTokenWithKey(key []byte, hash string) string {
+	h := hmac.New(sha256.New, key)
 	h.Write([]byte("appeal:" + hash))
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// verifyAppealToken accepts tokens issued under any key still kept after
+// rotation.
 func verifyAppealToken(hash, token string) bool {
 	if hash == "" || token == "" {
 		return false
 	}
-	expected := generateAppealToken(hash)
-	return hmac.Equal([]byte(expected), []byte(token))
+	for _, key := range getSecretKeys() {
+		if hmac.Equal([]byte(appealTokenWithKey(key, hash)), []byte(token)) {
+			return true
+		}
+	}
+	return false
 }
 
 func AppealStartHandler(c *gin.Context) {
@@ -182,12 +180,11 @@ func AppealStartHandler(c *gin.Context) {
 	}
 	ticketID := hex.EncodeToString(b)
 
-	appealTicketsMu.Lock()
-	appealTickets[ticketID] = &appealTicket{
-		Hash:      hash,
-		CreatedAt: time.Now(),
+	if err := modStore.SaveAppeal(c.Request.Context(), moderation.Appeal{ID: ticketID, Hash: hash, CreatedAt: time.Now()}); err != nil {
+		utils.Log("Error saving appeal: %v", err)
+		c.String(http.StatusInternalServerError, "Error creating appeal")
+		return
 	}
-	appealTicketsMu.Unlock()
 
 	scheme := getScheme(c.Request)
 	secretURL := fmt.Sprintf("%s://%s/appeal/%s", scheme, c.Request.Host, ticketID)
@@ -203,25 +200,13 @@ func AppealStartHandler(c *gin.Context) {
 func AppealViewHandler(c *gin.Context) {
 	ticketID := c.Param("ticket")
 
-	appealTicketsMu.Lock()
-	ticket, exists := appealTickets[ticketID]
-	var (
-		hash      string
-		createdAt time.Time
-		resolved  bool
-		unbanned  bool
-		message   string
-	)
-	if exists {
-		hash = ticket.Hash
-		createdAt = ticket.CreatedAt
-		resolved = ticket.Resolved
-		unbanned = ticket.Unbanned
-		message = ticket.Message
-	}
-	appealTicketsMu.Unlock()
-
-	if !exists || time.Since(createdAt) > appealTicketTTL {
+	ticket, exists, _ := modStore.Appeal(c.Request.Context(), ticketID)
+	hash := ticket.Hash
+	resolved := ticket.Resolved
+	unbanned := ticket.Unbanned
+	message := ticket.Message
+
+	if !exists || time.Since(ticket.CreatedAt) > appealTicketTTL {
 		c.String(http.StatusNotFound, "Appeal not found or expired.")
 		return
 	}
@@ -239,11 +224,10 @@ func AppealViewHandler(c *gin.Context) {
 			return
 		}
 
-		appealTicketsMu.Lock()
-		if t, ok := appealTickets[ticketID]; ok {
-			t.Message = msg
+		ticket.Message = msg
+		if err := modStore.SaveAppeal(c.Request.Context(), ticket); err != nil {
+			utils.Log("Error saving appeal message: %v", err)
 		}
-		appealTicketsMu.Unlock()
 
 		if ntfyAdminTopic != "" {
 			go notifyAdminAppeal(ticketID, hash)
@@ -299,7 +283,11 @@ func notifyAdminAppeal(ticketID, hash string) {
 		return
 	}
 	appealURL := fmt.Sprintf("https://gitgost.fly.dev/appeal/%s", ticketID)
-	payload := fmt.Sprintf(`{"topic":"%s","title":"New appeal filed","message":"Hash %s has filed an appeal.\n\n%s","tags":["warning"]}`, ntfyAdminTopic, hash, appealURL)
+	reasons := "none"
+	if t := getReportTally(context.Background(), hash); t.Count > 0 {
+		reasons = fmt.Sprintf("%s (score %d)", t.summary(), t.Score)
+	}
+	payload := fmt.Sprintf(`{"topic":"%s","title":"New appeal filed","message":"Hash %s has filed an appeal.\nReports: %s\n\n%s","tags":["warning"]}`, ntfyAdminTopic, hash, reasons, appealURL)
 	resp, err := http.Post("https://ntfy.sh", "application/json", strings.NewReader(payload))
 	if err != nil {
 		utils.Log("Error sending ntfy appeal notification: %v", err)
@@ -308,16 +296,62 @@ func notifyAdminAppeal(ticketID, hash string) {
 	resp.Body.Close()
 }
 
+// wantsJSON reports whether an admin client asked for JSON rather than the
+// HTML pages, with an Accept header or format=json.
+func wantsJSON(c *gin.Context) bool {
+	return c.Query("format") == "json" || strings.Contains(c.GetHeader("Accept"), "application/json")
+}
+
+type appealJSON struct {
+	Ticket    string       `json:"ticket"`
+	Hash      string       `json:"hash"`
+	Message   string       `json:"message"`
+	CreatedAt time.Time    `json:"created_at"`
+	Resolved  bool         `json:"resolved"`
+	Unbanned  bool         `json:"unbanned"`
+	Reports   *reportTally `json:"reports,omitempty"`
+}
+
+// adminAppealsJSON lists every appeal, with the report tally for open ones.
+func adminAppealsJSON(c *gin.Context) {
+	if _, ok := requireAdmin(c, roleModerator, ""); !ok {
+		return
+	}
+	appeals, err := modStore.Appeals(c.Request.Context(), time.Unix(0, 0))
+	if err != nil {
+		utils.Log("Error listing appeals: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "appeals unavailable"})
+		return
+	}
+	list := make([]appealJSON, 0, len(appeals))
+	for _, t := range appeals {
+		v := appealJSON{
+			Ticket:    t.ID,
+			Hash:      t.Hash,
+			Message:   t.Message,
+			CreatedAt: t.CreatedAt,
+			Resolved:  t.Resolved,
+			Unbanned:  t.Unbanned,
+		}
+		if !t.Resolved {
+			tally := getReportTally(c.Request.Context(), t.Hash)
+			v.Reports = &tally
+		}
+		list = append(list, v)
+	}
+	c.JSON(http.StatusOK, gin.H{"appeals": list})
+}
+
 func AdminAppealsHandler(c *gin.Context) {
-	password := c.Query("password")
-	if password == "" {
-		password, _ = c.Cookie("admin_pass")
+	if wantsJSON(c) {
+		adminAppealsJSON(c)
+		return
 	}
-	if password == "" || panicPassword == "" || subtle.ConstantTimeCompare([]byte(password), []byte(panicPassword)) != 1 {
+	password := adminPasswordFromRequest(c)
+	if who, ok := authenticateAdmin(password); !ok || !who.can(roleModerator) {
 		c.String(http.StatusUnauthorized, "Unauthorized")
 		return
 	}
-	appealTicketsMu.Lock()
 	type appealView struct {
 		TicketID  string
 		Hash      string
@@ -325,11 +359,16 @@ func AdminAppealsHandler(c *gin.Context) {
 		CreatedAt time.Time
 		Resolved  bool
 		Unbanned  bool
+		Reports   reportTally
+	}
+	appeals, err := modStore.Appeals(c.Request.Context(), time.Unix(0, 0))
+	if err != nil {
+		utils.Log("Error listing appeals: %v", err)
 	}
 	var openList, resolvedList []appealView
-	for id, t := range appealTickets {
+	for _, t := range appeals {
 		v := appealView{
-			TicketID:  id,
+			TicketID:  t.ID,
 			Hash:      t.Hash,
 			Message:   t.Message,
 			CreatedAt: t.CreatedAt,
@@ -339,10 +378,10 @@ func AdminAppealsHandler(c *gin.Context) {
 		if t.Resolved {
 			resolvedList = append(resolvedList, v)
 		} else {
+			v.Reports = getReportTally(c.Request.Context(), t.Hash)
 			openList = append(openList, v)
 		}
 	}
-	appealTicketsMu.Unlock()
 
 	c.Header("Content-Type", "text/html; charset=utf-8")
 	fmt.Fprintf(c.Writer, `<!DOCTYPE html><html lang="en"><head><meta charset="UTF-8"><title>Admin · Appeals · gitGost</title>
@@ -359,14 +398,15 @@ form{display:inline;}
 button{background:transparent;border:1px solid rgba(255,255,255,0.15);color:#c9d1d9;border-radius:6px;padding:4px 10px;cursor:pointer;font-size:12px;}
 button.unban{border-color:#3fb950;color:#3fb950;}
 button.dismiss{border-color:#f85149;color:#f85149;}
+input[name=reason]{background:transparent;border:1px solid rgba(255,255,255,0.15);color:#c9d1d9;border-radius:6px;padding:4px 8px;font-size:12px;width:110px;}
 </style></head><body>
 <h1>Appeals</h1>
 <p style="color:#9fb3ff;">Password-protected admin panel. All data is ephemeral (in-memory).</p>
 <hr class="sep">
 <h2>Open (%d)</h2>
-<table><tr><th>Ticket</th><th>Hash</th><th>Message</th><th>Age</th><th>Actions</th></tr>`, len(openList))
+<table><tr><th>Ticket</th><th>Hash</th><th>Message</th><th>Reports</th><th>Age</th><th>Actions</th></tr>`, len(openList))
 	if len(openList) == 0 {
-		fmt.Fprintf(c.Writer, `<tr><td colspan="5" class="empty">No open appeals.</td></tr>`)
+		fmt.Fprintf(c.Writer, `<tr><td colspan="6" class="empty">No open appeals.</td></tr>`)
 	}
 	for _, v := range openList {
 		msgPreview := v.Message
@@ -378,21 +418,28 @@ button.dismiss{border-color:#f85149;color:#f85149;}
 		ticketShort := template.HTMLEscapeString(v.TicketID[:8])
 		hash := template.HTMLEscapeString(v.Hash)
 		msg := template.HTMLEscapeString(msgPreview)
+		reasons := "none"
+		if v.Reports.Count > 0 {
+			reasons = fmt.Sprintf("%s (score %d)", v.Reports.summary(), v.Reports.Score)
+		}
+		reasons = template.HTMLEscapeString(reasons)
 		ageStr := template.HTMLEscapeString(age.String())
 		pwd := template.HTMLEscapeString(password)
-		fmt.Fprintf(c.Writer, `<tr><td><a href="/appeal/%s">%s</a></td><td>%s</td><td>%s</td><td>%s</td>
+		fmt.Fprintf(c.Writer, `<tr><td><a href="/appeal/%s">%s</a></td><td>%s</td><td>%s</td><td>%s</td><td>%s</td>
 	<td>
 	<form method="POST" action="/admin/appeals/%s/resolve" style="display:inline;">
 	<input type="hidden" name="password" value="%s">
 	<input type="hidden" name="outcome" value="unban">
+	<input type="text" name="reason" placeholder="Reason" maxlength="500">
 	<button type="submit" class="unban">Unban</button>
 	</form>
 	<form method="POST" action="/admin/appeals/%s/resolve" style="display:inline;">
 	<input type="hidden" name="password" value="%s">
 	<input type="hidden" name="outcome" value="dismiss">
+	<input type="text" name="reason" placeholder="Reason" maxlength="500">
 	<button type="submit" class="dismiss">Dismiss</button>
 	</form>
-	</td></tr>`, ticketID, ticketShort, hash, msg, ageStr, ticketID, pwd, ticketID, pwd)
+	</td></tr>`, ticketID, ticketShort, hash, msg, reasons, ageStr, ticketID, pwd, ticketID, pwd)
 	}
 	fmt.Fprintf(c.Writer, `</table>
 <hr class="sep"><h2>Resolved (%d)</h2><table><tr><th>Ticket</th><th>Hash</th><th>Outcome</th></tr>`, len(resolvedList))
@@ -415,20 +462,45 @@ func AdminAppealResolveHandler(c *gin.Context) {
 	ticketID := c.Param("ticket")
 	password := c.PostForm("password")
 	outcome := c.PostForm("outcome")
+	asJSON := wantsJSON(c)
 
-	if password == "" || panicPassword == "" || subtle.ConstantTimeCompare([]byte(password), []byte(panicPassword)) != 1 {
-		c.String(http.StatusUnauthorized, "Unauthorized")
-		return
+	var who adminIdentity
+	if asJSON {
+		var ok bool
+		if who, ok = requireAdmin(c, roleModerator, password); !ok {
+			return
+		}
+		if outcome != "unban" && outcome != "dismiss" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "outcome must be unban or dismiss"})
+			return
+		}
+	} else {
+		var ok bool
+		who, ok = authenticateAdmin(password)
+		if !ok || !who.can(roleModerator) {
+			c.String(http.StatusUnauthorized, "Unauthorized")
+			return
+		}
 	}
 
-	appealTicketsMu.Lock()
-	ticket, exists := appealTickets[ticketID]
-	if !exists {
-		appealTicketsMu.Unlock()
+	ticket, exists, err := modStore.Appeal(c.Request.Context(), ticketID)
+	if err != nil || !exists {
+		if asJSON {
+			c.JSON(http.StatusNotFound, gin.H{"error": "appeal not found"})
+			return
+		}
 		c.String(http.StatusNotFound, "Appeal not found")
 		return
 	}
 
+	decision := "upheld"
+	if outcome == "unban" {
+		decision = "unbanned"
+	}
+	if !audit(c, who, "appeal_resolve", ticket.Hash, adminReason(c, ""), fmt.Sprintf("ticket %s: %s", ticketID[:8], decision)) {
+		return
+	}
+
 	ticket.Resolved = true
 	if outcome == "unban" {
 		ticket.Unbanned = true
@@ -439,10 +511,14 @@ func AdminAppealResolveHandler(c *gin.Context) {
 	}
 	hash := ticket.Hash
 	unbanned := outcome == "unban"
-	appealTicketsMu.Unlock()
+	if err := modStore.SaveAppeal(c.Request.Context(), ticket); err != nil {
+		utils.Log("Error saving appeal resolution: %v", err)
+	}
 
 	if unbanned {
-		blockedStore.Delete(hash)
+		if err := modStore.SetBlocked(c.Request.Context(), hash, false); err != nil {
+			utils.Log("Error unblocking hash %s: %v", hash, err)
+		}
 	}
 
 	if ntfyAdminTopic != "" && ticket.Message != "" {
@@ -459,6 +535,10 @@ func AdminAppealResolveHandler(c *gin.Context) {
 		}()
 	}
 
+	if asJSON {
+		c.JSON(http.StatusOK, gin.H{"ticket": ticketID, "hash": hash, "outcome": decision})
+		return
+	}
 	c.SetCookie("admin_pass", password, 3600, "/admin/", "", true, true)
 	c.Redirect(http.StatusSeeOther, "/admin/appeals")
 }
@@ -27,7 +27,7 @@ type ethicalBucket struct {
 
 const maxEthicalSiteKeyLen = 64
 
-var ethicalStore = newBoundedMap[int64](ethicalStoreMax, 24*time.Hour)
+var ethicalStore = newBoundedMap[int64]("ethical-metrics", ethicalStoreMax, 24*time.Hour)
 
 func encodeEthicalKey(site, key string) string {
 	n := len(site)
@@ -11,6 +11,7 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
@@ -27,10 +28,14 @@ import (
 	"github.com/livrasand/gitGost/internal/database"
 	"github.com/livrasand/gitGost/internal/git"
 	"github.com/livrasand/gitGost/internal/github"
+	"github.com/livrasand/gitGost/internal/moderation"
+	"github.com/livrasand/gitGost/internal/prmsg"
 	"github.com/livrasand/gitGost/internal/provider"
 	cbprovider "github.com/livrasand/gitGost/internal/provider/codeberg"
 	ghprovider "github.com/livrasand/gitGost/internal/provider/github"
 	glprovider "github.com/livrasand/gitGost/internal/provider/gitlab"
+	"github.com/livrasand/gitGost/internal/reaper"
+	"github.com/livrasand/gitGost/internal/screen"
 	"github.com/livrasand/gitGost/internal/tokenpool"
 	"github.com/livrasand/gitGost/internal/utils"
 
@@ -72,177 +77,17 @@ func doGitHubWithTokenRotation(client *http.Client, buildRequest func(string) (*
 }
 
 const (
-	karmaStoreMax           = 10000
-	reportStoreMax          = 10000
-	blockedStoreMax         = 10000
-	flaggedStoreMax         = 10000
 	badgeCacheMax           = 10000
 	ethicalStoreMax         = 100000
 	rateLimitStoreMax       = 10000
 	reportRateLimitStoreMax = 10000
 	actionTokenMax          = 10000
 	reportTokenMax          = 10000
-)
-
-type boundedEntry[V any] struct {
-	value V
-	at    time.Time
-}
-
-type boundedMap[V any] struct {
-	mu      sync.Mutex
-	data    map[string]boundedEntry[V]
-	maxSize int
-	ttl     time.Duration
-}
-
-func newBoundedMap[V any](maxSize int, ttl time.Duration) *boundedMap[V] {
-	return &boundedMap[V]{
-		data:    make(map[string]boundedEntry[V]),
-		maxSize: maxSize,
-		ttl:     ttl,
-	}
-}
-
-func (m *boundedMap[V]) Get(key string) (V, bool) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	e, ok := m.data[key]
-	if !ok {
-		var zero V
-		return zero, false
-	}
-	if m.ttl > 0 && time.Since(e.at) > m.ttl {
-		delete(m.data, key)
-		var zero V
-		return zero, false
-	}
-	e.at = time.Now()
-	m.data[key] = e
-	return e.value, true
-}
-
-func (m *boundedMap[V]) Peek(key string) (V, bool) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	e, ok := m.data[key]
-	if !ok {
-		var zero V
-		return zero, false
-	}
-	if m.ttl > 0 && time.Since(e.at) > m.ttl {
-		delete(m.data, key)
-		var zero V
-		return zero, false
-	}
-	return e.value, true
-}
-
-func (m *boundedMap[V]) Set(key string, value V) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	now := time.Now()
-	_, exists := m.data[key]
-	needed := 1
-	if exists {
-		needed = 0
-	}
-	m.evictOldestLocked(needed)
-	m.data[key] = boundedEntry[V]{value: value, at: now}
-}
-
-func (m *boundedMap[V]) Update(key string, fn func(V, bool) V) V {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	now := time.Now()
-	old, ok := m.data[key]
-	present := false
-	var oldVal V
-	if ok {
-		if m.ttl > 0 && now.Sub(old.at) > m.ttl {
-			delete(m.data, key)
-		} else {
-			present = true
-			oldVal = old.value
-		}
-	}
-	needed := 1
-	if present {
-		needed = 0
-	}
-	m.evictOldestLocked(needed)
-	newVal := fn(oldVal, present)
-	m.data[key] = boundedEntry[V]{value: newVal, at: now}
-	return newVal
-}
-
-func (m *boundedMap[V]) Delete(key string) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	delete(m.data, key)
-}
 
-func (m *boundedMap[V]) Range(fn func(key string, value V) bool) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	for k, e := range m.data {
-		if m.ttl > 0 && time.Since(e.at) > m.ttl {
-			continue
-		}
-		if !fn(k, e.value) {
-			break
-		}
-	}
-}
-
-func (m *boundedMap[V]) evictOldestLocked(needed int) {
-	if m.maxSize <= 0 {
-		return
-	}
-	for len(m.data)+needed > m.maxSize {
-		var oldestKey string
-		var oldestAt time.Time
-		first := true
-		for k, e := range m.data {
-			if first || e.at.Before(oldestAt) {
-				oldestKey = k
-				oldestAt = e.at
-				first = false
-			}
-		}
-		if oldestKey == "" {
-			break
-		}
-		delete(m.data, oldestKey)
-	}
-}
-
-func windowAdd(store *boundedMap[[]time.Time], ip string, now time.Time, window time.Duration, max int) int {
-	count := store.Update(ip, func(times []time.Time, ok bool) []time.Time {
-		cutoff := now.Add(-window)
-		valid := make([]time.Time, 0, max+1)
-		for _, t := range times {
-			if t.After(cutoff) {
-				valid = append(valid, t)
-			}
-		}
-		valid = append(valid, now)
-		if len(valid) > max+1 {
-			n := max + 1
-			tmp := make([]time.Time, n)
-			copy(tmp, valid[len(valid)-n:])
-			valid = tmp
-		}
-		return valid
-	})
-	return len(count)
-}
-
-type reportState struct {
-	Count int
-	First time.Time
-	IPs   map[string]time.Time
-}
+	// maxShardFailovers bounds how many service accounts one push tries
+	// when the forge refuses the account hosting the fork.
+	maxShardFailovers = 3
+)
 
 func providerFromPath(path string) provider.Provider {
 	if strings.HasPrefix(path, "/v1/gl/") {
@@ -301,10 +146,13 @@ func ReceivePackDiscoveryHandler(c *gin.Context) {
 	repo := c.Param("repo")
 
 	prov := providerFromPath(c.Request.URL.Path)
-	refs, err := prov.GetRefs(owner, repo)
+	refs, err := prov.GetRefs(c.Request.Context(), owner, repo)
 	if err != nil {
 		utils.Log("Error getting refs: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get refs"})
+		if wait, ok := provider.RetryAfter(err); ok {
+			c.Header("Retry-After", strconv.Itoa(int(wait.Round(time.Second)/time.Second)))
+		}
+		c.JSON(providerErrorStatus(err, http.StatusInternalServerError), gin.H{"error": "Failed to get refs"})
 		return
 	}
 
@@ -315,6 +163,11 @@ func ReceivePackDiscoveryHandler(c *gin.Context) {
 	WritePktLine(&advertisement, "")
 
 	capabilities := "report-status delete-refs side-band-64k quiet ofs-delta push-options"
+	if challenge, _, ok := issuePowChallenge(); ok {
+		// git ignores unknown capabilities; git gost reads the header.
+		capabilities += " gitgost-pow=" + challenge
+		c.Header(powChallengeHeader, challenge)
+	}
 
 	first := true
 	for _, ref := range refs {
@@ -341,6 +194,41 @@ func ReceivePackDiscoveryHandler(c *gin.Context) {
 	c.Writer.Write(advertisement.Bytes())
 }
 
+// forklessBranchPrefix is the branch namespace maintainers grant gitGost
+// when they opt into forkless contributions.
+const forklessBranchPrefix = reaper.ForklessBranchPrefix
+
+// newForklessBranch names a new branch in a forkless target repo. The
+// random part keeps pushes made in the same second from landing on one
+// branch; the time lets the reaper age it.
+func newForklessBranch() string {
+	b := make([]byte, 4)
+	rand.Read(b) // crypto/rand.Read never fails
+	return fmt.Sprintf("%s%d-%s", forklessBranchPrefix, time.Now().Unix(), hex.EncodeToString(b))
+}
+
+// forkForPush forks owner/repo (as the user when githubToken is set) and
+// returns the fork owner with the token to push to it.
+func forkForPush(ctx context.Context, prov provider.Provider, owner, repo, githubToken string) (string, string, error) {
+	var forkOwner string
+	var err error
+	if _, ok := prov.(*ghprovider.GitHubProvider); ok && githubToken != "" {
+		forkOwner, err = github.ForkRepoWithToken(ctx, owner, repo, githubToken)
+	} else {
+		forkOwner, err = prov.ForkRepo(ctx, owner, repo)
+	}
+	if err != nil {
+		return "", "", err
+	}
+	utils.Log("Fork ready: %s/%s", forkOwner, repo)
+
+	pushToken := githubToken
+	if pushToken == "" {
+		pushToken = prov.GitToken(ctx, forkOwner)
+	}
+	return forkOwner, pushToken, nil
+}
+
 func ReceivePackHandler(c *gin.Context) {
 	owner := c.Param("owner")
 	repo := c.Param("repo")
@@ -368,14 +256,27 @@ func ReceivePackHandler(c *gin.Context) {
 		return
 	}
 
-	ip := c.ClientIP()
-	if checkRateLimit(ip) {
+	ip := clientKey(c)
+	// A redeemed token stands in for the per-IP limit and the proof of work.
+	redeemed := false
+	if raw := strings.TrimSpace(c.GetHeader(tokenHeader)); raw != "" {
+		if err := redeemToken(raw); err != nil {
+			writePolicyRejection(c, nil, "token rejected",
+				"TOKEN REJECTED", "",
+				"The anonymous token sent with this push was not accepted",
+				"("+err.Error()+"). Run `git gost tokens` for a fresh batch,",
+				"or push without one.")
+			return
+		}
+		redeemed = true
+	}
+	if !redeemed && checkRateLimit(ip) {
 		c.Writer.Header().Set("Content-Type", "application/x-git-receive-pack-result")
 		c.Writer.WriteHeader(http.StatusOK)
 		var errResp bytes.Buffer
 		WriteSidebandLine(&errResp, 2, "remote: ")
 		WriteSidebandLine(&errResp, 2, fmt.Sprintf("remote: Rate limit exceeded: max %d PRs per hour per IP.", rateLimitMaxPRs))
-		WriteSidebandLine(&errResp, 2, "remote: Please try again later.")
+		WriteSidebandLine(&errResp, 2, "remote: Please try again later, or push with an anonymous token (git gost tokens).")
 		WriteSidebandLine(&errResp, 2, "remote: ")
 		WriteSidebandLine(&errResp, 3, "push rejected: rate limit exceeded")
 		WritePktLine(&errResp, "")
@@ -387,20 +288,38 @@ func ReceivePackHandler(c *gin.Context) {
 
 	prov := providerFromPath(c.Request.URL.Path)
 
-	policy, err := prov.GetRepoPolicy(owner, repo)
-	if err == nil && policy != nil && policy.DenyAll {
-		c.Writer.Header().Set("Content-Type", "application/x-git-receive-pack-result")
-		c.Writer.WriteHeader(http.StatusOK)
-		var errResp bytes.Buffer
-		WriteSidebandLine(&errResp, 2, "remote: ")
-		WriteSidebandLine(&errResp, 2, "remote: CONTRIBUTION BLOCKED")
-		WriteSidebandLine(&errResp, 2, "remote: ")
-		WriteSidebandLine(&errResp, 2, "remote: This repository does not accept anonymous contributions")
-		WriteSidebandLine(&errResp, 2, "remote: via gitGost. Please contact the maintainer directly.")
-		WriteSidebandLine(&errResp, 2, "remote: ")
-		WriteSidebandLine(&errResp, 3, "push rejected: repository has opted out of gitGost")
-		WritePktLine(&errResp, "")
-		c.Writer.Write(errResp.Bytes())
+	policy, err := loadRepoPolicy(c.Request.Context(), prov, owner, repo)
+	if err != nil {
+		lines := []string{"INVALID .gitgost.yml", "", "This repository's gitGost policy could not be parsed:"}
+		var pe *provider.PolicyError
+		if errors.As(err, &pe) {
+			for _, problem := range pe.Problems {
+				lines = append(lines, "  - "+problem)
+			}
+		}
+		lines = append(lines, "", "Maintainers can check the file at /api/policy/<provider>/<owner>/<repo>.")
+		writePolicyRejection(c, nil, "invalid repository policy", lines...)
+		return
+	}
+	if policy.DenyAll {
+		writePolicyRejection(c, policy, "repository has opted out of gitGost",
+			"CONTRIBUTION BLOCKED",
+			"",
+			"This repository does not accept anonymous contributions",
+			"via gitGost. Please contact the maintainer directly.")
+		return
+	}
+	if !policy.Allows(provider.ActivityPR) {
+		writePolicyRejection(c, policy, "repository does not accept anonymous pull requests",
+			"PULL REQUESTS DISABLED",
+			"",
+			"This repository does not accept anonymous pull requests via gitGost.")
+		return
+	}
+	if repoRateLimited(prov, owner, repo, provider.ActivityPR, policy.HourlyLimit(provider.ActivityPR)) {
+		writePolicyRejection(c, policy, "repository rate limit exceeded",
+			fmt.Sprintf("This repository accepts at most %d anonymous PRs per hour.", policy.RateLimits.PRsPerHour),
+			"Please try again later.")
 		return
 	}
 
@@ -417,6 +336,29 @@ func ReceivePackHandler(c *gin.Context) {
 
 	utils.Log("Received push for %s/%s, size: %d bytes", owner, repo, len(body))
 
+	if !redeemed {
+		powSolution := c.GetHeader(powHeader)
+		if powSolution == "" {
+			powSolution = git.PushOption(body, "pow")
+		}
+		if err := checkPow(powSolution); err != nil {
+			lines := []string{"PROOF OF WORK REQUIRED", "",
+				"gitGost is seeing unusually heavy push activity and asks",
+				"every push to solve a small proof of work (" + err.Error() + ").",
+				"`git gost push` solves it automatically. Otherwise fetch a",
+				"challenge from /api/pow/challenge and push with -o pow=<solution>."}
+			writePolicyRejection(c, nil, "proof of work required", lines...)
+			return
+		}
+	}
+
+	baseBranch, rejection := resolveTargetBranch(c.Request.Context(), prov, policy, owner, repo, git.PushedRef(body))
+	if rejection != "" {
+		writePolicyRejection(c, policy, "target branch not allowed", rejection,
+			"Allowed target branches: "+strings.Join(policy.TargetBranches, ", "))
+		return
+	}
+
 	tempDir, err := utils.CreateTempDir()
 	if err != nil {
 		utils.Log("Error creating temp dir: %v", err)
@@ -432,7 +374,7 @@ func ReceivePackHandler(c *gin.Context) {
 
 	WriteSidebandLine(&response, 2, "remote: gitGost: Processing your anonymous contribution...")
 
-	newSHA, commitMessage, receivedPRHash, githubToken, err := git.ReceivePack(tempDir, body, owner, repo, prov.CloneURL(owner, repo), prov.TokenEnvVar(), "")
+	newSHA, commitMessage, receivedPRHash, githubToken, err := git.ReceivePack(tempDir, body, owner, repo, prov.CloneURL(owner, repo), prov.TokenEnvVar(), prov.GitToken(c.Request.Context(), owner))
 	if err != nil {
 		utils.Log("Error receiving pack: %v", err)
 		WriteSidebandLine(&response, 3, fmt.Sprintf("unpack error: %v", err))
@@ -444,42 +386,122 @@ func ReceivePackHandler(c *gin.Context) {
 	utils.Log("Commits received successfully, HEAD at: %s", newSHA)
 	WriteSidebandLine(&response, 2, "remote: gitGost: Commits anonymized successfully")
 
-	WriteSidebandLine(&response, 2, "remote: gitGost: Creating fork...")
-	forkOwner, err := func() (string, error) {
-		if githubToken != "" {
-			if _, ok := prov.(*ghprovider.GitHubProvider); ok {
-				return github.ForkRepoWithToken(owner, repo, githubToken)
-			}
+	if receivedPRHash != "" && isBlockedHash(receivedPRHash) {
+		WriteSidebandLine(&response, 1, "unpack ok\n")
+		WriteSidebandLine(&response, 2, "remote: gitGost: This PR was closed after community reports; it no longer accepts updates.")
+		WriteSidebandLine(&response, 3, "push rejected: pr-hash blocked by reports")
+		WritePktLine(&response, "")
+		c.Writer.Write(response.Bytes())
+		return
+	}
+
+	if policy.MaxChangedFiles > 0 {
+		changed, err := git.ChangedFiles(tempDir, baseBranch)
+		if err != nil {
+			// The limit can't be checked, so the push can't be let through.
+			utils.Log("Error counting changed files: %v", err)
+			WriteSidebandLine(&response, 1, "unpack ok\n")
+			WriteSidebandLine(&response, 2, "remote: gitGost: Could not check this repository's changed-files limit; please try again.")
+			WriteSidebandLine(&response, 3, "push rejected: changed files could not be counted")
+			WritePktLine(&response, "")
+			c.Writer.Write(response.Bytes())
+			return
 		}
-		return prov.ForkRepo(owner, repo)
-	}()
+		if changed > policy.MaxChangedFiles {
+			WriteSidebandLine(&response, 1, "unpack ok\n")
+			WriteSidebandLine(&response, 2, fmt.Sprintf("remote: gitGost: This repository accepts at most %d changed files per contribution (yours changes %d).", policy.MaxChangedFiles, changed))
+			writePolicyMessage(&response, policy)
+			WriteSidebandLine(&response, 3, "push rejected: too many changed files")
+			WritePktLine(&response, "")
+			c.Writer.Write(response.Bytes())
+			return
+		}
+	}
+	if policy.RequireSquash {
+		squashed, err := git.SquashOntoBase(tempDir, baseBranch, commitMessage)
+		if err != nil {
+			utils.Log("Error squashing commits: %v", err)
+			WriteSidebandLine(&response, 3, fmt.Sprintf("error squashing commits: %v", err))
+			WritePktLine(&response, "")
+			c.Writer.Write(response.Bytes())
+			return
+		}
+		newSHA = squashed
+		WriteSidebandLine(&response, 2, "remote: gitGost: Commits squashed as required by the repository")
+	}
+	mrOpts := provider.MROptions{
+		Base:   baseBranch,
+		Body:   policy.RenderPRBody(commitMessage, prDisclaimer),
+		Labels: policy.Labels,
+	}
+
+	verdict, err := screenPush(tempDir, baseBranch)
 	if err != nil {
-		utils.Log("Error creating fork: %v", err)
 		WriteSidebandLine(&response, 1, "unpack ok\n")
-		WriteSidebandLine(&response, 3, fmt.Sprintf("error creating fork: %v", err))
+		WriteSidebandLine(&response, 2, "remote: gitGost: This push could not be screened against the target branch; please try again.")
+		WriteSidebandLine(&response, 3, "push rejected: abuse screening failed")
 		WritePktLine(&response, "")
 		c.Writer.Write(response.Bytes())
 		return
 	}
-
-	utils.Log("Fork ready: %s/%s", forkOwner, repo)
-	WriteSidebandLine(&response, 2, fmt.Sprintf("remote: gitGost: Fork ready at %s/%s", forkOwner, repo))
+	switch verdict.Action {
+	case screen.Reject:
+		WriteSidebandLine(&response, 1, "unpack ok\n")
+		WriteSidebandLine(&response, 2, fmt.Sprintf("remote: gitGost: This push looks like spam or malware (score %d):", verdict.Score))
+		writeScreenFindings(&response, verdict)
+		WriteSidebandLine(&response, 3, "push rejected: failed abuse screening")
+		WritePktLine(&response, "")
+		c.Writer.Write(response.Bytes())
+		return
+	case screen.Warn:
+		WriteSidebandLine(&response, 2, fmt.Sprintf("remote: gitGost: This push needs a closer look (score %d); the PR opens as a draft:", verdict.Score))
+		writeScreenFindings(&response, verdict)
+		applyScreenVerdict(&mrOpts, verdict)
+	}
+
+	// Forkless mode: the maintainer opted in via .gitgost.yml, so the branch
+	// goes straight into the target repo under gitgost/* and the PR is a
+	// same-repo PR. A user-supplied token always uses its own fork.
+	forkless := policy != nil && policy.Forkless && githubToken == ""
+	branchPrefix := "gitgost-"
+	newBranch := ""
+	var forkOwner, pushToken string
+	if forkless {
+		branchPrefix = forklessBranchPrefix
+		newBranch = newForklessBranch()
+		forkOwner = owner
+		pushToken = prov.GitToken(c.Request.Context(), owner)
+		WriteSidebandLine(&response, 2, "remote: gitGost: Repository accepts forkless contributions, skipping fork")
+	} else {
+		WriteSidebandLine(&response, 2, "remote: gitGost: Creating fork...")
+		forkOwner, pushToken, err = forkForPush(c.Request.Context(), prov, owner, repo, githubToken)
+		if err != nil {
+			utils.Log("Error creating fork: %v", err)
+			WriteSidebandLine(&response, 1, "unpack ok\n")
+			writeProviderErrorSideband(&response, prov, err)
+			WriteSidebandLine(&response, 3, fmt.Sprintf("error creating fork: %v", err))
+			WritePktLine(&response, "")
+			c.Writer.Write(response.Bytes())
+			return
+		}
+		WriteSidebandLine(&response, 2, fmt.Sprintf("remote: gitGost: Fork ready at %s/%s", forkOwner, repo))
+	}
 
 	var branch, prURL string
 	isUpdate := false
 
 	if receivedPRHash != "" {
-		branchFromHash := fmt.Sprintf("gitgost-%s", receivedPRHash)
+		branchFromHash := branchPrefix + receivedPRHash
 		WriteSidebandLine(&response, 2, fmt.Sprintf("remote: gitGost: Updating existing PR (hash: %s)...", receivedPRHash))
 
-		existingPRURL, branchExists, err := prov.GetExistingMR(owner, repo, forkOwner, branchFromHash)
+		existingPRURL, branchExists, err := prov.GetExistingMR(c.Request.Context(), owner, repo, forkOwner, branchFromHash)
 		if err != nil {
 			utils.Log("Error checking existing PR: %v", err)
 		}
 
 		if branchExists {
 			WriteSidebandLine(&response, 2, "remote: gitGost: Pushing update to existing branch...")
-			branch, err = git.PushToGitHub(owner, repo, tempDir, forkOwner, branchFromHash, prov.PushURL(forkOwner, repo), prov.TokenEnvVar(), githubToken)
+			branch, err = git.PushToGitHub(owner, repo, tempDir, forkOwner, branchFromHash, true, prov.PushURL(forkOwner, repo), prov.TokenEnvVar(), pushToken)
 			if err != nil {
 				utils.Log("Error pushing update to fork: %v", err)
 				WriteSidebandLine(&response, 1, "unpack ok\n")
@@ -494,18 +516,20 @@ func ReceivePackHandler(c *gin.Context) {
 				utils.Log("Updated existing branch: %s, PR: %s", branch, prURL)
 			} else {
 				WriteSidebandLine(&response, 2, "remote: gitGost: PR was closed, creating new PR on existing branch...")
+				mrOpts.ReportURL = prReportURL(github.GeneratePRHash(owner, repo, branch))
 				if githubToken != "" {
 					if _, ok := prov.(*ghprovider.GitHubProvider); ok {
-						prURL, err = github.CreatePRWithToken(owner, repo, branch, forkOwner, commitMessage, githubToken)
+						prURL, err = github.CreatePRWithToken(c.Request.Context(), owner, repo, branch, forkOwner, commitMessage, githubToken, mrOpts)
 					} else {
-						prURL, err = prov.CreateMR(owner, repo, branch, forkOwner, commitMessage)
+						prURL, err = prov.CreateMR(c.Request.Context(), owner, repo, branch, forkOwner, commitMessage, mrOpts)
 					}
 				} else {
-					prURL, err = prov.CreateMR(owner, repo, branch, forkOwner, commitMessage)
+					prURL, err = prov.CreateMR(c.Request.Context(), owner, repo, branch, forkOwner, commitMessage, mrOpts)
 				}
 				if err != nil {
 					utils.Log("Error creating PR on existing branch: %v", err)
 					WriteSidebandLine(&response, 1, "unpack ok\n")
+					writeProviderErrorSideband(&response, prov, err)
 					WriteSidebandLine(&response, 3, fmt.Sprintf("error creating PR: %v", err))
 					WritePktLine(&response, "")
 					c.Writer.Write(response.Bytes())
@@ -513,6 +537,7 @@ func ReceivePackHandler(c *gin.Context) {
 				}
 				isUpdate = true
 				utils.Log("Created new PR on existing branch: %s, PR: %s", branch, prURL)
+				countRepoActivity(prov, owner, repo, provider.ActivityPR, policy.HourlyLimit(provider.ActivityPR))
 				if err := RecordPR(c.Request.Context(), owner, repo, prURL); err != nil {
 					utils.Log("Error recording stats: %v", err)
 				}
@@ -524,33 +549,73 @@ func ReceivePackHandler(c *gin.Context) {
 	}
 
 	if !isUpdate {
-		WriteSidebandLine(&response, 2, "remote: gitGost: Pushing to fork...")
-		branch, err = git.PushToGitHub(owner, repo, tempDir, forkOwner, "", prov.PushURL(forkOwner, repo), prov.TokenEnvVar(), githubToken)
-		if err != nil {
-			utils.Log("Error pushing to fork: %v", err)
-			WriteSidebandLine(&response, 1, "unpack ok\n")
-			WriteSidebandLine(&response, 3, fmt.Sprintf("error pushing to fork: %v", err))
-			WritePktLine(&response, "")
-			c.Writer.Write(response.Bytes())
-			return
-		}
+		for attempt := 1; ; attempt++ {
+			if forkless {
+				WriteSidebandLine(&response, 2, fmt.Sprintf("remote: gitGost: Pushing to %s/%s...", owner, repo))
+			} else {
+				WriteSidebandLine(&response, 2, "remote: gitGost: Pushing to fork...")
+			}
+			branch, err = git.PushToGitHub(owner, repo, tempDir, forkOwner, newBranch, false, prov.PushURL(forkOwner, repo), prov.TokenEnvVar(), pushToken)
+			if err != nil && forkless {
+				// The maintainer opted in but we lack write access (app not
+				// installed, or the namespace isn't granted): use a fork.
+				utils.Log("Forkless push to %s/%s failed, falling back to fork: %v", owner, repo, err)
+				WriteSidebandLine(&response, 2, "remote: gitGost: No write access to gitgost/* branches, creating fork...")
+				forkless, branchPrefix, newBranch = false, "gitgost-", ""
+				var forkErr error
+				forkOwner, pushToken, forkErr = forkForPush(c.Request.Context(), prov, owner, repo, githubToken)
+				if forkErr == nil {
+					WriteSidebandLine(&response, 2, fmt.Sprintf("remote: gitGost: Fork ready at %s/%s", forkOwner, repo))
+					// The forkless try doesn't count against the fork's
+					// maxShardFailovers.
+					attempt = 0
+					continue
+				}
+				err = forkErr
+			}
+			if err != nil {
+				utils.Log("Error pushing to fork: %v", err)
+				WriteSidebandLine(&response, 1, "unpack ok\n")
+				WriteSidebandLine(&response, 3, fmt.Sprintf("error pushing to fork: %v", err))
+				WritePktLine(&response, "")
+				c.Writer.Write(response.Bytes())
+				return
+			}
 
-		utils.Log("Pushed to fork branch: %s", branch)
-		WriteSidebandLine(&response, 2, fmt.Sprintf("remote: gitGost: Branch '%s' created", branch))
+			utils.Log("Pushed to fork branch: %s", branch)
+			WriteSidebandLine(&response, 2, fmt.Sprintf("remote: gitGost: Branch '%s' created", branch))
 
-		WriteSidebandLine(&response, 2, "remote: gitGost: Creating pull request...")
-		if githubToken != "" {
-			if _, ok := prov.(*ghprovider.GitHubProvider); ok {
-				prURL, err = github.CreatePRWithToken(owner, repo, branch, forkOwner, commitMessage, githubToken)
+			WriteSidebandLine(&response, 2, "remote: gitGost: Creating pull request...")
+			mrOpts.ReportURL = prReportURL(github.GeneratePRHash(owner, repo, branch))
+			if githubToken != "" {
+				if _, ok := prov.(*ghprovider.GitHubProvider); ok {
+					prURL, err = github.CreatePRWithToken(c.Request.Context(), owner, repo, branch, forkOwner, commitMessage, githubToken, mrOpts)
+				} else {
+					prURL, err = prov.CreateMR(c.Request.Context(), owner, repo, branch, forkOwner, commitMessage, mrOpts)
+				}
 			} else {
-				prURL, err = prov.CreateMR(owner, repo, branch, forkOwner, commitMessage)
+				prURL, err = prov.CreateMR(c.Request.Context(), owner, repo, branch, forkOwner, commitMessage, mrOpts)
 			}
-		} else {
-			prURL, err = prov.CreateMR(owner, repo, branch, forkOwner, commitMessage)
+
+			// The service account hosting the fork was refused (usually blocked
+			// by the maintainer): move the repo to another account and retry.
+			if err != nil && !forkless && githubToken == "" && errors.Is(err, provider.ErrForbidden) && attempt < maxShardFailovers {
+				utils.Log("Service account %s refused for %s/%s, failing over: %v", forkOwner, owner, repo, err)
+				tokenpool.MarkLoginBlocked(strings.ToLower(prov.Name()), owner, repo, forkOwner)
+				nextOwner, forkErr := prov.ForkRepo(c.Request.Context(), owner, repo)
+				if forkErr == nil && !strings.EqualFold(nextOwner, forkOwner) {
+					forkOwner = nextOwner
+					pushToken = prov.GitToken(c.Request.Context(), forkOwner)
+					WriteSidebandLine(&response, 2, fmt.Sprintf("remote: gitGost: Retrying from fork %s/%s", forkOwner, repo))
+					continue
+				}
+			}
+			break
 		}
 		if err != nil {
 			utils.Log("Error creating PR: %v", err)
 			WriteSidebandLine(&response, 1, "unpack ok\n")
+			writeProviderErrorSideband(&response, prov, err)
 			WriteSidebandLine(&response, 3, fmt.Sprintf("error creating PR: %v", err))
 			WritePktLine(&response, "")
 			c.Writer.Write(response.Bytes())
@@ -558,6 +623,7 @@ func ReceivePackHandler(c *gin.Context) {
 		}
 
 		utils.Log("Created PR: %s", prURL)
+		countRepoActivity(prov, owner, repo, provider.ActivityPR, policy.HourlyLimit(provider.ActivityPR))
 
 		if err := RecordPR(c.Request.Context(), owner, repo, prURL); err != nil {
 			utils.Log("Error recording stats: %v", err)
@@ -565,42 +631,12 @@ func ReceivePackHandler(c *gin.Context) {
 	}
 
 	if isGlobalBurstAlertActive() {
-		nowPR := time.Now()
-		recentBurstPRsMu.Lock()
-		cutoffPR := nowPR.Add(-recentBurstPRsTTL)
-		newURLs := recentBurstPRs[:0]
-		newAts := recentBurstPRsAt[:0]
-		for i, at := range recentBurstPRsAt {
-			if at.After(cutoffPR) {
-				newURLs = append(newURLs, recentBurstPRs[i])
-				newAts = append(newAts, at)
-			}
-		}
-		newURLs = append(newURLs, prURL)
-		newAts = append(newAts, nowPR)
-		recentBurstPRs = newURLs
-		recentBurstPRsAt = newAts
-		recentBurstPRsMu.Unlock()
+		recordBurstPR(prURL, time.Now())
 	}
 
 	outPRHash := github.GeneratePRHash(owner, repo, branch)
 
-	go func() {
-		ntfyTopic := github.NtfyTopicForPR(outPRHash)
-		var ntfyTitle, ntfyMsg string
-		actionBtn := fmt.Sprintf("http, Check Status, %s/api/pr/%s/status, clear=true, method=GET", github.NtfyServiceURL(), outPRHash)
-		if isUpdate {
-			ntfyTitle = "PR Updated · gitGost"
-			ntfyMsg = fmt.Sprintf("Your anonymous PR was updated.\nPR: %s\nTopic: %s/%s", prURL, github.NtfyBaseURL(), ntfyTopic)
-		} else {
-			ntfyTitle = "PR Created · gitGost"
-			ntfyMsg = fmt.Sprintf("Your anonymous PR was created.\nPR: %s\nTopic: %s/%s", prURL, github.NtfyBaseURL(), ntfyTopic)
-		}
-		if err := github.PublishNtfyEvent(outPRHash, ntfyTitle, ntfyMsg, actionBtn); err != nil {
-			utils.Log("ntfy publish error for hash %s: %v", outPRHash, err)
-		}
-	}()
-
+	var replySecret, notifySecret, notifyKey string
 	if prURL != "" {
 		provShort := "gh"
 		if strings.HasPrefix(c.Request.URL.Path, "/v1/gl/") {
@@ -608,20 +644,50 @@ func ReceivePackHandler(c *gin.Context) {
 		} else if strings.HasPrefix(c.Request.URL.Path, "/v1/cb/") {
 			provShort = "cb"
 		}
+		var num int
 		switch provShort {
 		case "gl":
-			if num := glprovider.ExtractMRIID(prURL); num > 0 {
-				trackPR(outPRHash, owner, repo, num, prURL, provShort)
-			}
+			num = glprovider.ExtractMRIID(prURL)
 		case "cb":
-			if num := cbprovider.ExtractPRNumber(prURL); num > 0 {
-				trackPR(outPRHash, owner, repo, num, prURL, provShort)
-			}
+			num = cbprovider.ExtractPRNumber(prURL)
 		default:
-			if num := github.ExtractPRNumber(prURL); num > 0 {
-				trackPR(outPRHash, owner, repo, num, prURL, provShort)
+			num = github.ExtractPRNumber(prURL)
+		}
+		if num > 0 {
+			trackPR(outPRHash, owner, repo, num, prURL, provShort)
+		}
+		pr := moderation.PR{
+			Hash:      outPRHash,
+			Provider:  provShort,
+			Owner:     owner,
+			Repo:      repo,
+			Number:    num,
+			URL:       prURL,
+			CreatedAt: time.Now(),
+		}
+		if err := modStore.AddPR(c.Request.Context(), pr); err != nil {
+			utils.Log("Error recording PR %s: %v", prURL, err)
+		}
+		// PRs opened before subscriptions existed get their secret on the
+		// next update.
+		clientKey := strings.TrimSpace(git.PushOption(body, "notify-key"))
+		if clientKey != "" {
+			if _, err := prmsg.ParseRecipientKey(clientKey); err != nil {
+				WriteSidebandLine(&response, 2, "remote: gitGost: Ignoring notify-key: not an x25519 public key")
+				clientKey = ""
 			}
 		}
+		notifySecret, notifyKey = openSubscription(c.Request.Context(), outPRHash, clientKey)
+		pushEvent := prEventOpened
+		if isUpdate {
+			pushEvent = prEventUpdated
+		}
+		if err := publishPREvent(c.Request.Context(), pr, moderation.PREvent{Type: pushEvent}); err != nil {
+			utils.Log("Error publishing %s event for %s: %v", pushEvent, prURL, err)
+		}
+		if !isUpdate {
+			replySecret = openReplyBox(outPRHash, owner, repo, provShort)
+		}
 	}
 
 	WriteSidebandLine(&response, 2, "remote: ")
@@ -638,17 +704,45 @@ func ReceivePackHandler(c *gin.Context) {
 	WriteSidebandLine(&response, 2, fmt.Sprintf("remote: Branch: %s", branch))
 	WriteSidebandLine(&response, 2, fmt.Sprintf("remote: PR Hash: %s", outPRHash))
 	WriteSidebandLine(&response, 2, "remote: ")
-	WriteSidebandLine(&response, 2, "remote: Subscribe to PR notifications (no account needed):")
-	WriteSidebandLine(&response, 2, fmt.Sprintf("remote:   %s/%s", github.NtfyBaseURL(), github.NtfyTopicForPR(outPRHash)))
-	WriteSidebandLine(&response, 2, "remote: ")
+	if notifySecret != "" {
+		WriteSidebandLine(&response, 2, "remote: Private PR notifications, end-to-end encrypted (shown once, keep them safe):")
+		WriteSidebandLine(&response, 2, fmt.Sprintf("remote:   Subscription secret: %s", notifySecret))
+		keyArg := "<notify-key secret>"
+		if notifyKey != "" {
+			WriteSidebandLine(&response, 2, fmt.Sprintf("remote:   Notification key: %s", notifyKey))
+			keyArg = notifyKey
+		}
+		WriteSidebandLine(&response, 2, fmt.Sprintf("remote:   git gost notifications %s %s %s", outPRHash, notifySecret, keyArg))
+		token, _ := newPRURLToken(outPRHash, moderation.Subscription{TokenHash: prmsg.TokenHash(notifySecret)}, time.Now())
+		stream, feed := prSubscriberURLs(outPRHash, token)
+		WriteSidebandLine(&response, 2, fmt.Sprintf("remote:   Feed and stream links (valid %dh; new ones with git gost notifications url):", int(prURLTokenTTL.Hours())))
+		WriteSidebandLine(&response, 2, fmt.Sprintf("remote:   Atom feed: %s", feed))
+		WriteSidebandLine(&response, 2, fmt.Sprintf("remote:   Live stream: %s", stream))
+		WriteSidebandLine(&response, 2, "remote: ")
+	}
+	if ntfyForward {
+		if prNotifyKey(c.Request.Context(), outPRHash) != "" {
+			WriteSidebandLine(&response, 2, "remote: ntfy topic (encrypted; read with git gost notifications open):")
+		} else {
+			WriteSidebandLine(&response, 2, "remote: Public ntfy topic (anyone with the PR hash can read it):")
+		}
+		WriteSidebandLine(&response, 2, fmt.Sprintf("remote:   %s/%s", github.NtfyBaseURL(), github.NtfyTopicForPR(outPRHash)))
+		WriteSidebandLine(&response, 2, "remote: ")
+	}
 	WriteSidebandLine(&response, 2, "remote: To update this PR on future pushes, use:")
 	WriteSidebandLine(&response, 2, fmt.Sprintf("remote:   git push gost <branch>:main -o pr-hash=%s", outPRHash))
 	WriteSidebandLine(&response, 2, "remote: ")
+	if replySecret != "" {
+		WriteSidebandLine(&response, 2, "remote: Private replies with the maintainer (secret shown once, keep it safe):")
+		WriteSidebandLine(&response, 2, fmt.Sprintf("remote:   git gost inbox %s %s", outPRHash, replySecret))
+		WriteSidebandLine(&response, 2, "remote: ")
+	}
 	WriteSidebandLine(&response, 2, "remote: Your identity has been anonymized.")
 	WriteSidebandLine(&response, 2, "remote: No trace to you remains in the commit history.")
 	WriteSidebandLine(&response, 2, "remote: ")
 	WriteSidebandLine(&response, 2, "remote: ========================================")
 	WriteSidebandLine(&response, 2, "remote: ")
+	writePolicyMessage(&response, policy)
 
 	WriteSidebandLine(&response, 1, "unpack ok\n")
 	WriteSidebandLine(&response, 1, "ok refs/heads/main\n")
@@ -665,7 +759,7 @@ func UploadPackDiscoveryHandler(c *gin.Context) {
 	repo := c.Param("repo")
 
 	prov := providerFromPath(c.Request.URL.Path)
-	token := os.Getenv(prov.TokenEnvVar())
+	token := prov.GitToken(c.Request.Context(), owner)
 
 	q := url.Values{}
 	q.Set("service", "git-upload-pack")
@@ -721,7 +815,7 @@ func UploadPackHandler(c *gin.Context) {
 	}
 
 	prov := providerFromPath(c.Request.URL.Path)
-	token := os.Getenv(prov.TokenEnvVar())
+	token := prov.GitToken(c.Request.Context(), owner)
 
 	remoteURL := prov.CloneURL(owner, repo) + "/git-upload-pack"
 	req, err := http.NewRequest("POST", remoteURL, bytes.NewReader(body))
@@ -818,50 +912,41 @@ var (
 	startTime           = time.Now()
 	dbClient            *database.SupabaseClient
 	dbOnce              sync.Once
-	secretKey           []byte
-	identityMu          sync.Mutex
-	karmaStore          = newBoundedMap[int](karmaStoreMax, 24*time.Hour)
-	reportStore         = newBoundedMap[reportState](reportStoreMax, reportWindow)
-	flaggedStore        = newBoundedMap[time.Time](flaggedStoreMax, flaggedCooldown)
-	blockedStore        = newBoundedMap[bool](blockedStoreMax, 0)
-	panicMode           bool
-	panicMu             sync.Mutex
+	modStore            moderation.Store = moderation.NewMemory()
 	panicPassword       string
 	ntfyAdminTopic      string
 	mentaAPIEndpoint    string
 	mentaAPIKey         string
-	rateLimitStore      = newBoundedMap[[]time.Time](rateLimitStoreMax, rateLimitWindow)
+	rateLimitStore      = newBoundedMap[[]time.Time]("push-rate", rateLimitStoreMax, rateLimitWindow)
 	rateLimitWindow     = time.Hour
 	rateLimitMaxPRs     = 5
-	globalBurstMu       sync.Mutex
-	globalBurstTimes    []time.Time
-	globalBurstIPs      []string
 	globalBurstWindow   = 60 * time.Second
 	globalBurstMaxTotal = 20
 	globalBurstMaxIPs   = 10
-	globalBurstAlerted  bool
-	recentBurstPRsMu    sync.Mutex
-	recentBurstPRs      []string
-	recentBurstPRsAt    []time.Time
 	recentBurstPRsTTL   = 2 * time.Hour
 
-	actionTokens   = newBoundedMap[time.Time](actionTokenMax, actionTokenTTL)
+	// The panic switch, the burst window and the PRs opened during a burst
+	// are single keys so every instance sees the same ones.
+	flagStore     = newBoundedMap[bool]("flags", 0, 0)
+	burstStore    = newBoundedMap[globalBurst]("burst", 0, 0)
+	burstPRsStore = newBoundedMap[[]moderation.PR]("burst-prs", 0, recentBurstPRsTTL)
+
+	actionTokens   = newBoundedMap[time.Time]("action-tokens", actionTokenMax, actionTokenTTL)
 	actionTokenTTL = 10 * time.Minute
 
-	rollbackLimitMu    sync.Mutex
-	rollbackLimitTimes []time.Time
+	rollbackLimitStore = newBoundedMap[[]time.Time]("rollback-limit", 1, rollbackLimitWin)
 	rollbackLimitMax   = 5
 	rollbackLimitWin   = time.Minute
 
-	reportRateLimitStore  = newBoundedMap[[]time.Time](reportRateLimitStoreMax, reportRateLimitWindow)
+	reportRateLimitStore  = newBoundedMap[[]time.Time]("report-rate", reportRateLimitStoreMax, reportRateLimitWindow)
 	reportRateLimitWindow = time.Hour
 	reportRateLimitMax    = 5
 
-	reportTokens   = newBoundedMap[time.Time](reportTokenMax, reportTokenTTL)
+	reportTokens   = newBoundedMap[time.Time]("report-tokens", reportTokenMax, reportTokenTTL)
 	reportTokenTTL = 10 * time.Minute
 
-	reportFormTmpl   = template.Must(template.New("reportForm").Parse(`<!DOCTYPE html><html lang="en"><head><meta charset="UTF-8" /><script src="https://mentacaptchaeu.eu.pythonanywhere.com/menta-captcha.js"></script><title>Report content · gitGost</title><style>body{font-family:Inter,system-ui,-apple-system,Segoe UI,sans-serif;background:#0d1117;color:#c9d1d9;display:flex;align-items:center;justify-content:center;min-height:100vh;margin:0;padding:32px;} .shell{background:linear-gradient(145deg, rgba(255,166,87,0.16), rgba(255,107,107,0.14));border:1px solid rgba(255,166,87,0.45);border-radius:16px;padding:1.5px;box-shadow:0 16px 38px rgba(0,0,0,.42);max-width:620px;width:100%;} .card{background:#0d1117;border-radius:14px;padding:26px;border:1px solid rgba(255,255,255,0.05);} h1{margin:0 0 6px;font-size:24px;color:#ffa657;} .eyebrow{display:inline-flex;align-items:center;gap:.35rem;padding:.35rem .75rem;background:rgba(255,166,87,0.12);color:#ffa657;border:1px solid rgba(255,166,87,0.4);border-radius:999px;font-family:'IBM Plex Mono', monospace;font-size:.85rem;margin-bottom:5px;} .sub{margin:6px 0 14px;color:#9fb3ff;font-size:14px;} .policy{background:rgba(255,255,255,0.03);border:1px solid rgba(255,255,255,0.05);border-radius:12px;padding:14px;margin:14px 0;font-size:13px;line-height:1.55;} .policy strong{color:#ffa657;} label{display:block;font-weight:700;margin:12px 0 6px;letter-spacing:.01em;} .readonly{background:rgba(255,255,255,0.04);border:1px solid rgba(255,255,255,0.08);border-radius:10px;padding:12px;color:#c9d1d9;font-family:'IBM Plex Mono', monospace;} button{margin-top:14px;width:100%;padding:12px;border-radius:10px;border:none;background:linear-gradient(135deg,#ffa657,#ff6b6b);color:#0d1117;font-weight:700;font-size:15px;cursor:pointer;box-shadow:0 10px 30px rgba(0,0,0,0.25);} .note{margin-top:10px;font-size:12px;color:#9fb3ff;} .error{color:#ffb4c4;font-size:13px;margin-top:10px;} .count{display:flex;gap:8px;align-items:center;margin:10px 0;font-family:'IBM Plex Mono', monospace;} .pill{padding:6px 10px;border-radius:999px;border:1px solid rgba(255,255,255,0.08);background:rgba(255,255,255,0.04);} .pill strong{color:#ffa657;} .state{margin-left:auto;font-size:12px;color:#9fb3ff;} .legend{font-size:12px;color:#9fb3ff;margin-top:10px;} input[type=text]{width:100%;padding:12px;border-radius:10px;border:1px solid rgba(255,255,255,0.08);background:rgba(255,255,255,0.04);color:#c9d1d9;} form{margin-top:12px;} a{color:#9fb3ff;} .locked{opacity:.55;pointer-events:none;} </style></head><body><div class="shell"><div class="card"><div class="eyebrow">Anonymous moderation</div><h1>Report content</h1><div class="sub">Flag abuse from anonymous contributions.</div><div class="policy"><ul style="margin:0 0 6px 18px; padding:0 0 0 4px; line-height:1.6;">` + string(reportPolicyHTML) + `</ul><div class="note">Reports reset after 30 days.</div></div><form method="POST" action="/v1/moderation/report" onsubmit="const t=document.getElementById('menta-report')?.token; document.getElementById('report-captcha-token').value=t||''"><label for="hash">Hash</label><input type="text" id="hash" name="hash" value="{{.Hash}}" placeholder="goster-xxxxx" {{if eq .State "blocked"}}class="locked" readonly{{end}} /><div class="count"><div class="pill">Reports: <strong>{{.Reports}}</strong></div><div class="state">State: {{.State}}</div></div><input type="hidden" name="report_token" value="{{.ReportToken}}" /><input type="hidden" name="captcha_token" id="report-captcha-token" /><div class="note">Please complete the CAPTCHA below.</div><menta-widget id="menta-report" data-cap-api-endpoint="/api/captcha" data-cap-i18n-initial-state="I'm not a robot"></menta-widget><button type="submit" {{if eq .State "blocked"}}disabled class="locked"{{end}}>Submit report</button></form><div class="legend">Hash identifies the anonymous submitter. No personal data is collected.</div>{{if .Error}}<div class="error">{{.Error}}</div>{{end}}</div></div></body></html>`))
-	reportThanksTmpl = template.Must(template.New("reportThanks").Parse(`<!DOCTYPE html><html lang="en"><head><meta charset="UTF-8" /><title>Report received · gitGost</title><style>body{font-family:Inter,system-ui,-apple-system,Segoe UI,sans-serif;background:#0d1117;color:#c9d1d9;display:flex;align-items:center;justify-content:center;min-height:100vh;margin:0;padding:32px;} .shell{background:linear-gradient(145deg, rgba(255,166,87,0.16), rgba(255,107,107,0.14));border:1px solid rgba(255,166,87,0.45);border-radius:16px;padding:1.5px;box-shadow:0 16px 38px rgba(0,0,0,.42);max-width:620px;width:100%;} .card{background:#0d1117;border-radius:14px;padding:26px;border:1px solid rgba(255,255,255,0.05);} h1{margin:0 0 10px;font-size:24px;color:#ffa657;} p{margin:6px 0 0;color:#9fb3ff;} .pill{display:inline-block;margin-top:12px;padding:8px 12px;border-radius:999px;background:rgba(255,255,255,0.04);color:#ffa657;font-weight:700;border:1px solid rgba(255,255,255,0.08);} .cta{margin-top:16px;display:inline-block;padding:12px 16px;border-radius:10px;background:linear-gradient(135deg,#ffa657,#ff6b6b);color:#0d1117;font-weight:700;text-decoration:none;box-shadow:0 10px 30px rgba(0,0,0,0.25);} .small{margin-top:12px;font-size:12px;color:#9fb3ff;} .state{margin-top:10px;font-size:14px;} </style></head><body><div class="shell"><div class="card"><h1>Report received</h1><p>Hash: <strong>{{.Hash}}</strong></p><span class="pill">Total reports: {{.Reports}}</span><div class="state">State: {{.State}}</div><p class="small">Thanks for helping moderate. Your identity stays anonymous.</p><a class="cta" href="https://gitgost.fly.dev/" target="_blank" rel="noreferrer">Explore gitGost</a></div></div></body></html>`))
+	reportFormTmpl   = template.Must(template.New("reportForm").Parse(`<!DOCTYPE html><html lang="en"><head><meta charset="UTF-8" /><script src="https://mentacaptchaeu.eu.pythonanywhere.com/menta-captcha.js"></script><title>Report content · gitGost</title><style>body{font-family:Inter,system-ui,-apple-system,Segoe UI,sans-serif;background:#0d1117;color:#c9d1d9;display:flex;align-items:center;justify-content:center;min-height:100vh;margin:0;padding:32px;} .shell{background:linear-gradient(145deg, rgba(255,166,87,0.16), rgba(255,107,107,0.14));border:1px solid rgba(255,166,87,0.45);border-radius:16px;padding:1.5px;box-shadow:0 16px 38px rgba(0,0,0,.42);max-width:620px;width:100%;} .card{background:#0d1117;border-radius:14px;padding:26px;border:1px solid rgba(255,255,255,0.05);} h1{margin:0 0 6px;font-size:24px;color:#ffa657;} .eyebrow{display:inline-flex;align-items:center;gap:.35rem;padding:.35rem .75rem;background:rgba(255,166,87,0.12);color:#ffa657;border:1px solid rgba(255,166,87,0.4);border-radius:999px;font-family:'IBM Plex Mono', monospace;font-size:.85rem;margin-bottom:5px;} .sub{margin:6px 0 14px;color:#9fb3ff;font-size:14px;} .policy{background:rgba(255,255,255,0.03);border:1px solid rgba(255,255,255,0.05);border-radius:12px;padding:14px;margin:14px 0;font-size:13px;line-height:1.55;} .policy strong{color:#ffa657;} label{display:block;font-weight:700;margin:12px 0 6px;letter-spacing:.01em;} .readonly{background:rgba(255,255,255,0.04);border:1px solid rgba(255,255,255,0.08);border-radius:10px;padding:12px;color:#c9d1d9;font-family:'IBM Plex Mono', monospace;} button{margin-top:14px;width:100%;padding:12px;border-radius:10px;border:none;background:linear-gradient(135deg,#ffa657,#ff6b6b);color:#0d1117;font-weight:700;font-size:15px;cursor:pointer;box-shadow:0 10px 30px rgba(0,0,0,0.25);} .note{margin-top:10px;font-size:12px;color:#9fb3ff;} .error{color:#ffb4c4;font-size:13px;margin-top:10px;} .count{display:flex;gap:8px;align-items:center;margin:10px 0;font-family:'IBM Plex Mono', monospace;} .pill{padding:6px 10px;border-radius:999px;border:1px solid rgba(255,255,255,0.08);background:rgba(255,255,255,0.04);} .pill strong{color:#ffa657;} .state{margin-left:auto;font-size:12px;color:#9fb3ff;} .legend{font-size:12px;color:#9fb3ff;margin-top:10px;} input[type=text],select,textarea{width:100%;box-sizing:border-box;padding:12px;border-radius:10px;border:1px solid rgba(255,255,255,0.08);background:rgba(255,255,255,0.04);color:#c9d1d9;font-family:inherit;} select option{background:#0d1117;} form{margin-top:12px;} a{color:#9fb3ff;} .locked{opacity:.55;pointer-events:none;} </style></head><body><div class="shell"><div class="card"><div class="eyebrow">Anonymous moderation</div><h1>Report content</h1><div class="sub">Flag abuse from anonymous contributions.</div><div class="policy"><ul style="margin:0 0 6px 18px; padding:0 0 0 4px; line-height:1.6;">` + string(reportPolicyHTML) + `</ul><div class="note">Reports reset after 30 days.</div></div><form method="POST" action="/v1/moderation/report" onsubmit="const t=document.getElementById('menta-report')?.token; document.getElementById('report-captcha-token').value=t||''"><label for="hash">Hash</label><input type="text" id="hash" name="hash" value="{{.Hash}}" placeholder="goster-xxxxx" {{if eq .State "blocked"}}class="locked" readonly{{end}} /><div class="count"><div class="pill">Reports: <strong>{{.Reports}}</strong></div><div class="pill">Score: <strong>{{.Score}}</strong></div><div class="state">State: {{.State}}</div></div><label for="category">Reason</label><select id="category" name="category" required><option value="">Choose a category…</option>{{range .Categories}}<option value="{{.ID}}"{{if eq .ID $.Category}} selected{{end}}>{{.Label}}</option>{{end}}</select><label for="detail">Details (optional)</label><textarea id="detail" name="detail" maxlength="500" rows="3" placeholder="What is wrong with this content?"></textarea><input type="hidden" name="report_token" value="{{.ReportToken}}" /><input type="hidden" name="captcha_token" id="report-captcha-token" /><div class="note">Please complete the CAPTCHA below.</div><menta-widget id="menta-report" data-cap-api-endpoint="/api/captcha" data-cap-i18n-initial-state="I'm not a robot"></menta-widget><button type="submit" {{if eq .State "blocked"}}disabled class="locked"{{end}}>Submit report</button></form><div class="legend">Hash identifies the anonymous submitter. No personal data is collected.</div>{{if .Error}}<div class="error">{{.Error}}</div>{{end}}</div></div></body></html>`))
+	reportThanksTmpl = template.Must(template.New("reportThanks").Parse(`<!DOCTYPE html><html lang="en"><head><meta charset="UTF-8" /><title>Report received · gitGost</title><style>body{font-family:Inter,system-ui,-apple-system,Segoe UI,sans-serif;background:#0d1117;color:#c9d1d9;display:flex;align-items:center;justify-content:center;min-height:100vh;margin:0;padding:32px;} .shell{background:linear-gradient(145deg, rgba(255,166,87,0.16), rgba(255,107,107,0.14));border:1px solid rgba(255,166,87,0.45);border-radius:16px;padding:1.5px;box-shadow:0 16px 38px rgba(0,0,0,.42);max-width:620px;width:100%;} .card{background:#0d1117;border-radius:14px;padding:26px;border:1px solid rgba(255,255,255,0.05);} h1{margin:0 0 10px;font-size:24px;color:#ffa657;} p{margin:6px 0 0;color:#9fb3ff;} .pill{display:inline-block;margin-top:12px;padding:8px 12px;border-radius:999px;background:rgba(255,255,255,0.04);color:#ffa657;font-weight:700;border:1px solid rgba(255,255,255,0.08);} .cta{margin-top:16px;display:inline-block;padding:12px 16px;border-radius:10px;background:linear-gradient(135deg,#ffa657,#ff6b6b);color:#0d1117;font-weight:700;text-decoration:none;box-shadow:0 10px 30px rgba(0,0,0,0.25);} .small{margin-top:12px;font-size:12px;color:#9fb3ff;} .state{margin-top:10px;font-size:14px;} </style></head><body><div class="shell"><div class="card"><h1>Report received</h1><p>Hash: <strong>{{.Hash}}</strong></p><span class="pill">Total reports: {{.Reports}} · score {{.Score}}</span><div class="state">State: {{.State}}</div><p class="small">Thanks for helping moderate. Your identity stays anonymous.</p><a class="cta" href="https://gitgost.fly.dev/" target="_blank" rel="noreferrer">Explore gitGost</a></div></div></body></html>`))
 )
 
 type anonymousIssueRequest struct {
@@ -884,7 +969,7 @@ const (
 	flaggedCooldown = 6 * time.Hour
 )
 
-var reportPolicyHTML = template.HTML(`<li><strong>0–2 reports:</strong> internal log only.</li><li><strong>3–5 reports:</strong> hash flagged, 6h cooldown, karma reset.</li><li><strong>6+ reports:</strong> hash blocked; we attempt to remove its comments.</li>`)
+var reportPolicyHTML = template.HTML(`<li><strong>Weights:</strong> spam and other count 1, harassment 2, illegal content 3; malware blocks immediately.</li><li><strong>Score 0–2:</strong> internal log only.</li><li><strong>Score 3–5:</strong> hash flagged, 6h cooldown, karma reset.</li><li><strong>Score 6+:</strong> hash blocked; we attempt to remove its comments, or close its PR.</li>`)
 
 type prTrack struct {
 	Owner    string
@@ -896,57 +981,136 @@ type prTrack struct {
 	AddedAt  time.Time
 }
 
+// prTrackStore caches lookups of the PR records in modStore, which are what
+// let a hash be followed for as long as its PR is open and prRetention after.
 var (
-	prTrackMu           sync.RWMutex
-	prTrackStore        = make(map[string]*prTrack)
-	prTrackTTL          = 24 * time.Hour
-	prTrackEvictionOnce sync.Once
+	prTrackStore = newBoundedMap[prTrack]("pr-track", 0, prTrackTTL)
+	prTrackTTL   = 24 * time.Hour
+
+	prRetention     = 30 * 24 * time.Hour
+	prPurgeInterval = 6 * time.Hour
+	// prRecheckPage is how many stale open PRs are listed at a time for
+	// recheckStalePRs.
+	prRecheckPage = 100
+	prPurgeOnce     sync.Once
 )
 
-func startPRTrackEviction() {
-	prTrackEvictionOnce.Do(func() {
+// InitPRRetention sets how long PR records are kept after their PR closes
+// and starts purging older ones. retention <= 0 keeps them forever.
+func InitPRRetention(retention time.Duration) {
+	prRetention = retention
+	if retention <= 0 {
+		return
+	}
+	prPurgeOnce.Do(func() {
 		go func() {
-			ticker := time.NewTicker(10 * time.Minute)
-			defer ticker.Stop()
-			for range ticker.C {
-				prTrackMu.Lock()
-				for hash, t := range prTrackStore {
-					if time.Since(t.AddedAt) > prTrackTTL {
-						delete(prTrackStore, hash)
-					}
-				}
-				prTrackMu.Unlock()
+			for {
+				purgeClosedPRs(time.Now())
+				time.Sleep(prPurgeInterval)
 			}
 		}()
 	})
 }
 
+func purgeClosedPRs(now time.Time) {
+	if prRetention <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	recheckStalePRs(ctx, now.Add(-prRetention))
+	n, err := modStore.PurgePRs(ctx, now.Add(-prRetention))
+	if err != nil {
+		utils.Log("Error purging closed PRs: %v", err)
+		return
+	}
+	if n > 0 {
+		utils.Log("Purged %d PR records closed more than %s ago", n, prRetention)
+	}
+}
+
+// recheckStalePRs asks the forge about PRs opened before cutoff that are
+// still recorded as open, and marks those it reports closed or gone. A PR
+// closed while no webhook or status check was watching would otherwise
+// keep its record forever.
+func recheckStalePRs(ctx context.Context, cutoff time.Time) {
+	until := cutoff
+	for ctx.Err() == nil {
+		prs, err := modStore.PRs(ctx, moderation.PRQuery{Until: until, OpenOnly: true, Limit: prRecheckPage})
+		if err != nil {
+			utils.Log("Error listing open PRs to recheck: %v", err)
+			return
+		}
+		for _, pr := range prs {
+			t := prTrack{Owner: pr.Owner, Repo: pr.Repo, Number: pr.Number, PRURL: pr.URL, Provider: pr.Provider}
+			status, err := fetchMRStatus(ctx, t)
+			switch {
+			case errors.Is(err, provider.ErrNotFound):
+			case err != nil:
+				utils.Log("Error rechecking PR %s: %v", pr.URL, err)
+				continue
+			case !prStateClosed(status.State):
+				continue
+			}
+			markTrackedPRClosed(ctx, t)
+		}
+		if len(prs) < prRecheckPage {
+			return
+		}
+		until = prs[len(prs)-1].CreatedAt
+	}
+}
+
 func trackPR(prHash, owner, repo string, number int, prURL, provider string) {
-	startPRTrackEviction()
-	prTrackMu.Lock()
-	defer prTrackMu.Unlock()
-	prTrackStore[prHash] = &prTrack{
+	prTrackStore.Set(prHash, prTrack{
 		Owner:    owner,
 		Repo:     repo,
 		Number:   number,
 		PRURL:    prURL,
 		Provider: provider,
 		AddedAt:  time.Now(),
-	}
+	})
 }
 
+// getPRTrack finds the PR behind prHash, in the cache or else in the PR
+// records, so a PR stays reachable across restarts and long reviews.
 func getPRTrack(prHash string) (prTrack, bool) {
-	prTrackMu.Lock()
-	defer prTrackMu.Unlock()
-	t, ok := prTrackStore[prHash]
-	if !ok {
+	if t, ok := prTrackStore.Get(prHash); ok && time.Since(t.AddedAt) <= prTrackTTL {
+		return t, true
+	}
+	if prHash == "" {
+		return prTrack{}, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	prs, err := modStore.PRs(ctx, moderation.PRQuery{Hash: prHash, Limit: 1})
+	if err != nil {
+		utils.Log("Error looking up PR %s: %v", prHash, err)
 		return prTrack{}, false
 	}
-	if time.Since(t.AddedAt) > prTrackTTL {
-		delete(prTrackStore, prHash)
+	if len(prs) == 0 || prs[0].Number <= 0 {
 		return prTrack{}, false
 	}
-	return *t, true
+	pr := prs[0]
+	trackPR(prHash, pr.Owner, pr.Repo, pr.Number, pr.URL, pr.Provider)
+	return prTrack{Owner: pr.Owner, Repo: pr.Repo, Number: pr.Number, PRURL: pr.URL, Provider: pr.Provider, AddedAt: time.Now()}, true
+}
+
+// prStateClosed reports whether a forge PR state means it is no longer open.
+func prStateClosed(state string) bool {
+	switch strings.ToLower(state) {
+	case "closed", "merged", "locked":
+		return true
+	}
+	return false
+}
+
+func prStateOpen(state string) bool {
+	switch strings.ToLower(state) {
+	case "open", "opened", "reopened":
+		return true
+	}
+	return false
 }
 
 func providerFromName(name string) provider.Provider {
@@ -1056,51 +1220,70 @@ func MentaCaptchaProxyHandler(c *gin.Context) {
 }
 
 func isPanicMode() bool {
-	panicMu.Lock()
-	defer panicMu.Unlock()
-	return panicMode
+	active, _ := flagStore.Get("panic")
+	return active
 }
 
-func isGlobalBurstAlertActive() bool {
-	globalBurstMu.Lock()
-	defer globalBurstMu.Unlock()
-	return globalBurstAlerted
+func setPanicMode(active bool) {
+	flagStore.Set("panic", active)
 }
 
-func recordGlobalBurst(ip string) {
-	now := time.Now()
-	globalBurstMu.Lock()
-	defer globalBurstMu.Unlock()
+// globalBurst is the sliding window of pushes across all clients, and
+// whether the admins have been alerted about it.
+type globalBurst struct {
+	Times   []time.Time `json:"times"`
+	IPs     []string    `json:"ips"`
+	Alerted bool        `json:"alerted"`
+}
 
+// live drops the pushes older than globalBurstWindow.
+func (b globalBurst) live(now time.Time) globalBurst {
 	cutoff := now.Add(-globalBurstWindow)
-	newTimes := globalBurstTimes[:0]
-	newIPs := globalBurstIPs[:0]
-	for i, t := range globalBurstTimes {
-		if t.After(cutoff) {
-			newTimes = append(newTimes, t)
-			newIPs = append(newIPs, globalBurstIPs[i])
+	out := globalBurst{Alerted: b.Alerted}
+	for i, t := range b.Times {
+		if t.After(cutoff) && i < len(b.IPs) {
+			out.Times = append(out.Times, t)
+			out.IPs = append(out.IPs, b.IPs[i])
 		}
 	}
-	newTimes = append(newTimes, now)
-	newIPs = append(newIPs, ip)
-	globalBurstTimes = newTimes
-	globalBurstIPs = newIPs
-
-	total := len(globalBurstTimes)
+	return out
+}
 
-	seen := make(map[string]struct{}, total)
-	for _, bip := range globalBurstIPs {
-		seen[bip] = struct{}{}
+// load returns the pushes in b and how many distinct IPs made them.
+func (b globalBurst) load() (int, int) {
+	seen := make(map[string]struct{}, len(b.IPs))
+	for _, ip := range b.IPs {
+		seen[ip] = struct{}{}
 	}
-	distinctIPs := len(seen)
+	return len(b.Times), len(seen)
+}
 
-	if !globalBurstAlerted && (total >= globalBurstMaxTotal || distinctIPs >= globalBurstMaxIPs) {
-		globalBurstAlerted = true
-		go notifyAdminGlobalBurst(total, distinctIPs)
-	}
+func isGlobalBurstAlertActive() bool {
+	b, _ := burstStore.Get("global")
+	return b.Alerted
+}
 
-	if globalBurstAlerted && total < globalBurstMaxTotal/2 && distinctIPs < globalBurstMaxIPs/2 {
-		globalBurstAlerted = false
+func recordGlobalBurst(ip string) {
+	now := time.Now()
+	alert := false
+	b, _ := burstStore.Update("global", func(b globalBurst, _ bool) globalBurst {
+		b = b.live(now)
+		b.Times = append(b.Times, now)
+		b.IPs = append(b.IPs, ip)
+		total, distinctIPs := b.load()
+
+		alert = false
+		if !b.Alerted && (total >= globalBurstMaxTotal || distinctIPs >= globalBurstMaxIPs) {
+			b.Alerted = true
+			alert = true
+		}
+		if b.Alerted && total < globalBurstMaxTotal/2 && distinctIPs < globalBurstMaxIPs/2 {
+			b.Alerted = false
+		}
+		return b
+	})
+	if alert {
+		go notifyAdminGlobalBurst(b.load())
 	}
 }
 
@@ -1145,7 +1328,7 @@ func notifyAdminRateLimit(ip string, count int) {
 	}
 	serviceURL := github.NtfyServiceURL()
 	title := "Rate limit exceeded · gitGost"
-	msg := fmt.Sprintf("IP %s exceeded the limit of %d PRs/hour (attempts: %d).", ip, rateLimitMaxPRs, count)
+	msg := fmt.Sprintf("Client %s (daily IP hash) exceeded the limit of %d PRs/hour (attempts: %d).", ip, rateLimitMaxPRs, count)
 	tokActivate := newActionToken()
 	tokRollback := newActionToken()
 	tokDeactivate := newActionToken()
@@ -1165,26 +1348,25 @@ func PanicHandler(c *gin.Context) {
 		Password string `json:"password"`
 		Token    string `json:"token"`
 		Active   bool   `json:"active"`
+		Reason   string `json:"reason"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
 		return
 	}
-	authorized := (panicPassword != "" && req.Password == panicPassword) ||
-		(req.Token != "" && consumeActionToken(req.Token))
-	if !authorized {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+	who, ok := adminFromActionToken(c, req.Token, req.Password)
+	if !ok {
 		return
 	}
-	panicMu.Lock()
-	panicMode = req.Active
-	panicMu.Unlock()
-
 	state := "deactivated"
 	if req.Active {
 		state = "activated"
 	}
-	utils.Log("panic mode %s", state)
+	if !audit(c, who, "panic", "", adminReason(c, req.Reason), state) {
+		return
+	}
+	setPanicMode(req.Active)
+	utils.Log("panic mode %s by %s", state, who.Name)
 	c.JSON(http.StatusOK, gin.H{"panic_mode": req.Active, "state": state})
 }
 
@@ -1194,95 +1376,140 @@ func ServiceStatusHandler(c *gin.Context) {
 	})
 }
 
+// AdminStatusHandler reports who the caller is and what needs attention:
+// panic mode, the burst alert, PRs a rollback would close and open appeals.
+func AdminStatusHandler(c *gin.Context) {
+	who, ok := requireAdmin(c, roleModerator, "")
+	if !ok {
+		return
+	}
+	pending := len(burstPRs(false))
+
+	openAppeals := 0
+	appeals, err := modStore.Appeals(c.Request.Context(), time.Unix(0, 0))
+	if err != nil {
+		utils.Log("Error listing appeals: %v", err)
+	}
+	for _, a := range appeals {
+		if !a.Resolved {
+			openAppeals++
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"admin":        who.Name,
+		"role":         who.Role,
+		"panic_mode":   isPanicMode(),
+		"burst_alert":  isGlobalBurstAlertActive(),
+		"rollback_prs": pending,
+		"open_appeals": openAppeals,
+	})
+}
+
+// RollbackBurstHandler closes open anonymous PRs. With no selector it takes
+// the PRs collected during the last burst alert; with repo, provider, since,
+// until or hash_prefix it picks them from the persisted PR records instead.
+// preview lists what would be closed without closing anything.
 func RollbackBurstHandler(c *gin.Context) {
 	var req struct {
 		Password string `json:"password"`
 		Token    string `json:"token"`
+		Reason   string `json:"reason"`
+		rollbackSelector
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
 		return
 	}
 
-	authorized := (panicPassword != "" && req.Password == panicPassword) ||
-		(req.Token != "" && consumeActionToken(req.Token))
-	if !authorized {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+	// An ntfy action token only stands for the burst rollback its button
+	// sends. Selectors and previews are attributed to a named operator.
+	var who adminIdentity
+	var ok bool
+	if req.selects() {
+		if who, ok = requireAdmin(c, roleOperator, req.Password); ok && !who.named() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "targeted rollbacks and previews require a named operator credential"})
+			return
+		}
+	} else {
+		who, ok = adminFromActionToken(c, req.Token, req.Password)
+	}
+	if !ok {
+		return
+	}
+	reason := adminReason(c, req.Reason)
+	q, targeted, err := req.query()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var prs []moderation.PR
+	if targeted {
+		prs, err = modStore.PRs(c.Request.Context(), q)
+		if err != nil {
+			utils.Log("Error listing PRs for rollback: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "PR records unavailable"})
+			return
+		}
+		if len(prs) > rollbackMaxPRs && !req.Preview {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("selectors match more than %d open PRs; narrow them or preview first", rollbackMaxPRs)})
+			return
+		}
+	} else if req.Preview {
+		prs = burstPRs(false)
+	}
+	if req.Preview {
+		truncated := len(prs) > rollbackMaxPRs
+		if truncated {
+			prs = prs[:rollbackMaxPRs]
+		}
+		results := make([]rollbackResult, 0, len(prs))
+		for _, pr := range prs {
+			results = append(results, rollbackResult{PR: pr, Status: "would_close"})
+		}
+		c.JSON(http.StatusOK, gin.H{"preview": true, "matched": len(results), "truncated": truncated, "results": results})
 		return
 	}
 
 	now := time.Now()
-	rollbackLimitMu.Lock()
-	valid := rollbackLimitTimes[:0]
-	for _, t := range rollbackLimitTimes {
-		if now.Sub(t) < rollbackLimitWin {
-			valid = append(valid, t)
-		}
-	}
-	valid = append(valid, now)
-	rollbackLimitTimes = valid
-	exceeded := len(valid) > rollbackLimitMax
-	rollbackLimitMu.Unlock()
-	if exceeded {
+	if windowAdd(rollbackLimitStore, "global", now, rollbackLimitWin, rollbackLimitMax) > rollbackLimitMax {
 		c.JSON(http.StatusTooManyRequests, gin.H{"error": "rollback rate limit exceeded"})
 		return
 	}
 
-	recentBurstPRsMu.Lock()
-	toClose := make([]string, len(recentBurstPRs))
-	copy(toClose, recentBurstPRs)
-	recentBurstPRs = recentBurstPRs[:0]
-	recentBurstPRsAt = recentBurstPRsAt[:0]
-	recentBurstPRsMu.Unlock()
-
-	if len(toClose) == 0 {
-		c.JSON(http.StatusOK, gin.H{"closed": 0, "message": "no burst PRs to close"})
+	if !targeted {
+		prs = burstPRs(true)
+	}
+	scope := req.describe()
+	if len(prs) == 0 {
+		if !audit(c, who, "rollback", scope, reason, "no PRs to close") {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"closed": 0, "message": "no PRs to close", "results": []rollbackResult{}})
 		return
 	}
 
-	const maxCloseWorkers = 5
-	closeConcurrency := make(chan struct{}, maxCloseWorkers)
-	var (
-		wg     sync.WaitGroup
-		mu     sync.Mutex
-		closed []string
-		failed []string
-	)
-	for _, prURL := range toClose {
-		wg.Add(1)
-		go func(u string) {
-			defer wg.Done()
-			closeConcurrency <- struct{}{}
-			defer func() { <-closeConcurrency }()
-			var closeErr error
-			switch {
-			case strings.Contains(u, "gitlab.com"):
-				closeErr = glprovider.New().CloseMRByURL(u)
-			case strings.Contains(u, "codeberg.org"):
-				closeErr = cbprovider.New().CloseMRByURL(u)
-			default:
-				closeErr = github.ClosePRByURL(u)
-			}
-			if err := closeErr; err != nil {
-				utils.Log("rollback: failed to close %s: %v", u, err)
-				mu.Lock()
-				failed = append(failed, u)
-				mu.Unlock()
-			} else {
-				mu.Lock()
-				closed = append(closed, u)
-				mu.Unlock()
-			}
-		}(prURL)
+	if !audit(c, who, "rollback", scope, reason, fmt.Sprintf("closing %d PRs", len(prs))) {
+		return
+	}
+	// Closing must finish even if the admin's connection drops mid-rollback.
+	results := closeRollbackPRs(context.WithoutCancel(c.Request.Context()), prs)
+	closed, failed := []string{}, []string{}
+	for _, r := range results {
+		if r.Status == "closed" {
+			closed = append(closed, r.URL)
+		} else {
+			failed = append(failed, r.URL)
+		}
 	}
-	wg.Wait()
 
-	utils.Log("rollback: closed %d PRs, failed %d", len(closed), len(failed))
+	utils.Log("rollback (%s) by %s: closed %d PRs, failed %d", scope, who.Name, len(closed), len(failed))
 	c.JSON(http.StatusOK, gin.H{
 		"closed":      len(closed),
 		"failed":      len(failed),
 		"closed_urls": closed,
 		"failed_urls": failed,
+		"results":     results,
 	})
 }
 
@@ -1378,18 +1605,22 @@ func CreateAnonymousIssueHandler(c *gin.Context) {
 		return
 	}
 
-	if !verifyMentaCaptcha(req.CaptchaToken) {
+	if !humanVerified(c, req.CaptchaToken) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "captcha verification failed"})
 		return
 	}
 
+	if _, ok := enforceRepoPolicy(c, providerFromPath(c.Request.URL.Path), owner, repo, provider.ActivityIssue); !ok {
+		return
+	}
+
 	prov := providerFromPath(c.Request.URL.Path)
 	if req.GitHubToken != "" {
 		if _, ok := prov.(*ghprovider.GitHubProvider); ok {
-			issueURL, issueNumber, err := github.CreateAnonymousIssueWithToken(owner, repo, req.Title, req.Body, req.Labels, req.GitHubToken)
+			issueURL, issueNumber, err := github.CreateAnonymousIssueWithToken(c.Request.Context(), owner, repo, req.Title, req.Body, req.Labels, req.GitHubToken)
 			if err != nil {
 				utils.Log("Error creating issue: %v", err)
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				respondProviderError(c, err, http.StatusBadRequest)
 				return
 			}
 			userToken := generateUserToken()
@@ -1409,10 +1640,10 @@ func CreateAnonymousIssueHandler(c *gin.Context) {
 			return
 		}
 	}
-	issueURL, issueNumber, err := prov.CreateAnonymousIssue(owner, repo, req.Title, req.Body, req.Labels)
+	issueURL, issueNumber, err := prov.CreateAnonymousIssue(c.Request.Context(), owner, repo, req.Title, req.Body, req.Labels)
 	if err != nil {
 		utils.Log("Error creating issue: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondProviderError(c, err, http.StatusBadRequest)
 		return
 	}
 
@@ -2089,22 +2320,26 @@ func CreateAnonymousCommentHandler(c *gin.Context) {
 		return
 	}
 
-	if !verifyMentaCaptcha(req.CaptchaToken) {
+	if !humanVerified(c, req.CaptchaToken) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "captcha verification failed"})
 		return
 	}
 
+	if _, ok := enforceRepoPolicy(c, providerFromPath(c.Request.URL.Path), owner, repo, provider.ActivityComment); !ok {
+		return
+	}
+
 	userToken := req.UserToken
 	if strings.TrimSpace(userToken) == "" {
 		userToken = generateUserToken()
 	}
 	hash := deriveHash(owner, repo, number, userToken)
-	reports := getReportCountWithWindow(c.Request.Context(), hash)
-	if reports > 5 {
+	reports := getReportScore(c.Request.Context(), hash)
+	if reports >= reportBlockScore {
 		c.JSON(http.StatusForbidden, gin.H{"error": "hash bloqueado por reportes"})
 		return
 	}
-	if reports > 2 {
+	if reports >= reportFlagScore {
 		if blocked := isFlaggedCooldown(hash); blocked {
 			c.JSON(http.StatusTooManyRequests, gin.H{"error": "cooldown activo por reportes"})
 			return
@@ -2112,11 +2347,11 @@ func CreateAnonymousCommentHandler(c *gin.Context) {
 	}
 	currentKarma := getKarma(c.Request.Context(), hash)
 	karma := currentKarma + 1
-	if reports > 2 {
+	if reports >= reportFlagScore {
 		karma = 0
 	}
 	updateKarma(c.Request.Context(), hash, karma)
-	if reports > 2 {
+	if reports >= reportFlagScore {
 		markFlaggedAction(hash)
 		if err := github.UpdateCommentsKarmaByHash(hash, 0); err != nil {
 			utils.Log("Error updating comment karma for hash %s: %v", hash, err)
@@ -2130,10 +2365,10 @@ func CreateAnonymousCommentHandler(c *gin.Context) {
 	prov := providerFromPath(c.Request.URL.Path)
 	if req.GitHubToken != "" {
 		if _, ok := prov.(*ghprovider.GitHubProvider); ok {
-			commentURL, err := github.CreateAnonymousCommentWithToken(owner, repo, number, bodyWithLegend, req.GitHubToken)
+			commentURL, err := github.CreateAnonymousCommentWithToken(c.Request.Context(), owner, repo, number, bodyWithLegend, req.GitHubToken)
 			if err != nil {
 				utils.Log("Error creating comment: %v", err)
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				respondProviderError(c, err, http.StatusBadRequest)
 				return
 			}
 			if dbClient != nil {
@@ -2151,10 +2386,10 @@ func CreateAnonymousCommentHandler(c *gin.Context) {
 			return
 		}
 	}
-	commentURL, err := prov.CreateAnonymousComment(owner, repo, number, bodyWithLegend)
+	commentURL, err := prov.CreateAnonymousComment(c.Request.Context(), owner, repo, number, bodyWithLegend)
 	if err != nil {
 		utils.Log("Error creating comment: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondProviderError(c, err, http.StatusBadRequest)
 		return
 	}
 
@@ -2196,22 +2431,26 @@ func CreateAnonymousPRCommentHandler(c *gin.Context) {
 		return
 	}
 
-	if !verifyMentaCaptcha(req.CaptchaToken) {
+	if !humanVerified(c, req.CaptchaToken) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "captcha verification failed"})
 		return
 	}
 
+	if _, ok := enforceRepoPolicy(c, providerFromPath(c.Request.URL.Path), owner, repo, provider.ActivityComment); !ok {
+		return
+	}
+
 	userToken := req.UserToken
 	if strings.TrimSpace(userToken) == "" {
 		userToken = generateUserToken()
 	}
 	hash := deriveHash(owner, repo, number, userToken)
-	reports := getReportCountWithWindow(c.Request.Context(), hash)
-	if reports > 5 {
+	reports := getReportScore(c.Request.Context(), hash)
+	if reports >= reportBlockScore {
 		c.JSON(http.StatusForbidden, gin.H{"error": "hash bloqueado por reportes"})
 		return
 	}
-	if reports > 2 {
+	if reports >= reportFlagScore {
 		if blocked := isFlaggedCooldown(hash); blocked {
 			c.JSON(http.StatusTooManyRequests, gin.H{"error": "cooldown activo por reportes"})
 			return
@@ -2219,11 +2458,11 @@ func CreateAnonymousPRCommentHandler(c *gin.Context) {
 	}
 	currentKarma := getKarma(c.Request.Context(), hash)
 	karma := currentKarma + 1
-	if reports > 2 {
+	if reports >= reportFlagScore {
 		karma = 0
 	}
 	updateKarma(c.Request.Context(), hash, karma)
-	if reports > 2 {
+	if reports >= reportFlagScore {
 		markFlaggedAction(hash)
 		if err := github.UpdateCommentsKarmaByHash(hash, 0); err != nil {
 			utils.Log("Error updating PR comment karma for hash %s: %v", hash, err)
@@ -2237,10 +2476,10 @@ func CreateAnonymousPRCommentHandler(c *gin.Context) {
 	prov := providerFromPath(c.Request.URL.Path)
 	if req.GitHubToken != "" {
 		if _, ok := prov.(*ghprovider.GitHubProvider); ok {
-			commentURL, err := github.CreateAnonymousPRCommentWithToken(owner, repo, number, bodyWithLegend, req.GitHubToken)
+			commentURL, err := github.CreateAnonymousPRCommentWithToken(c.Request.Context(), owner, repo, number, bodyWithLegend, req.GitHubToken)
 			if err != nil {
 				utils.Log("Error creating PR comment: %v", err)
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				respondProviderError(c, err, http.StatusBadRequest)
 				return
 			}
 			if dbClient != nil {
@@ -2258,10 +2497,10 @@ func CreateAnonymousPRCommentHandler(c *gin.Context) {
 			return
 		}
 	}
-	commentURL, err := prov.CreateAnonymousPRComment(owner, repo, number, bodyWithLegend)
+	commentURL, err := prov.CreateAnonymousPRComment(c.Request.Context(), owner, repo, number, bodyWithLegend)
 	if err != nil {
 		utils.Log("Error creating PR comment: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondProviderError(c, err, http.StatusBadRequest)
 		return
 	}
 
@@ -2301,22 +2540,26 @@ func CreateAnonymousDiscussionCommentHandler(c *gin.Context) {
 		return
 	}
 
-	if !verifyMentaCaptcha(req.CaptchaToken) {
+	if !humanVerified(c, req.CaptchaToken) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "captcha verification failed"})
 		return
 	}
 
+	if _, ok := enforceRepoPolicy(c, providerFromPath(c.Request.URL.Path), owner, repo, provider.ActivityComment); !ok {
+		return
+	}
+
 	userToken := req.UserToken
 	if strings.TrimSpace(userToken) == "" {
 		userToken = generateUserToken()
 	}
 	hash := deriveHash(owner, repo, number, userToken)
-	reports := getReportCountWithWindow(c.Request.Context(), hash)
-	if reports > 5 {
+	reports := getReportScore(c.Request.Context(), hash)
+	if reports >= reportBlockScore {
 		c.JSON(http.StatusForbidden, gin.H{"error": "hash bloqueado por reportes"})
 		return
 	}
-	if reports > 2 {
+	if reports >= reportFlagScore {
 		if blocked := isFlaggedCooldown(hash); blocked {
 			c.JSON(http.StatusTooManyRequests, gin.H{"error": "cooldown activo por reportes"})
 			return
@@ -2324,11 +2567,11 @@ func CreateAnonymousDiscussionCommentHandler(c *gin.Context) {
 	}
 	currentKarma := getKarma(c.Request.Context(), hash)
 	karma := currentKarma + 1
-	if reports > 2 {
+	if reports >= reportFlagScore {
 		karma = 0
 	}
 	updateKarma(c.Request.Context(), hash, karma)
-	if reports > 2 {
+	if reports >= reportFlagScore {
 		markFlaggedAction(hash)
 	}
 	reportURL := fmt.Sprintf("%s://%s/v1/moderation/report?hash=%s", getScheme(c.Request), c.Request.Host, hash)
@@ -2339,10 +2582,10 @@ func CreateAnonymousDiscussionCommentHandler(c *gin.Context) {
 	prov := providerFromPath(c.Request.URL.Path)
 	if req.GitHubToken != "" {
 		if _, ok := prov.(*ghprovider.GitHubProvider); ok {
-			commentURL, err := github.CreateAnonymousDiscussionCommentWithToken(owner, repo, number, bodyWithLegend, req.GitHubToken)
+			commentURL, err := github.CreateAnonymousDiscussionCommentWithToken(c.Request.Context(), owner, repo, number, bodyWithLegend, req.GitHubToken)
 			if err != nil {
 				utils.Log("Error creating discussion comment: %v", err)
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				respondProviderError(c, err, http.StatusBadRequest)
 				return
 			}
 			if dbClient != nil {
@@ -2360,10 +2603,10 @@ func CreateAnonymousDiscussionCommentHandler(c *gin.Context) {
 			return
 		}
 	}
-	commentURL, err := prov.CreateAnonymousDiscussionComment(owner, repo, number, bodyWithLegend)
+	commentURL, err := prov.CreateAnonymousDiscussionComment(c.Request.Context(), owner, repo, number, bodyWithLegend)
 	if err != nil {
 		utils.Log("Error creating discussion comment: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondProviderError(c, err, http.StatusBadRequest)
 		return
 	}
 
@@ -2382,12 +2625,15 @@ func CreateAnonymousDiscussionCommentHandler(c *gin.Context) {
 	})
 }
 
-func renderReportForm(c *gin.Context, hash string, reports int, state, err, reportToken string) {
+func renderReportForm(c *gin.Context, hash string, tally reportTally, state, category, err, reportToken string) {
 	c.Header("Content-Type", "text/html; charset=utf-8")
 	_ = reportFormTmpl.Execute(c.Writer, gin.H{
 		"Hash":        hash,
-		"Reports":     reports,
+		"Reports":     tally.Count,
+		"Score":       tally.Score,
 		"State":       state,
+		"Categories":  reportCategories,
+		"Category":    category,
 		"Error":       err,
 		"PolicyHTML":  reportPolicyHTML,
 		"ReportToken": reportToken,
@@ -2417,113 +2663,110 @@ func consumeReportToken(token string) bool {
 	if !ok {
 		return false
 	}
-	reportTokens.Delete(token)
+	// A token that can't be deleted could be used again.
+	if err := reportTokens.Delete(token); err != nil {
+		return false
+	}
 	return time.Now().Before(expiry)
 }
 
 func ReportHashHandler(c *gin.Context) {
+	ctx := c.Request.Context()
 	if c.Request.Method == http.MethodGet {
 		hash := strings.TrimSpace(c.Query("hash"))
 		if hash == "" {
-			renderReportForm(c, "", 0, "sin datos", "El hash es obligatorio", newReportToken())
+			renderReportForm(c, "", reportTally{}, "sin datos", "", "El hash es obligatorio", newReportToken())
 			return
 		}
+		tally := getReportTally(ctx, hash)
 		if isBlockedHash(hash) {
-			renderReportForm(c, hash, 6, "bloqueado", "Este hash ya fue baneado/eliminado.", newReportToken())
+			renderReportForm(c, hash, tally, "bloqueado", "", "Este hash ya fue baneado/eliminado.", newReportToken())
 			return
 		}
-		reports := getReportCountWithWindow(c.Request.Context(), hash)
-		renderReportForm(c, hash, reports, reportStateLabel(reports), "", newReportToken())
+		renderReportForm(c, hash, tally, reportStateLabel(tally.Score), "", "", newReportToken())
 		return
 	}
 
 	hash := strings.TrimSpace(c.PostForm("hash"))
 	if hash == "" {
-		renderReportForm(c, "", 0, "sin datos", "El hash es obligatorio.", newReportToken())
+		renderReportForm(c, "", reportTally{}, "sin datos", "", "El hash es obligatorio.", newReportToken())
 		return
 	}
 
+	current := getReportTally(ctx, hash)
 	if isBlockedHash(hash) {
-		renderReportForm(c, hash, 6, "bloqueado", "Este hash ya fue baneado/eliminado.", newReportToken())
+		renderReportForm(c, hash, current, "bloqueado", "", "Este hash ya fue baneado/eliminado.", newReportToken())
+		return
+	}
+
+	currentState := reportStateLabel(current.Score)
+	category := strings.TrimSpace(c.PostForm("category"))
+	if _, ok := lookupReportCategory(category); !ok {
+		renderReportForm(c, hash, current, currentState, "", "Choose a report category.", newReportToken())
 		return
 	}
+	detail := cleanReportDetail(c.PostForm("detail"))
 
-	currentReports := getReportCountWithWindow(c.Request.Context(), hash)
-	currentState := reportStateLabel(currentReports)
-	ip := strings.TrimSpace(c.ClientIP())
+	ip := clientKey(c)
 	if checkReportRateLimit(ip) {
-		renderReportForm(c, hash, currentReports, currentState, fmt.Sprintf("Rate limit exceeded: max %d reports per hour per IP.", reportRateLimitMax), newReportToken())
+		renderReportForm(c, hash, current, currentState, category, fmt.Sprintf("Rate limit exceeded: max %d reports per hour per IP.", reportRateLimitMax), newReportToken())
 		return
 	}
 
 	captchaToken := strings.TrimSpace(c.PostForm("captcha_token"))
 	if !verifyMentaCaptcha(captchaToken) {
-		renderReportForm(c, hash, currentReports, currentState, "CAPTCHA verification failed.", newReportToken())
+		renderReportForm(c, hash, current, currentState, category, "CAPTCHA verification failed.", newReportToken())
 		return
 	}
 
 	reportToken := strings.TrimSpace(c.PostForm("report_token"))
 	if !consumeReportToken(reportToken) {
-		renderReportForm(c, hash, currentReports, currentState, "Invalid or expired report token. Please reload the page.", newReportToken())
+		renderReportForm(c, hash, current, currentState, category, "Invalid or expired report token. Please reload the page.", newReportToken())
 		return
 	}
 
-	reports := recordReport(c.Request.Context(), hash, ip)
-	if reports >= 6 {
-		setBlockedHash(hash)
-		go func(h string) {
-			if err := github.DeleteCommentsByHash(h); err != nil {
-				utils.Log("Error deleting comments for hash %s: %v", h, err)
+	tally := recordReport(ctx, hash, moderation.Report{Reporter: ip, Category: category, Detail: detail, At: time.Now()})
+	if tally.Score >= reportBlockScore {
+		if isTrackedPRHash(hash) {
+			if tally.Count >= prCloseReporters {
+				setBlockedHash(hash)
+				go closeReportedPR(hash)
 			}
-		}(hash)
+		} else {
+			setBlockedHash(hash)
+			go func(h string) {
+				if err := github.DeleteCommentsByHash(h); err != nil {
+					utils.Log("Error deleting comments for hash %s: %v", h, err)
+				}
+			}(hash)
+		}
 	}
 
 	c.Header("Content-Type", "text/html; charset=utf-8")
-	_ = reportThanksTmpl.Execute(c.Writer, gin.H{"Hash": hash, "Reports": reports, "State": reportStateLabel(reports)})
+	_ = reportThanksTmpl.Execute(c.Writer, gin.H{"Hash": hash, "Reports": tally.Count, "Score": tally.Score, "State": reportStateLabel(tally.Score)})
 }
 
-func recordReport(ctx context.Context, hash, ip string) int {
-	reports := 0
+// recordReport stores r against hash and returns the updated tally. A hash
+// whose weighted score reaches reportFlagScore is flagged and loses its
+// karma; blocking is left to the caller.
+func recordReport(ctx context.Context, hash string, r moderation.Report) reportTally {
+	stored := false
 	if dbClient != nil {
 		_ = dbClient.DeleteOldReports(ctx, hash, time.Now().Add(-reportWindow))
-		if exists, err := dbClient.HasReportFromIP(ctx, hash, ip); err == nil && exists {
-			if count, err := dbClient.GetReportCount(ctx, hash); err == nil {
-				return count
-			}
-			return 0
-		}
-		if err := dbClient.InsertReport(ctx, hash, ip); err == nil {
-			if count, err := dbClient.GetReportCount(ctx, hash); err == nil {
-				reports = count
-			}
+		if exists, err := dbClient.HasReportFromIP(ctx, hash, r.Reporter); err == nil && exists {
+			return getReportTally(ctx, hash)
 		}
+		stored = dbClient.InsertReport(ctx, hash, r.Reporter, reportReason(r.Category, r.Detail)) == nil
 	}
 
-	if reports == 0 {
-		now := time.Now()
-		state := reportStore.Update(hash, func(s reportState, ok bool) reportState {
-			if !ok || time.Since(s.First) > reportWindow {
-				if ip == "" {
-					return reportState{Count: 1, First: now}
-				}
-				return reportState{Count: 1, First: now, IPs: map[string]time.Time{ip: now}}
-			}
-			if ip != "" {
-				if s.IPs == nil {
-					s.IPs = make(map[string]time.Time)
-				}
-				if t, ok := s.IPs[ip]; ok && time.Since(t) <= reportWindow {
-					return s
-				}
-				s.IPs[ip] = now
-			}
-			s.Count++
-			return s
-		})
-		reports = state.Count
+	if !stored {
+		if _, err := modStore.AddReport(ctx, hash, r, reportWindow); err != nil {
+			utils.Log("Error recording report for hash %s: %v", hash, err)
+		}
 	}
 
-	if reports >= 3 && reports <= 5 {
+	tally := getReportTally(ctx, hash)
+	if tally.Score >= reportFlagScore && tally.Score < reportBlockScore {
 		updateKarma(ctx, hash, 0)
 		markFlaggedAction(hash)
 		if err := github.UpdateCommentsKarmaByHash(hash, 0); err != nil {
@@ -2531,52 +2774,26 @@ func recordReport(ctx context.Context, hash, ip string) int {
 		}
 	}
 
-	return reports
-}
-
-func getReportCountWithWindow(ctx context.Context, hash string) int {
-	if hash == "" {
-		return 0
-	}
-	if dbClient != nil {
-		_ = dbClient.DeleteOldReports(ctx, hash, time.Now().Add(-reportWindow))
-		if count, err := dbClient.GetReportCount(ctx, hash); err == nil {
-			if s, ok := reportStore.Get(hash); ok && s.Count > count {
-				return s.Count
-			}
-			return count
-		}
-	}
-
-	if s, ok := reportStore.Get(hash); ok {
-		return s.Count
-	}
-	return 0
-}
-
-func reportStateLabel(count int) string {
-	switch {
-	case count >= 6:
-		return "bloqueado"
-	case count >= 3:
-		return "flagged"
-	default:
-		return "registrado"
-	}
+	return tally
 }
 
 func setBlockedHash(hash string) {
 	if hash == "" {
 		return
 	}
-	blockedStore.Set(hash, true)
+	if err := modStore.SetBlocked(context.Background(), hash, true); err != nil {
+		utils.Log("Error blocking hash %s: %v", hash, err)
+	}
 }
 
 func isBlockedHash(hash string) bool {
 	if hash == "" {
 		return false
 	}
-	blocked, _ := blockedStore.Peek(hash)
+	blocked, err := modStore.IsBlocked(context.Background(), hash)
+	if err != nil {
+		utils.Log("Error checking block for hash %s: %v", hash, err)
+	}
 	return blocked
 }
 
@@ -2584,7 +2801,7 @@ func isFlaggedCooldown(hash string) bool {
 	if hash == "" {
 		return false
 	}
-	last, ok := flaggedStore.Peek(hash)
+	last, ok, _ := modStore.LastFlagged(context.Background(), hash)
 	if !ok {
 		return false
 	}
@@ -2595,31 +2812,38 @@ func markFlaggedAction(hash string) {
 	if hash == "" {
 		return
 	}
-	flaggedStore.Set(hash, time.Now())
+	_ = modStore.MarkFlagged(context.Background(), hash, time.Now())
 }
 
-func getSecretKey() []byte {
-	identityMu.Lock()
-	defer identityMu.Unlock()
-	if secretKey != nil {
-		return secretKey
-	}
-	b := make([]byte, 32)
-	_, err := rand.Read(b)
-	if err != nil {
-		b = []byte(time.Now().String())
+// deriveHash returns the anonymous hash for a user token on an issue or PR.
+// A hash that already has history under an older key keeps being used, so
+// rotating the secret key doesn't hand everyone a clean slate.
+func deriveHash(owner, repo string, number int, userToken string) string {
+	input := fmt.Sprintf("%s/%s#%d|%s", owner, repo, number, userToken)
+	keys := getSecretKeys()
+	for i := len(keys) - 1; i > 0; i-- {
+		if h := hmacHash(keys[i], input); hashHasHistory(h) {
+			return h
+		}
 	}
-	secretKey = b
-	return secretKey
+	return hmacHash(keys[0], input)
 }
 
-func deriveHash(owner, repo string, number int, userToken string) string {
-	input := fmt.Sprintf("%s/%s#%d|%s", owner, repo, number, userToken)
-	h := hmac.New(sha256.New, getSecretKey())
+func hmacHash(key []byte, input string) string {
+	h := hmac.New(sha256.New, key)
 	h.Write([]byte(input))
 	return hex.EncodeToString(h.Sum(nil))[:8]
 }
 
+func hashHasHistory(hash string) bool {
+	ctx := context.Background()
+	if _, ok, _ := modStore.Karma(ctx, hash); ok {
+		return true
+	}
+	blocked, _ := modStore.IsBlocked(ctx, hash)
+	return blocked
+}
+
 func generateUserToken() string {
 	buf := make([]byte, 10)
 	_, err := rand.Read(buf)
@@ -2633,23 +2857,25 @@ func getKarma(ctx context.Context, hash string) int {
 	if hash == "" {
 		return 0
 	}
-	if karma, ok := karmaStore.Get(hash); ok {
+	if karma, ok, err := modStore.Karma(ctx, hash); err == nil && ok {
 		return karma
 	}
 
 	if dbClient != nil {
 		if karma, err := dbClient.GetKarma(ctx, hash); err == nil {
-			karmaStore.Set(hash, karma)
+			_ = modStore.SetKarma(ctx, hash, karma)
 			return karma
 		}
 	}
 
-	karmaStore.Set(hash, 0)
+	_ = modStore.SetKarma(ctx, hash, 0)
 	return 0
 }
 
 func updateKarma(ctx context.Context, hash string, karma int) {
-	karmaStore.Set(hash, karma)
+	if err := modStore.SetKarma(ctx, hash, karma); err != nil {
+		utils.Log("Error storing karma for hash %s: %v", hash, err)
+	}
 	if dbClient != nil {
 		_ = dbClient.UpsertKarma(ctx, hash, karma)
 	}
@@ -2670,6 +2896,8 @@ func BadgeHandler(c *gin.Context) {
 	switch badge {
 	case "anonymous-friendly.svg":
 		serveAnonymousFriendlyBadge(c)
+	case "maintainer-verified.svg":
+		serveMaintainerVerifiedBadge(c)
 	case "deployed.svg":
 		if isPanicMode() {
 			serveSuspendedBadge(c)
@@ -2736,9 +2964,9 @@ func serveAnonymousFriendlyBadge(c *gin.Context) {
 		if len(parts) == 2 {
 			owner, repoName := parts[0], parts[1]
 			if c.Query("provider") == "gl" {
-				verified = glprovider.New().IsRepoVerified(owner, repoName)
+				verified = glprovider.New().IsRepoVerified(c.Request.Context(), owner, repoName)
 			} else {
-				verified = github.IsRepoVerified(owner, repoName)
+				verified = github.IsRepoVerified(c.Request.Context(), owner, repoName)
 			}
 		}
 	}
@@ -2813,7 +3041,7 @@ func serveDeployedBadge(c *gin.Context) {
 }
 
 var (
-	badgeCache = newBoundedMap[int](badgeCacheMax, 5*time.Minute)
+	badgeCache = newBoundedMap[int]("badge-cache", badgeCacheMax, 5*time.Minute)
 )
 
 func BadgePRCountHandler(c *gin.Context) {
@@ -2890,14 +3118,19 @@ func PRStatusHandler(c *gin.Context) {
 		return
 	}
 
-	topic := github.NtfyTopicForPR(hash)
-	subscribeURL := fmt.Sprintf("%s/%s", github.NtfyBaseURL(), topic)
-
-	c.JSON(http.StatusOK, gin.H{
-		"hash":          hash,
-		"ntfy_topic":    topic,
-		"subscribe_url": subscribeURL,
-	})
+	// The built-in endpoints need the secret printed at push time.
+	resp := gin.H{
+		"hash":       hash,
+		"events_url": fmt.Sprintf("%s/api/pr/%s/events", github.NtfyServiceURL(), hash),
+		"stream_url": fmt.Sprintf("%s/api/pr/%s/events/stream", github.NtfyServiceURL(), hash),
+		"feed_url":   fmt.Sprintf("%s/api/pr/%s/feed.atom", github.NtfyServiceURL(), hash),
+	}
+	if ntfyForward {
+		topic := github.NtfyTopicForPR(hash)
+		resp["ntfy_topic"] = topic
+		resp["subscribe_url"] = fmt.Sprintf("%s/%s", github.NtfyBaseURL(), topic)
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 func PRCheckHandler(c *gin.Context) {
@@ -2909,19 +3142,31 @@ func PRCheckHandler(c *gin.Context) {
 
 	track, ok := getPRTrack(hash)
 	if !ok {
-		c.JSON(http.StatusNotFound, gin.H{
+		resp := gin.H{
 			"hash":    hash,
 			"tracked": false,
 			"error":   "PR not found. This endpoint only tracks PRs created through gitGost.",
-		})
+		}
+		if rs := replyStatus(hash); rs != nil {
+			resp["replies"] = rs
+		}
+		c.JSON(http.StatusNotFound, resp)
 		return
 	}
 
-	prov := providerFromName(track.Provider)
-	status, err := prov.GetMRStatus(track.Owner, track.Repo, track.Number)
+	source := "webhook"
+	status := new(provider.MRStatus)
+	stored, ok := storedPRStatus(c.Request.Context(), hash)
+	var err error
+	if ok {
+		*status = stored
+	} else {
+		source = "poll"
+		status, err = fetchMRStatus(c.Request.Context(), track)
+	}
 	if err != nil {
 		utils.Log("Error fetching MR status for %s/%s#%d: %v", track.Owner, track.Repo, track.Number, err)
-		c.JSON(http.StatusOK, gin.H{
+		resp := gin.H{
 			"hash":      hash,
 			"tracked":   true,
 			"pr_number": track.Number,
@@ -2929,7 +3174,18 @@ func PRCheckHandler(c *gin.Context) {
 			"repo":      track.Repo,
 			"pr_url":    track.PRURL,
 			"error":     "could not fetch status from provider",
-		})
+		}
+		if wait, ok := provider.RetryAfter(err); ok {
+			resp["error"] = "provider rate limit reached"
+			resp["retry_after"] = int(wait.Round(time.Second) / time.Second)
+		} else if errors.Is(err, provider.ErrNotFound) {
+			resp["error"] = "pull request no longer exists on provider"
+			markTrackedPRClosed(c.Request.Context(), track)
+		}
+		if rs := replyStatus(hash); rs != nil {
+			resp["replies"] = rs
+		}
+		c.JSON(http.StatusOK, resp)
 		return
 	}
 
@@ -2950,23 +3206,42 @@ func PRCheckHandler(c *gin.Context) {
 		"title":      status.Title,
 		"comments":   status.Comments,
 		"updated_at": status.UpdatedAt,
+		"source":     source,
 	}
 
 	if events != nil {
 		response["events"] = events
 	}
+	if rs := replyStatus(hash); rs != nil {
+		response["replies"] = rs
+	}
+	if prStateClosed(status.State) {
+		markTrackedPRClosed(c.Request.Context(), track)
+	} else if prStateOpen(status.State) {
+		if err := modStore.ReopenPR(c.Request.Context(), track.PRURL); err != nil {
+			utils.Log("Error marking PR %s reopened: %v", track.PRURL, err)
+		}
+	}
 
 	if status.ETag != "" && status.ETag != track.LastETag {
-		prTrackMu.Lock()
-		if t, ok := prTrackStore[hash]; ok {
-			t.LastETag = status.ETag
-		}
-		prTrackMu.Unlock()
+		prTrackStore.Update(hash, func(t prTrack, ok bool) prTrack {
+			if ok {
+				t.LastETag = status.ETag
+			}
+			return t
+		})
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+// markTrackedPRClosed starts the retention clock of a PR seen closed.
+func markTrackedPRClosed(ctx context.Context, t prTrack) {
+	if err := modStore.MarkPRClosed(ctx, t.PRURL, time.Now()); err != nil {
+		utils.Log("Error marking PR %s closed: %v", t.PRURL, err)
+	}
+}
+
 func VerifyHandler(c *gin.Context) {
 	shortHash := commitHash
 	if len(shortHash) > 7 {
@@ -3268,10 +3543,10 @@ func GitLabProxyHandler(c *gin.Context) {
 }
 
 type ghProxyCacheEntry struct {
-	body        []byte
-	contentType string
-	etag        string
-	cachedAt    time.Time
+	Body        []byte    `json:"body"`
+	ContentType string    `json:"content_type"`
+	ETag        string    `json:"etag"`
+	CachedAt    time.Time `json:"cached_at"`
 }
 
 const (
@@ -3279,33 +3554,7 @@ const (
 	ghProxyCacheMaxBody    = 2 << 20
 )
 
-var (
-	ghProxyCacheMu sync.Mutex
-	ghProxyCache   = map[string]ghProxyCacheEntry{}
-)
-
-func ghProxyCacheGet(key string) (ghProxyCacheEntry, bool) {
-	ghProxyCacheMu.Lock()
-	defer ghProxyCacheMu.Unlock()
-	e, ok := ghProxyCache[key]
-	return e, ok
-}
-
-func ghProxyCachePut(key string, e ghProxyCacheEntry) {
-	ghProxyCacheMu.Lock()
-	defer ghProxyCacheMu.Unlock()
-	if len(ghProxyCache) >= ghProxyCacheMaxEntries {
-		var oldestKey string
-		var oldest time.Time
-		for k, v := range ghProxyCache {
-			if oldestKey == "" || v.cachedAt.Before(oldest) {
-				oldestKey, oldest = k, v.cachedAt
-			}
-		}
-		delete(ghProxyCache, oldestKey)
-	}
-	ghProxyCache[key] = e
-}
+var ghProxyCache = newBoundedMap[ghProxyCacheEntry]("gh-proxy-cache", ghProxyCacheMaxEntries, 0)
 
 // GitHubAPIProxyHandler keeps browser requests to api.github.com on the server.
 // Successful GET responses are cached and revalidated with If-None-Match so
@@ -3328,7 +3577,7 @@ func GitHubAPIProxyHandler(c *gin.Context) {
 		sum := sha256.Sum256([]byte(fallbackToken))
 		cacheKey += "|" + hex.EncodeToString(sum[:8])
 	}
-	entry, hasEntry := ghProxyCacheGet(cacheKey)
+	entry, hasEntry := ghProxyCache.Get(cacheKey)
 	resp, err := doGitHubWithTokenRotation(&http.Client{Timeout: 15 * time.Second}, func(token string) (*http.Request, error) {
 		req, requestErr := http.NewRequestWithContext(c.Request.Context(), c.Request.Method, target, nil)
 		if requestErr != nil {
@@ -3337,8 +3586,8 @@ func GitHubAPIProxyHandler(c *gin.Context) {
 		if token != "" {
 			req.Header.Set("Authorization", "token "+token)
 		}
-		if hasEntry && entry.etag != "" {
-			req.Header.Set("If-None-Match", entry.etag)
+		if hasEntry && entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
 		}
 		req.Header.Set("Accept", c.GetHeader("Accept"))
 		req.Header.Set("User-Agent", "gitGost")
@@ -3354,22 +3603,22 @@ func GitHubAPIProxyHandler(c *gin.Context) {
 	if hasEntry && (resp.StatusCode == http.StatusNotModified ||
 		resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests) {
 		if resp.StatusCode != http.StatusNotModified {
-			utils.Log("GitHub API proxy rate-limited for %s; serving cached copy from %s", path, entry.cachedAt.Format(time.RFC3339))
+			utils.Log("GitHub API proxy rate-limited for %s; serving cached copy from %s", path, entry.CachedAt.Format(time.RFC3339))
 		}
-		c.Writer.Header().Set("Content-Type", entry.contentType)
+		c.Writer.Header().Set("Content-Type", entry.ContentType)
 		c.Writer.Header().Set("X-GitGost-Cache", "hit")
 		c.Writer.WriteHeader(http.StatusOK)
-		c.Writer.Write(entry.body)
+		c.Writer.Write(entry.Body)
 		return
 	}
 
 	body, readErr := io.ReadAll(io.LimitReader(resp.Body, ghProxyCacheMaxBody+1))
 	if readErr == nil && resp.StatusCode == http.StatusOK && len(body) <= ghProxyCacheMaxBody {
-		ghProxyCachePut(cacheKey, ghProxyCacheEntry{
-			body:        body,
-			contentType: resp.Header.Get("Content-Type"),
-			etag:        resp.Header.Get("ETag"),
-			cachedAt:    time.Now(),
+		ghProxyCache.Set(cacheKey, ghProxyCacheEntry{
+			Body:        body,
+			ContentType: resp.Header.Get("Content-Type"),
+			ETag:        resp.Header.Get("ETag"),
+			CachedAt:    time.Now(),
 		})
 	}
 
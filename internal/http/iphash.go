@@ -0,0 +1,95 @@
+package http
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/utils"
+)
+
+// Rate limiters, report dedup and burst detection never see a raw IP: they
+// key on an HMAC of it under a random key that is replaced every UTC day and
+// only ever held in memory. Instances sharing a state service get the day's
+// key from its memory, so the same IP maps to the same value on all of them;
+// across days there is nothing linking the two.
+const ipKeyNamespace = "ip-key"
+
+var (
+	ipKeyMu  sync.Mutex
+	ipKey    []byte
+	ipKeyDay string
+	// ipKeyShared is false while a process-local key stands in for the
+	// shared one, which is retried after ipKeyRetry.
+	ipKeyShared bool
+	ipKeyAt     time.Time
+	ipKeyNow    = time.Now
+	// ipKeyTTL keeps a day's key around a little past midnight.
+	ipKeyTTL   = 26 * time.Hour
+	ipKeyRetry = time.Minute
+)
+
+func currentIPKey() []byte {
+	now := ipKeyNow()
+	day := now.UTC().Format("2006-01-02")
+	ipKeyMu.Lock()
+	if ipKey != nil && ipKeyDay == day && (ipKeyShared || now.Sub(ipKeyAt) < ipKeyRetry) {
+		key := ipKey
+		ipKeyMu.Unlock()
+		return key
+	}
+	ipKeyMu.Unlock()
+
+	fresh := func() []byte {
+		b := make([]byte, 32)
+		rand.Read(b) // crypto/rand.Read never fails
+		return b
+	}
+	// The first instance to need today's key picks it; the rest adopt it.
+	// The state service is asked without holding ipKeyMu, so a slow one
+	// doesn't stall every request that hashes an IP.
+	b, ok, err := sharedState.Update(context.Background(), ipKeyNamespace, day, ipKeyTTL, func(old []byte, ok bool) ([]byte, bool) {
+		if ok {
+			return old, false
+		}
+		return fresh(), true
+	})
+
+	ipKeyMu.Lock()
+	defer ipKeyMu.Unlock()
+	if err != nil || !ok || len(b) == 0 {
+		utils.Log("Error loading the shared IP key: %v", err)
+		// A random key of this process's own, never one derived from
+		// anything stored, until the shared one can be read again.
+		if ipKey == nil || ipKeyDay != day {
+			ipKey = fresh()
+		}
+		ipKeyDay, ipKeyShared, ipKeyAt = day, false, now
+		return ipKey
+	}
+	ipKey, ipKeyDay, ipKeyShared, ipKeyAt = b, day, true, now
+	return ipKey
+}
+
+// anonIP returns the HMAC of ip under today's key. Empty input stays empty so
+// callers can keep treating "" as "unknown client".
+func anonIP(ip string) string {
+	ip = strings.TrimSpace(ip)
+	if ip == "" {
+		return ""
+	}
+	h := hmac.New(sha256.New, currentIPKey())
+	h.Write([]byte(ip))
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// clientKey is the identifier IP-keyed stores use for the requesting client.
+func clientKey(c *gin.Context) string {
+	return anonIP(c.ClientIP())
+}
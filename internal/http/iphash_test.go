@@ -0,0 +1,83 @@
+package http
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/livrasand/gitGost/internal/state"
+)
+
+// failingState is a state backend that can't be reached.
+type failingState struct{ state.Store }
+
+func (failingState) Update(context.Context, string, string, time.Duration, state.UpdateFunc) ([]byte, bool, error) {
+	return nil, false, errors.New("unreachable")
+}
+
+func TestAnonIPRotatesDaily(t *testing.T) {
+	day := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	ipKeyNow = func() time.Time { return day }
+	t.Cleanup(func() { ipKeyNow = time.Now })
+
+	a := anonIP("203.0.113.7")
+	if a == "" || a == "203.0.113.7" {
+		t.Fatalf("anonIP = %q", a)
+	}
+	if anonIP(" 203.0.113.7 ") != a {
+		t.Error("same IP hashed differently within a day")
+	}
+	if anonIP("203.0.113.8") == a {
+		t.Error("different IPs collided")
+	}
+	if anonIP("") != "" {
+		t.Error("empty IP should stay empty")
+	}
+
+	day = day.Add(15 * time.Hour)
+	if anonIP("203.0.113.7") == a {
+		t.Error("key did not rotate at the day boundary")
+	}
+}
+
+func TestReportRateLimitUsesHashedIP(t *testing.T) {
+	key := anonIP("198.51.100.1")
+	for i := 0; i < reportRateLimitMax; i++ {
+		if checkReportRateLimit(key) {
+			t.Fatalf("limited after %d reports", i+1)
+		}
+	}
+	if !checkReportRateLimit(key) {
+		t.Error("limit not enforced for hashed key")
+	}
+	if _, ok := reportRateLimitStore.Get("198.51.100.1"); ok {
+		t.Error("raw IP stored in limiter")
+	}
+}
+
+func TestAnonIPFallbackIsRandom(t *testing.T) {
+	useState(t, failingState{})
+	ipKeyMu.Lock()
+	ipKey, ipKeyDay = nil, ""
+	ipKeyMu.Unlock()
+	t.Cleanup(func() {
+		ipKeyMu.Lock()
+		ipKey, ipKeyDay = nil, ""
+		ipKeyMu.Unlock()
+	})
+
+	a := anonIP("203.0.113.7")
+	if anonIP("203.0.113.7") != a {
+		t.Error("fallback key changed between calls")
+	}
+	// Nothing persisted may reproduce the fallback key.
+	mac := hmac.New(sha256.New, append([]byte(time.Now().UTC().Format("2006-01-02")), getSecretKey()...))
+	mac.Write([]byte("203.0.113.7"))
+	if a == hex.EncodeToString(mac.Sum(nil))[:32] {
+		t.Error("fallback key derived from the moderation secret")
+	}
+}
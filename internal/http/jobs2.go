@@ -15,7 +15,6 @@ import (
 	"os"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/livrasand/gitGost/internal/git"
@@ -38,6 +37,9 @@ const (
 	remoteJobMaxConcurrent = 3
 	// remoteJobTimeout acota la duración total de un job remoto (clon + bundle).
 	remoteJobTimeout = 30 * time.Minute
+	// remoteJobCancelPoll es cada cuánto el worker mira si un DELETE, atendido
+	// quizá por otra instancia, canceló su job.
+	remoteJobCancelPoll = 2 * time.Second
 	// maxBundleSize rechaza bundles que Openbin no podría aceptar (4 GiB).
 	maxBundleSize = 4 * 1024 * 1024 * 1024
 )
@@ -80,16 +82,12 @@ type remoteJob struct {
 
 // remoteJobs es la cola de trabajos remotos en memoria (TTL: los resultados
 // dejan de estar disponibles pasadas 24 h).
-var remoteJobs = newBoundedMap[*remoteJob](remoteJobsMax, remoteJobsTTL)
+var remoteJobs = newBoundedMap[*remoteJob]("remote-jobs", remoteJobsMax, remoteJobsTTL)
 
 // remoteJobSlots es el semáforo de workers: solo remoteJobMaxConcurrent jobs
 // pueden ejecutarse a la vez; el resto recibe 429 desde el handler.
 var remoteJobSlots = make(chan struct{}, remoteJobMaxConcurrent)
 
-// remoteJobCancels registra el context.CancelFunc de cada worker en curso
-// (clave: ID del job) para que DELETE pueda cancelar la operación en marcha.
-var remoteJobCancels sync.Map
-
 // openbinClient permite subir bundles grandes sin el timeout corto del proxy.
 var openbinClient = &http.Client{Timeout: 30 * time.Minute}
 
@@ -140,25 +138,41 @@ func GetRemoteJobHandler(c *gin.Context) {
 	})
 }
 
-// DeleteRemoteJobHandler marca un trabajo como cancelado. No borra TmpDir: si
-// el worker aún corre, su defer os.RemoveAll(dir) limpia el directorio al
-// terminar; el estado cancelado queda hasta que el TTL lo evicte.
+// DeleteRemoteJobHandler marca un trabajo como cancelado en el estado
+// compartido. El worker, corra en esta instancia o en otra, lo ve en menos de
+// remoteJobCancelPoll y cancela su contexto. No borra TmpDir: el defer
+// os.RemoveAll(dir) del worker limpia el directorio al terminar; el estado
+// cancelado queda hasta que el TTL lo evicte.
 func DeleteRemoteJobHandler(c *gin.Context) {
 	id := c.Param("id")
-	if job, ok := remoteJobs.Get(id); ok && job.Status != rjCancelled {
+	remoteJobs.Update(id, func(job *remoteJob, ok bool) *remoteJob {
+		if !ok || job == nil || job.Status == rjCancelled {
+			return job
+		}
 		next := *job
 		next.Status = rjCancelled
 		next.Progress = "Cancelado"
-		remoteJobs.Set(id, &next)
-	}
-	// Cancelar el worker en curso si aún corre; LoadAndDelete lo retira del
-	// registro para que la cancelación ocurra una sola vez.
-	if v, ok := remoteJobCancels.LoadAndDelete(id); ok {
-		if cancel, ok := v.(context.CancelFunc); ok {
-			cancel()
+		return &next
+	})
+	c.Status(http.StatusNoContent)
+}
+
+// watchJobCancel cancela el contexto del worker en cuanto el job aparece
+// cancelado en el estado compartido. Termina con el worker.
+func watchJobCancel(ctx context.Context, id string, cancel context.CancelFunc) {
+	t := time.NewTicker(remoteJobCancelPoll)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if jobCancelled(id) {
+				cancel()
+				return
+			}
 		}
 	}
-	c.Status(http.StatusNoContent)
 }
 
 // jobCancelled indica si un job fue marcado como cancelado por DELETE.
@@ -174,9 +188,7 @@ func runRemoteJob(job *remoteJob) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), remoteJobTimeout)
 	defer cancel()
-	// Exponer el cancel a DELETE; se retira al salir del worker.
-	remoteJobCancels.Store(job.ID, cancel)
-	defer remoteJobCancels.Delete(job.ID)
+	go watchJobCancel(ctx, job.ID, cancel)
 
 	// DELETE pudo cancelar el job antes de que este worker se registrara: no
 	// iniciar trabajo pesado ni publicar estados si ya está cancelado.
@@ -185,15 +197,22 @@ func runRemoteJob(job *remoteJob) {
 	}
 
 	// dir se captura por referencia: cada publish propaga el TmpDir real.
+	// Un job ya cancelado no se sobrescribe, aunque el DELETE llegue entre
+	// dos comprobaciones.
 	dir := ""
 	publish := func(status, progress, errMsg string, result *remoteJobResult) {
-		next := *job
-		next.Status = status
-		next.Progress = progress
-		next.Error = errMsg
-		next.Result = result
-		next.TmpDir = dir
-		remoteJobs.Set(job.ID, &next)
+		remoteJobs.Update(job.ID, func(cur *remoteJob, ok bool) *remoteJob {
+			if ok && cur != nil && cur.Status == rjCancelled {
+				return cur
+			}
+			next := *job
+			next.Status = status
+			next.Progress = progress
+			next.Error = errMsg
+			next.Result = result
+			next.TmpDir = dir
+			return &next
+		})
 	}
 	publish(rjRunning, "Preparando descarga...", "", nil)
 
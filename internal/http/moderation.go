@@ -0,0 +1,117 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/moderation"
+	"github.com/livrasand/gitGost/internal/utils"
+)
+
+const (
+	// secretKeysKept is how many HMAC keys survive a rotation. Older keys
+	// still recognize hashes and appeal tokens issued under them.
+	secretKeysKept = 3
+	// secretKeyRefresh bounds how long another instance's rotation takes to
+	// reach this one.
+	secretKeyRefresh = time.Minute
+)
+
+var (
+	// modPersistent is set once moderation state lives in a database that
+	// survives restarts.
+	modPersistent bool
+
+	secretKeysMu      sync.Mutex
+	secretKeysCache   [][]byte
+	secretKeysFetched time.Time
+)
+
+// InitModeration moves moderation state (bans, flags, karma, reports,
+// appeals, the HMAC keys and the token signing keys) into a SQLite database at path. With an empty
+// path everything stays in memory and is lost on restart.
+func InitModeration(path string) error {
+	if path == "" {
+		return nil
+	}
+	store, err := moderation.OpenSQLite(path)
+	if err != nil {
+		return err
+	}
+	setModerationStore(store)
+	modPersistent = true
+	return nil
+}
+
+func setModerationStore(store moderation.Store) {
+	modStore = store
+	modPersistent = false
+	secretKeysMu.Lock()
+	secretKeysCache = nil
+	secretKeysMu.Unlock()
+	tokenKeysMu.Lock()
+	tokenKeysCache = nil
+	tokenKeysMu.Unlock()
+}
+
+// getSecretKeys returns the HMAC keys, newest first. If the store can't be
+// read the last known keys are used; with none, a process-local key.
+func getSecretKeys() [][]byte {
+	secretKeysMu.Lock()
+	defer secretKeysMu.Unlock()
+	if secretKeysCache != nil && time.Since(secretKeysFetched) < secretKeyRefresh {
+		return secretKeysCache
+	}
+	keys, err := modStore.SecretKeys(context.Background())
+	if err != nil || len(keys) == 0 {
+		utils.Log("Error loading secret keys: %v", err)
+		if secretKeysCache == nil {
+			b := make([]byte, 32)
+			if _, err := rand.Read(b); err != nil {
+				b = []byte(time.Now().String())
+			}
+			secretKeysCache = [][]byte{b}
+		}
+		secretKeysFetched = time.Now()
+		return secretKeysCache
+	}
+	out := make([][]byte, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, k.Key)
+	}
+	secretKeysCache, secretKeysFetched = out, time.Now()
+	return secretKeysCache
+}
+
+// getSecretKey returns the key new hashes and tokens are derived with.
+func getSecretKey() []byte {
+	return getSecretKeys()[0]
+}
+
+// AdminRotateSecretKeyHandler starts deriving new hashes under a fresh key.
+// Existing hashes keep working for the next secretKeysKept-1 rotations.
+func AdminRotateSecretKeyHandler(c *gin.Context) {
+	who, ok := requireAdmin(c, roleOperator, "")
+	if !ok {
+		return
+	}
+	if !audit(c, who, "rotate_key", "", adminReason(c, ""), fmt.Sprintf("keeping %d", secretKeysKept)) {
+		return
+	}
+	k, err := modStore.RotateSecretKey(c.Request.Context(), secretKeysKept)
+	if err != nil {
+		utils.Log("Error rotating secret key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "rotation failed"})
+		return
+	}
+	secretKeysMu.Lock()
+	secretKeysCache = nil
+	secretKeysMu.Unlock()
+	utils.Log("Secret key rotated (id %d) by %s", k.ID, who.Name)
+	c.JSON(http.StatusOK, gin.H{"key_id": k.ID, "kept": secretKeysKept})
+}
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/livrasand/gitGost/internal/moderation"
+)
+
+func useModerationStore(t *testing.T, path string) {
+	t.Helper()
+	old := modStore
+	if err := InitModeration(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		modStore.Close()
+		setModerationStore(old)
+	})
+}
+
+func TestModerationSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "moderation.db")
+	useModerationStore(t, path)
+
+	hash := deriveHash("o", "r", 7, "TOKEN")
+	appealToken := generateAppealToken(hash)
+	setBlockedHash(hash)
+	modStore.Close()
+
+	// A redeploy opens the same file with nothing cached.
+	if err := InitModeration(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := deriveHash("o", "r", 7, "TOKEN"); got != hash {
+		t.Fatalf("hash changed across restart: %s != %s", got, hash)
+	}
+	if !isBlockedHash(hash) {
+		t.Error("ban lost across restart")
+	}
+	if !verifyAppealToken(hash, appealToken) {
+		t.Error("appeal token lost across restart")
+	}
+}
+
+func TestSecretKeyRotationKeepsIdentities(t *testing.T) {
+	old := modStore
+	setModerationStore(moderation.NewMemory())
+	t.Cleanup(func() { setModerationStore(old) })
+
+	known := deriveHash("o", "r", 1, "KNOWN")
+	updateKarma(context.Background(), known, 3)
+	appealToken := generateAppealToken(known)
+	fresh := deriveHash("o", "r", 1, "FRESH")
+
+	if _, err := modStore.RotateSecretKey(context.Background(), secretKeysKept); err != nil {
+		t.Fatal(err)
+	}
+	setModerationStore(modStore)
+
+	if got := deriveHash("o", "r", 1, "KNOWN"); got != known {
+		t.Errorf("hash with history changed after rotation: %s != %s", got, known)
+	}
+	if got := deriveHash("o", "r", 1, "FRESH"); got == fresh {
+		t.Error("hash without history still derived from the retired key")
+	}
+	if !verifyAppealToken(known, appealToken) {
+		t.Error("appeal token from the previous key rejected")
+	}
+}
@@ -0,0 +1,527 @@
+package http
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/github"
+	"github.com/livrasand/gitGost/internal/moderation"
+	"github.com/livrasand/gitGost/internal/prmsg"
+	"github.com/livrasand/gitGost/internal/utils"
+)
+
+// Every PR event is served to the PR's subscribers as a Server-Sent Events
+// stream, a long-poll and an Atom feed. Subscribing takes the secret printed
+// once at push time, sent in a header; the hash alone is not enough.
+// EventSource and feed readers can't set headers, so the secret buys them
+// URL tokens that expire after prURLTokenTTL instead.
+const (
+	subscriptionHeader = "X-Gitgost-Subscription"
+	// prURLTokenTTL is how long a stream or feed URL keeps working.
+	prURLTokenTTL = 24 * time.Hour
+
+	// prEventPoll is how often waiting subscribers look for events that
+	// another instance recorded.
+	prEventPoll = 2 * time.Second
+	// prStreamHeartbeat keeps idle streams from being cut by proxies.
+	prStreamHeartbeat = 25 * time.Second
+	prPollDefaultWait = 30 * time.Second
+	prPollMaxWait     = 60 * time.Second
+	prFeedEntries     = 50
+)
+
+// PR event types gitGost records itself.
+const (
+	prEventOpened         = "opened"
+	prEventUpdated        = "updated"
+	prEventReportedClosed = "closed_after_reports"
+)
+
+var (
+	// ntfyForward also publishes every PR event to the PR's ntfy topic.
+	ntfyForward = true
+
+	prEventsMu sync.Mutex
+	// prEventsWake is closed and replaced whenever this instance records a
+	// PR event.
+	prEventsWake = make(chan struct{})
+)
+
+// InitNotifications turns forwarding of PR events to ntfy on or off. The
+// built-in endpoints are always served.
+func InitNotifications(forwardToNtfy bool) {
+	ntfyForward = forwardToNtfy
+}
+
+// openSubscription gives the PR a subscription and returns its secret, or ""
+// when the PR already has one. Notifications are encrypted to clientKey, the
+// public half of a key the author holds; without one a key pair is made here,
+// only its public half is kept, and the private half is returned as key.
+func openSubscription(ctx context.Context, prHash, clientKey string) (secret, key string) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		utils.Log("Error creating subscription secret for %s: %v", prHash, err)
+		return "", ""
+	}
+	secret = hex.EncodeToString(b)
+	sub := moderation.Subscription{TokenHash: prmsg.TokenHash(secret), NotifyKey: clientKey}
+	if clientKey == "" {
+		var err error
+		if key, sub.NotifyKey, err = prmsg.NewSecret(); err != nil {
+			utils.Log("Error creating notification key for %s: %v", prHash, err)
+			return "", ""
+		}
+	}
+	created, err := modStore.SetPRSubscription(ctx, prHash, sub)
+	if err != nil {
+		utils.Log("Error storing subscription for %s: %v", prHash, err)
+		return "", ""
+	}
+	if !created {
+		return "", ""
+	}
+	return secret, key
+}
+
+// publishPREvent records e for the PR's subscribers, wakes the ones waiting
+// on this instance and forwards it to ntfy when that is on.
+func publishPREvent(ctx context.Context, pr moderation.PR, e moderation.PREvent) error {
+	e.Hash = pr.Hash
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	if err := modStore.AddPREvent(ctx, e); err != nil {
+		return err
+	}
+	prEventsMu.Lock()
+	close(prEventsWake)
+	prEventsWake = make(chan struct{})
+	prEventsMu.Unlock()
+	if ntfyForward {
+		go forwardToNtfy(pr, e)
+	}
+	return nil
+}
+
+// forwardToNtfy publishes e to the PR's public ntfy topic. When the PR has a
+// notification key the relay only gets ciphertext, with nothing in the title
+// or actions that names the PR.
+func forwardToNtfy(pr moderation.PR, e moderation.PREvent) {
+	if title, _ := prEventText(e, pr.URL); title == "" {
+		return
+	}
+	n := newPRNotification(e, pr.URL, prNotifyKey(context.Background(), pr.Hash))
+	if n.Title == "" && n.Sealed == "" {
+		return
+	}
+	title, msg := n.Title+" · gitGost", n.Message
+	actionBtn := fmt.Sprintf("http, Check Status, %s/api/pr/%s/status, clear=true, method=GET", github.NtfyServiceURL(), pr.Hash)
+	if n.Sealed != "" {
+		title, msg, actionBtn = "Encrypted notification · gitGost", n.Sealed, ""
+	}
+	if err := github.PublishNtfyEvent(pr.Hash, title, msg, actionBtn); err != nil {
+		utils.Log("ntfy publish error for hash %s: %v", pr.Hash, err)
+	}
+}
+
+// prNotifyKey is the key the PR's notifications are encrypted to, or "" for
+// PRs subscribed before notifications were encrypted.
+func prNotifyKey(ctx context.Context, hash string) string {
+	sub, _, err := modStore.PRSubscription(ctx, hash)
+	if err != nil {
+		utils.Log("Error reading subscription of %s: %v", hash, err)
+	}
+	return sub.NotifyKey
+}
+
+// prEventText is the title and message telling the contributor about e, or
+// "" for events they aren't told about.
+func prEventText(e moderation.PREvent, prURL string) (string, string) {
+	who := e.Author
+	if who == "" {
+		who = "Someone"
+	}
+	var title, what string
+	switch e.Type {
+	case prEventOpened:
+		return "PR Created", fmt.Sprintf("Your anonymous PR was created.\nPR: %s", prURL)
+	case prEventUpdated:
+		return "PR Updated", fmt.Sprintf("Your anonymous PR was updated.\nPR: %s", prURL)
+	case prEventReportedClosed:
+		return "PR Closed", fmt.Sprintf("Your anonymous PR was closed after community reports.\nPR: %s\nFurther pushes with this pr-hash are rejected.", prURL)
+	case prEventCommented:
+		title, what = "New Comment", "commented on"
+	case prEventApproved:
+		title, what = "PR Approved", "approved"
+	case prEventChangesRequested:
+		title, what = "Changes Requested", "requested changes on"
+	case prEventReviewed:
+		title, what = "New Review", "reviewed"
+	case prEventMerged:
+		title, what = "PR Merged", "merged"
+	case prEventClosed:
+		title, what = "PR Closed", "closed"
+	case prEventReopened:
+		title, what = "PR Reopened", "reopened"
+	default:
+		return "", ""
+	}
+	return title, fmt.Sprintf("%s %s your anonymous PR.\nPR: %s", who, what, prURL)
+}
+
+// prNotification is a PR event as subscribers receive it. With a
+// notification key, only ID and CreatedAt stay readable: the rest is sealed
+// into Sealed, which decrypts to the same JSON object.
+type prNotification struct {
+	ID        int64     `json:"id,omitempty"`
+	Type      string    `json:"type,omitempty"`
+	Title     string    `json:"title,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Author    string    `json:"author,omitempty"`
+	Body      string    `json:"body,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Sealed    string    `json:"sealed,omitempty"`
+}
+
+// prNotifyBodyMax keeps a sealed notification within one ntfy message.
+const prNotifyBodyMax = 2000
+
+func newPRNotification(e moderation.PREvent, prURL, key string) prNotification {
+	title, msg := prEventText(e, prURL)
+	if title == "" {
+		title = e.Type
+	}
+	n := prNotification{
+		Type: e.Type, Title: title, Message: msg,
+		Author: e.Author, Body: e.Body, URL: prURL, CreatedAt: e.CreatedAt,
+	}
+	if key == "" {
+		n.ID = e.ID
+		return n
+	}
+	if len(n.Body) > prNotifyBodyMax {
+		n.Body = strings.ToValidUTF8(n.Body[:prNotifyBodyMax], "") + "…"
+	}
+	plain, err := json.Marshal(n)
+	if err == nil {
+		n.Sealed, err = prmsg.SealNotification(key, e.Hash, plain)
+	}
+	if err != nil {
+		// Never fall back to plaintext.
+		utils.Log("Error sealing notification for %s: %v", e.Hash, err)
+	}
+	return prNotification{ID: e.ID, CreatedAt: e.CreatedAt, Sealed: n.Sealed}
+}
+
+// prURLToken signs access to the PR's stream and feed until exp. It is bound
+// to the subscription secret's hash, so a new secret voids old URLs.
+func prURLToken(key []byte, hash, tokenHash string, exp int64) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("gitgost subscription url\x00" + hash + "\x00" + strconv.FormatInt(exp, 10) + "\x00" + tokenHash))
+	return strconv.FormatInt(exp, 10) + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// newPRURLToken returns a URL token for the PR and when it expires.
+func newPRURLToken(hash string, sub moderation.Subscription, now time.Time) (string, time.Time) {
+	exp := now.Add(prURLTokenTTL).Truncate(time.Second)
+	return prURLToken(getSecretKeys()[0], hash, sub.TokenHash, exp.Unix()), exp
+}
+
+// prURLTokenValid reports whether token was issued for the PR under any kept
+// instance key and has not expired.
+func prURLTokenValid(hash string, sub moderation.Subscription, token string, now time.Time) bool {
+	expStr, _, ok := strings.Cut(token, ".")
+	exp, err := strconv.ParseInt(expStr, 10, 64)
+	if !ok || err != nil || now.Unix() >= exp {
+		return false
+	}
+	for _, k := range getSecretKeys() {
+		if hmac.Equal([]byte(token), []byte(prURLToken(k, hash, sub.TokenHash, exp))) {
+			return true
+		}
+	}
+	return false
+}
+
+// prSubscriberURLs are the PR's stream and feed URLs carrying token.
+func prSubscriberURLs(hash, token string) (stream, feed string) {
+	q := "?token=" + url.QueryEscape(token)
+	return fmt.Sprintf("%s/api/pr/%s/events/stream%s", github.NtfyServiceURL(), hash, q),
+		fmt.Sprintf("%s/api/pr/%s/feed.atom%s", github.NtfyServiceURL(), hash, q)
+}
+
+// prSubscriber checks the subscription secret sent for the PR in the path
+// and returns the PR's hash and subscription. With urlToken, a ?token= from
+// PRSubscriptionTokenHandler stands in for the secret.
+func prSubscriber(c *gin.Context, urlToken bool) (string, moderation.Subscription, bool) {
+	hash := strings.ToLower(strings.TrimSpace(c.Param("hash")))
+	secret := c.GetHeader(subscriptionHeader)
+	var token string
+	if secret == "" && urlToken {
+		token = c.Query("token")
+	}
+	if secret == "" && token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"hash": hash, "error": "subscription secret required in " + subscriptionHeader})
+		return "", moderation.Subscription{}, false
+	}
+	sub, ok, err := modStore.PRSubscription(c.Request.Context(), hash)
+	if err != nil {
+		utils.Log("Error reading subscription of %s: %v", hash, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not check subscription"})
+		return "", moderation.Subscription{}, false
+	}
+	if secret == "" {
+		if !ok || !prURLTokenValid(hash, sub, token, time.Now()) {
+			c.JSON(http.StatusForbidden, gin.H{"hash": hash, "error": "invalid or expired subscription token"})
+			return "", moderation.Subscription{}, false
+		}
+		return hash, sub, true
+	}
+	if !ok || !prmsg.TokenMatches(secret, sub.TokenHash) {
+		c.JSON(http.StatusForbidden, gin.H{"hash": hash, "error": "wrong subscription secret"})
+		return "", moderation.Subscription{}, false
+	}
+	return hash, sub, true
+}
+
+// PRSubscriptionTokenHandler trades the subscription secret for stream and
+// feed URLs that work without headers until they expire.
+func PRSubscriptionTokenHandler(c *gin.Context) {
+	hash, sub, ok := prSubscriber(c, false)
+	if !ok {
+		return
+	}
+	token, exp := newPRURLToken(hash, sub, time.Now())
+	stream, feed := prSubscriberURLs(hash, token)
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"hash":       hash,
+		"token":      token,
+		"expires_at": exp.UTC().Format(time.RFC3339),
+		"stream_url": stream,
+		"feed_url":   feed,
+	})
+}
+
+// waitPREvents returns the PR's events after afterID, oldest first, waiting
+// up to wait for the first one.
+func waitPREvents(ctx context.Context, hash string, afterID int64, wait time.Duration) ([]moderation.PREvent, error) {
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	poll := time.NewTicker(prEventPoll)
+	defer poll.Stop()
+	for {
+		// Take the wake channel before reading, so an event recorded in
+		// between still wakes us.
+		prEventsMu.Lock()
+		wake := prEventsWake
+		prEventsMu.Unlock()
+
+		events, err := modStore.PREvents(ctx, moderation.PREventQuery{Hash: hash, AfterID: afterID})
+		if err != nil {
+			return nil, err
+		}
+		if len(events) > 0 {
+			for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+				events[i], events[j] = events[j], events[i]
+			}
+			return events, nil
+		}
+		select {
+		case <-wake:
+		case <-poll.C:
+		case <-deadline.C:
+			return nil, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func prURLForHash(hash string) string {
+	if t, ok := getPRTrack(hash); ok {
+		return t.PRURL
+	}
+	return ""
+}
+
+// PREventsHandler long-polls for the PR's events after ?after=<id>, waiting
+// up to ?wait=<seconds>.
+func PREventsHandler(c *gin.Context) {
+	hash, sub, ok := prSubscriber(c, false)
+	if !ok {
+		return
+	}
+	after, _ := strconv.ParseInt(c.Query("after"), 10, 64)
+	wait := prPollDefaultWait
+	if s := c.Query("wait"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "wait must be a number of seconds"})
+			return
+		}
+		wait = time.Duration(n) * time.Second
+		if wait > prPollMaxWait {
+			wait = prPollMaxWait
+		}
+	}
+
+	events, err := waitPREvents(c.Request.Context(), hash, after, wait)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			utils.Log("Error listing events for %s: %v", hash, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not list events"})
+		}
+		return
+	}
+	prURL := prURLForHash(hash)
+	out := make([]prNotification, 0, len(events))
+	for _, e := range events {
+		out = append(out, newPRNotification(e, prURL, sub.NotifyKey))
+		after = e.ID
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"hash": hash, "events": out, "last_id": after})
+}
+
+// PREventStreamHandler streams the PR's events as Server-Sent Events,
+// starting after Last-Event-ID (or ?after=<id>) so reconnecting clients miss
+// nothing.
+func PREventStreamHandler(c *gin.Context) {
+	hash, sub, ok := prSubscriber(c, true)
+	if !ok {
+		return
+	}
+	last := c.GetHeader("Last-Event-ID")
+	if last == "" {
+		last = c.Query("after")
+	}
+	after, _ := strconv.ParseInt(last, 10, 64)
+	prURL := prURLForHash(hash)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	fmt.Fprint(c.Writer, "retry: 5000\n\n")
+	c.Writer.Flush()
+
+	ctx := c.Request.Context()
+	for {
+		events, err := waitPREvents(ctx, hash, after, prStreamHeartbeat)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				utils.Log("Error streaming events for %s: %v", hash, err)
+			}
+			return
+		}
+		if len(events) == 0 {
+			fmt.Fprint(c.Writer, ": keepalive\n\n")
+		}
+		for _, e := range events {
+			data, err := json.Marshal(newPRNotification(e, prURL, sub.NotifyKey))
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(c.Writer, "id: %d\ndata: %s\n\n", e.ID, data)
+			after = e.ID
+		}
+		c.Writer.Flush()
+	}
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string    `xml:"id"`
+	Title   string    `xml:"title"`
+	Updated string    `xml:"updated"`
+	Link    *atomLink `xml:"link,omitempty"`
+	Content string    `xml:"content"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  string      `xml:"author>name"`
+	Link    *atomLink   `xml:"link,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// PRFeedHandler serves the PR's latest events as an Atom feed. Entries of
+// PRs with a notification key carry the sealed text and no links.
+func PRFeedHandler(c *gin.Context) {
+	hash, sub, ok := prSubscriber(c, true)
+	if !ok {
+		return
+	}
+	events, err := modStore.PREvents(c.Request.Context(), moderation.PREventQuery{Hash: hash, Limit: prFeedEntries})
+	if err != nil {
+		utils.Log("Error listing events for %s: %v", hash, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not list events"})
+		return
+	}
+	var prURL string
+	if sub.NotifyKey == "" {
+		prURL = prURLForHash(hash)
+	}
+	feed := atomFeed{
+		ID:      "urn:gitgost:pr:" + hash,
+		Title:   "gitGost PR " + hash,
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Author:  "gitGost",
+	}
+	if prURL != "" {
+		feed.Link = &atomLink{Href: prURL, Rel: "alternate"}
+	}
+	if len(events) > 0 {
+		feed.Updated = events[0].CreatedAt.UTC().Format(time.RFC3339)
+	}
+	for _, e := range events {
+		n := newPRNotification(e, prURL, sub.NotifyKey)
+		content := n.Message
+		if n.Body != "" {
+			content += "\n\n" + n.Body
+		}
+		if sub.NotifyKey != "" {
+			n.Title, content = "Encrypted notification", n.Sealed
+		}
+		entry := atomEntry{
+			ID:      fmt.Sprintf("urn:gitgost:pr:%s:event:%d", hash, e.ID),
+			Title:   n.Title,
+			Updated: e.CreatedAt.UTC().Format(time.RFC3339),
+			Content: content,
+		}
+		if prURL != "" {
+			entry.Link = &atomLink{Href: prURL}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	out, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not build feed"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/atom+xml; charset=utf-8", append([]byte(xml.Header), out...))
+}
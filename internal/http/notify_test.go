@@ -0,0 +1,238 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/moderation"
+	"github.com/livrasand/gitGost/internal/prmsg"
+	"github.com/livrasand/gitGost/internal/state"
+)
+
+func TestPRNotificationEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useState(t, state.NewMemory())
+	old := modStore
+	setModerationStore(moderation.NewMemory())
+	t.Cleanup(func() { setModerationStore(old) })
+	InitNotifications(false)
+	t.Cleanup(func() { InitNotifications(true) })
+
+	ctx := context.Background()
+	pr := moderation.PR{Hash: "abcd1234", Provider: "gh", Owner: "acme", Repo: "site", Number: 5, URL: "https://github.com/acme/site/pull/5"}
+	_ = modStore.AddPR(ctx, pr)
+	// A subscription from before notifications were encrypted.
+	secret := strings.Repeat("5", 48)
+	_, _ = modStore.SetPRSubscription(ctx, pr.Hash, moderation.Subscription{TokenHash: prmsg.TokenHash(secret)})
+	if again, _ := openSubscription(ctx, pr.Hash, ""); again != "" {
+		t.Error("a second push issued another secret")
+	}
+
+	r := gin.New()
+	r.GET("/api/pr/:hash/events", PREventsHandler)
+	r.GET("/api/pr/:hash/events/stream", PREventStreamHandler)
+	r.GET("/api/pr/:hash/feed.atom", PRFeedHandler)
+	r.POST("/api/pr/:hash/events/token", PRSubscriptionTokenHandler)
+	r.GET("/api/pr-status/:hash", PRStatusHandler)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	get := func(path, secret string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		if secret != "" {
+			req.Header.Set("X-Gitgost-Subscription", secret)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if resp := get("/api/pr/abcd1234/events?wait=0", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("without secret: %d", resp.StatusCode)
+	}
+	if resp := get("/api/pr/abcd1234/events?wait=0", strings.Repeat("0", 48)); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("wrong secret: %d", resp.StatusCode)
+	}
+	if resp := get("/api/pr/ffff0000/feed.atom", secret); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("secret of another PR: %d", resp.StatusCode)
+	}
+	if resp := get("/api/pr/abcd1234/feed.atom?secret="+secret, ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("secret in the URL: %d", resp.StatusCode)
+	}
+
+	// The secret buys URL tokens for clients that can't set headers.
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/pr/abcd1234/events/token", nil)
+	req.Header.Set("X-Gitgost-Subscription", secret)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var urls struct {
+		Token     string `json:"token"`
+		StreamURL string `json:"stream_url"`
+		FeedURL   string `json:"feed_url"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&urls)
+	resp.Body.Close()
+	if urls.Token == "" || !strings.HasSuffix(urls.FeedURL, "/api/pr/abcd1234/feed.atom?token="+url.QueryEscape(urls.Token)) {
+		t.Fatalf("token response = %+v", urls)
+	}
+	pathOf := func(raw string) string {
+		u, _ := url.Parse(raw)
+		return u.RequestURI()
+	}
+	if resp := get("/api/pr/abcd1234/events?wait=0&token="+url.QueryEscape(urls.Token), ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("token on the long-poll: %d", resp.StatusCode)
+	}
+	if resp := get("/api/pr/ffff0000/feed.atom?token="+url.QueryEscape(urls.Token), ""); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("token of another PR: %d", resp.StatusCode)
+	}
+	expired := prURLToken(getSecretKeys()[0], pr.Hash, prmsg.TokenHash(secret), time.Now().Add(-time.Second).Unix())
+	if resp := get("/api/pr/abcd1234/feed.atom?token="+url.QueryEscape(expired), ""); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expired token: %d", resp.StatusCode)
+	}
+
+	// A waiting long-poll returns with the event published meanwhile.
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		time.Sleep(100 * time.Millisecond)
+		_ = publishPREvent(ctx, pr, moderation.PREvent{Type: prEventApproved, Author: "maint"})
+	}()
+	resp = get("/api/pr/abcd1234/events?after=0&wait=10", secret)
+	<-published
+	var poll struct {
+		Events []prNotification `json:"events"`
+		LastID int64            `json:"last_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&poll); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(poll.Events) != 1 || poll.Events[0].Title != "PR Approved" || poll.Events[0].URL != pr.URL || poll.LastID != poll.Events[0].ID {
+		t.Fatalf("long-poll = %+v", poll)
+	}
+
+	// The stream resumes after Last-Event-ID.
+	_ = publishPREvent(ctx, pr, moderation.PREvent{Type: prEventMerged, Author: "lead"})
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	req, _ = http.NewRequestWithContext(sctx, http.MethodGet, srv.URL+pathOf(urls.StreamURL), nil)
+	req.Header.Set("Last-Event-ID", strconv.FormatInt(poll.LastID, 10))
+	stream, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Body.Close()
+	if ct := stream.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("stream content type %q", ct)
+	}
+	sc := bufio.NewScanner(stream.Body)
+	var data string
+	for sc.Scan() {
+		if line, ok := strings.CutPrefix(sc.Text(), "data: "); ok {
+			data = line
+			break
+		}
+	}
+	var n prNotification
+	if err := json.Unmarshal([]byte(data), &n); err != nil || n.Type != prEventMerged || n.Message != "lead merged your anonymous PR.\nPR: "+pr.URL {
+		t.Errorf("streamed %q: %v", data, err)
+	}
+	cancel()
+
+	resp = get(pathOf(urls.FeedURL), "")
+	var feed atomFeed
+	if err := xml.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(feed.Entries) != 2 || feed.Entries[0].Title != "PR Merged" || feed.Entries[1].Title != "PR Approved" {
+		t.Errorf("feed = %+v", feed)
+	}
+
+	resp = get("/api/pr-status/abcd1234", "")
+	var status map[string]string
+	_ = json.NewDecoder(resp.Body).Decode(&status)
+	resp.Body.Close()
+	if status["subscribe_url"] != "" || !strings.HasSuffix(status["feed_url"], "/api/pr/abcd1234/feed.atom") {
+		t.Errorf("pr-status without ntfy forwarding = %v", status)
+	}
+}
+
+func TestEncryptedPRNotifications(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useState(t, state.NewMemory())
+	old := modStore
+	setModerationStore(moderation.NewMemory())
+	t.Cleanup(func() { setModerationStore(old) })
+
+	relayed := make(chan string, 4)
+	ntfy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		relayed <- r.Header.Get("Title") + "\n" + r.Header.Get("Actions") + "\n" + string(body)
+	}))
+	defer ntfy.Close()
+	t.Setenv("NTFY_BASE_URL", ntfy.URL)
+
+	ctx := context.Background()
+	pr := moderation.PR{Hash: "abcd1234", Provider: "gh", Owner: "acme", Repo: "site", Number: 5, URL: "https://github.com/acme/site/pull/5"}
+	_ = modStore.AddPR(ctx, pr)
+	secret, key := openSubscription(ctx, pr.Hash, "")
+	if secret == "" || key == "" {
+		t.Fatalf("openSubscription = %q, %q", secret, key)
+	}
+	// The server keeps only the public half.
+	if sub, _, _ := modStore.PRSubscription(ctx, pr.Hash); strings.Contains(sub.NotifyKey, key) || !strings.HasPrefix(sub.NotifyKey, "x25519:") {
+		t.Errorf("stored key = %q", sub.NotifyKey)
+	}
+
+	_ = publishPREvent(ctx, pr, moderation.PREvent{Type: prEventCommented, Author: "maint", Body: "one nit"})
+	got := <-relayed
+	if strings.Contains(got, pr.URL) || strings.Contains(got, "abcd1234") || strings.Contains(got, "one nit") {
+		t.Errorf("relay saw %q", got)
+	}
+	sealed := got[strings.LastIndex(got, "\n")+1:]
+	plain, err := prmsg.OpenNotification(key, pr.Hash, sealed)
+	var n prNotification
+	if err != nil || json.Unmarshal(plain, &n) != nil || n.URL != pr.URL || n.Body != "one nit" || n.Title != "New Comment" {
+		t.Fatalf("relayed notification = %s, %v", plain, err)
+	}
+
+	r := gin.New()
+	r.GET("/api/pr/:hash/events", PREventsHandler)
+	r.GET("/api/pr/:hash/feed.atom", PRFeedHandler)
+	req := httptest.NewRequest(http.MethodGet, "/api/pr/abcd1234/events?wait=0", nil)
+	req.Header.Set("X-Gitgost-Subscription", secret)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var poll struct {
+		Events []prNotification `json:"events"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &poll); err != nil || len(poll.Events) != 1 || poll.Events[0].Type != "" || poll.Events[0].ID == 0 {
+		t.Fatalf("long-poll = %s", w.Body)
+	}
+	if plain, err := prmsg.OpenNotification(key, pr.Hash, poll.Events[0].Sealed); err != nil || !strings.Contains(string(plain), "one nit") {
+		t.Errorf("polled notification = %s, %v", plain, err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/pr/abcd1234/feed.atom", nil)
+	req.Header.Set("X-Gitgost-Subscription", secret)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if feed := w.Body.String(); strings.Contains(feed, pr.URL) || !strings.Contains(feed, prmsg.SealedNotificationPrefix) {
+		t.Errorf("feed = %s", feed)
+	}
+}
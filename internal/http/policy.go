@@ -0,0 +1,218 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/provider"
+)
+
+const (
+	repoActivityStoreMax = 10000
+	repoActivityWindow   = time.Hour
+)
+
+// repoActivityStore backs the per-repo hourly limits maintainers set in
+// .gitgost.yml, keyed by activity and repo.
+var repoActivityStore = newBoundedMap[[]time.Time]("repo-activity", repoActivityStoreMax, repoActivityWindow)
+
+func repoActivityKey(prov provider.Provider, owner, repo, activity string) string {
+	return fmt.Sprintf("%s:%s:%s/%s", activity, strings.ToLower(prov.Name()), strings.ToLower(owner), strings.ToLower(repo))
+}
+
+// checkRepoRateLimit counts one activity against owner/repo and reports
+// whether the repo's own hourly limit is exceeded.
+func checkRepoRateLimit(prov provider.Provider, owner, repo, activity string, limit int) bool {
+	if limit <= 0 {
+		return false
+	}
+	return windowAdd(repoActivityStore, repoActivityKey(prov, owner, repo, activity), time.Now(), repoActivityWindow, limit) > limit
+}
+
+// repoRateLimited reports whether owner/repo already reached its hourly
+// limit for activity, without counting anything. Pushes check it up front
+// and call countRepoActivity once the PR exists, so rejected or failed
+// pushes don't use up the repo's quota.
+func repoRateLimited(prov provider.Provider, owner, repo, activity string, limit int) bool {
+	if limit <= 0 {
+		return false
+	}
+	times, _ := repoActivityStore.Get(repoActivityKey(prov, owner, repo, activity))
+	cutoff := time.Now().Add(-repoActivityWindow)
+	n := 0
+	for _, t := range times {
+		if t.After(cutoff) {
+			n++
+		}
+	}
+	return n >= limit
+}
+
+// countRepoActivity counts one activity against owner/repo.
+func countRepoActivity(prov provider.Provider, owner, repo, activity string, limit int) {
+	if limit <= 0 {
+		return
+	}
+	windowAdd(repoActivityStore, repoActivityKey(prov, owner, repo, activity), time.Now(), repoActivityWindow, limit)
+}
+
+var activityNouns = map[string]string{
+	provider.ActivityPR:      "pull requests",
+	provider.ActivityIssue:   "issues",
+	provider.ActivityComment: "comments",
+}
+
+// loadRepoPolicy fetches the policy of owner/repo. Fetch failures fall back
+// to the permissive default, as before; only an invalid file is an error.
+func loadRepoPolicy(ctx context.Context, prov provider.Provider, owner, repo string) (*provider.RepoPolicy, error) {
+	policy, err := prov.GetRepoPolicy(ctx, owner, repo)
+	if err != nil {
+		if errors.Is(err, provider.ErrInvalidPolicy) {
+			return nil, err
+		}
+		return &provider.RepoPolicy{}, nil
+	}
+	if policy == nil {
+		policy = &provider.RepoPolicy{}
+	}
+	return policy, nil
+}
+
+// enforceRepoPolicy applies the repo policy to an API request for activity.
+// It writes the error response and returns false when the request must stop.
+func enforceRepoPolicy(c *gin.Context, prov provider.Provider, owner, repo, activity string) (*provider.RepoPolicy, bool) {
+	policy, err := loadRepoPolicy(c.Request.Context(), prov, owner, repo)
+	if err != nil {
+		var pe *provider.PolicyError
+		resp := gin.H{"error": "this repository's .gitgost.yml is invalid; contact the maintainer"}
+		if errors.As(err, &pe) {
+			resp["policy_errors"] = pe.Problems
+		}
+		c.JSON(http.StatusUnprocessableEntity, resp)
+		return nil, false
+	}
+	if !policy.Allows(activity) {
+		resp := gin.H{"error": fmt.Sprintf("this repository does not accept anonymous %s via gitGost", activityNouns[activity])}
+		if policy.Message != "" {
+			resp["message"] = policy.Message
+		}
+		c.JSON(http.StatusForbidden, resp)
+		return nil, false
+	}
+	if checkRepoRateLimit(prov, owner, repo, activity, policy.HourlyLimit(activity)) {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error": fmt.Sprintf("this repository accepts at most %d anonymous %s per hour", policy.HourlyLimit(activity), activityNouns[activity]),
+		})
+		return nil, false
+	}
+	return policy, true
+}
+
+// sidebandText splits a maintainer message into sideband-safe lines,
+// dropping control characters so a policy can't inject terminal escapes.
+func sidebandText(msg string) []string {
+	clean := strings.Map(func(r rune) rune {
+		if r == '\n' || !unicode.IsControl(r) {
+			return r
+		}
+		return -1
+	}, msg)
+	var lines []string
+	for _, l := range strings.Split(clean, "\n") {
+		lines = append(lines, "remote: "+strings.TrimRight(l, " "))
+	}
+	return lines
+}
+
+// writePolicyMessage shows the maintainer's custom message, if any.
+func writePolicyMessage(w *bytes.Buffer, policy *provider.RepoPolicy) {
+	if policy == nil || policy.Message == "" {
+		return
+	}
+	WriteSidebandLine(w, 2, "remote: ")
+	for _, line := range sidebandText(policy.Message) {
+		WriteSidebandLine(w, 2, line)
+	}
+	WriteSidebandLine(w, 2, "remote: ")
+}
+
+// writePolicyRejection writes a complete receive-pack rejection.
+func writePolicyRejection(c *gin.Context, policy *provider.RepoPolicy, reason string, lines ...string) {
+	c.Writer.Header().Set("Content-Type", "application/x-git-receive-pack-result")
+	c.Writer.WriteHeader(http.StatusOK)
+	var errResp bytes.Buffer
+	WriteSidebandLine(&errResp, 2, "remote: ")
+	for _, l := range lines {
+		WriteSidebandLine(&errResp, 2, "remote: "+l)
+	}
+	writePolicyMessage(&errResp, policy)
+	WriteSidebandLine(&errResp, 2, "remote: ")
+	WriteSidebandLine(&errResp, 3, "push rejected: "+reason)
+	WritePktLine(&errResp, "")
+	c.Writer.Write(errResp.Bytes())
+}
+
+// RepoPolicyHandler lets maintainers check how gitGost reads their
+// .gitgost.yml, including every validation problem.
+func RepoPolicyHandler(c *gin.Context) {
+	var prov provider.Provider
+	switch c.Param("provider") {
+	case "gl", "gitlab":
+		prov = providerFromName("gl")
+	case "cb", "codeberg":
+		prov = providerFromName("cb")
+	case "gh", "github":
+		prov = providerFromName("gh")
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown provider"})
+		return
+	}
+	owner, repo := c.Param("owner"), c.Param("repo")
+
+	policy, err := prov.GetRepoPolicy(c.Request.Context(), owner, repo)
+	if err != nil {
+		var pe *provider.PolicyError
+		if errors.As(err, &pe) {
+			c.JSON(http.StatusOK, gin.H{"valid": false, "errors": pe.Problems})
+			return
+		}
+		respondProviderError(c, err, http.StatusBadGateway)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"valid": true, "policy": policy})
+}
+
+// prDisclaimer is what {{disclaimer}} expands to in a PR body template.
+const prDisclaimer = "*This is an anonymous contribution made via [gitGost](https://gitgost.livrasand.com). The original author's identity has been anonymized to protect their privacy.*"
+
+// resolveTargetBranch picks the base branch of the PR for a push to
+// pushedRef. Without target_branches the provider default is used (""). A
+// pushed branch in the list is used as is; a feature branch that doesn't
+// exist on the target goes to the first allowed branch; any other existing
+// branch is rejected with the returned message.
+func resolveTargetBranch(ctx context.Context, prov provider.Provider, policy *provider.RepoPolicy, owner, repo, pushedRef string) (string, string) {
+	if len(policy.TargetBranches) == 0 {
+		return "", ""
+	}
+	pushed := strings.TrimPrefix(pushedRef, "refs/heads/")
+	if pushed != "" && policy.AllowsTargetBranch(pushed) {
+		return pushed, ""
+	}
+	if pushed != "" {
+		if refs, err := prov.GetRefs(ctx, owner, repo); err == nil {
+			for _, r := range refs {
+				if r.Ref == "refs/heads/"+pushed {
+					return "", fmt.Sprintf("This repository does not accept anonymous PRs against %q.", pushed)
+				}
+			}
+		}
+	}
+	return policy.TargetBranches[0], ""
+}
@@ -0,0 +1,35 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/livrasand/gitGost/internal/provider"
+	"github.com/livrasand/gitGost/internal/state"
+)
+
+func TestRepoRateLimitOnlyCountsCreatedPRs(t *testing.T) {
+	useState(t, state.NewMemory())
+	prov := providerFromName("gh")
+
+	// Checking the limit, as every push does before it is validated,
+	// uses up nothing.
+	for i := 0; i < 5; i++ {
+		if repoRateLimited(prov, "acme", "site", provider.ActivityPR, 2) {
+			t.Fatalf("limited after %d checks and no PRs", i+1)
+		}
+	}
+	countRepoActivity(prov, "acme", "site", provider.ActivityPR, 2)
+	if repoRateLimited(prov, "Acme", "Site", provider.ActivityPR, 2) {
+		t.Fatal("limited after one PR of two")
+	}
+	countRepoActivity(prov, "acme", "site", provider.ActivityPR, 2)
+	if !repoRateLimited(prov, "Acme", "Site", provider.ActivityPR, 2) {
+		t.Error("not limited after two PRs of two")
+	}
+	if repoRateLimited(prov, "acme", "other", provider.ActivityPR, 2) {
+		t.Error("another repo's PRs counted")
+	}
+	if repoRateLimited(prov, "acme", "site", provider.ActivityPR, 0) {
+		t.Error("limited without a limit")
+	}
+}
@@ -0,0 +1,193 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/pow"
+)
+
+// Proof-of-work modes. "burst" only asks for work while global push
+// activity is elevated, so normal contributors never notice it.
+const (
+	powModeOff    = "off"
+	powModeBurst  = "burst"
+	powModeAlways = "always"
+)
+
+const (
+	powChallengeTTL = 10 * time.Minute
+	powSpentMax     = 50000
+	// maxPowExtraBits bounds how much a burst can raise the difficulty;
+	// each extra bit doubles the expected work.
+	maxPowExtraBits = 6
+	// powBitsSlack lets a challenge issued just before the difficulty rose
+	// still be redeemed.
+	powBitsSlack = 2
+
+	powHeader          = "X-Gitgost-Pow"
+	powChallengeHeader = "X-Gitgost-Pow-Challenge"
+)
+
+var (
+	powMu       sync.RWMutex
+	powMode     = powModeOff
+	powBaseBits = 18
+	powIssuer   *pow.Issuer
+
+	// powSpent remembers redeemed challenges until they expire.
+	powSpent = newBoundedMap[bool]("pow-spent", powSpentMax, powChallengeTTL)
+
+	errPowRequired = errors.New("proof of work required")
+	errPowReplayed = errors.New("proof of work already used")
+	errPowUnstored = errors.New("proof of work could not be recorded; try again later")
+)
+
+// InitPow configures the proof-of-work gate.
+func InitPow(mode string, baseBits int) {
+	powMu.Lock()
+	defer powMu.Unlock()
+	switch mode {
+	case powModeBurst, powModeAlways:
+		powMode = mode
+	default:
+		powMode = powModeOff
+	}
+	if baseBits > 0 && baseBits <= pow.MaxBits {
+		powBaseBits = baseBits
+	}
+}
+
+func getPowIssuer() *pow.Issuer {
+	powMu.Lock()
+	defer powMu.Unlock()
+	if powIssuer == nil {
+		powIssuer = &pow.Issuer{Key: getSecretKey(), TTL: powChallengeTTL}
+	}
+	return powIssuer
+}
+
+// globalBurstLoad returns the pushes and distinct IPs recorded by
+// recordGlobalBurst in the current window.
+func globalBurstLoad() (int, int) {
+	b, _ := burstStore.Get("global")
+	return b.live(time.Now()).load()
+}
+
+// powDifficulty returns the current difficulty and whether work is required
+// at all. Every quarter of the burst alert threshold adds one bit.
+func powDifficulty() (int, bool) {
+	powMu.RLock()
+	mode, base := powMode, powBaseBits
+	powMu.RUnlock()
+	if mode == powModeOff {
+		return 0, false
+	}
+	total, distinct := globalBurstLoad()
+	required := mode == powModeAlways ||
+		isGlobalBurstAlertActive() ||
+		total >= globalBurstMaxTotal/2 ||
+		distinct >= globalBurstMaxIPs/2
+	extra := total * 4 / globalBurstMaxTotal
+	if extra > maxPowExtraBits {
+		extra = maxPowExtraBits
+	}
+	bits := base + extra
+	if bits > pow.MaxBits {
+		bits = pow.MaxBits
+	}
+	return bits, required
+}
+
+// issuePowChallenge returns a challenge when work is currently required.
+func issuePowChallenge() (string, int, bool) {
+	bits, required := powDifficulty()
+	if !required {
+		return "", 0, false
+	}
+	challenge, err := getPowIssuer().Issue(bits, time.Now())
+	if err != nil {
+		return "", 0, false
+	}
+	return challenge, bits, true
+}
+
+// checkPow verifies solution when work is required and marks it spent.
+func checkPow(solution string) error {
+	bits, required := powDifficulty()
+	if !required {
+		return nil
+	}
+	return checkPowAt(solution, bits)
+}
+
+// checkPowAt verifies solution against a fixed difficulty, regardless of
+// the configured mode.
+func checkPowAt(solution string, bits int) error {
+	solution = strings.TrimSpace(solution)
+	if solution == "" {
+		return errPowRequired
+	}
+	minBits := bits - powBitsSlack
+	if minBits < 0 {
+		minBits = 0
+	}
+	challenge, err := getPowIssuer().Verify(solution, minBits, time.Now())
+	if err != nil {
+		return err
+	}
+	replayed := false
+	if _, err := powSpent.Update(challenge, func(_ bool, present bool) bool {
+		replayed = present
+		return true
+	}); err != nil {
+		return errPowUnstored
+	}
+	if replayed {
+		return errPowReplayed
+	}
+	return nil
+}
+
+// PowChallengeHandler hands out a challenge for clients that can't read the
+// info/refs advertisement, such as the web UI.
+func PowChallengeHandler(c *gin.Context) {
+	challenge, bits, required := issuePowChallenge()
+	if !required {
+		c.JSON(http.StatusOK, gin.H{"required": false})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"required":   true,
+		"bits":       bits,
+		"challenge":  challenge,
+		"expires_in": int(powChallengeTTL / time.Second),
+	})
+}
+
+// powGate guards API writes. Clients send the solution in X-Gitgost-Pow;
+// a missing or bad one gets 428 with a fresh challenge.
+func powGate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tokenRedeemed(c) {
+			c.Next()
+			return
+		}
+		err := checkPow(c.GetHeader(powHeader))
+		if err == nil {
+			c.Next()
+			return
+		}
+		resp := gin.H{"error": err.Error()}
+		if challenge, bits, ok := issuePowChallenge(); ok {
+			c.Header(powChallengeHeader, challenge)
+			resp["challenge"] = challenge
+			resp["bits"] = bits
+		}
+		c.AbortWithStatusJSON(http.StatusPreconditionRequired, resp)
+	}
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/pow"
+)
+
+func setPowMode(t *testing.T, mode string, bits int) {
+	t.Helper()
+	oldMode, oldBits := powMode, powBaseBits
+	InitPow(mode, bits)
+	t.Cleanup(func() { powMode, powBaseBits = oldMode, oldBits })
+}
+
+func TestPowDifficultyScalesWithBurst(t *testing.T) {
+	setPowMode(t, powModeBurst, 8)
+	old, _ := burstStore.Get("global")
+	burstStore.Set("global", globalBurst{})
+	defer burstStore.Set("global", old)
+
+	if _, required := powDifficulty(); required {
+		t.Fatal("burst mode must not require work on an idle server")
+	}
+	now := time.Now()
+	var b globalBurst
+	for i := 0; i < globalBurstMaxTotal; i++ {
+		b.Times = append(b.Times, now)
+		b.IPs = append(b.IPs, "10.0.0.1")
+	}
+	burstStore.Set("global", b)
+	bits, required := powDifficulty()
+	if !required || bits != 8+4 {
+		t.Errorf("difficulty at the alert threshold = %d (required %v), want 12", bits, required)
+	}
+}
+
+func TestPowGate(t *testing.T) {
+	setPowMode(t, powModeAlways, 8)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/write", powGate(), func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/write", nil))
+	if w.Code != http.StatusPreconditionRequired {
+		t.Fatalf("status without proof = %d, want 428", w.Code)
+	}
+	var body struct {
+		Challenge string `json:"challenge"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Challenge == "" {
+		t.Fatalf("428 body = %s", w.Body)
+	}
+	if w.Header().Get(powChallengeHeader) != body.Challenge {
+		t.Error("challenge header and body differ")
+	}
+
+	solution, err := pow.Solve(context.Background(), body.Challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/write", nil)
+		req.Header.Set(powHeader, solution)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := send(); code != http.StatusCreated {
+		t.Fatalf("status with proof = %d, want 201", code)
+	}
+	if code := send(); code != http.StatusPreconditionRequired {
+		t.Errorf("replayed proof status = %d, want 428", code)
+	}
+}
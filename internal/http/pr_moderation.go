@@ -0,0 +1,52 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/livrasand/gitGost/internal/github"
+	"github.com/livrasand/gitGost/internal/moderation"
+	"github.com/livrasand/gitGost/internal/utils"
+)
+
+// closeTrackedPR closes a PR through its provider; tests replace it.
+var closeTrackedPR = func(ctx context.Context, t prTrack) error {
+	return providerFromName(t.Provider).CloseMRByURL(ctx, t.PRURL)
+}
+
+// prReportURL is the page every anonymous PR body links to so readers can
+// report it. Reports count against the PR hash like any other hash.
+func prReportURL(prHash string) string {
+	return fmt.Sprintf("%s/v1/moderation/report?hash=%s", github.NtfyServiceURL(), prHash)
+}
+
+// isTrackedPRHash reports whether hash belongs to a PR this instance opened.
+func isTrackedPRHash(hash string) bool {
+	_, ok := getPRTrack(hash)
+	return ok
+}
+
+// closeReportedPR closes the PR behind a hash that reports just blocked and
+// tells its author.
+func closeReportedPR(prHash string) {
+	t, ok := getPRTrack(prHash)
+	if !ok {
+		utils.Log("Blocked PR hash %s is no longer tracked, cannot close it", prHash)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := closeTrackedPR(ctx, t); err != nil {
+		utils.Log("Error closing reported PR %s: %v", t.PRURL, err)
+		return
+	}
+	if err := modStore.MarkPRClosed(ctx, t.PRURL, time.Now()); err != nil {
+		utils.Log("Error marking PR %s closed: %v", t.PRURL, err)
+	}
+	utils.Log("Closed reported PR %s (hash %s)", t.PRURL, prHash)
+	pr := moderation.PR{Hash: prHash, Provider: t.Provider, Owner: t.Owner, Repo: t.Repo, Number: t.Number, URL: t.PRURL}
+	if err := publishPREvent(ctx, pr, moderation.PREvent{Type: prEventReportedClosed}); err != nil {
+		utils.Log("Error publishing close of %s: %v", t.PRURL, err)
+	}
+}
@@ -0,0 +1,158 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/moderation"
+	"github.com/livrasand/gitGost/internal/provider"
+)
+
+func TestReportedPRIsClosedByDistinctReporters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	old := modStore
+	setModerationStore(moderation.NewMemory())
+	t.Cleanup(func() { setModerationStore(old) })
+
+	ntfy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ntfy.Close()
+	t.Setenv("NTFY_BASE_URL", ntfy.URL)
+
+	closed := make(chan prTrack, 1)
+	oldClose := closeTrackedPR
+	closeTrackedPR = func(_ context.Context, t prTrack) error {
+		closed <- t
+		return nil
+	}
+	t.Cleanup(func() { closeTrackedPR = oldClose })
+
+	const prHash = "deadbeef"
+	trackPR(prHash, "o", "r", 12, "https://github.com/o/r/pull/12", "gh")
+
+	r := gin.New()
+	r.POST("/v1/moderation/report", ReportHashHandler)
+	report := func(ip string) {
+		t.Helper()
+		form := url.Values{"hash": {prHash}, "category": {"malware"}, "report_token": {newReportToken()}}
+		req := httptest.NewRequest(http.MethodPost, "/v1/moderation/report", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Report received") {
+			t.Fatalf("report = %d %s", w.Code, w.Body.String())
+		}
+	}
+
+	// Fewer reporters than prCloseReporters don't close a PR, however
+	// severe the category and however often they report.
+	for i := 1; i < prCloseReporters; i++ {
+		report(fmt.Sprintf("192.0.2.%d", i))
+		report(fmt.Sprintf("192.0.2.%d", i))
+	}
+	select {
+	case got := <-closed:
+		t.Fatalf("closed %s before %d distinct reporters", got.PRURL, prCloseReporters)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if isBlockedHash(prHash) {
+		t.Error("PR hash blocked before enough reporters")
+	}
+
+	report(fmt.Sprintf("192.0.2.%d", prCloseReporters))
+	select {
+	case got := <-closed:
+		if got.PRURL != "https://github.com/o/r/pull/12" {
+			t.Errorf("closed %s", got.PRURL)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reported PR was not closed")
+	}
+	if !isBlockedHash(prHash) {
+		t.Error("PR hash not blocked")
+	}
+}
+
+func TestMROptionsReportLink(t *testing.T) {
+	opts := provider.MROptions{ReportURL: prReportURL("cafe1234")}
+	got := opts.AppendReportLink("body")
+	if !strings.HasPrefix(got, "body\n\n") || !strings.Contains(got, "/v1/moderation/report?hash=cafe1234") {
+		t.Errorf("AppendReportLink = %q", got)
+	}
+	if got := (provider.MROptions{}).AppendReportLink("body"); got != "body" {
+		t.Errorf("without ReportURL = %q", got)
+	}
+}
+
+func TestPRTrackingOutlivesCache(t *testing.T) {
+	old := modStore
+	setModerationStore(moderation.NewMemory())
+	t.Cleanup(func() { setModerationStore(old) })
+	oldRetention := prRetention
+	prRetention = 30 * 24 * time.Hour
+	t.Cleanup(func() { prRetention = oldRetention })
+
+	const prHash = "feedface"
+	ctx := context.Background()
+	opened := time.Now().Add(-60 * 24 * time.Hour)
+	_ = modStore.AddPR(ctx, moderation.PR{Hash: prHash, Provider: "cb", Owner: "o", Repo: "r", Number: 4, URL: "https://codeberg.org/o/r/pulls/4", CreatedAt: opened})
+	// A restart, or a review longer than the cache ttl.
+	prTrackStore.Delete(prHash)
+
+	got, ok := getPRTrack(prHash)
+	if !ok || got.Number != 4 || got.Provider != "cb" || got.PRURL != "https://codeberg.org/o/r/pulls/4" {
+		t.Fatalf("getPRTrack = %+v, %v", got, ok)
+	}
+	if _, ok := getPRTrack("feed"); ok {
+		t.Error("a hash prefix resolved to a PR")
+	}
+
+	// PRs the forge still reports open are kept however old they are.
+	closedOnForge := map[int]bool{}
+	oldFetch := fetchMRStatus
+	fetchMRStatus = func(_ context.Context, t prTrack) (*provider.MRStatus, error) {
+		if closedOnForge[t.Number] {
+			return &provider.MRStatus{State: "closed", Number: t.Number}, nil
+		}
+		return &provider.MRStatus{State: "open", Number: t.Number}, nil
+	}
+	t.Cleanup(func() { fetchMRStatus = oldFetch })
+	purgeClosedPRs(time.Now())
+	prTrackStore.Delete(prHash)
+	if _, ok := getPRTrack(prHash); !ok {
+		t.Fatal("open PR purged")
+	}
+
+	// One the forge closed unnoticed is marked closed by the purge, and
+	// goes a retention period later.
+	const staleHash = "0ddba11"
+	_ = modStore.AddPR(ctx, moderation.PR{Hash: staleHash, Provider: "gh", Owner: "o", Repo: "r", Number: 5, URL: "https://github.com/o/r/pull/5", CreatedAt: opened})
+	closedOnForge[5] = true
+	purgeClosedPRs(time.Now())
+	if prs, _ := modStore.PRs(ctx, moderation.PRQuery{Hash: staleHash}); len(prs) != 1 || prs[0].ClosedAt.IsZero() {
+		t.Fatalf("stale PR after recheck = %+v", prs)
+	}
+	purgeClosedPRs(time.Now().Add(31 * 24 * time.Hour))
+	if prs, _ := modStore.PRs(ctx, moderation.PRQuery{Hash: staleHash}); len(prs) != 0 {
+		t.Errorf("stale PR kept past retention: %+v", prs)
+	}
+
+	markTrackedPRClosed(ctx, got)
+	purgeClosedPRs(time.Now().Add(29 * 24 * time.Hour))
+	prTrackStore.Delete(prHash)
+	if _, ok := getPRTrack(prHash); !ok {
+		t.Fatal("closed PR purged before its retention ran out")
+	}
+	purgeClosedPRs(time.Now().Add(31 * 24 * time.Hour))
+	prTrackStore.Delete(prHash)
+	if _, ok := getPRTrack(prHash); ok {
+		t.Error("closed PR kept past retention")
+	}
+}
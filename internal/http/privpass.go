@@ -0,0 +1,262 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/privpass"
+	"github.com/livrasand/gitGost/internal/utils"
+)
+
+// Anonymous credentials. After a CAPTCHA or a proof of work, a client gets
+// a batch of blind-signed tokens; each push, issue or comment can redeem one
+// instead of being judged by its IP. Every client sees the same issuing key,
+// so a redemption can't be tied back to the batch it came from.
+
+const (
+	tokenHeader      = "X-Gitgost-Token"
+	tokenRedeemedKey = "gitgost.token"
+
+	// tokenKeyLifetime is how long a key issues tokens. Tokens from the
+	// previous key stay redeemable for one more lifetime.
+	tokenKeyLifetime = 24 * time.Hour
+	tokenKeysKept    = 2
+	// tokenKeyRefresh bounds how long another instance's rotation takes to
+	// reach this one.
+	tokenKeyRefresh = time.Minute
+	tokenBatchMax   = 16
+	// tokenPowExtraBits makes a batch cost more work than a single write.
+	tokenPowExtraBits = 2
+)
+
+var (
+	tokenKeyBits = privpass.KeyBits
+
+	tokenKeysMu sync.Mutex
+	// tokenKeysCache holds the stored keys, newest first, and tokenKeyIDs
+	// their IDs in the moderation store.
+	tokenKeysCache   []*rsa.PrivateKey
+	tokenKeyIDs      []int64
+	tokenKeyCreated  time.Time
+	tokenKeysFetched time.Time
+
+	tokenIssueStore = newBoundedMap[[]time.Time]("token-issue", prCheckLimiterStoreMax, tokenIssueWin)
+	tokenIssueMax   = 10
+	tokenIssueWin   = time.Hour
+
+	errTokenSpent      = errors.New("token already redeemed")
+	errTokenUnrecorded = errors.New("token could not be recorded; try again later")
+)
+
+// tokenKeys returns the issuing key and the previous one. They live in the
+// moderation store next to the HMAC keys, so tokens survive restarts and
+// redeem on any instance. The first instance to find the issuing key older
+// than tokenKeyLifetime rotates it.
+func tokenKeys() (*rsa.PrivateKey, *rsa.PrivateKey, error) {
+	tokenKeysMu.Lock()
+	defer tokenKeysMu.Unlock()
+	now := time.Now()
+	if tokenKeysCache != nil && now.Sub(tokenKeysFetched) < tokenKeyRefresh && now.Sub(tokenKeyCreated) <= tokenKeyLifetime {
+		return tokenKeyPair()
+	}
+	ctx := context.Background()
+	stored, err := modStore.TokenKeys(ctx)
+	if err == nil && (len(stored) == 0 || now.Sub(stored[0].CreatedAt) > tokenKeyLifetime) {
+		var priv *rsa.PrivateKey
+		if priv, err = rsa.GenerateKey(rand.Reader, tokenKeyBits); err == nil {
+			stored, err = modStore.AddTokenKey(ctx, x509.MarshalPKCS1PrivateKey(priv), now.Add(-tokenKeyLifetime), tokenKeysKept)
+		}
+	}
+	if err != nil {
+		if tokenKeysCache == nil {
+			return nil, nil, err
+		}
+		utils.Log("Error loading token keys: %v", err)
+		tokenKeysFetched = now
+		return tokenKeyPair()
+	}
+	keys := make([]*rsa.PrivateKey, 0, len(stored))
+	ids := make([]int64, 0, len(stored))
+	for _, k := range stored {
+		priv, err := x509.ParsePKCS1PrivateKey(k.Key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("token key %d: %w", k.ID, err)
+		}
+		keys = append(keys, priv)
+		ids = append(ids, k.ID)
+	}
+	tokenKeysCache, tokenKeyIDs, tokenKeyCreated, tokenKeysFetched = keys, ids, stored[0].CreatedAt, now
+	return tokenKeyPair()
+}
+
+func tokenKeyPair() (*rsa.PrivateKey, *rsa.PrivateKey, error) {
+	var prev *rsa.PrivateKey
+	if len(tokenKeysCache) > 1 {
+		prev = tokenKeysCache[1]
+	}
+	return tokenKeysCache[0], prev, nil
+}
+
+// verifyingKey returns the key with that token key ID among the issuing
+// and previous keys, and its ID in the moderation store.
+func verifyingKey(keyID string) (*rsa.PublicKey, int64, error) {
+	if _, _, err := tokenKeys(); err != nil {
+		return nil, 0, err
+	}
+	tokenKeysMu.Lock()
+	defer tokenKeysMu.Unlock()
+	for i, k := range tokenKeysCache {
+		if i < len(tokenKeyIDs) && privpass.KeyID(&k.PublicKey) == keyID {
+			return &k.PublicKey, tokenKeyIDs[i], nil
+		}
+	}
+	return nil, 0, privpass.ErrUnknownKey
+}
+
+// redeemToken verifies a token and marks it spent in the moderation store,
+// where the spend stays until its key is dropped. A token whose spend can't
+// be recorded is rejected.
+func redeemToken(raw string) error {
+	tok, err := privpass.ParseToken(raw)
+	if err != nil {
+		return err
+	}
+	pub, keyID, err := verifyingKey(tok.KeyID)
+	if err != nil {
+		return err
+	}
+	if err := privpass.Verify(pub, tok); err != nil {
+		return err
+	}
+	fresh, err := modStore.SpendToken(context.Background(), keyID, privpass.SpendID(tok))
+	if err != nil {
+		utils.Log("Error recording token spend: %v", err)
+		return errTokenUnrecorded
+	}
+	if !fresh {
+		return errTokenSpent
+	}
+	return nil
+}
+
+// tokenGate redeems the token in X-Gitgost-Token, if any. A bad token is
+// rejected outright rather than silently falling back to other checks.
+func tokenGate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := strings.TrimSpace(c.GetHeader(tokenHeader))
+		if raw == "" {
+			c.Next()
+			return
+		}
+		if err := redeemToken(raw); err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token rejected: " + err.Error()})
+			return
+		}
+		c.Set(tokenRedeemedKey, true)
+		c.Next()
+	}
+}
+
+func tokenRedeemed(c *gin.Context) bool {
+	return c.GetBool(tokenRedeemedKey)
+}
+
+// humanVerified reports whether the request redeemed a token or carries a
+// valid CAPTCHA.
+func humanVerified(c *gin.Context, captchaToken string) bool {
+	return tokenRedeemed(c) || verifyMentaCaptcha(captchaToken)
+}
+
+// TokenKeysHandler publishes the keys tokens are currently signed with.
+func TokenKeysHandler(c *gin.Context) {
+	cur, prev, err := tokenKeys()
+	if err != nil {
+		utils.Log("Error generating token key: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "token issuance unavailable"})
+		return
+	}
+	keys := []gin.H{}
+	for _, k := range []*rsa.PrivateKey{cur, prev} {
+		if k == nil {
+			continue
+		}
+		n, e := privpass.EncodePublicKey(&k.PublicKey)
+		keys = append(keys, gin.H{"id": privpass.KeyID(&k.PublicKey), "n": n, "e": e})
+	}
+	c.JSON(http.StatusOK, gin.H{"keys": keys, "issuing": keys[0]["id"], "batch_max": tokenBatchMax})
+}
+
+type tokenIssueRequest struct {
+	KeyID        string   `json:"key_id"`
+	Blinded      []string `json:"blinded"`
+	CaptchaToken string   `json:"captcha_token"`
+}
+
+// TokenIssueHandler blind-signs a batch of tokens. The client proves it is
+// not a script with a CAPTCHA or, failing that, an always-on proof of work.
+func TokenIssueHandler(c *gin.Context) {
+	var req tokenIssueRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Blinded) == 0 || len(req.Blinded) > tokenBatchMax {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("send between 1 and %d blinded tokens", tokenBatchMax)})
+		return
+	}
+	cur, _, err := tokenKeys()
+	if err != nil {
+		utils.Log("Error generating token key: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "token issuance unavailable"})
+		return
+	}
+	if req.KeyID != privpass.KeyID(&cur.PublicKey) {
+		c.JSON(http.StatusConflict, gin.H{"error": "issuing key changed; fetch /api/tokens/keys again"})
+		return
+	}
+
+	// Without a CAPTCHA service configured verifyMentaCaptcha lets
+	// everything through, so only a proof of work counts then.
+	if mentaAPIEndpoint == "" || !verifyMentaCaptcha(req.CaptchaToken) {
+		powMu.RLock()
+		bits := powBaseBits + tokenPowExtraBits
+		powMu.RUnlock()
+		if err := checkPowAt(c.GetHeader(powHeader), bits); err != nil {
+			resp := gin.H{"error": "captcha or proof of work required: " + err.Error()}
+			if challenge, err := getPowIssuer().Issue(bits, time.Now()); err == nil {
+				c.Header(powChallengeHeader, challenge)
+				resp["challenge"] = challenge
+				resp["bits"] = bits
+			}
+			c.JSON(http.StatusPreconditionRequired, resp)
+			return
+		}
+	}
+
+	if windowAdd(tokenIssueStore, clientKey(c), time.Now(), tokenIssueWin, tokenIssueMax) > tokenIssueMax {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "token issuance rate limit exceeded"})
+		return
+	}
+
+	sigs := make([]string, 0, len(req.Blinded))
+	for _, b := range req.Blinded {
+		raw, err := base64.RawURLEncoding.DecodeString(b)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid blinded token"})
+			return
+		}
+		sig, err := privpass.Sign(cur, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid blinded token"})
+			return
+		}
+		sigs = append(sigs, base64.RawURLEncoding.EncodeToString(sig))
+	}
+	c.JSON(http.StatusOK, gin.H{"key_id": req.KeyID, "signatures": sigs})
+}
@@ -0,0 +1,129 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/moderation"
+	"github.com/livrasand/gitGost/internal/pow"
+	"github.com/livrasand/gitGost/internal/privpass"
+)
+
+func useTestTokenKey(t *testing.T) {
+	t.Helper()
+	old := modStore
+	setModerationStore(moderation.NewMemory())
+	tokenKeysMu.Lock()
+	oldBits := tokenKeyBits
+	tokenKeyBits = 1024
+	tokenKeysMu.Unlock()
+	t.Cleanup(func() {
+		setModerationStore(old)
+		tokenKeysMu.Lock()
+		tokenKeyBits = oldBits
+		tokenKeysMu.Unlock()
+	})
+}
+
+// failingSpends is a moderation store that can't record token spends.
+type failingSpends struct{ moderation.Store }
+
+func (failingSpends) SpendToken(context.Context, int64, string) (bool, error) {
+	return false, errors.New("disk full")
+}
+
+// forgetTokenKeys drops this process's copy of the keys, as a restart or
+// another instance would start without it.
+func forgetTokenKeys() {
+	tokenKeysMu.Lock()
+	tokenKeysCache = nil
+	tokenKeysMu.Unlock()
+}
+
+func TestTokenIssueAndRedeem(t *testing.T) {
+	useTestTokenKey(t)
+	setPowMode(t, powModeAlways, 4)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/tokens", TokenIssueHandler)
+	r.POST("/write", tokenGate(), powGate(), func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	cur, _, err := tokenKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	blinded, pending, err := privpass.Blind(&cur.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, _ := json.Marshal(tokenIssueRequest{
+		KeyID:   privpass.KeyID(&cur.PublicKey),
+		Blinded: []string{base64.RawURLEncoding.EncodeToString(blinded)},
+	})
+	issue := func(solution string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/tokens", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if solution != "" {
+			req.Header.Set(powHeader, solution)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := issue("")
+	if w.Code != http.StatusPreconditionRequired {
+		t.Fatalf("issuance without work = %d, want 428", w.Code)
+	}
+	solution, err := pow.Solve(context.Background(), w.Header().Get(powChallengeHeader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = issue(solution)
+	if w.Code != http.StatusOK {
+		t.Fatalf("issuance = %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Signatures []string `json:"signatures"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Signatures) != 1 {
+		t.Fatalf("issuance body = %s", w.Body)
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(resp.Signatures[0])
+	tok, err := pending.Finish(sig)
+	if err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+
+	write := func(token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/write", nil)
+		req.Header.Set(tokenHeader, token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	store := modStore
+	setModerationStore(failingSpends{store})
+	if code := write(tok.String()); code != http.StatusUnauthorized {
+		t.Errorf("write with unrecorded spend = %d, want 401", code)
+	}
+	setModerationStore(store)
+	forgetTokenKeys()
+	if code := write(tok.String()); code != http.StatusCreated {
+		t.Fatalf("write with token = %d, want 201 without proof of work", code)
+	}
+	if code := write(tok.String()); code != http.StatusUnauthorized {
+		t.Errorf("double-spent token = %d, want 401", code)
+	}
+	if code := write("v1.nope.AAAA.AAAA"); code != http.StatusUnauthorized {
+		t.Errorf("garbage token = %d, want 401", code)
+	}
+}
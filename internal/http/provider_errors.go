@@ -0,0 +1,58 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/provider"
+)
+
+// providerErrorStatus maps a typed provider error to the HTTP status we
+// return to API clients. Untyped errors keep the given fallback.
+func providerErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, provider.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, provider.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, provider.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, provider.ErrConflict):
+		return http.StatusConflict
+	}
+	return fallback
+}
+
+// respondProviderError writes the JSON error for a failed provider call,
+// setting Retry-After when the forge told us when to come back.
+func respondProviderError(c *gin.Context, err error, fallback int) {
+	status := providerErrorStatus(err, fallback)
+	body := gin.H{"error": err.Error()}
+	if wait, ok := provider.RetryAfter(err); ok {
+		secs := int(wait.Round(time.Second) / time.Second)
+		c.Header("Retry-After", strconv.Itoa(secs))
+		body["retry_after"] = secs
+	}
+	c.JSON(status, body)
+}
+
+// writeProviderErrorSideband explains a failed provider call to the git
+// client in plain words before the fatal band-3 line is written.
+func writeProviderErrorSideband(w io.Writer, prov provider.Provider, err error) {
+	switch {
+	case errors.Is(err, provider.ErrRateLimited):
+		wait, _ := provider.RetryAfter(err)
+		WriteSidebandLine(w, 2, fmt.Sprintf("remote: gitGost: %s rate limit reached, try again in %s.", prov.Name(), wait.Round(time.Second)))
+	case errors.Is(err, provider.ErrNotFound):
+		WriteSidebandLine(w, 2, fmt.Sprintf("remote: gitGost: repository not found on %s.", prov.Name()))
+	case errors.Is(err, provider.ErrForbidden):
+		WriteSidebandLine(w, 2, fmt.Sprintf("remote: gitGost: %s refused the request (blocked or missing permissions).", prov.Name()))
+	case errors.Is(err, provider.ErrConflict):
+		WriteSidebandLine(w, 2, fmt.Sprintf("remote: gitGost: %s reported a conflict (the pull request may already exist).", prov.Name()))
+	}
+}
@@ -0,0 +1,367 @@
+package http
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/prmsg"
+	"github.com/livrasand/gitGost/internal/provider"
+	"github.com/livrasand/gitGost/internal/utils"
+)
+
+const (
+	replyBoxMax = 10000
+	replyBoxTTL = 30 * 24 * time.Hour
+	// replyTTL is how long a message stays readable.
+	replyTTL        = 14 * 24 * time.Hour
+	maxRepliesPerPR = 40
+	// replyMaxSkew bounds how far a reply's timestamp may be from now, so a
+	// captured reply can't be replayed later.
+	replyMaxSkew = 10 * time.Minute
+)
+
+// Sides of the conversation in a reply box.
+const (
+	replyMaintainer  = "maintainer"
+	replyContributor = "contributor"
+)
+
+// reply is a stored message and who sent it.
+type reply struct {
+	prmsg.Message
+	ID        string    `json:"id"`
+	From      string    `json:"from"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Read      bool      `json:"read"`
+}
+
+// replyBox holds the encrypted conversation about one anonymous PR. Only
+// public keys and token hashes are kept; the contributor's secret is shown
+// once at push time.
+type replyBox struct {
+	Owner                string
+	Repo                 string
+	Provider             string
+	ContributorKey       string
+	ContributorTokenHash string
+	Messages             []reply
+}
+
+// live drops expired messages.
+func (b replyBox) live(now time.Time) replyBox {
+	kept := b.Messages[:0:0]
+	for _, m := range b.Messages {
+		if now.Before(m.ExpiresAt) {
+			kept = append(kept, m)
+		}
+	}
+	b.Messages = kept
+	return b
+}
+
+// unread counts the live unread messages addressed to party.
+func (b replyBox) unread(party string, now time.Time) int {
+	n := 0
+	for _, m := range b.live(now).Messages {
+		if m.From != party && !m.Read {
+			n++
+		}
+	}
+	return n
+}
+
+var replyBoxes = newBoundedMap[replyBox]("reply-boxes", replyBoxMax, replyBoxTTL)
+
+// openReplyBox creates the reply box of a new PR and returns the secret the
+// contributor needs to read and send replies. Pushes updating a PR keep
+// their box and get "".
+func openReplyBox(prHash, owner, repo, provShort string) string {
+	if _, ok := replyBoxes.Get(prHash); ok {
+		return ""
+	}
+	secret, public, err := prmsg.NewSecret()
+	if err != nil {
+		utils.Log("Error creating reply key for %s: %v", prHash, err)
+		return ""
+	}
+	token, err := prmsg.AuthToken(secret)
+	if err != nil {
+		utils.Log("Error creating reply token for %s: %v", prHash, err)
+		return ""
+	}
+	replyBoxes.Set(prHash, replyBox{
+		Owner: owner, Repo: repo, Provider: provShort,
+		ContributorKey: public, ContributorTokenHash: prmsg.TokenHash(token),
+	})
+	return secret
+}
+
+// replyStatus summarizes unread replies for /api/pr/:hash/status.
+func replyStatus(prHash string) gin.H {
+	box, ok := replyBoxes.Get(prHash)
+	if !ok {
+		return nil
+	}
+	now := time.Now()
+	return gin.H{
+		"unread_for_contributor": box.unread(replyContributor, now),
+		"unread_for_maintainer":  box.unread(replyMaintainer, now),
+	}
+}
+
+// maintainerKey returns the signing key policy publishes, or nil when it has
+// none.
+func maintainerKey(policy *provider.RepoPolicy) ed25519.PublicKey {
+	if policy == nil || policy.PublicKey == "" {
+		return nil
+	}
+	pub, err := prmsg.ParsePublicKey(policy.PublicKey)
+	if err != nil {
+		return nil
+	}
+	return pub
+}
+
+func replyBoxFromRequest(c *gin.Context) (string, replyBox, bool) {
+	hash := strings.ToLower(strings.TrimSpace(c.Param("hash")))
+	box, ok := replyBoxes.Get(hash)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"hash": hash, "error": "no private replies are possible for this PR"})
+		return "", replyBox{}, false
+	}
+	return hash, box, true
+}
+
+func replyToken(c *gin.Context) string {
+	if tok := c.GetHeader("X-Gitgost-Mailbox-Token"); tok != "" {
+		return tok
+	}
+	return strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+}
+
+// replyParty tells which side of the conversation the request's token
+// belongs to, or "".
+func replyParty(c *gin.Context, box replyBox, policy *provider.RepoPolicy) string {
+	tok := replyToken(c)
+	if prmsg.TokenMatches(tok, box.ContributorTokenHash) {
+		return replyContributor
+	}
+	if policy != nil && policy.Mailbox != nil && prmsg.TokenMatches(tok, policy.Mailbox.TokenSHA256) {
+		return replyMaintainer
+	}
+	return ""
+}
+
+func replyPolicy(c *gin.Context, box replyBox) (*provider.RepoPolicy, bool) {
+	policy, err := loadRepoPolicy(c.Request.Context(), providerFromName(box.Provider), box.Owner, box.Repo)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "the repository's .gitgost.yml is invalid"})
+		return nil, false
+	}
+	return policy, true
+}
+
+// PRReplyKeyHandler gives each side what it needs to write a reply: the
+// contributor key maintainers encrypt to, the mailbox key contributors
+// encrypt to, and the maintainer key gitGost verifies signatures against.
+func PRReplyKeyHandler(c *gin.Context) {
+	hash, box, ok := replyBoxFromRequest(c)
+	if !ok {
+		return
+	}
+	resp := gin.H{
+		"hash":            hash,
+		"owner":           box.Owner,
+		"repo":            box.Repo,
+		"contributor_key": box.ContributorKey,
+		"verified":        false,
+	}
+	if policy, err := loadRepoPolicy(c.Request.Context(), providerFromName(box.Provider), box.Owner, box.Repo); err == nil {
+		if pub := maintainerKey(policy); pub != nil {
+			resp["verified"] = true
+			resp["maintainer_key"] = prmsg.FormatPublicKey(pub)
+		}
+		if policy.Mailbox != nil {
+			resp["mailbox_key"] = policy.Mailbox.Key
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// PRRepliesHandler lists the live replies addressed to the caller and marks
+// them read.
+func PRRepliesHandler(c *gin.Context) {
+	hash, box, ok := replyBoxFromRequest(c)
+	if !ok {
+		return
+	}
+	policy, ok := replyPolicy(c, box)
+	if !ok {
+		return
+	}
+	party := replyParty(c, box, policy)
+	if party == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "a valid mailbox token is required"})
+		return
+	}
+
+	now := time.Now()
+	var inbox []reply
+	replyBoxes.Update(hash, func(b replyBox, present bool) replyBox {
+		if !present {
+			b = box
+		}
+		b = b.live(now)
+		inbox = nil
+		for i := range b.Messages {
+			if b.Messages[i].From != party {
+				inbox = append(inbox, b.Messages[i])
+				b.Messages[i].Read = true
+			}
+		}
+		return b
+	})
+	if inbox == nil {
+		inbox = []reply{}
+	}
+	c.JSON(http.StatusOK, gin.H{"hash": hash, "as": party, "messages": inbox})
+}
+
+// PRReplyPostHandler stores a reply from either side. Contributors and
+// maintainers authenticate with their mailbox token; maintainers may instead
+// sign with the public_key of .gitgost.yml.
+func PRReplyPostHandler(c *gin.Context) {
+	hash, box, ok := replyBoxFromRequest(c)
+	if !ok {
+		return
+	}
+	var msg prmsg.Message
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message"})
+		return
+	}
+	if len(msg.Ciphertext) > prmsg.MaxPlaintext+64 {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("replies are limited to %d bytes", prmsg.MaxPlaintext)})
+		return
+	}
+	now := time.Now()
+	if skew := now.Sub(time.Unix(msg.SentAt, 0)); skew > replyMaxSkew || skew < -replyMaxSkew {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sent_at is too far from the current time"})
+		return
+	}
+
+	policy, ok := replyPolicy(c, box)
+	if !ok {
+		return
+	}
+	party := replyParty(c, box, policy)
+	if party == "" && len(msg.Signature) > 0 {
+		if pub := maintainerKey(policy); pub != nil && prmsg.Verify(pub, hash, &msg) == nil {
+			party = replyMaintainer
+		}
+	}
+	switch {
+	case party == "":
+		c.JSON(http.StatusForbidden, gin.H{"error": "a valid mailbox token or maintainer signature is required"})
+		return
+	case party == replyContributor && policy.Mailbox == nil:
+		c.JSON(http.StatusForbidden, gin.H{"error": "the maintainer has not set up a mailbox in .gitgost.yml"})
+		return
+	}
+
+	sum := sha256.Sum256(append(append([]byte{}, msg.Nonce...), msg.Ciphertext...))
+	stored := reply{Message: msg, ID: hex.EncodeToString(sum[:8]), From: party, ExpiresAt: now.Add(replyTTL)}
+	var rejected string
+	_, err := replyBoxes.Update(hash, func(b replyBox, present bool) replyBox {
+		if !present {
+			b = box
+		}
+		b = b.live(now)
+		rejected = ""
+		for _, m := range b.Messages {
+			if m.ID == stored.ID {
+				rejected = "reply already stored"
+				return b
+			}
+		}
+		if len(b.Messages) >= maxRepliesPerPR {
+			rejected = fmt.Sprintf("this PR already has %d replies", maxRepliesPerPR)
+			return b
+		}
+		b.Messages = append(b.Messages, stored)
+		return b
+	})
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "reply could not be stored; try again later"})
+		return
+	}
+	if rejected != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": rejected})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"hash": hash, "stored": true, "id": stored.ID, "from": party, "expires_at": stored.ExpiresAt})
+}
+
+// serveMaintainerVerifiedBadge shows whether a repo publishes a maintainer
+// key in .gitgost.yml.
+func serveMaintainerVerifiedBadge(c *gin.Context) {
+	owner, repo, found := strings.Cut(c.Query("repo"), "/")
+	verified := false
+	if found && owner != "" && repo != "" {
+		policy, err := loadRepoPolicy(c.Request.Context(), providerFromName(c.Query("provider")), owner, repo)
+		verified = err == nil && policy.PublicKey != ""
+	}
+
+	label, value, color := "gitGost", "maintainer verified", "#4CAF50"
+	if !verified {
+		value, color = "unverified", "#9E9E9E"
+	}
+	labelW := 56
+	valueW := len(value)*7 + 10
+	totalW := labelW + valueW
+	labelMid := labelW / 2
+	valueMid := labelW + valueW/2
+
+	svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s" viewBox="0 0 %d 20">
+  <title>%s: %s</title>
+  <linearGradient id="s" x2="0" y2="100%%">
+    <stop offset="0" stop-color="#bbb" stop-opacity=".1"/>
+    <stop offset="1" stop-opacity=".1"/>
+  </linearGradient>
+  <clipPath id="r">
+    <rect width="%d" height="20" rx="3" fill="#fff"/>
+  </clipPath>
+  <g clip-path="url(#r)">
+    <rect width="%d" height="20" fill="#555"/>
+    <rect x="%d" width="%d" height="20" fill="%s"/>
+    <rect width="%d" height="20" fill="url(#s)"/>
+  </g>
+  <g fill="#fff" text-anchor="middle" font-family="DejaVu Sans,Verdana,Geneva,sans-serif" font-size="110">
+    <text x="%d" y="150" fill="#010101" fill-opacity=".3" transform="scale(.1)" textLength="%d" lengthAdjust="spacing">%s</text>
+    <text x="%d" y="140" transform="scale(.1)" textLength="%d" lengthAdjust="spacing">%s</text>
+    <text x="%d" y="150" fill="#010101" fill-opacity=".3" transform="scale(.1)" textLength="%d" lengthAdjust="spacing">%s</text>
+    <text x="%d" y="140" transform="scale(.1)" textLength="%d" lengthAdjust="spacing">%s</text>
+  </g>
+</svg>`,
+		totalW, label, value, totalW,
+		label, value,
+		totalW,
+		labelW,
+		labelW, valueW, color,
+		totalW,
+		labelMid*10, (labelW-10)*10, label,
+		labelMid*10, (labelW-10)*10, label,
+		valueMid*10, (valueW-6)*10, value,
+		valueMid*10, (valueW-6)*10, value,
+	)
+
+	c.Header("Content-Type", "image/svg+xml")
+	c.Header("Cache-Control", "public, max-age=300")
+	c.String(http.StatusOK, svg)
+}
@@ -0,0 +1,153 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/prmsg"
+	"github.com/livrasand/gitGost/internal/provider"
+	"github.com/livrasand/gitGost/internal/tokenpool"
+)
+
+// cachePolicy primes the shared loader so handlers see raw as the policy of
+// owner/repo on GitHub without touching the network.
+func cachePolicy(t *testing.T, owner, repo, raw string) {
+	t.Helper()
+	old := provider.Policies
+	provider.Policies = provider.NewPolicyLoader(time.Hour, time.Hour)
+	t.Cleanup(func() { provider.Policies = old })
+	fetch := func(context.Context, string) (provider.PolicyFileResult, error) {
+		return provider.PolicyFileResult{Content: []byte(raw)}, nil
+	}
+	if _, _, err := provider.Policies.Load(context.Background(), provider.PolicyKey(tokenpool.GitHub, owner, repo), fetch); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPRReplies(t *testing.T) {
+	privText, pubText, _ := prmsg.GenerateMaintainerKey()
+	signer, _ := prmsg.ParsePrivateKey(privText)
+	boxSecret, boxKey, _ := prmsg.NewSecret()
+	maintainerToken, _ := prmsg.AuthToken(boxSecret)
+	cachePolicy(t, "acme", "widgets", "version: 1\npublic_key: \""+pubText+"\"\nmailbox:\n  key: \""+boxKey+"\"\n  token_sha256: "+prmsg.TokenHash(maintainerToken)+"\n")
+
+	secret := openReplyBox("deadbeef", "acme", "widgets", "gh")
+	if secret == "" || openReplyBox("deadbeef", "acme", "widgets", "gh") != "" {
+		t.Fatal("the secret must be issued exactly once per PR")
+	}
+	defer replyBoxes.Delete("deadbeef")
+	contributorToken, _ := prmsg.AuthToken(secret)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/pr/:hash/replies", PRRepliesHandler)
+	r.GET("/api/pr/:hash/replies/key", PRReplyKeyHandler)
+	r.POST("/api/pr/:hash/replies", PRReplyPostHandler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/pr/deadbeef/replies/key", nil))
+	var keys struct {
+		ContributorKey string `json:"contributor_key"`
+		MaintainerKey  string `json:"maintainer_key"`
+		MailboxKey     string `json:"mailbox_key"`
+		Verified       bool   `json:"verified"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &keys); err != nil || !keys.Verified || keys.MaintainerKey != pubText || keys.MailboxKey != boxKey {
+		t.Fatalf("keys response = %s", w.Body)
+	}
+
+	post := func(token string, m *prmsg.Message) int {
+		b, _ := json.Marshal(m)
+		req := httptest.NewRequest(http.MethodPost, "/api/pr/deadbeef/replies", bytes.NewReader(b))
+		if token != "" {
+			req.Header.Set("X-Gitgost-Mailbox-Token", token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	read := func(token, secret string) []string {
+		req := httptest.NewRequest(http.MethodGet, "/api/pr/deadbeef/replies", nil)
+		req.Header.Set("X-Gitgost-Mailbox-Token", token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var list struct {
+			Messages []reply `json:"messages"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Fatalf("list = %s", w.Body)
+		}
+		var out []string
+		for _, m := range list.Messages {
+			plain, err := prmsg.Open(secret, "deadbeef", &m.Message)
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			out = append(out, string(plain))
+		}
+		return out
+	}
+
+	now := time.Now().Unix()
+	signed, _ := prmsg.Seal(signer, keys.ContributorKey, "deadbeef", []byte("can you add a test?"), now)
+	if code := post("", signed); code != http.StatusCreated {
+		t.Fatalf("signed maintainer post = %d", code)
+	}
+	if code := post("", signed); code != http.StatusConflict {
+		t.Errorf("replayed message = %d, want 409", code)
+	}
+	otherPriv, _, _ := prmsg.GenerateMaintainerKey()
+	otherSigner, _ := prmsg.ParsePrivateKey(otherPriv)
+	forged, _ := prmsg.Seal(otherSigner, keys.ContributorKey, "deadbeef", []byte("hi"), now)
+	if code := post("", forged); code != http.StatusForbidden {
+		t.Errorf("forged message = %d, want 403", code)
+	}
+	viaToken, _ := prmsg.Encrypt(keys.ContributorKey, "deadbeef", []byte("thanks!"), now)
+	if code := post(maintainerToken, viaToken); code != http.StatusCreated {
+		t.Fatalf("token maintainer post = %d", code)
+	}
+	answer, _ := prmsg.Encrypt(keys.MailboxKey, "deadbeef", []byte("sure, done"), now)
+	if code := post(contributorToken, answer); code != http.StatusCreated {
+		t.Fatalf("contributor post = %d", code)
+	}
+
+	if st := replyStatus("deadbeef"); st["unread_for_contributor"] != 2 || st["unread_for_maintainer"] != 1 {
+		t.Errorf("status before reading = %v", st)
+	}
+	if got := read(contributorToken, secret); len(got) != 2 || got[0] != "can you add a test?" {
+		t.Errorf("contributor inbox = %q", got)
+	}
+	if got := read(maintainerToken, boxSecret); len(got) != 1 || got[0] != "sure, done" {
+		t.Errorf("maintainer inbox = %q", got)
+	}
+	if st := replyStatus("deadbeef"); st["unread_for_contributor"] != 0 || st["unread_for_maintainer"] != 0 {
+		t.Errorf("status after reading = %v", st)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/pr/deadbeef/replies", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous read = %d, want 401", w.Code)
+	}
+}
+
+func TestReplyExpiry(t *testing.T) {
+	now := time.Now()
+	b := replyBox{Messages: []reply{
+		{From: replyMaintainer, ExpiresAt: now.Add(-time.Second)},
+		{From: replyMaintainer, ExpiresAt: now.Add(time.Hour)},
+	}}
+	if n := b.unread(replyContributor, now); n != 1 {
+		t.Errorf("unread = %d, want only the live message", n)
+	}
+	if len(b.live(now).Messages) != 1 || len(b.Messages) != 2 {
+		t.Error("live must drop expired messages without touching the original")
+	}
+}
@@ -0,0 +1,200 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/moderation"
+	"github.com/livrasand/gitGost/internal/utils"
+)
+
+const (
+	// reportFlagScore and reportBlockScore are the weighted report totals at
+	// which a hash is flagged and blocked.
+	reportFlagScore  = 3
+	reportBlockScore = 6
+	// prCloseReporters is how many distinct reporters it takes to close a
+	// PR upstream, which a single report, even for malware, can't do.
+	prCloseReporters = 3
+	// reportDetailMax caps the optional note a reporter can attach.
+	reportDetailMax = 500
+	// reportNotesShown bounds how many notes the admin views list per hash.
+	reportNotesShown = 20
+)
+
+type reportCategory struct {
+	ID     string
+	Label  string
+	Weight int
+}
+
+// reportCategories are the reasons a reporter can pick, in display order.
+// Malware weighs a full block on its own.
+var reportCategories = []reportCategory{
+	{ID: "spam", Label: "Spam", Weight: 1},
+	{ID: "harassment", Label: "Harassment", Weight: 2},
+	{ID: "malware", Label: "Malware", Weight: reportBlockScore},
+	{ID: "illegal", Label: "Illegal content", Weight: 3},
+	{ID: "other", Label: "Other", Weight: 1},
+}
+
+func lookupReportCategory(id string) (reportCategory, bool) {
+	for _, cat := range reportCategories {
+		if cat.ID == id {
+			return cat, true
+		}
+	}
+	return reportCategory{}, false
+}
+
+// normalizeReportCategory maps reports filed before categories existed, or
+// with a category since removed, to "other".
+func normalizeReportCategory(id string) string {
+	if _, ok := lookupReportCategory(id); ok {
+		return id
+	}
+	return "other"
+}
+
+// cleanReportDetail trims the reporter's note, drops control characters and
+// caps its length.
+func cleanReportDetail(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '\n' {
+			return ' '
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(s))
+	if r := []rune(s); len(r) > reportDetailMax {
+		s = string(r[:reportDetailMax])
+	}
+	return s
+}
+
+// reportReason packs category and detail into the single reason column the
+// shared reports table has.
+func reportReason(category, detail string) string {
+	if detail == "" {
+		return category
+	}
+	return category + ": " + detail
+}
+
+func parseReportReason(reason string) (category, detail string) {
+	category, detail, _ = strings.Cut(reason, ": ")
+	return category, detail
+}
+
+type reportNote struct {
+	Category string    `json:"category"`
+	Detail   string    `json:"detail"`
+	At       time.Time `json:"at"`
+}
+
+// reportTally aggregates the reports against one hash.
+type reportTally struct {
+	Count      int            `json:"count"`
+	Score      int            `json:"score"`
+	Categories map[string]int `json:"categories"`
+	Notes      []reportNote   `json:"notes"`
+}
+
+func tallyReports(reports []moderation.Report) reportTally {
+	t := reportTally{Categories: make(map[string]int)}
+	for _, r := range reports {
+		cat, _ := lookupReportCategory(normalizeReportCategory(r.Category))
+		t.Count++
+		t.Score += cat.Weight
+		t.Categories[cat.ID]++
+		if r.Detail != "" {
+			t.Notes = append(t.Notes, reportNote{Category: cat.ID, Detail: r.Detail, At: r.At})
+		}
+	}
+	if len(t.Notes) > reportNotesShown {
+		t.Notes = t.Notes[len(t.Notes)-reportNotesShown:]
+	}
+	return t
+}
+
+// summary renders the per-category counts in display order, e.g.
+// "spam 2 · malware 1".
+func (t reportTally) summary() string {
+	var parts []string
+	for _, cat := range reportCategories {
+		if n := t.Categories[cat.ID]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", cat.ID, n))
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
+// getReportTally aggregates the reports against hash within reportWindow,
+// preferring whichever of Supabase and the local store holds more weight.
+func getReportTally(ctx context.Context, hash string) reportTally {
+	if hash == "" {
+		return tallyReports(nil)
+	}
+	since := time.Now().Add(-reportWindow)
+	local, err := modStore.Reports(ctx, hash, since)
+	if err != nil {
+		utils.Log("Error listing reports for hash %s: %v", hash, err)
+	}
+	tally := tallyReports(local)
+	if dbClient != nil {
+		_ = dbClient.DeleteOldReports(ctx, hash, since)
+		if rows, err := dbClient.GetReports(ctx, hash); err == nil {
+			remote := make([]moderation.Report, 0, len(rows))
+			for _, row := range rows {
+				if row.CreatedAt.Before(since) {
+					continue
+				}
+				category, detail := parseReportReason(row.Reason)
+				remote = append(remote, moderation.Report{Category: category, Detail: detail, At: row.CreatedAt})
+			}
+			if t := tallyReports(remote); t.Score > tally.Score {
+				tally = t
+			}
+		}
+	}
+	return tally
+}
+
+// getReportScore returns the weighted report total for hash, which the
+// flag and block thresholds are measured against.
+func getReportScore(ctx context.Context, hash string) int {
+	return getReportTally(ctx, hash).Score
+}
+
+func reportStateLabel(score int) string {
+	switch {
+	case score >= reportBlockScore:
+		return "bloqueado"
+	case score >= reportFlagScore:
+		return "flagged"
+	default:
+		return "registrado"
+	}
+}
+
+// AdminReportsHandler shows the aggregated reasons hash was reported for.
+func AdminReportsHandler(c *gin.Context) {
+	if _, ok := requireAdmin(c, roleModerator, ""); !ok {
+		return
+	}
+	hash := strings.TrimSpace(c.Param("hash"))
+	tally := getReportTally(c.Request.Context(), hash)
+	c.JSON(http.StatusOK, gin.H{
+		"hash":    hash,
+		"state":   reportStateLabel(tally.Score),
+		"blocked": isBlockedHash(hash),
+		"reports": tally,
+	})
+}
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/livrasand/gitGost/internal/moderation"
+)
+
+func TestTallyReportsWeighsCategories(t *testing.T) {
+	now := time.Now()
+	tally := tallyReports([]moderation.Report{
+		{Category: "spam", At: now},
+		{Category: "harassment", Detail: "insults", At: now},
+		{Category: "report", At: now}, // filed before categories existed
+	})
+	if tally.Count != 3 || tally.Score != 4 {
+		t.Fatalf("tally = %+v", tally)
+	}
+	if tally.Categories["other"] != 1 || tally.Categories["harassment"] != 1 {
+		t.Errorf("categories = %v", tally.Categories)
+	}
+	if len(tally.Notes) != 1 || tally.Notes[0].Detail != "insults" {
+		t.Errorf("notes = %+v", tally.Notes)
+	}
+	if got := tally.summary(); got != "spam 1 · harassment 1 · other 1" {
+		t.Errorf("summary = %q", got)
+	}
+}
+
+func TestRecordReportThresholds(t *testing.T) {
+	old := modStore
+	setModerationStore(moderation.NewMemory())
+	t.Cleanup(func() { setModerationStore(old) })
+	ctx := context.Background()
+
+	report := func(hash, reporter, category string) reportTally {
+		return recordReport(ctx, hash, moderation.Report{Reporter: reporter, Category: category, At: time.Now()})
+	}
+
+	if got := report("spammy", "a", "spam").Score; got != 1 {
+		t.Fatalf("score = %d", got)
+	}
+	if got := report("spammy", "a", "malware").Score; got != 1 {
+		t.Fatalf("repeat reporter counted: %d", got)
+	}
+	report("spammy", "b", "spam")
+	if got := report("spammy", "c", "spam"); got.Score != reportFlagScore || reportStateLabel(got.Score) != "flagged" {
+		t.Fatalf("three spam reports = %+v", got)
+	}
+	if _, flagged, _ := modStore.LastFlagged(ctx, "spammy"); !flagged {
+		t.Error("hash not flagged at reportFlagScore")
+	}
+
+	if got := report("dropper", "a", "malware").Score; got < reportBlockScore {
+		t.Errorf("malware score = %d, want a block", got)
+	}
+}
+
+func TestCleanReportDetail(t *testing.T) {
+	if got := cleanReportDetail("  line one\nline\x00 two  "); got != "line one line two" {
+		t.Errorf("cleanReportDetail = %q", got)
+	}
+	if got := cleanReportDetail(strings.Repeat("ñ", reportDetailMax+10)); len([]rune(got)) != reportDetailMax {
+		t.Errorf("detail not capped: %d runes", len([]rune(got)))
+	}
+	if cat, detail := parseReportReason(reportReason("illegal", "a: b")); cat != "illegal" || detail != "a: b" {
+		t.Errorf("reason round trip = %q, %q", cat, detail)
+	}
+}
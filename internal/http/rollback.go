@@ -0,0 +1,166 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livrasand/gitGost/internal/moderation"
+	"github.com/livrasand/gitGost/internal/utils"
+)
+
+const (
+	// rollbackMaxPRs caps how many PRs one targeted rollback may close.
+	rollbackMaxPRs = 200
+	// rollbackMinHashPrefix keeps a hash prefix from matching half the log.
+	rollbackMinHashPrefix = 4
+	rollbackCloseWorkers  = 5
+)
+
+// rollbackSelector narrows a rollback to the persisted PRs of one repo (or
+// owner), one forge, a creation time window or a PR hash prefix.
+type rollbackSelector struct {
+	Repo       string `json:"repo"`
+	Provider   string `json:"provider"`
+	Since      string `json:"since"`
+	Until      string `json:"until"`
+	HashPrefix string `json:"hash_prefix"`
+	Preview    bool   `json:"preview"`
+}
+
+// query turns the selector into a PR query over open PRs. targeted is false
+// when no selector is set, which means the last burst.
+func (s rollbackSelector) query() (q moderation.PRQuery, targeted bool, err error) {
+	q = moderation.PRQuery{OpenOnly: true, Limit: rollbackMaxPRs + 1}
+	if s.Repo != "" {
+		owner, repo, _ := strings.Cut(strings.Trim(s.Repo, "/"), "/")
+		if owner == "" || strings.Contains(repo, "/") {
+			return q, false, fmt.Errorf("repo must be owner or owner/repo")
+		}
+		q.Owner, q.Repo = owner, repo
+	}
+	switch s.Provider {
+	case "", "gh", "gl", "cb":
+		q.Provider = s.Provider
+	default:
+		return q, false, fmt.Errorf("provider must be gh, gl or cb")
+	}
+	for _, f := range []struct {
+		name, value string
+		dst         *time.Time
+	}{{"since", s.Since, &q.Since}, {"until", s.Until, &q.Until}} {
+		if f.value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, f.value)
+		if err != nil {
+			return q, false, fmt.Errorf("invalid %s, want RFC 3339", f.name)
+		}
+		*f.dst = t
+	}
+	if s.HashPrefix != "" && len(s.HashPrefix) < rollbackMinHashPrefix {
+		return q, false, fmt.Errorf("hash_prefix needs at least %d characters", rollbackMinHashPrefix)
+	}
+	q.HashPrefix = s.HashPrefix
+	targeted = s.Repo != "" || s.Provider != "" || s.Since != "" || s.Until != "" || s.HashPrefix != ""
+	return q, targeted, nil
+}
+
+// selects reports whether any selector, or a preview, is set.
+func (s rollbackSelector) selects() bool {
+	return s.Repo != "" || s.Provider != "" || s.Since != "" || s.Until != "" || s.HashPrefix != "" || s.Preview
+}
+
+// describe renders the selector for the audit log, e.g.
+// "repo=acme/site since=2026-10-01T00:00:00Z".
+func (s rollbackSelector) describe() string {
+	var parts []string
+	for _, f := range []struct{ name, value string }{
+		{"repo", s.Repo}, {"provider", s.Provider}, {"since", s.Since}, {"until", s.Until}, {"hash_prefix", s.HashPrefix},
+	} {
+		if f.value != "" {
+			parts = append(parts, f.name+"="+f.value)
+		}
+	}
+	if len(parts) == 0 {
+		return "burst"
+	}
+	return strings.Join(parts, " ")
+}
+
+// rollbackResult is what a rollback did, or would do, with one PR.
+type rollbackResult struct {
+	moderation.PR
+	Status string `json:"status"` // would_close, closed or failed
+	Error  string `json:"error,omitempty"`
+}
+
+// burstPRs lists the PRs collected during the last burst alert, emptying
+// the list when take is set.
+func burstPRs(take bool) []moderation.PR {
+	if !take {
+		prs, _ := burstPRsStore.Get("recent")
+		return prs
+	}
+	var prs []moderation.PR
+	burstPRsStore.Update("recent", func(old []moderation.PR, _ bool) []moderation.PR {
+		prs = old
+		return []moderation.PR{}
+	})
+	return prs
+}
+
+// recordBurstPR adds a PR opened while the burst alert is active, dropping
+// the ones older than recentBurstPRsTTL.
+func recordBurstPR(prURL string, now time.Time) {
+	burstPRsStore.Update("recent", func(old []moderation.PR, _ bool) []moderation.PR {
+		cutoff := now.Add(-recentBurstPRsTTL)
+		prs := make([]moderation.PR, 0, len(old)+1)
+		for _, pr := range old {
+			if pr.CreatedAt.After(cutoff) {
+				prs = append(prs, pr)
+			}
+		}
+		return append(prs, moderation.PR{URL: prURL, Provider: providerFromURL(prURL), CreatedAt: now})
+	})
+}
+
+func providerFromURL(u string) string {
+	switch {
+	case strings.Contains(u, "gitlab.com"):
+		return "gl"
+	case strings.Contains(u, "codeberg.org"):
+		return "cb"
+	default:
+		return "gh"
+	}
+}
+
+// closeRollbackPRs closes prs a few at a time and marks each closed PR in
+// the store. Results keep the order of prs.
+func closeRollbackPRs(ctx context.Context, prs []moderation.PR) []rollbackResult {
+	results := make([]rollbackResult, len(prs))
+	sem := make(chan struct{}, rollbackCloseWorkers)
+	var wg sync.WaitGroup
+	for i, pr := range prs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = rollbackResult{PR: pr, Status: "closed"}
+			if err := closeTrackedPR(ctx, prTrack{PRURL: pr.URL, Provider: pr.Provider}); err != nil {
+				utils.Log("rollback: failed to close %s: %v", pr.URL, err)
+				results[i].Status, results[i].Error = "failed", err.Error()
+				return
+			}
+			if err := modStore.MarkPRClosed(ctx, pr.URL, time.Now()); err != nil {
+				utils.Log("rollback: error marking %s closed: %v", pr.URL, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,135 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/moderation"
+)
+
+func TestRollbackSelector(t *testing.T) {
+	if _, targeted, err := (rollbackSelector{}).query(); err != nil || targeted {
+		t.Errorf("empty selector: targeted=%v err=%v", targeted, err)
+	}
+	q, targeted, err := rollbackSelector{Repo: "acme/site", Since: "2026-10-01T00:00:00Z"}.query()
+	if err != nil || !targeted || q.Owner != "acme" || q.Repo != "site" || q.Since.IsZero() || !q.OpenOnly {
+		t.Errorf("query = %+v, %v, %v", q, targeted, err)
+	}
+	for _, bad := range []rollbackSelector{
+		{Repo: "a/b/c"},
+		{Provider: "bitbucket"},
+		{Since: "yesterday"},
+		{HashPrefix: "ab"},
+	} {
+		if _, _, err := bad.query(); err == nil {
+			t.Errorf("%+v accepted", bad)
+		}
+	}
+}
+
+func TestTargetedRollback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	old := modStore
+	setModerationStore(moderation.NewMemory())
+	t.Cleanup(func() { setModerationStore(old) })
+	oldPass := panicPassword
+	panicPassword = "pw"
+	t.Cleanup(func() { panicPassword = oldPass })
+	if err := InitAdmins("alice:operator:" + digest("s")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = InitAdmins("") })
+
+	var mu sync.Mutex
+	var closed []string
+	oldClose := closeTrackedPR
+	closeTrackedPR = func(_ context.Context, t prTrack) error {
+		if strings.HasSuffix(t.PRURL, "/3") {
+			return errors.New("forbidden")
+		}
+		mu.Lock()
+		closed = append(closed, t.PRURL)
+		mu.Unlock()
+		return nil
+	}
+	t.Cleanup(func() { closeTrackedPR = oldClose })
+
+	ctx := context.Background()
+	now := time.Now()
+	for i, pr := range []moderation.PR{
+		{Hash: "aaaa0001", Provider: "gh", Owner: "acme", Repo: "site", URL: "https://github.com/acme/site/pull/1"},
+		{Hash: "aaaa0002", Provider: "gh", Owner: "acme", Repo: "other", URL: "https://github.com/acme/other/pull/2"},
+		{Hash: "aaaa0003", Provider: "gh", Owner: "Acme", Repo: "Site", URL: "https://github.com/acme/site/pull/3"},
+	} {
+		pr.CreatedAt = now.Add(time.Duration(i) * time.Minute)
+		_ = modStore.AddPR(ctx, pr)
+	}
+
+	r := gin.New()
+	r.POST("/admin/rollback", RollbackBurstHandler)
+	rollbackAs := func(cred, body string) (int, map[string]any) {
+		req := httptest.NewRequest(http.MethodPost, "/admin/rollback", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if cred != "" {
+			req.Header.Set("X-Admin-Password", cred)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var out map[string]any
+		_ = json.Unmarshal(w.Body.Bytes(), &out)
+		return w.Code, out
+	}
+	rollback := func(body string) (int, map[string]any) { return rollbackAs("alice:s", body) }
+
+	// Neither the shared panic password nor an ntfy action token may pick
+	// PRs by selector or preview them.
+	if code, out := rollbackAs("pw", `{"repo":"acme/site","preview":true}`); code != http.StatusForbidden {
+		t.Fatalf("panic password preview = %d %v", code, out)
+	}
+	token := newActionToken()
+	if code, out := rollbackAs("", `{"token":"`+token+`","repo":"acme/site"}`); code != http.StatusUnauthorized {
+		t.Fatalf("action token targeted rollback = %d %v", code, out)
+	}
+	if _, ok := actionTokens.Get(token); !ok {
+		t.Error("action token consumed by a rejected targeted rollback")
+	}
+	if len(closed) != 0 {
+		t.Fatalf("closed %v without a named operator", closed)
+	}
+
+	code, out := rollback(`{"repo":"acme/site","preview":true}`)
+	if code != http.StatusOK || out["matched"] != float64(2) || len(closed) != 0 {
+		t.Fatalf("preview = %d %v (closed %v)", code, out, closed)
+	}
+
+	code, out = rollback(`{"repo":"acme/site","reason":"slow spam"}`)
+	if code != http.StatusOK || out["closed"] != float64(1) || out["failed"] != float64(1) {
+		t.Fatalf("rollback = %d %v", code, out)
+	}
+	results := out["results"].([]any)
+	statuses := map[string]string{}
+	for _, r := range results {
+		m := r.(map[string]any)
+		statuses[m["url"].(string)] = m["status"].(string)
+	}
+	if statuses["https://github.com/acme/site/pull/1"] != "closed" || statuses["https://github.com/acme/site/pull/3"] != "failed" {
+		t.Errorf("results = %v", statuses)
+	}
+
+	open, _ := modStore.PRs(ctx, moderation.PRQuery{OpenOnly: true})
+	if len(open) != 2 {
+		t.Errorf("open after rollback = %+v", open)
+	}
+	entries, _ := modStore.AuditLog(ctx, moderation.AuditQuery{Action: "rollback"})
+	if len(entries) != 1 || entries[0].Target != "repo=acme/site" || entries[0].Detail != "closing 2 PRs" || entries[0].Reason != "slow spam" {
+		t.Errorf("audit = %+v", entries)
+	}
+}
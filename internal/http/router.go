@@ -29,7 +29,7 @@ func localhostCORS() gin.HandlerFunc {
 		if isLocalhostOrigin(origin) {
 			c.Header("Access-Control-Allow-Origin", origin)
 			c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-			c.Header("Access-Control-Allow-Headers", "Content-Type")
+			c.Header("Access-Control-Allow-Headers", "Content-Type, X-Gitgost-Subscription")
 			if c.Request.Method == "OPTIONS" {
 				c.AbortWithStatus(http.StatusNoContent)
 				return
@@ -65,14 +65,14 @@ const (
 )
 
 var (
-	adminLimiterStore = newBoundedMap[[]time.Time](adminLimiterStoreMax, adminLimiterWin)
+	adminLimiterStore = newBoundedMap[[]time.Time]("admin-limit", adminLimiterStoreMax, adminLimiterWin)
 	adminLimiterMax   = 10
 	adminLimiterWin   = time.Minute
 )
 
 func adminLimiter() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
+		ip := clientKey(c)
 		count := windowAdd(adminLimiterStore, ip, time.Now(), adminLimiterWin, adminLimiterMax)
 		if count > adminLimiterMax {
 			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "admin rate limit exceeded"})
@@ -96,14 +96,16 @@ var (
 	proxyLimiterWin = time.Minute
 )
 
-func proxyLimiter() gin.HandlerFunc {
-	return prCheckWindowLimiter("proxy rate limit exceeded", proxyLimiterMax, proxyLimiterWin)
+func proxyLimiter(route string) gin.HandlerFunc {
+	return prCheckWindowLimiter("proxy-limit:"+route, "proxy rate limit exceeded", proxyLimiterMax, proxyLimiterWin)
 }
 
-func prCheckWindowLimiter(message string, max int, win time.Duration) gin.HandlerFunc {
-	store := newBoundedMap[[]time.Time](prCheckLimiterStoreMax, win)
+// prCheckWindowLimiter gives each route its own window in namespace ns.
+// Names must be unique and stable, since every instance has to agree on them.
+func prCheckWindowLimiter(ns, message string, max int, win time.Duration) gin.HandlerFunc {
+	store := newBoundedMap[[]time.Time](ns, prCheckLimiterStoreMax, win)
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
+		ip := clientKey(c)
 		count := windowAdd(store, ip, time.Now(), win, max)
 		if count > max {
 			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": message})
@@ -113,19 +115,19 @@ func prCheckWindowLimiter(message string, max int, win time.Duration) gin.Handle
 	}
 }
 
-func prCheckLimiter() gin.HandlerFunc {
-	return prCheckWindowLimiter("PR check rate limit exceeded", prCheckLimiterMax, prCheckLimiterWin)
+func prCheckLimiter(route string) gin.HandlerFunc {
+	return prCheckWindowLimiter("pr-check-limit:"+route, "PR check rate limit exceeded", prCheckLimiterMax, prCheckLimiterWin)
 }
 
 var (
-	v2LimiterStore = newBoundedMap[[]time.Time](prCheckLimiterStoreMax, v2LimiterWin)
+	v2LimiterStore = newBoundedMap[[]time.Time]("v2-limit", prCheckLimiterStoreMax, v2LimiterWin)
 	v2LimiterMax   = 30
 	v2LimiterWin   = time.Minute
 )
 
 func v2Limiter() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
+		ip := clientKey(c)
 		count := windowAdd(v2LimiterStore, ip, time.Now(), v2LimiterWin, v2LimiterMax)
 		if count > v2LimiterMax {
 			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "v2 rate limit exceeded"})
@@ -136,14 +138,14 @@ func v2Limiter() gin.HandlerFunc {
 }
 
 var (
-	captchaLimiterStore = newBoundedMap[[]time.Time](prCheckLimiterStoreMax, captchaLimiterWin)
+	captchaLimiterStore = newBoundedMap[[]time.Time]("captcha-limit", prCheckLimiterStoreMax, captchaLimiterWin)
 	captchaLimiterMax   = 30
 	captchaLimiterWin   = time.Minute
 )
 
 func captchaLimiter() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
+		ip := clientKey(c)
 		count := windowAdd(captchaLimiterStore, ip, time.Now(), captchaLimiterWin, captchaLimiterMax)
 		if count > captchaLimiterMax {
 			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "captcha rate limit exceeded"})
@@ -241,6 +243,12 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 	r.GET("/manifest", EthicalMetricsManifestHandler)
 	r.GET("/version", EthicalMetricsVersionHandler)
 	r.GET("/badges/:badge", BadgeHandler)
+	// Other instances reach the shared state here (GITGOST_STATE_TOKEN).
+	r.Any("/internal/state/*path", StateServiceHandler)
+	// Forge webhooks; each delivery is verified against its repo's secret.
+	r.POST("/webhooks/github", GitHubWebhookHandler)
+	r.POST("/webhooks/gitlab", GitLabWebhookHandler)
+	r.POST("/webhooks/codeberg", CodebergWebhookHandler)
 	r.GET("/badge/:owner/:repo", BadgePRCountHandler)
 	r.GET("/install", InstallScriptHandler)
 	r.StaticFile("/repo.html", "./web/repo.html")
@@ -272,10 +280,10 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 			gh.POST("/:owner/:repo/git-receive-pack", ReceivePackHandler)
 			gh.POST("/:owner/:repo/git-upload-pack", UploadPackHandler)
 			gh.GET("/:owner/:repo/issues/templates", GetIssueTemplatesHandler)
-			gh.POST("/:owner/:repo/issues/anonymous", CreateAnonymousIssueHandler)
-			gh.POST("/:owner/:repo/issues/:number/comments/anonymous", CreateAnonymousCommentHandler)
-			gh.POST("/:owner/:repo/pulls/:number/comments/anonymous", CreateAnonymousPRCommentHandler)
-			gh.POST("/:owner/:repo/discussions/:number/comments/anonymous", CreateAnonymousDiscussionCommentHandler)
+			gh.POST("/:owner/:repo/issues/anonymous", tokenGate(), powGate(), CreateAnonymousIssueHandler)
+			gh.POST("/:owner/:repo/issues/:number/comments/anonymous", tokenGate(), powGate(), CreateAnonymousCommentHandler)
+			gh.POST("/:owner/:repo/pulls/:number/comments/anonymous", tokenGate(), powGate(), CreateAnonymousPRCommentHandler)
+			gh.POST("/:owner/:repo/discussions/:number/comments/anonymous", tokenGate(), powGate(), CreateAnonymousDiscussionCommentHandler)
 		}
 
 		gl := v1.Group("/gl")
@@ -283,9 +291,9 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 			gl.GET("/:owner/:repo/info/refs", refsHandler)
 			gl.POST("/:owner/:repo/git-receive-pack", ReceivePackHandler)
 			gl.POST("/:owner/:repo/git-upload-pack", UploadPackHandler)
-			gl.POST("/:owner/:repo/issues/anonymous", CreateAnonymousIssueHandler)
-			gl.POST("/:owner/:repo/issues/:number/comments/anonymous", CreateAnonymousCommentHandler)
-			gl.POST("/:owner/:repo/pulls/:number/comments/anonymous", CreateAnonymousPRCommentHandler)
+			gl.POST("/:owner/:repo/issues/anonymous", tokenGate(), powGate(), CreateAnonymousIssueHandler)
+			gl.POST("/:owner/:repo/issues/:number/comments/anonymous", tokenGate(), powGate(), CreateAnonymousCommentHandler)
+			gl.POST("/:owner/:repo/pulls/:number/comments/anonymous", tokenGate(), powGate(), CreateAnonymousPRCommentHandler)
 		}
 
 		cb := v1.Group("/cb")
@@ -293,9 +301,9 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 			cb.GET("/:owner/:repo/info/refs", refsHandler)
 			cb.POST("/:owner/:repo/git-receive-pack", ReceivePackHandler)
 			cb.POST("/:owner/:repo/git-upload-pack", UploadPackHandler)
-			cb.POST("/:owner/:repo/issues/anonymous", CreateAnonymousIssueHandler)
-			cb.POST("/:owner/:repo/issues/:number/comments/anonymous", CreateAnonymousCommentHandler)
-			cb.POST("/:owner/:repo/pulls/:number/comments/anonymous", CreateAnonymousPRCommentHandler)
+			cb.POST("/:owner/:repo/issues/anonymous", tokenGate(), powGate(), CreateAnonymousIssueHandler)
+			cb.POST("/:owner/:repo/issues/:number/comments/anonymous", tokenGate(), powGate(), CreateAnonymousCommentHandler)
+			cb.POST("/:owner/:repo/pulls/:number/comments/anonymous", tokenGate(), powGate(), CreateAnonymousPRCommentHandler)
 		}
 	}
 
@@ -315,25 +323,37 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 		api.GET("/stats", StatsHandler)
 		api.GET("/recent-prs", RecentPRsHandler)
 		api.GET("/pr-status/:hash", PRStatusHandler)
-		api.GET("/pr/:hash/status", prCheckLimiter(), PRCheckHandler)
+		api.GET("/pr/:hash/status", prCheckLimiter("pr-status"), PRCheckHandler)
+		api.GET("/pr/:hash/events", prCheckLimiter("pr-events"), PREventsHandler)
+		api.GET("/pr/:hash/events/stream", prCheckLimiter("pr-events-stream"), PREventStreamHandler)
+		api.GET("/pr/:hash/feed.atom", prCheckLimiter("pr-feed"), PRFeedHandler)
+		api.POST("/pr/:hash/events/token", prCheckLimiter("pr-events-token"), PRSubscriptionTokenHandler)
+		api.GET("/pow/challenge", prCheckLimiter("pow-challenge"), PowChallengeHandler)
+		api.GET("/tokens/keys", prCheckLimiter("token-keys"), TokenKeysHandler)
+		api.POST("/tokens", prCheckLimiter("tokens"), TokenIssueHandler)
+		api.GET("/pr/:hash/replies", prCheckLimiter("replies"), PRRepliesHandler)
+		api.GET("/pr/:hash/replies/key", prCheckLimiter("reply-key"), PRReplyKeyHandler)
+		api.POST("/pr/:hash/replies", prCheckLimiter("reply-post"), tokenGate(), powGate(), PRReplyPostHandler)
 		api.GET("/search", SearchHandler)
-		api.GET("/users/search", prCheckLimiter(), UsersSearchHandler)
-		api.GET("/code/search", prCheckLimiter(), CodeSearchHandler)
-		api.GET("/github/packages/:owner", prCheckLimiter(), GitHubPackagesHandler)
-		api.GET("/users/profile", prCheckLimiter(), UserProfileHandler)
-		api.GET("/users/repos", prCheckLimiter(), UserReposHandler)
-		api.GET("/users/readme", prCheckLimiter(), UserReadmeHandler)
-		api.GET("/users/starred", prCheckLimiter(), UserStarredHandler)
-		api.GET("/users/orgs", prCheckLimiter(), UserOrgsHandler)
-		api.GET("/users/events", prCheckLimiter(), UserEventsHandler)
-		api.GET("/users/contributions", prCheckLimiter(), UserContributionsHandler)
-		api.GET("/gh-proxy/*path", proxyLimiter(), GitHubAPIProxyHandler)
-		api.GET("/blame/:provider/:owner/:repo", proxyLimiter(), BlameHandler)
-		api.GET("/file-history/:provider/:owner/:repo", proxyLimiter(), FileHistoryHandler)
-		api.GET("/release-asset/:provider/:owner/:repo", proxyLimiter(), ReleaseAssetDownloadHandler)
+		api.GET("/users/search", prCheckLimiter("users-search"), UsersSearchHandler)
+		api.GET("/code/search", prCheckLimiter("code-search"), CodeSearchHandler)
+		api.GET("/github/packages/:owner", prCheckLimiter("github-packages"), GitHubPackagesHandler)
+		api.GET("/users/profile", prCheckLimiter("users-profile"), UserProfileHandler)
+		api.GET("/users/repos", prCheckLimiter("users-repos"), UserReposHandler)
+		api.GET("/users/readme", prCheckLimiter("users-readme"), UserReadmeHandler)
+		api.GET("/users/starred", prCheckLimiter("users-starred"), UserStarredHandler)
+		api.GET("/users/orgs", prCheckLimiter("users-orgs"), UserOrgsHandler)
+		api.GET("/users/events", prCheckLimiter("users-events"), UserEventsHandler)
+		api.GET("/users/contributions", prCheckLimiter("users-contributions"), UserContributionsHandler)
+		api.GET("/gh-proxy/*path", proxyLimiter("gh-proxy"), GitHubAPIProxyHandler)
+		api.GET("/blame/:provider/:owner/:repo", proxyLimiter("blame"), BlameHandler)
+		api.GET("/file-history/:provider/:owner/:repo", proxyLimiter("file-history"), FileHistoryHandler)
+		api.GET("/release-asset/:provider/:owner/:repo", proxyLimiter("release-asset"), ReleaseAssetDownloadHandler)
 		api.GET("/trending/:provider", TrendingHandler)
-		api.GET("/cb-proxy/*path", proxyLimiter(), CodebergProxyHandler)
-		api.GET("/gl-proxy/*path", proxyLimiter(), GitLabProxyHandler)
+		api.GET("/policy/:provider/:owner/:repo", prCheckLimiter("policy"), RepoPolicyHandler)
+		api.POST("/webhook-secret", prCheckLimiter("webhook-secret"), WebhookSecretHandler)
+		api.GET("/cb-proxy/*path", proxyLimiter("cb-proxy"), CodebergProxyHandler)
+		api.GET("/gl-proxy/*path", proxyLimiter("gl-proxy"), GitLabProxyHandler)
 		api.GET("/gl-notes/:owner/:repo/:number", GitLabIssueNotesProxyHandler)
 		api.GET("/gl-commit-count/:owner/:repo", GitLabCommitCountHandler)
 		api.GET("/gl-avatar", GitLabAvatarHandler)
@@ -355,10 +375,18 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 	admin := r.Group("/admin")
 	admin.Use(adminLimiter())
 	{
+		admin.GET("/status", AdminStatusHandler)
 		admin.POST("/panic", PanicHandler)
 		admin.POST("/rollback", RollbackBurstHandler)
 		admin.GET("/appeals", AdminAppealsHandler)
 		admin.POST("/appeals/:ticket/resolve", AdminAppealResolveHandler)
+		admin.GET("/reports/:hash", AdminReportsHandler)
+		admin.POST("/hashes/:hash/block", AdminBlockHashHandler)
+		admin.POST("/hashes/:hash/unblock", AdminUnblockHashHandler)
+		admin.GET("/audit", AdminAuditHandler)
+		admin.GET("/tokens", AdminTokenPoolHandler)
+		admin.GET("/reaper", AdminReaperHandler)
+		admin.POST("/keys/rotate", AdminRotateSecretKeyHandler)
 	}
 
 	r.GET("/api/status", ServiceStatusHandler)
@@ -0,0 +1,81 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/livrasand/gitGost/internal/git"
+	"github.com/livrasand/gitGost/internal/provider"
+	"github.com/livrasand/gitGost/internal/screen"
+	"github.com/livrasand/gitGost/internal/utils"
+)
+
+var (
+	screenMu    sync.RWMutex
+	screenRules *screen.Rules // nil disables screening
+)
+
+// InitScreening loads the push screening rules. mode "off" disables
+// screening; an empty rulesFile uses screen.DefaultRules.
+func InitScreening(mode, rulesFile string) error {
+	if mode == "off" {
+		setScreenRules(nil)
+		return nil
+	}
+	rules, err := screen.LoadRules(rulesFile)
+	if err != nil {
+		return err
+	}
+	setScreenRules(&rules)
+	return nil
+}
+
+func setScreenRules(r *screen.Rules) {
+	screenMu.Lock()
+	screenRules = r
+	screenMu.Unlock()
+}
+
+// screenPush scores the anonymized commits in tempDir against baseBranch.
+// A push that can't be diffed can't be screened, so the error is returned
+// for the caller to reject it rather than let it through unchecked.
+func screenPush(tempDir, baseBranch string) (screen.Verdict, error) {
+	screenMu.RLock()
+	rules := screenRules
+	screenMu.RUnlock()
+	if rules == nil {
+		return screen.Verdict{}, nil
+	}
+	diffs, err := git.DiffAgainstBase(tempDir, baseBranch)
+	if err != nil {
+		utils.Log("Error diffing push for screening: %v", err)
+		return screen.Verdict{}, err
+	}
+	v := rules.Score(diffs)
+	if v.Action != screen.Allow {
+		utils.Log("Push screening: score %d, action %s, findings %+v", v.Score, v.Action, v.Findings)
+	}
+	return v, nil
+}
+
+// applyScreenVerdict turns a Warn verdict into a draft PR with the warning
+// label.
+func applyScreenVerdict(opts *provider.MROptions, v screen.Verdict) {
+	if v.Action != screen.Warn {
+		return
+	}
+	opts.Draft = true
+	screenMu.RLock()
+	defer screenMu.RUnlock()
+	if screenRules != nil && screenRules.WarnLabel != "" {
+		// Copy so the cached policy's label slice is never appended to.
+		opts.Labels = append(append([]string(nil), opts.Labels...), screenRules.WarnLabel)
+	}
+}
+
+func writeScreenFindings(response *bytes.Buffer, v screen.Verdict) {
+	for _, f := range v.Findings {
+		WriteSidebandLine(response, 2, fmt.Sprintf("remote:   - %s (+%d): %s", f.Rule, f.Weight, f.Detail))
+	}
+}
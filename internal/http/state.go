@@ -0,0 +1,205 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/state"
+	"github.com/livrasand/gitGost/internal/utils"
+)
+
+// sharedState holds the runtime state every instance must agree on: rate
+// limits, one-shot tokens, remote jobs, tracked PRs, caches and the panic
+// flag. It is process memory unless InitState points it elsewhere.
+var (
+	sharedState state.Store = state.NewMemory()
+	// stateServeToken, when set, exposes a local sharedState to other
+	// instances under /internal/state.
+	stateServeToken string
+
+	stateLimitsMu sync.Mutex
+	stateLimits   = map[string]int{}
+)
+
+// InitState selects the shared state backend: "" or "memory", "sqlite:<path>"
+// or the URL of another instance's /internal/state. token authenticates to
+// that URL; with a local backend it lets other instances use this one.
+func InitState(spec, token string) error {
+	store, err := state.Open(spec, token)
+	if err != nil {
+		return err
+	}
+	remote := strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://")
+	if !remote {
+		// Served to peers from memory, never written to disk.
+		store = state.Volatile(store, ipKeyNamespace)
+		stateServeToken = token
+	}
+	setStateStore(store)
+	return nil
+}
+
+func setStateStore(store state.Store) {
+	stateLimitsMu.Lock()
+	defer stateLimitsMu.Unlock()
+	sharedState = store
+	if l, ok := store.(state.Limiter); ok {
+		for ns, max := range stateLimits {
+			l.SetLimit(ns, max)
+		}
+	}
+}
+
+// registerStateNamespace caps ns at max keys.
+func registerStateNamespace(ns string, max int) {
+	stateLimitsMu.Lock()
+	defer stateLimitsMu.Unlock()
+	stateLimits[ns] = max
+	if l, ok := sharedState.(state.Limiter); ok && max > 0 {
+		l.SetLimit(ns, max)
+	}
+}
+
+// StateServiceHandler serves sharedState to other instances when
+// GITGOST_STATE_TOKEN is set and this instance keeps state locally. When
+// disabled it answers 403, not 404, so a misconfigured peer fails loudly
+// instead of reading every key as unset.
+func StateServiceHandler(c *gin.Context) {
+	if stateServeToken == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "state service disabled"})
+		return
+	}
+	http.StripPrefix("/internal/state", state.Handler(sharedState, stateServeToken)).ServeHTTP(c.Writer, c.Request)
+}
+
+// boundedMap is a namespace of sharedState holding JSON-encoded values of
+// type V. Entries expire ttl after they were last written; a maxSize above zero caps the namespace, evicting the least recently
+// written keys first.
+type boundedMap[V any] struct {
+	ns  string
+	ttl time.Duration
+}
+
+func newBoundedMap[V any](ns string, maxSize int, ttl time.Duration) *boundedMap[V] {
+	registerStateNamespace(ns, maxSize)
+	return &boundedMap[V]{ns: ns, ttl: ttl}
+}
+
+func (m *boundedMap[V]) decode(key string, raw []byte) (V, bool) {
+	var v V
+	if err := json.Unmarshal(raw, &v); err != nil {
+		utils.Log("state %s/%s: %v", m.ns, key, err)
+		var zero V
+		return zero, false
+	}
+	return v, true
+}
+
+func (m *boundedMap[V]) encode(key string, v V) ([]byte, bool) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		utils.Log("state %s/%s: %v", m.ns, key, err)
+		return nil, false
+	}
+	return raw, true
+}
+
+// Get returns the value of key. Reads never write, so they leave its ttl
+// alone.
+func (m *boundedMap[V]) Get(key string) (V, bool) {
+	raw, ok, err := sharedState.Get(context.Background(), m.ns, key)
+	if err != nil {
+		utils.Log("state %s/%s: %v", m.ns, key, err)
+		ok = false
+	}
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return m.decode(key, raw)
+}
+
+func (m *boundedMap[V]) Set(key string, value V) {
+	raw, ok := m.encode(key, value)
+	if !ok {
+		return
+	}
+	if err := sharedState.Set(context.Background(), m.ns, key, raw, m.ttl); err != nil {
+		utils.Log("state %s/%s: %v", m.ns, key, err)
+	}
+}
+
+// Update stores fn(old, present) atomically. fn may run more than once when
+// another instance wins a race, so anything it records outside the value
+// must be reassigned, not accumulated. When the backend fails, fn may not
+// have run at all: callers guarding something must treat the error as a
+// refusal.
+func (m *boundedMap[V]) Update(key string, fn func(V, bool) V) (V, error) {
+	var result V
+	_, _, err := sharedState.Update(context.Background(), m.ns, key, m.ttl, func(old []byte, ok bool) ([]byte, bool) {
+		var cur V
+		present := false
+		if ok {
+			cur, present = m.decode(key, old)
+		}
+		result = fn(cur, present)
+		raw, ok := m.encode(key, result)
+		return raw, ok
+	})
+	if err != nil {
+		utils.Log("state %s/%s: %v", m.ns, key, err)
+	}
+	return result, err
+}
+
+func (m *boundedMap[V]) Delete(key string) error {
+	err := sharedState.Delete(context.Background(), m.ns, key)
+	if err != nil {
+		utils.Log("state %s/%s: %v", m.ns, key, err)
+	}
+	return err
+}
+
+func (m *boundedMap[V]) Range(fn func(key string, value V) bool) {
+	err := sharedState.Range(context.Background(), m.ns, func(key string, raw []byte) bool {
+		v, ok := m.decode(key, raw)
+		if !ok {
+			return true
+		}
+		return fn(key, v)
+	})
+	if err != nil {
+		utils.Log("state %s: %v", m.ns, err)
+	}
+}
+
+// windowAdd records a hit for ip and returns the hits within window. When
+// the hit can't be recorded it returns max+1, so limiters fail closed.
+func windowAdd(store *boundedMap[[]time.Time], ip string, now time.Time, window time.Duration, max int) int {
+	count, err := store.Update(ip, func(times []time.Time, ok bool) []time.Time {
+		cutoff := now.Add(-window)
+		valid := make([]time.Time, 0, max+1)
+		for _, t := range times {
+			if t.After(cutoff) {
+				valid = append(valid, t)
+			}
+		}
+		valid = append(valid, now)
+		if len(valid) > max+1 {
+			n := max + 1
+			tmp := make([]time.Time, n)
+			copy(tmp, valid[len(valid)-n:])
+			valid = tmp
+		}
+		return valid
+	})
+	if err != nil {
+		return max + 1
+	}
+	return len(count)
+}
@@ -0,0 +1,105 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/pow"
+	"github.com/livrasand/gitGost/internal/state"
+)
+
+// useState points sharedState at store for the rest of the test.
+func useState(t *testing.T, store state.Store) {
+	t.Helper()
+	old := sharedState
+	setStateStore(store)
+	t.Cleanup(func() { setStateStore(old) })
+}
+
+func TestInstancesShareState(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useState(t, state.NewMemory())
+	oldToken := stateServeToken
+	stateServeToken = "peer-token"
+	t.Cleanup(func() { stateServeToken = oldToken })
+
+	// Instance A keeps the state and serves it; instance B talks to it.
+	r := gin.New()
+	r.Any("/internal/state/*path", StateServiceHandler)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	peer := state.NewRemote(srv.URL+"/internal/state", "peer-token")
+	ctx := context.Background()
+
+	setPanicMode(true)
+	t.Cleanup(func() { setPanicMode(false) })
+	if v, ok, err := peer.Get(ctx, "flags", "panic"); err != nil || !ok || string(v) != "true" {
+		t.Fatalf("peer sees panic flag %q %v %v", v, ok, err)
+	}
+
+	trackPR("cafe", "o", "r", 7, "https://github.com/o/r/pull/7", "gh")
+	if _, ok, _ := peer.Get(ctx, "pr-track", "cafe"); !ok {
+		t.Error("tracked PR not visible to the peer")
+	}
+
+	if _, _, err := state.NewRemote(srv.URL+"/internal/state", "wrong").Get(ctx, "flags", "panic"); err == nil {
+		t.Error("state service accepted a wrong token")
+	}
+	stateServeToken = ""
+	if _, _, err := peer.Get(ctx, "flags", "panic"); err == nil {
+		t.Error("state service served without a token")
+	}
+}
+
+func TestLimitsHoldAcrossInstances(t *testing.T) {
+	srv := httptest.NewServer(http.StripPrefix("/internal/state", state.Handler(state.NewMemory(), "tok")))
+	defer srv.Close()
+	useState(t, state.NewRemote(srv.URL+"/internal/state", "tok"))
+
+	// Two limiters over one namespace stand in for the same limiter on two
+	// machines.
+	a := newBoundedMap[[]time.Time]("test-limit", 10, time.Minute)
+	b := newBoundedMap[[]time.Time]("test-limit", 10, time.Minute)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		windowAdd(a, "client", now, time.Minute, 5)
+		windowAdd(b, "client", now, time.Minute, 5)
+	}
+	if n := windowAdd(a, "client", now, time.Minute, 5); n != 6 {
+		t.Errorf("window holds %d pushes, want 6", n)
+	}
+
+	job := &remoteJob{ID: "job1", Status: rjQueued, Created: now}
+	remoteJobs.Set(job.ID, job)
+	defer remoteJobs.Delete(job.ID)
+	got, ok := newBoundedMap[*remoteJob]("remote-jobs", remoteJobsMax, remoteJobsTTL).Get("job1")
+	if !ok || got.Status != rjQueued {
+		t.Errorf("job from the other instance = %+v, %v", got, ok)
+	}
+}
+
+func TestStateFailuresFailClosed(t *testing.T) {
+	useState(t, failingState{state.NewMemory()})
+	setPowMode(t, powModeAlways, 4)
+
+	limit := newBoundedMap[[]time.Time]("test-limit", 10, time.Minute)
+	if n := windowAdd(limit, "client", time.Now(), time.Minute, 5); n <= 5 {
+		t.Errorf("unrecorded hit counted as %d, want over the limit", n)
+	}
+
+	challenge, err := getPowIssuer().Issue(4, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	solution, err := pow.Solve(context.Background(), challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkPowAt(solution, 4); err != errPowUnstored {
+		t.Errorf("unrecorded proof of work = %v, want %v", err, errPowUnstored)
+	}
+}
@@ -0,0 +1,628 @@
+package http
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/github"
+	"github.com/livrasand/gitGost/internal/moderation"
+	"github.com/livrasand/gitGost/internal/prmsg"
+	"github.com/livrasand/gitGost/internal/provider"
+	"github.com/livrasand/gitGost/internal/utils"
+)
+
+// Forges that are set up to deliver PR events here let the status endpoint
+// answer from what they reported instead of polling the forge API.
+const (
+	webhookMaxBody = 5 << 20
+	// webhookDeliveryTTL is how long a delivery ID is remembered, so a
+	// redelivered event is recorded once.
+	webhookDeliveryTTL = 24 * time.Hour
+	prStatusMax        = 10000
+	// prStatusTTL drops the status of PRs no forge has reported on for a
+	// while; the next event seeds it again from the forge.
+	prStatusTTL = 30 * 24 * time.Hour
+)
+
+// PR event types recorded from webhooks.
+const (
+	prEventCommented        = "commented"
+	prEventApproved         = "approved"
+	prEventChangesRequested = "changes_requested"
+	prEventReviewed         = "reviewed"
+	prEventMerged           = "merged"
+	prEventClosed           = "closed"
+	prEventReopened         = "reopened"
+)
+
+var (
+	// githubAppWebhookSecret signs the GitHub App's own deliveries. Only the
+	// operator holds it; every other delivery is checked against the secret
+	// of the repo it is about.
+	githubAppWebhookSecret string
+
+	webhookDeliveries = newBoundedMap[bool]("webhook-deliveries", 100000, webhookDeliveryTTL)
+	// prStatusStore holds the PR state, title and comment count as of the
+	// last webhook, keyed by PR hash.
+	prStatusStore = newBoundedMap[provider.MRStatus]("pr-status", prStatusMax, prStatusTTL)
+
+	// fetchMRStatus asks the forge for a PR's status; tests replace it.
+	fetchMRStatus = func(ctx context.Context, t prTrack) (*provider.MRStatus, error) {
+		return providerFromName(t.Provider).GetMRStatus(ctx, t.Owner, t.Repo, t.Number)
+	}
+)
+
+// InitWebhooks sets the GitHub App's webhook secret. Repos without the App
+// set up their own webhook secret in .gitgost.yml.
+func InitWebhooks(githubAppSecret string) {
+	githubAppWebhookSecret = githubAppSecret
+}
+
+// repoWebhookSecret is the secret the forge signs owner/repo's deliveries
+// with. It is derived from the instance key and the hash the repo publishes,
+// so it changes when the maintainer replaces their webhook secret and never
+// has to be stored.
+func repoWebhookSecret(key []byte, prov, owner, repo, secretHash string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("gitgost webhook\x00" + prov + "\x00" + strings.ToLower(owner+"/"+repo) + "\x00" + secretHash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookSecretsFor lists the secrets a delivery about owner/repo may be
+// signed with: the repo's secret under every kept instance key, newest first.
+func webhookSecretsFor(ctx context.Context, prov, owner, repo string) []string {
+	policy, err := loadRepoPolicy(ctx, providerFromName(prov), owner, repo)
+	if err != nil || policy.Webhook == nil {
+		return nil
+	}
+	var secrets []string
+	for _, k := range getSecretKeys() {
+		secrets = append(secrets, repoWebhookSecret(k, prov, owner, repo, policy.Webhook.SecretSHA256))
+	}
+	return secrets
+}
+
+// WebhookSecretHandler gives a maintainer the signing secret to configure
+// their repo's webhook with, in exchange for the webhook secret whose hash
+// the repo's .gitgost.yml publishes. Signing secrets derive from the
+// instance key, so they are only handed out when that key is persisted;
+// an in-memory key would change on every restart.
+func WebhookSecretHandler(c *gin.Context) {
+	if !modPersistent {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "webhook signing secrets need persistent moderation storage (GITGOST_MODERATION_DB) on this instance"})
+		return
+	}
+	var req struct {
+		Provider string `json:"provider"`
+		Repo     string `json:"repo"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	prov, forge := "", ""
+	switch req.Provider {
+	case "gh", "github":
+		prov, forge = "gh", "github"
+	case "gl", "gitlab":
+		prov, forge = "gl", "gitlab"
+	case "cb", "codeberg":
+		prov, forge = "cb", "codeberg"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown provider"})
+		return
+	}
+	owner, repo := splitFullName(strings.Trim(req.Repo, "/"))
+	if owner == "" || repo == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "repo must be owner/repo"})
+		return
+	}
+	policy, err := loadRepoPolicy(c.Request.Context(), providerFromName(prov), owner, repo)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "the repository's .gitgost.yml is invalid"})
+		return
+	}
+	if policy.Webhook == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "the repository's .gitgost.yml has no webhook block"})
+		return
+	}
+	if !prmsg.TokenMatches(c.GetHeader("X-Gitgost-Webhook-Secret"), policy.Webhook.SecretSHA256) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "webhook secret does not match .gitgost.yml"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"url":    fmt.Sprintf("%s/webhooks/%s", github.NtfyServiceURL(), forge),
+		"secret": repoWebhookSecret(getSecretKey(), prov, owner, repo, policy.Webhook.SecretSHA256),
+	})
+}
+
+// webhookEvent is a forge delivery reduced to what gitGost records.
+type webhookEvent struct {
+	Owner  string
+	Repo   string
+	Number int
+	Type   string
+	Author string
+	Body   string
+	Title  string
+	State  string
+	At     time.Time
+}
+
+// webhookParser turns a verified delivery into an event; ok is false for
+// deliveries gitGost doesn't record.
+type webhookParser func(event string, body []byte) (ev webhookEvent, ok bool, err error)
+
+func GitHubWebhookHandler(c *gin.Context) {
+	handleWebhook(c, "gh", c.GetHeader("X-GitHub-Event"), c.GetHeader("X-GitHub-Delivery"),
+		func(secret string, body []byte) bool {
+			return validHMAC(secret, body, strings.TrimPrefix(c.GetHeader("X-Hub-Signature-256"), "sha256="))
+		}, parseGitHubWebhook)
+}
+
+func GitLabWebhookHandler(c *gin.Context) {
+	delivery := c.GetHeader("X-Gitlab-Event-UUID")
+	if delivery == "" {
+		delivery = c.GetHeader("Idempotency-Key")
+	}
+	handleWebhook(c, "gl", c.GetHeader("X-Gitlab-Event"), delivery,
+		func(secret string, _ []byte) bool {
+			return subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Gitlab-Token")), []byte(secret)) == 1
+		}, parseGitLabWebhook)
+}
+
+func CodebergWebhookHandler(c *gin.Context) {
+	event, delivery, sig := c.GetHeader("X-Forgejo-Event-Type"), c.GetHeader("X-Forgejo-Delivery"), c.GetHeader("X-Forgejo-Signature")
+	for _, h := range []string{"X-Gitea-Event-Type", "X-Forgejo-Event", "X-Gitea-Event"} {
+		if event == "" {
+			event = c.GetHeader(h)
+		}
+	}
+	if delivery == "" {
+		delivery = c.GetHeader("X-Gitea-Delivery")
+	}
+	if sig == "" {
+		sig = c.GetHeader("X-Gitea-Signature")
+	}
+	handleWebhook(c, "cb", event, delivery,
+		func(secret string, body []byte) bool { return validHMAC(secret, body, sig) }, parseCodebergWebhook)
+}
+
+func validHMAC(secret string, body []byte, sigHex string) bool {
+	sig, err := hex.DecodeString(sigHex)
+	if err != nil || len(sig) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(sig, mac.Sum(nil))
+}
+
+func handleWebhook(c *gin.Context, prov, event, delivery string, verify func(secret string, body []byte) bool, parse webhookParser) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, webhookMaxBody+1))
+	if err != nil || len(body) > webhookMaxBody {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "payload too large"})
+		return
+	}
+	ev, ok, err := parse(event, body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+	if !verifyWebhook(c, prov, ev, body, verify) {
+		return
+	}
+	deliveryKey := prov + ":" + delivery
+	if delivery != "" {
+		seen := false
+		webhookDeliveries.Update(deliveryKey, func(_ bool, present bool) bool {
+			seen = present
+			return true
+		})
+		if seen {
+			c.JSON(http.StatusOK, gin.H{"status": "duplicate"})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	prs, err := modStore.PRs(ctx, moderation.PRQuery{Provider: prov, Owner: ev.Owner, Repo: ev.Repo, Number: ev.Number, Limit: 1})
+	if err != nil {
+		// Let the forge's redelivery through.
+		webhookDeliveries.Delete(deliveryKey)
+		utils.Log("Error looking up PR for %s webhook: %v", prov, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not look up PR"})
+		return
+	}
+	if len(prs) == 0 {
+		c.JSON(http.StatusOK, gin.H{"status": "untracked"})
+		return
+	}
+	pr := prs[0]
+	if err := recordPREvent(ctx, pr, ev); err != nil {
+		webhookDeliveries.Delete(deliveryKey)
+		utils.Log("Error recording %s event on %s: %v", ev.Type, pr.URL, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not record event"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "recorded"})
+}
+
+// verifyWebhook checks the delivery's signature against the GitHub App's
+// secret or the secret of the repo it is about, and writes the error
+// response when neither matches.
+func verifyWebhook(c *gin.Context, prov string, ev webhookEvent, body []byte, verify func(secret string, body []byte) bool) bool {
+	if prov == "gh" && githubAppWebhookSecret != "" && verify(githubAppWebhookSecret, body) {
+		return true
+	}
+	secrets := webhookSecretsFor(c.Request.Context(), prov, ev.Owner, ev.Repo)
+	if len(secrets) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no webhook secret is configured for this repository"})
+		return false
+	}
+	for _, secret := range secrets {
+		if verify(secret, body) {
+			return true
+		}
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid signature"})
+	return false
+}
+
+// recordPREvent publishes ev to the PR's subscribers and folds it into the
+// PR's cached status.
+func recordPREvent(ctx context.Context, pr moderation.PR, ev webhookEvent) error {
+	if ev.At.IsZero() {
+		ev.At = time.Now()
+	}
+	if err := publishPREvent(ctx, pr, moderation.PREvent{
+		Type: ev.Type, Author: ev.Author, Body: ev.Body, CreatedAt: ev.At,
+	}); err != nil {
+		return err
+	}
+	switch ev.Type {
+	case prEventMerged, prEventClosed:
+		if err := modStore.MarkPRClosed(ctx, pr.URL, ev.At); err != nil {
+			utils.Log("Error marking PR %s closed: %v", pr.URL, err)
+		}
+	case prEventReopened:
+		if err := modStore.ReopenPR(ctx, pr.URL); err != nil {
+			utils.Log("Error marking PR %s reopened: %v", pr.URL, err)
+		}
+	}
+	updatePRStatus(ctx, pr, ev)
+	return nil
+}
+
+// updatePRStatus applies ev to the cached status. The first event for a PR
+// seeds the cache with one forge call, which already reflects ev.
+func updatePRStatus(ctx context.Context, pr moderation.PR, ev webhookEvent) {
+	if _, ok := prStatusStore.Get(pr.Hash); !ok {
+		st, err := fetchMRStatus(ctx, prTrack{Owner: pr.Owner, Repo: pr.Repo, Number: pr.Number, PRURL: pr.URL, Provider: pr.Provider})
+		if err == nil {
+			st.Events, st.ETag = nil, ""
+			prStatusStore.Set(pr.Hash, *st)
+			return
+		}
+		utils.Log("Error seeding status of %s: %v", pr.URL, err)
+	}
+	prStatusStore.Update(pr.Hash, func(st provider.MRStatus, _ bool) provider.MRStatus {
+		st.Number = pr.Number
+		if ev.Title != "" {
+			st.Title = ev.Title
+		}
+		if ev.State != "" {
+			st.State = ev.State
+		}
+		if ev.Type == prEventCommented {
+			st.Comments++
+		}
+		st.UpdatedAt = ev.At.UTC().Format(time.RFC3339)
+		return st
+	})
+}
+
+// storedPRStatus is the status endpoint's answer from webhook data, or false
+// when no forge has reported on the PR.
+func storedPRStatus(ctx context.Context, hash string) (provider.MRStatus, bool) {
+	st, ok := prStatusStore.Get(hash)
+	if !ok {
+		return provider.MRStatus{}, false
+	}
+	events, err := modStore.PREvents(ctx, moderation.PREventQuery{Hash: hash, Limit: 10})
+	if err != nil {
+		utils.Log("Error listing events for %s: %v", hash, err)
+	}
+	st.Events = make([]provider.Event, 0, len(events))
+	for i := len(events) - 1; i >= 0; i-- {
+		e := events[i]
+		st.Events = append(st.Events, provider.Event{
+			ID:        strconv.FormatInt(e.ID, 10),
+			Type:      e.Type,
+			Author:    e.Author,
+			Body:      e.Body,
+			CreatedAt: e.CreatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	return st, true
+}
+
+func parseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// splitFullName splits "group/sub/repo" into its namespace and repo.
+func splitFullName(full string) (string, string) {
+	i := strings.LastIndex(full, "/")
+	if i < 0 {
+		return "", full
+	}
+	return full[:i], full[i+1:]
+}
+
+type forgeUser struct {
+	Login    string `json:"login"`
+	Username string `json:"username"`
+}
+
+func (u forgeUser) name() string {
+	if u.Login != "" {
+		return u.Login
+	}
+	return u.Username
+}
+
+// forgePR is the pull request object GitHub and Forgejo/Gitea share.
+type forgePR struct {
+	Number    int    `json:"number"`
+	Title     string `json:"title"`
+	State     string `json:"state"`
+	Merged    bool   `json:"merged"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// forgePRPayload covers the pull_request, review and comment deliveries of
+// GitHub and Forgejo/Gitea.
+type forgePRPayload struct {
+	Action      string   `json:"action"`
+	PullRequest *forgePR `json:"pull_request"`
+	Issue       *struct {
+		Number      int             `json:"number"`
+		Title       string          `json:"title"`
+		State       string          `json:"state"`
+		PullRequest json.RawMessage `json:"pull_request"`
+	} `json:"issue"`
+	Comment *struct {
+		Body      string    `json:"body"`
+		User      forgeUser `json:"user"`
+		CreatedAt string    `json:"created_at"`
+	} `json:"comment"`
+	Review *struct {
+		State       string    `json:"state"`
+		Type        string    `json:"type"`
+		Body        string    `json:"body"`
+		Content     string    `json:"content"`
+		User        forgeUser `json:"user"`
+		SubmittedAt string    `json:"submitted_at"`
+	} `json:"review"`
+	Repository struct {
+		Name     string    `json:"name"`
+		FullName string    `json:"full_name"`
+		Owner    forgeUser `json:"owner"`
+	} `json:"repository"`
+	Sender forgeUser `json:"sender"`
+	IsPull bool      `json:"is_pull"`
+}
+
+func (p forgePRPayload) base() webhookEvent {
+	ev := webhookEvent{Owner: p.Repository.Owner.name(), Repo: p.Repository.Name, Author: p.Sender.name()}
+	if ev.Owner == "" {
+		ev.Owner, ev.Repo = splitFullName(p.Repository.FullName)
+	}
+	if pr := p.PullRequest; pr != nil {
+		ev.Number, ev.Title, ev.State = pr.Number, pr.Title, pr.State
+		if pr.Merged {
+			ev.State = prEventMerged
+		}
+		ev.At = parseTime(pr.UpdatedAt)
+	}
+	return ev
+}
+
+// prAction maps a pull_request action to an event type.
+func (p forgePRPayload) prAction(ev webhookEvent) (webhookEvent, bool) {
+	switch p.Action {
+	case "closed":
+		ev.Type = prEventClosed
+		if ev.State == prEventMerged {
+			ev.Type = prEventMerged
+		}
+	case "reopened":
+		ev.Type = prEventReopened
+	default:
+		return ev, false
+	}
+	return ev, ev.Number > 0
+}
+
+// issueComment maps a comment on an issue that is a PR.
+func (p forgePRPayload) issueComment(ev webhookEvent) (webhookEvent, bool) {
+	if p.Action != "created" || p.Issue == nil || p.Comment == nil {
+		return ev, false
+	}
+	if !p.IsPull && (len(p.Issue.PullRequest) == 0 || string(p.Issue.PullRequest) == "null") {
+		return ev, false
+	}
+	ev.Number, ev.Title, ev.State = p.Issue.Number, p.Issue.Title, p.Issue.State
+	ev.Type, ev.Body = prEventCommented, p.Comment.Body
+	if a := p.Comment.User.name(); a != "" {
+		ev.Author = a
+	}
+	ev.At = parseTime(p.Comment.CreatedAt)
+	return ev, ev.Number > 0
+}
+
+func reviewType(state string) string {
+	switch strings.ToLower(state) {
+	case "approved", "pull_request_review_approved":
+		return prEventApproved
+	case "changes_requested", "rejected", "pull_request_review_rejected":
+		return prEventChangesRequested
+	default:
+		return prEventReviewed
+	}
+}
+
+func parseGitHubWebhook(event string, body []byte) (webhookEvent, bool, error) {
+	var p forgePRPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return webhookEvent{}, false, err
+	}
+	ev := p.base()
+	switch event {
+	case "pull_request":
+		ev, ok := p.prAction(ev)
+		return ev, ok, nil
+	case "issue_comment":
+		ev, ok := p.issueComment(ev)
+		return ev, ok, nil
+	case "pull_request_review":
+		if p.Action != "submitted" || p.Review == nil {
+			return ev, false, nil
+		}
+		ev.Type, ev.Body = reviewType(p.Review.State), p.Review.Body
+		if a := p.Review.User.name(); a != "" {
+			ev.Author = a
+		}
+		if at := parseTime(p.Review.SubmittedAt); !at.IsZero() {
+			ev.At = at
+		}
+		return ev, ev.Number > 0, nil
+	case "pull_request_review_comment":
+		if p.Action != "created" || p.Comment == nil {
+			return ev, false, nil
+		}
+		ev.Type, ev.Body = prEventCommented, p.Comment.Body
+		if a := p.Comment.User.name(); a != "" {
+			ev.Author = a
+		}
+		ev.At = parseTime(p.Comment.CreatedAt)
+		return ev, ev.Number > 0, nil
+	}
+	return ev, false, nil
+}
+
+func parseCodebergWebhook(event string, body []byte) (webhookEvent, bool, error) {
+	var p forgePRPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return webhookEvent{}, false, err
+	}
+	ev := p.base()
+	switch event {
+	case "pull_request":
+		ev, ok := p.prAction(ev)
+		return ev, ok, nil
+	case "issue_comment", "pull_request_comment":
+		ev, ok := p.issueComment(ev)
+		return ev, ok, nil
+	case "pull_request_review_approved", "pull_request_review_rejected", "pull_request_review_comment":
+		ev.Type = reviewType(event)
+		if p.Review != nil {
+			ev.Body = p.Review.Content
+		}
+		return ev, ev.Number > 0, nil
+	}
+	return ev, false, nil
+}
+
+type gitlabPayload struct {
+	ObjectKind string    `json:"object_kind"`
+	User       forgeUser `json:"user"`
+	Project    struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+	ObjectAttributes struct {
+		IID          int    `json:"iid"`
+		Title        string `json:"title"`
+		State        string `json:"state"`
+		Action       string `json:"action"`
+		Note         string `json:"note"`
+		NoteableType string `json:"noteable_type"`
+		UpdatedAt    string `json:"updated_at"`
+		CreatedAt    string `json:"created_at"`
+	} `json:"object_attributes"`
+	MergeRequest *struct {
+		IID   int    `json:"iid"`
+		Title string `json:"title"`
+		State string `json:"state"`
+	} `json:"merge_request"`
+}
+
+// gitlabTime parses GitLab's timestamps, which come as RFC 3339 or as
+// "2006-01-02 15:04:05 UTC" depending on the hook.
+func gitlabTime(s string) time.Time {
+	if t := parseTime(s); !t.IsZero() {
+		return t
+	}
+	t, err := time.Parse("2006-01-02 15:04:05 MST", s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func parseGitLabWebhook(event string, body []byte) (webhookEvent, bool, error) {
+	var p gitlabPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return webhookEvent{}, false, err
+	}
+	attrs := p.ObjectAttributes
+	ev := webhookEvent{Author: p.User.name()}
+	ev.Owner, ev.Repo = splitFullName(p.Project.PathWithNamespace)
+	switch event {
+	case "Merge Request Hook":
+		ev.Number, ev.Title, ev.State = attrs.IID, attrs.Title, attrs.State
+		ev.At = gitlabTime(attrs.UpdatedAt)
+		switch attrs.Action {
+		case "merge":
+			ev.Type = prEventMerged
+		case "close":
+			ev.Type = prEventClosed
+		case "reopen":
+			ev.Type = prEventReopened
+		case "approved":
+			ev.Type = prEventApproved
+		default:
+			return ev, false, nil
+		}
+		return ev, ev.Number > 0, nil
+	case "Note Hook":
+		if attrs.NoteableType != "MergeRequest" || p.MergeRequest == nil {
+			return ev, false, nil
+		}
+		ev.Number, ev.Title, ev.State = p.MergeRequest.IID, p.MergeRequest.Title, p.MergeRequest.State
+		ev.Type, ev.Body = prEventCommented, attrs.Note
+		ev.At = gitlabTime(attrs.CreatedAt)
+		return ev, ev.Number > 0, nil
+	}
+	return ev, false, nil
+}
@@ -0,0 +1,238 @@
+package http
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livrasand/gitGost/internal/moderation"
+	"github.com/livrasand/gitGost/internal/prmsg"
+	"github.com/livrasand/gitGost/internal/provider"
+	"github.com/livrasand/gitGost/internal/state"
+	"github.com/livrasand/gitGost/internal/tokenpool"
+)
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhooksRecordPREvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useState(t, state.NewMemory())
+	old := modStore
+	setModerationStore(moderation.NewMemory())
+	t.Cleanup(func() { setModerationStore(old) })
+	InitWebhooks("gh-app-secret")
+	t.Cleanup(func() { InitWebhooks("") })
+
+	// acme/site and grp/sub/api publish a webhook secret; other/repo doesn't.
+	oldPolicies := provider.Policies
+	provider.Policies = provider.NewPolicyLoader(time.Hour, time.Hour)
+	t.Cleanup(func() { provider.Policies = oldPolicies })
+	for key, raw := range map[string]string{
+		provider.PolicyKey(tokenpool.GitHub, "acme", "site"):   "version: 1\nwebhook:\n  secret_sha256: " + prmsg.TokenHash("site-secret") + "\n",
+		provider.PolicyKey(tokenpool.GitLab, "grp/sub", "api"): "version: 1\nwebhook:\n  secret_sha256: " + prmsg.TokenHash("api-secret") + "\n",
+		provider.PolicyKey(tokenpool.GitHub, "other", "repo"):  "version: 1\n",
+	} {
+		fetch := func(context.Context, string) (provider.PolicyFileResult, error) {
+			return provider.PolicyFileResult{Content: []byte(raw)}, nil
+		}
+		if _, _, err := provider.Policies.Load(context.Background(), key, fetch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	notified := make(chan string, 10)
+	ntfy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		notified <- r.Header.Get("Title")
+	}))
+	defer ntfy.Close()
+	t.Setenv("NTFY_BASE_URL", ntfy.URL)
+
+	fetches := 0
+	oldFetch := fetchMRStatus
+	fetchMRStatus = func(_ context.Context, t prTrack) (*provider.MRStatus, error) {
+		fetches++
+		return &provider.MRStatus{State: "open", Title: "Fix typo", Number: t.Number, Comments: 2}, nil
+	}
+	t.Cleanup(func() { fetchMRStatus = oldFetch })
+
+	ctx := context.Background()
+	_ = modStore.AddPR(ctx, moderation.PR{Hash: "abcd1234", Provider: "gh", Owner: "acme", Repo: "site", Number: 5, URL: "https://github.com/acme/site/pull/5"})
+	_ = modStore.AddPR(ctx, moderation.PR{Hash: "beef5678", Provider: "gl", Owner: "grp/sub", Repo: "api", Number: 8, URL: "https://gitlab.com/grp/sub/api/-/merge_requests/8"})
+
+	r := gin.New()
+	r.POST("/webhooks/github", GitHubWebhookHandler)
+	r.POST("/webhooks/gitlab", GitLabWebhookHandler)
+	r.POST("/webhooks/codeberg", CodebergWebhookHandler)
+	r.GET("/api/pr/:hash/status", PRCheckHandler)
+	r.POST("/api/webhook-secret", WebhookSecretHandler)
+	post := func(path, body string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// The maintainer exchanges their webhook secret for the signing secret.
+	signingSecret := func(forge, repo, secret string) string {
+		w := post("/api/webhook-secret", `{"provider":"`+forge+`","repo":"`+repo+`"}`, map[string]string{"Content-Type": "application/json", "X-Gitgost-Webhook-Secret": secret})
+		var resp struct {
+			URL    string `json:"url"`
+			Secret string `json:"secret"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK || !strings.HasSuffix(resp.URL, "/webhooks/"+forge) {
+			t.Fatalf("signing secret for %s: %d %s", repo, w.Code, w.Body)
+		}
+		return resp.Secret
+	}
+	// Without a persisted instance key a restart would change every signing
+	// secret, so none is handed out.
+	if w := post("/api/webhook-secret", `{"provider":"github","repo":"acme/site"}`, map[string]string{"Content-Type": "application/json", "X-Gitgost-Webhook-Secret": "site-secret"}); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("signing secret without persistent storage: %d", w.Code)
+	}
+	modPersistent = true
+	t.Cleanup(func() { modPersistent = false })
+	if w := post("/api/webhook-secret", `{"provider":"github","repo":"acme/site"}`, map[string]string{"Content-Type": "application/json", "X-Gitgost-Webhook-Secret": "api-secret"}); w.Code != http.StatusForbidden {
+		t.Fatalf("signing secret with another repo's secret: %d", w.Code)
+	}
+	siteSecret, apiSecret := signingSecret("github", "acme/site", "site-secret"), signingSecret("gitlab", "grp/sub/api", "api-secret")
+	if siteSecret == apiSecret || siteSecret == "" {
+		t.Fatalf("signing secrets %q and %q", siteSecret, apiSecret)
+	}
+
+	review := `{"action":"submitted","review":{"state":"approved","body":"LGTM","user":{"login":"maint"}},` +
+		`"pull_request":{"number":5,"title":"Fix typo","state":"open"},"repository":{"name":"site","owner":{"login":"acme"}},"sender":{"login":"maint"}}`
+	gh := map[string]string{"X-GitHub-Event": "pull_request_review", "X-GitHub-Delivery": "d1", "X-Hub-Signature-256": "sha256=" + sign(siteSecret, review)}
+
+	if w := post("/webhooks/github", review, map[string]string{"X-GitHub-Event": "pull_request_review", "X-Hub-Signature-256": "sha256=" + sign("wrong", review)}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("bad signature: %d", w.Code)
+	}
+	// Another repo's signing secret can't vouch for acme/site.
+	if w := post("/webhooks/github", review, map[string]string{"X-GitHub-Event": "pull_request_review", "X-Hub-Signature-256": "sha256=" + sign(apiSecret, review)}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("another repo's secret: %d", w.Code)
+	}
+	other := strings.Replace(review, `"name":"site","owner":{"login":"acme"}`, `"name":"repo","owner":{"login":"other"}`, 1)
+	if w := post("/webhooks/github", other, map[string]string{"X-GitHub-Event": "pull_request_review", "X-Hub-Signature-256": "sha256=" + sign(siteSecret, other)}); w.Code != http.StatusNotFound {
+		t.Fatalf("repo without a webhook secret: %d", w.Code)
+	}
+	if w := post("/webhooks/github", review, gh); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "recorded") {
+		t.Fatalf("review: %d %s", w.Code, w.Body)
+	}
+	if w := post("/webhooks/github", review, gh); !strings.Contains(w.Body.String(), "duplicate") {
+		t.Fatalf("redelivery: %s", w.Body)
+	}
+	if got := <-notified; got != "PR Approved · gitGost" {
+		t.Errorf("ntfy title = %q", got)
+	}
+
+	comment := `{"action":"created","issue":{"number":5,"title":"Fix typo","state":"open","pull_request":{"url":"x"}},` +
+		`"comment":{"body":"one nit","user":{"login":"maint"},"created_at":"2026-10-01T10:00:00Z"},"repository":{"name":"site","owner":{"login":"acme"}}}`
+	// The GitHub App signs its deliveries with its own secret.
+	if w := post("/webhooks/github", comment, map[string]string{"X-GitHub-Event": "issue_comment", "X-GitHub-Delivery": "d2", "X-Hub-Signature-256": "sha256=" + sign("gh-app-secret", comment)}); !strings.Contains(w.Body.String(), "recorded") {
+		t.Fatalf("App delivery: %s", w.Body)
+	}
+	<-notified
+
+	req := httptest.NewRequest(http.MethodGet, "/api/pr/abcd1234/status", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var status struct {
+		Source   string           `json:"source"`
+		State    string           `json:"state"`
+		Comments int              `json:"comments"`
+		Events   []provider.Event `json:"events"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Source != "webhook" || status.Comments != 3 || len(status.Events) != 2 ||
+		status.Events[0].Type != prEventApproved || status.Events[1].Body != "one nit" {
+		t.Errorf("status = %+v", status)
+	}
+	if fetches != 1 {
+		t.Errorf("forge fetched %d times, want once to seed the status", fetches)
+	}
+
+	merge := `{"object_kind":"merge_request","user":{"username":"lead"},"project":{"path_with_namespace":"grp/sub/api"},` +
+		`"object_attributes":{"iid":8,"title":"Add docs","state":"merged","action":"merge","updated_at":"2026-10-02 09:00:00 UTC"}}`
+	if w := post("/webhooks/gitlab", merge, map[string]string{"X-Gitlab-Event": "Merge Request Hook", "X-Gitlab-Token": "nope"}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("bad GitLab token: %d", w.Code)
+	}
+	if w := post("/webhooks/gitlab", merge, map[string]string{"X-Gitlab-Event": "Merge Request Hook", "X-Gitlab-Token": "gh-app-secret"}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("GitLab delivery with the GitHub App secret: %d", w.Code)
+	}
+	if w := post("/webhooks/gitlab", merge, map[string]string{"X-Gitlab-Event": "Merge Request Hook", "X-Gitlab-Token": apiSecret}); !strings.Contains(w.Body.String(), "recorded") {
+		t.Fatalf("merge: %s", w.Body)
+	}
+	if got := <-notified; got != "PR Merged · gitGost" {
+		t.Errorf("ntfy title = %q", got)
+	}
+	if prs, _ := modStore.PRs(ctx, moderation.PRQuery{HashPrefix: "beef5678"}); len(prs) != 1 || prs[0].ClosedAt.IsZero() {
+		t.Errorf("merged PR not marked closed: %+v", prs)
+	}
+
+	reopen := strings.NewReplacer(`"state":"merged","action":"merge"`, `"state":"opened","action":"reopen"`).Replace(merge)
+	if w := post("/webhooks/gitlab", reopen, map[string]string{"X-Gitlab-Event": "Merge Request Hook", "X-Gitlab-Token": apiSecret}); !strings.Contains(w.Body.String(), "recorded") {
+		t.Fatalf("reopen: %s", w.Body)
+	}
+	<-notified
+	if prs, _ := modStore.PRs(ctx, moderation.PRQuery{HashPrefix: "beef5678"}); len(prs) != 1 || !prs[0].ClosedAt.IsZero() {
+		t.Errorf("reopened PR still marked closed: %+v", prs)
+	}
+}
+
+func TestPollReopensPR(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useState(t, state.NewMemory())
+	old := modStore
+	setModerationStore(moderation.NewMemory())
+	t.Cleanup(func() { setModerationStore(old) })
+	oldFetch := fetchMRStatus
+	fetchMRStatus = func(_ context.Context, t prTrack) (*provider.MRStatus, error) {
+		return &provider.MRStatus{State: "open", Number: t.Number}, nil
+	}
+	t.Cleanup(func() { fetchMRStatus = oldFetch })
+
+	ctx := context.Background()
+	url := "https://github.com/acme/site/pull/5"
+	_ = modStore.AddPR(ctx, moderation.PR{Hash: "abcd1234", Provider: "gh", Owner: "acme", Repo: "site", Number: 5, URL: url})
+	_ = modStore.MarkPRClosed(ctx, url, time.Now().Add(-time.Hour))
+
+	r := gin.New()
+	r.GET("/api/pr/:hash/status", PRCheckHandler)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/pr/abcd1234/status", nil))
+	if prs, _ := modStore.PRs(ctx, moderation.PRQuery{HashPrefix: "abcd1234"}); len(prs) != 1 || !prs[0].ClosedAt.IsZero() {
+		t.Errorf("PR polled open still marked closed: %+v", prs)
+	}
+}
+
+func TestParseCodebergWebhook(t *testing.T) {
+	body := `{"action":"reviewed","number":3,"pull_request":{"number":3,"title":"T","state":"open"},` +
+		`"review":{"type":"pull_request_review_rejected","content":"needs tests"},` +
+		`"repository":{"name":"r","full_name":"o/r","owner":{"login":"o","username":"o"}},"sender":{"login":"m"}}`
+	ev, ok, err := parseCodebergWebhook("pull_request_review_rejected", []byte(body))
+	if err != nil || !ok || ev.Type != prEventChangesRequested || ev.Body != "needs tests" || ev.Owner != "o" || ev.Number != 3 || ev.Author != "m" {
+		t.Errorf("rejected review = %+v, %v, %v", ev, ok, err)
+	}
+	closed := `{"action":"closed","number":3,"pull_request":{"number":3,"merged":true},"repository":{"name":"r","owner":{"login":"o"}}}`
+	if ev, ok, _ := parseCodebergWebhook("pull_request", []byte(closed)); !ok || ev.Type != prEventMerged {
+		t.Errorf("merged PR = %+v, %v", ev, ok)
+	}
+	if _, ok, _ := parseCodebergWebhook("pull_request", []byte(`{"action":"synchronized","pull_request":{"number":3}}`)); ok {
+		t.Error("a push to the PR was recorded")
+	}
+}
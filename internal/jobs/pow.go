@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/livrasand/gitGost/internal/pow"
+)
+
+// powSolveTimeout bounds the work spent on one challenge.
+const powSolveTimeout = 5 * time.Minute
+
+// pushRemote returns the remote a push will go to: the first positional
+// argument, or origin.
+func pushRemote(args []string) string {
+	for i := 0; i < len(args); i++ {
+		switch a := args[i]; {
+		case a == "-o" || a == "--push-option" || a == "--repo" || a == "--receive-pack":
+			i++
+		case !strings.HasPrefix(a, "-"):
+			return a
+		}
+	}
+	return "origin"
+}
+
+// remoteURL resolves a remote name (or URL) to its push URL.
+func remoteURL(dir, remote string) string {
+	if strings.Contains(remote, "://") {
+		return remote
+	}
+	cmd := exec.Command("git", "remote", "get-url", "--push", remote)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// fetchPowChallenge reads the challenge a gitGost remote advertises in
+// info/refs, or "" when it asks for none.
+func fetchPowChallenge(remote string) string {
+	if !strings.HasPrefix(remote, "http://") && !strings.HasPrefix(remote, "https://") {
+		return ""
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(remote, "/")+"/info/refs?service=git-receive-pack", nil)
+	if err != nil {
+		return ""
+	}
+	resp, err := jobHTTPClient.Do(req)
+	if err != nil {
+		return ""
+	}
+	resp.Body.Close()
+	return resp.Header.Get("X-Gitgost-Pow-Challenge")
+}
+
+// withPow prepends a solved proof of work to the push arguments when the
+// remote asks for one. Failures leave the arguments alone; the server then
+// explains what it expected.
+func withPow(s *Store, job *Job, args []string) []string {
+	challenge := fetchPowChallenge(remoteURL(job.CWD, pushRemote(args)))
+	if challenge == "" {
+		return args
+	}
+	bits, _ := pow.Bits(challenge)
+	_ = s.SetProgress(job.ID, fmt.Sprintf("Resolviendo prueba de trabajo (%d bits)...", bits))
+	ctx, cancel := context.WithTimeout(context.Background(), powSolveTimeout)
+	defer cancel()
+	solution, err := pow.Solve(ctx, challenge)
+	if err != nil {
+		_ = s.SetProgress(job.ID, fmt.Sprintf("No se pudo resolver la prueba de trabajo: %v", err))
+		return args
+	}
+	return append([]string{"-o", "pow=" + solution}, args...)
+}
@@ -0,0 +1,21 @@
+package jobs
+
+import "testing"
+
+func TestPushRemote(t *testing.T) {
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{nil, "origin"},
+		{[]string{"gost", "main"}, "gost"},
+		{[]string{"-o", "pr-hash=abcd", "gost", "feature:main"}, "gost"},
+		{[]string{"--force", "https://gitgost.fly.dev/v1/gh/o/r"}, "https://gitgost.fly.dev/v1/gh/o/r"},
+		{[]string{"--push-option", "x=y"}, "origin"},
+	}
+	for _, tc := range cases {
+		if got := pushRemote(tc.args); got != tc.want {
+			t.Errorf("pushRemote(%q) = %q, want %q", tc.args, got, tc.want)
+		}
+	}
+}
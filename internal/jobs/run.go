@@ -35,7 +35,15 @@ func Run(s *Store, id int64) error {
 }
 
 func runGitOperation(s *Store, job *Job) error {
-	args := append([]string{job.Operation}, job.Args...)
+	opArgs := job.Args
+	var global []string
+	if job.Operation == "push" {
+		// A token already covers the proof of work.
+		if global = tokenArgs(s, job); global == nil {
+			opArgs = withPow(s, job, opArgs)
+		}
+	}
+	args := append(append(global, job.Operation), opArgs...)
 	return execGitWithRetry(s, job, job.CWD, args...)
 }
 
@@ -26,6 +26,12 @@ CREATE TABLE IF NOT EXISTS jobs (
     pid INTEGER NOT NULL DEFAULT 0,
     created_at TEXT NOT NULL,
     updated_at TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    server TEXT NOT NULL,
+    token TEXT NOT NULL,
+    created_at TEXT NOT NULL
 );`
 
 type Store struct {
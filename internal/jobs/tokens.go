@@ -0,0 +1,108 @@
+package jobs
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TokenMaxAge mirrors how long a gitGost server keeps accepting tokens from
+// a retired key; older ones are dropped instead of wasted on a push.
+const TokenMaxAge = 48 * time.Hour
+
+// AddTokens stores anonymous tokens issued by server.
+func (s *Store) AddTokens(server string, tokens []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, t := range tokens {
+		if _, err := tx.Exec(`INSERT INTO tokens (server, token, created_at) VALUES (?, ?, ?)`, server, t, now); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// CountTokens returns how many usable tokens are stored for server.
+func (s *Store) CountTokens(server string) (int, error) {
+	if err := s.pruneTokens(); err != nil {
+		return 0, err
+	}
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM tokens WHERE server = ?`, server).Scan(&n)
+	return n, err
+}
+
+// TakeToken removes and returns the oldest usable token for server, or ""
+// when there is none.
+func (s *Store) TakeToken(server string) (string, error) {
+	if err := s.pruneTokens(); err != nil {
+		return "", err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+	var (
+		id    int64
+		token string
+	)
+	err = tx.QueryRow(`SELECT id, token FROM tokens WHERE server = ? ORDER BY id LIMIT 1`, server).Scan(&id, &token)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(`DELETE FROM tokens WHERE id = ?`, id); err != nil {
+		return "", err
+	}
+	return token, tx.Commit()
+}
+
+func (s *Store) pruneTokens() error {
+	cutoff := time.Now().Add(-TokenMaxAge).UTC().Format(time.RFC3339Nano)
+	if _, err := s.db.Exec(`DELETE FROM tokens WHERE created_at < ?`, cutoff); err != nil {
+		return fmt.Errorf("purgar tokens: %w", err)
+	}
+	return nil
+}
+
+// tokenServer returns the stored server whose base URL the remote lives
+// under, so a token is only ever sent to the server that issued it.
+func (s *Store) tokenServer(remote string) string {
+	rows, err := s.db.Query(`SELECT DISTINCT server FROM tokens`)
+	if err != nil {
+		return ""
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var server string
+		if rows.Scan(&server) == nil && strings.HasPrefix(remote, strings.TrimSuffix(server, "/")+"/") {
+			return server
+		}
+	}
+	return ""
+}
+
+// tokenArgs returns the git options that send an anonymous token with a
+// push bound for the server that issued it, so the push isn't judged by the
+// client's IP. It returns nil when there is no token to spend.
+func tokenArgs(s *Store, job *Job) []string {
+	server := s.tokenServer(remoteURL(job.CWD, pushRemote(job.Args)))
+	if server == "" {
+		return nil
+	}
+	token, err := s.TakeToken(server)
+	if err != nil || token == "" {
+		return nil
+	}
+	_ = s.SetProgress(job.ID, "Usando un token anónimo para el push")
+	return []string{"-c", "http.extraHeader=X-Gitgost-Token: " + token}
+}
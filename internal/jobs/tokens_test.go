@@ -0,0 +1,38 @@
+package jobs
+
+import "testing"
+
+func TestTokenWallet(t *testing.T) {
+	s := testStore(t)
+	const server = "https://gitgost.example"
+	if err := s.AddTokens(server, []string{"t1", "t2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddTokens("https://other.example", []string{"o1"}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.CountTokens(server); err != nil || n != 2 {
+		t.Fatalf("CountTokens = %d, %v", n, err)
+	}
+
+	if got := s.tokenServer(server + "/v1/gh/o/r"); got != server {
+		t.Errorf("tokenServer = %q", got)
+	}
+	if got := s.tokenServer("https://gitgost.example.evil/v1/gh/o/r"); got != "" {
+		t.Errorf("token offered to a look-alike host: %q", got)
+	}
+
+	job := &Job{Args: []string{server + "/v1/gh/o/r", "main"}}
+	if args := tokenArgs(s, job); len(args) != 2 || args[1] != "http.extraHeader=X-Gitgost-Token: t1" {
+		t.Errorf("tokenArgs = %q", args)
+	}
+	if tok, _ := s.TakeToken(server); tok != "t2" {
+		t.Errorf("TakeToken = %q, want t2", tok)
+	}
+	if tok, _ := s.TakeToken(server); tok != "" {
+		t.Errorf("TakeToken on empty wallet = %q", tok)
+	}
+	if args := tokenArgs(s, job); args != nil {
+		t.Errorf("tokenArgs without tokens = %q", args)
+	}
+}
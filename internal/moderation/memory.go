@@ -0,0 +1,407 @@
+package moderation
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryMaxHashes bounds each per-hash table so a flood of fresh hashes
+// can't grow the process without limit.
+const memoryMaxHashes = 10000
+
+// memoryMaxAudit bounds the in-memory audit log; the oldest entries go
+// first. Use OpenSQLite to keep the full history.
+const memoryMaxAudit = 10000
+
+// memoryMaxPRs bounds the in-memory PR records; the oldest go first.
+const memoryMaxPRs = 10000
+
+// memoryMaxPREvents bounds the in-memory PR events; the oldest go first.
+const memoryMaxPREvents = 10000
+
+type memoryStore struct {
+	mu      sync.Mutex
+	blocked map[string]bool
+	flagged map[string]time.Time
+	karma   map[string]int
+	reports map[string][]Report
+	appeals map[string]Appeal
+	keys    []SecretKey
+	nextKey int64
+	tokKeys []SecretKey
+	nextTok int64
+	spent   map[int64]map[string]bool
+	audit   []AuditEntry
+	auditID int64
+	prs     []PR
+	events  []PREvent
+	eventID int64
+	subs    map[string]Subscription
+}
+
+// NewMemory returns a Store that lives only as long as the process.
+func NewMemory() Store {
+	return &memoryStore{
+		blocked: make(map[string]bool),
+		flagged: make(map[string]time.Time),
+		karma:   make(map[string]int),
+		reports: make(map[string][]Report),
+		appeals: make(map[string]Appeal),
+		subs:    make(map[string]Subscription),
+		spent:   make(map[int64]map[string]bool),
+	}
+}
+
+// makeRoom drops an arbitrary entry when m is full.
+func makeRoom[V any](m map[string]V, key string) {
+	if _, ok := m[key]; ok || len(m) < memoryMaxHashes {
+		return
+	}
+	for k := range m {
+		delete(m, k)
+		return
+	}
+}
+
+func (s *memoryStore) IsBlocked(_ context.Context, hash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.blocked[hash], nil
+}
+
+func (s *memoryStore) SetBlocked(_ context.Context, hash string, blocked bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !blocked {
+		delete(s.blocked, hash)
+		return nil
+	}
+	makeRoom(s.blocked, hash)
+	s.blocked[hash] = true
+	return nil
+}
+
+func (s *memoryStore) LastFlagged(_ context.Context, hash string) (time.Time, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.flagged[hash]
+	return t, ok, nil
+}
+
+func (s *memoryStore) MarkFlagged(_ context.Context, hash string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	makeRoom(s.flagged, hash)
+	s.flagged[hash] = at
+	return nil
+}
+
+func (s *memoryStore) Karma(_ context.Context, hash string) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.karma[hash]
+	return k, ok, nil
+}
+
+func (s *memoryStore) SetKarma(_ context.Context, hash string, karma int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	makeRoom(s.karma, hash)
+	s.karma[hash] = karma
+	return nil
+}
+
+func (s *memoryStore) AddReport(_ context.Context, hash string, r Report, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cutoff := r.At.Add(-window)
+	kept := s.reports[hash][:0]
+	duplicate := false
+	for _, old := range s.reports[hash] {
+		if old.At.Before(cutoff) {
+			continue
+		}
+		if r.Reporter != "" && old.Reporter == r.Reporter {
+			duplicate = true
+		}
+		kept = append(kept, old)
+	}
+	if !duplicate {
+		makeRoom(s.reports, hash)
+		kept = append(kept, r)
+	}
+	s.reports[hash] = kept
+	return len(kept), nil
+}
+
+func (s *memoryStore) ReportCount(_ context.Context, hash string, since time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, r := range s.reports[hash] {
+		if !r.At.Before(since) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *memoryStore) Reports(_ context.Context, hash string, since time.Time) ([]Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Report
+	for _, r := range s.reports[hash] {
+		if !r.At.Before(since) {
+			out = append(out, r)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].At.Before(out[j].At) })
+	return out, nil
+}
+
+func (s *memoryStore) SaveAppeal(_ context.Context, a Appeal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	makeRoom(s.appeals, a.ID)
+	s.appeals[a.ID] = a
+	return nil
+}
+
+func (s *memoryStore) Appeal(_ context.Context, id string) (Appeal, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.appeals[id]
+	return a, ok, nil
+}
+
+func (s *memoryStore) Appeals(_ context.Context, since time.Time) ([]Appeal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Appeal, 0, len(s.appeals))
+	for _, a := range s.appeals {
+		if !a.CreatedAt.Before(since) {
+			out = append(out, a)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (s *memoryStore) SecretKeys(_ context.Context) ([]SecretKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.keys) == 0 {
+		if _, err := s.rotateLocked(1); err != nil {
+			return nil, err
+		}
+	}
+	return append([]SecretKey(nil), s.keys...), nil
+}
+
+func (s *memoryStore) RotateSecretKey(_ context.Context, keep int) (SecretKey, error) {
+	if keep < 1 {
+		return SecretKey{}, ErrKeepTooSmall
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rotateLocked(keep)
+}
+
+func (s *memoryStore) TokenKeys(_ context.Context) ([]SecretKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SecretKey(nil), s.tokKeys...), nil
+}
+
+func (s *memoryStore) AddTokenKey(_ context.Context, key []byte, staleBefore time.Time, keep int) ([]SecretKey, error) {
+	if keep < 1 {
+		return nil, ErrKeepTooSmall
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.tokKeys) == 0 || !s.tokKeys[0].CreatedAt.After(staleBefore) {
+		s.nextTok++
+		k := SecretKey{ID: s.nextTok, Key: append([]byte(nil), key...), CreatedAt: time.Now()}
+		s.tokKeys = append([]SecretKey{k}, s.tokKeys...)
+		if len(s.tokKeys) > keep {
+			for _, old := range s.tokKeys[keep:] {
+				delete(s.spent, old.ID)
+			}
+			s.tokKeys = s.tokKeys[:keep]
+		}
+	}
+	return append([]SecretKey(nil), s.tokKeys...), nil
+}
+
+func (s *memoryStore) SpendToken(_ context.Context, keyID int64, spendID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := s.spent[keyID]
+	if ids == nil {
+		ids = map[string]bool{}
+		s.spent[keyID] = ids
+	}
+	if ids[spendID] {
+		return false, nil
+	}
+	ids[spendID] = true
+	return true, nil
+}
+
+func (s *memoryStore) rotateLocked(keep int) (SecretKey, error) {
+	b, err := newKeyBytes()
+	if err != nil {
+		return SecretKey{}, err
+	}
+	s.nextKey++
+	k := SecretKey{ID: s.nextKey, Key: b, CreatedAt: time.Now()}
+	s.keys = append([]SecretKey{k}, s.keys...)
+	if len(s.keys) > keep {
+		s.keys = s.keys[:keep]
+	}
+	return k, nil
+}
+
+func (s *memoryStore) AppendAudit(_ context.Context, e AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auditID++
+	e.ID = s.auditID
+	if len(s.audit) >= memoryMaxAudit {
+		s.audit = append(s.audit[:0], s.audit[1:]...)
+	}
+	s.audit = append(s.audit, e)
+	return nil
+}
+
+func (s *memoryStore) AuditLog(_ context.Context, q AuditQuery) ([]AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []AuditEntry
+	for i := len(s.audit) - 1; i >= 0 && len(out) < q.limit(); i-- {
+		if q.matches(s.audit[i]) {
+			out = append(out, s.audit[i])
+		}
+	}
+	return out, nil
+}
+
+func (s *memoryStore) AddPR(_ context.Context, pr PR) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.prs {
+		if p.URL == pr.URL {
+			return nil
+		}
+	}
+	if len(s.prs) >= memoryMaxPRs {
+		s.prs = append(s.prs[:0], s.prs[1:]...)
+	}
+	s.prs = append(s.prs, pr)
+	return nil
+}
+
+func (s *memoryStore) PRs(_ context.Context, q PRQuery) ([]PR, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []PR
+	for i := len(s.prs) - 1; i >= 0 && len(out) < q.limit(); i-- {
+		if q.matches(s.prs[i]) {
+			out = append(out, s.prs[i])
+		}
+	}
+	return out, nil
+}
+
+func (s *memoryStore) MarkPRClosed(_ context.Context, url string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.prs {
+		if s.prs[i].URL == url && s.prs[i].ClosedAt.IsZero() {
+			s.prs[i].ClosedAt = at
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) ReopenPR(_ context.Context, url string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.prs {
+		if s.prs[i].URL == url {
+			s.prs[i].ClosedAt = time.Time{}
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) PurgePRs(_ context.Context, closedBefore time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.prs[:0]
+	purged := make(map[string]bool)
+	for _, pr := range s.prs {
+		if pr.ClosedAt.IsZero() || !pr.ClosedAt.Before(closedBefore) {
+			kept = append(kept, pr)
+		} else {
+			purged[pr.Hash] = true
+			delete(s.subs, pr.Hash)
+		}
+	}
+	n := len(s.prs) - len(kept)
+	s.prs = kept
+	events := s.events[:0]
+	for _, e := range s.events {
+		if !purged[e.Hash] {
+			events = append(events, e)
+		}
+	}
+	s.events = events
+	return n, nil
+}
+
+func (s *memoryStore) AddPREvent(_ context.Context, e PREvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.eventID++
+	e.ID = s.eventID
+	if len(s.events) >= memoryMaxPREvents {
+		s.events = append(s.events[:0], s.events[1:]...)
+	}
+	s.events = append(s.events, e)
+	return nil
+}
+
+func (s *memoryStore) PREvents(_ context.Context, q PREventQuery) ([]PREvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []PREvent
+	for i := len(s.events) - 1; i >= 0 && len(out) < q.limit(); i-- {
+		if q.matches(s.events[i]) {
+			out = append(out, s.events[i])
+		}
+	}
+	return out, nil
+}
+
+func (s *memoryStore) SetPRSubscription(_ context.Context, hash string, sub Subscription) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subs[hash]; ok {
+		return false, nil
+	}
+	makeRoom(s.subs, hash)
+	s.subs[hash] = sub
+	return true, nil
+}
+
+func (s *memoryStore) PRSubscription(_ context.Context, hash string) (Subscription, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subs[hash]
+	return sub, ok, nil
+}
+
+func (s *memoryStore) Close() error { return nil }
@@ -0,0 +1,264 @@
+package moderation
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Store keeps moderation decisions: blocked and flagged hashes, karma,
+// reports, appeal tickets, the PRs this instance opened and the HMAC keys
+// anonymous hashes are derived from. NewMemory keeps everything in the process; OpenSQLite survives
+// restarts.
+type Store interface {
+	IsBlocked(ctx context.Context, hash string) (bool, error)
+	SetBlocked(ctx context.Context, hash string, blocked bool) error
+
+	// LastFlagged returns when a flagged hash last acted.
+	LastFlagged(ctx context.Context, hash string) (time.Time, bool, error)
+	MarkFlagged(ctx context.Context, hash string, at time.Time) error
+
+	Karma(ctx context.Context, hash string) (int, bool, error)
+	SetKarma(ctx context.Context, hash string, karma int) error
+
+	// AddReport records r against hash and returns how many reports it has
+	// within window. A reporter that already reported the hash within
+	// window is not counted twice; an empty reporter always counts.
+	AddReport(ctx context.Context, hash string, r Report, window time.Duration) (int, error)
+	ReportCount(ctx context.Context, hash string, since time.Time) (int, error)
+	// Reports lists the reports against hash made at or after since,
+	// oldest first.
+	Reports(ctx context.Context, hash string, since time.Time) ([]Report, error)
+
+	SaveAppeal(ctx context.Context, a Appeal) error
+	Appeal(ctx context.Context, id string) (Appeal, bool, error)
+	// Appeals lists appeals created at or after since.
+	Appeals(ctx context.Context, since time.Time) ([]Appeal, error)
+
+	// SecretKeys returns the HMAC keys, newest first, creating the first
+	// one when there is none.
+	SecretKeys(ctx context.Context) ([]SecretKey, error)
+	// RotateSecretKey adds a new key and keeps at most keep keys in total,
+	// so hashes derived under recent keys can still be recognized.
+	RotateSecretKey(ctx context.Context, keep int) (SecretKey, error)
+	// TokenKeys returns the keys anonymous tokens are blind-signed with,
+	// newest first.
+	TokenKeys(ctx context.Context) ([]SecretKey, error)
+	// AddTokenKey makes key the newest token key and keeps at most keep
+	// keys, unless a key created after staleBefore exists: then another
+	// instance rotated first and nothing changes. It returns the keys
+	// afterwards, newest first.
+	AddTokenKey(ctx context.Context, key []byte, staleBefore time.Time, keep int) ([]SecretKey, error)
+	// SpendToken records that the token spendID, signed with the token key
+	// keyID, was redeemed, and reports false when it already was. Spends
+	// are forgotten together with their key.
+	SpendToken(ctx context.Context, keyID int64, spendID string) (bool, error)
+
+	// AddPR records a PR this instance opened. A PR already recorded under
+	// the same URL is left as it is.
+	AddPR(ctx context.Context, pr PR) error
+	// PRs lists the recorded PRs matching q, newest first.
+	PRs(ctx context.Context, q PRQuery) ([]PR, error)
+	// MarkPRClosed notes that the PR at url was closed at at. A PR already
+	// marked closed keeps its first close time.
+	MarkPRClosed(ctx context.Context, url string, at time.Time) error
+	// ReopenPR clears the close time of the PR at url, so a reopened PR is
+	// kept like any open one.
+	ReopenPR(ctx context.Context, url string) error
+	// PurgePRs forgets the PRs closed before closedBefore, their events
+	// and subscriptions, and reports how many PRs it removed. Open PRs are
+	// kept.
+	PurgePRs(ctx context.Context, closedBefore time.Time) (int, error)
+
+	// AddPREvent records something a forge reported about a PR.
+	AddPREvent(ctx context.Context, e PREvent) error
+	// PREvents lists the events matching q, newest first.
+	PREvents(ctx context.Context, q PREventQuery) ([]PREvent, error)
+
+	// SetPRSubscription stores the subscription of the PR with that hash
+	// unless it already has one, and reports whether it stored it.
+	SetPRSubscription(ctx context.Context, hash string, sub Subscription) (bool, error)
+	// PRSubscription returns the subscription of a PR.
+	PRSubscription(ctx context.Context, hash string) (Subscription, bool, error)
+
+	// AppendAudit records an admin action. The log is append-only: there is
+	// no way to edit or remove an entry through the Store.
+	AppendAudit(ctx context.Context, e AuditEntry) error
+	// AuditLog lists entries matching q, newest first.
+	AuditLog(ctx context.Context, q AuditQuery) ([]AuditEntry, error)
+
+	Close() error
+}
+
+// Report is one report against a hash. Reporter is an opaque key (the
+// daily IP hash), never an address.
+type Report struct {
+	Reporter string
+	Category string
+	Detail   string
+	At       time.Time
+}
+
+// Appeal is an anonymous request to lift a ban.
+type Appeal struct {
+	ID        string
+	Hash      string
+	Message   string
+	CreatedAt time.Time
+	Resolved  bool
+	Unbanned  bool
+}
+
+// AuditEntry is one admin action. Target is what it acted on (a hash, an
+// appeal ticket, a PR URL); Detail carries the outcome.
+type AuditEntry struct {
+	ID     int64     `json:"id"`
+	At     time.Time `json:"at"`
+	Actor  string    `json:"actor"`
+	Role   string    `json:"role"`
+	Action string    `json:"action"`
+	Target string    `json:"target,omitempty"`
+	Reason string    `json:"reason,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// AuditQuery filters AuditLog. Zero fields match everything; Limit <= 0
+// means DefaultAuditLimit.
+type AuditQuery struct {
+	Since  time.Time
+	Until  time.Time
+	Actor  string
+	Action string
+	Limit  int
+}
+
+// DefaultAuditLimit caps AuditLog results when the query sets no limit.
+const DefaultAuditLimit = 100
+
+func (q AuditQuery) limit() int {
+	if q.Limit <= 0 {
+		return DefaultAuditLimit
+	}
+	return q.Limit
+}
+
+func (q AuditQuery) matches(e AuditEntry) bool {
+	return (q.Since.IsZero() || !e.At.Before(q.Since)) &&
+		(q.Until.IsZero() || e.At.Before(q.Until)) &&
+		(q.Actor == "" || e.Actor == q.Actor) &&
+		(q.Action == "" || e.Action == q.Action)
+}
+
+// PR is a pull or merge request opened for an anonymous contribution.
+// Provider is the short forge name: gh, gl or cb.
+type PR struct {
+	Hash      string    `json:"hash"`
+	Provider  string    `json:"provider"`
+	Owner     string    `json:"owner"`
+	Repo      string    `json:"repo"`
+	Number    int       `json:"number"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+	ClosedAt  time.Time `json:"closed_at,omitzero"`
+}
+
+// PRQuery filters PRs. Zero fields match everything; Owner and Repo match
+// case-insensitively, HashPrefix matches the start of the PR hash and
+// Limit <= 0 means DefaultPRLimit.
+type PRQuery struct {
+	Provider   string
+	Owner      string
+	Repo       string
+	Number     int
+	Hash       string
+	HashPrefix string
+	Since      time.Time
+	Until      time.Time
+	OpenOnly   bool
+	Limit      int
+}
+
+// DefaultPRLimit caps PRs results when the query sets no limit.
+const DefaultPRLimit = 100
+
+func (q PRQuery) limit() int {
+	if q.Limit <= 0 {
+		return DefaultPRLimit
+	}
+	return q.Limit
+}
+
+func (q PRQuery) matches(pr PR) bool {
+	return (q.Provider == "" || pr.Provider == q.Provider) &&
+		(q.Owner == "" || strings.EqualFold(pr.Owner, q.Owner)) &&
+		(q.Repo == "" || strings.EqualFold(pr.Repo, q.Repo)) &&
+		(q.Number == 0 || pr.Number == q.Number) &&
+		(q.Hash == "" || pr.Hash == q.Hash) &&
+		strings.HasPrefix(pr.Hash, q.HashPrefix) &&
+		(q.Since.IsZero() || !pr.CreatedAt.Before(q.Since)) &&
+		(q.Until.IsZero() || pr.CreatedAt.Before(q.Until)) &&
+		(!q.OpenOnly || pr.ClosedAt.IsZero())
+}
+
+// PREvent is a review, comment, merge or close on a recorded PR. ID grows
+// with every event the store records.
+type PREvent struct {
+	ID        int64     `json:"id"`
+	Hash      string    `json:"hash"`
+	Type      string    `json:"type"`
+	Author    string    `json:"author,omitempty"`
+	Body      string    `json:"body,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PREventQuery filters PREvents. Zero fields match everything; AfterID
+// skips events up to and including that ID, and Limit <= 0 means
+// DefaultPREventLimit.
+type PREventQuery struct {
+	Hash    string
+	AfterID int64
+	Limit   int
+}
+
+// DefaultPREventLimit caps PREvents results when the query sets no limit.
+const DefaultPREventLimit = 100
+
+func (q PREventQuery) limit() int {
+	if q.Limit <= 0 {
+		return DefaultPREventLimit
+	}
+	return q.Limit
+}
+
+func (q PREventQuery) matches(e PREvent) bool {
+	return (q.Hash == "" || e.Hash == q.Hash) && e.ID > q.AfterID
+}
+
+// Subscription is how the author of a PR follows it: the hash of the secret
+// that opens its notification endpoints and, when set, the public key its
+// notifications are encrypted to.
+type Subscription struct {
+	TokenHash string
+	NotifyKey string
+}
+
+// SecretKey is an HMAC key for deriving anonymous hashes, or a token
+// signing key in PKCS #1 form.
+type SecretKey struct {
+	ID        int64
+	Key       []byte
+	CreatedAt time.Time
+}
+
+// ErrKeepTooSmall is returned when a rotation would drop the new key.
+var ErrKeepTooSmall = errors.New("moderation: must keep at least one key")
+
+func newKeyBytes() ([]byte, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,426 @@
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func stores(t *testing.T) map[string]Store {
+	t.Helper()
+	sq, err := OpenSQLite(filepath.Join(t.TempDir(), "moderation.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sq.Close() })
+	return map[string]Store{"memory": NewMemory(), "sqlite": sq}
+}
+
+func TestStores(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now()
+
+			if b, _ := s.IsBlocked(ctx, "h1"); b {
+				t.Fatal("fresh hash blocked")
+			}
+			_ = s.SetBlocked(ctx, "h1", true)
+			_ = s.SetBlocked(ctx, "h1", true)
+			if b, _ := s.IsBlocked(ctx, "h1"); !b {
+				t.Fatal("block not stored")
+			}
+			_ = s.SetBlocked(ctx, "h1", false)
+			if b, _ := s.IsBlocked(ctx, "h1"); b {
+				t.Fatal("unblock not stored")
+			}
+
+			if _, ok, _ := s.Karma(ctx, "h1"); ok {
+				t.Fatal("karma for unknown hash")
+			}
+			_ = s.SetKarma(ctx, "h1", 4)
+			if k, ok, _ := s.Karma(ctx, "h1"); !ok || k != 4 {
+				t.Fatalf("Karma = %d, %v", k, ok)
+			}
+
+			_ = s.MarkFlagged(ctx, "h1", now)
+			if at, ok, _ := s.LastFlagged(ctx, "h1"); !ok || at.UnixNano() != now.UnixNano() {
+				t.Fatalf("LastFlagged = %v, %v", at, ok)
+			}
+
+			win := time.Hour
+			if n, _ := s.AddReport(ctx, "h2", Report{Reporter: "ipA", At: now.Add(-2 * win)}, win); n != 1 {
+				t.Fatalf("first report count = %d", n)
+			}
+			if n, _ := s.AddReport(ctx, "h2", Report{Reporter: "ipA", At: now}, win); n != 1 {
+				t.Fatalf("expired report still counted: %d", n)
+			}
+			if n, _ := s.AddReport(ctx, "h2", Report{Reporter: "ipA", At: now}, win); n != 1 {
+				t.Fatalf("duplicate reporter counted: %d", n)
+			}
+			if n, _ := s.AddReport(ctx, "h2", Report{Reporter: "ipB", Category: "malware", Detail: "dropper", At: now}, win); n != 2 {
+				t.Fatalf("second reporter count = %d", n)
+			}
+			if n, _ := s.AddReport(ctx, "h2", Report{At: now}, win); n != 3 {
+				t.Fatalf("anonymous report count = %d", n)
+			}
+			if n, _ := s.ReportCount(ctx, "h2", now.Add(-win)); n != 3 {
+				t.Fatalf("ReportCount = %d", n)
+			}
+			list, err := s.Reports(ctx, "h2", now.Add(-win))
+			if err != nil || len(list) != 3 || list[1].Category != "malware" || list[1].Detail != "dropper" || list[1].Reporter != "ipB" {
+				t.Fatalf("Reports = %+v, %v", list, err)
+			}
+
+			a := Appeal{ID: "t1", Hash: "h1", CreatedAt: now}
+			_ = s.SaveAppeal(ctx, a)
+			a.Message, a.Resolved, a.Unbanned = "please", true, true
+			_ = s.SaveAppeal(ctx, a)
+			got, ok, err := s.Appeal(ctx, "t1")
+			if err != nil || !ok || got.Message != "please" || !got.Resolved || !got.Unbanned || got.Hash != "h1" {
+				t.Fatalf("Appeal = %+v, %v, %v", got, ok, err)
+			}
+			if list, _ := s.Appeals(ctx, now.Add(-time.Minute)); len(list) != 1 {
+				t.Fatalf("Appeals = %+v", list)
+			}
+			if list, _ := s.Appeals(ctx, now.Add(time.Minute)); len(list) != 0 {
+				t.Fatalf("Appeals after since = %+v", list)
+			}
+		})
+	}
+}
+
+func TestSecretKeyRotation(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			first, err := s.SecretKeys(ctx)
+			if err != nil || len(first) != 1 || len(first[0].Key) != 32 {
+				t.Fatalf("SecretKeys = %+v, %v", first, err)
+			}
+			again, _ := s.SecretKeys(ctx)
+			if !bytes.Equal(again[0].Key, first[0].Key) {
+				t.Fatal("key changed without rotation")
+			}
+			k2, _ := s.RotateSecretKey(ctx, 2)
+			k3, _ := s.RotateSecretKey(ctx, 2)
+			keys, _ := s.SecretKeys(ctx)
+			if len(keys) != 2 || !bytes.Equal(keys[0].Key, k3.Key) || !bytes.Equal(keys[1].Key, k2.Key) {
+				t.Fatalf("keys after rotation = %d", len(keys))
+			}
+			if _, err := s.RotateSecretKey(ctx, 0); err != ErrKeepTooSmall {
+				t.Errorf("RotateSecretKey(0) = %v", err)
+			}
+		})
+	}
+}
+
+func TestTokenKeyRotation(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if keys, err := s.TokenKeys(ctx); err != nil || len(keys) != 0 {
+				t.Fatalf("TokenKeys = %+v, %v", keys, err)
+			}
+			keys, err := s.AddTokenKey(ctx, []byte("k1"), time.Now().Add(-time.Hour), 2)
+			if err != nil || len(keys) != 1 || string(keys[0].Key) != "k1" {
+				t.Fatalf("first key = %+v, %v", keys, err)
+			}
+			// Another instance saw the same stale key: its key is dropped.
+			keys, _ = s.AddTokenKey(ctx, []byte("lost"), time.Now().Add(-time.Hour), 2)
+			if len(keys) != 1 || string(keys[0].Key) != "k1" {
+				t.Fatalf("racing rotation stored %+v", keys)
+			}
+			_, _ = s.AddTokenKey(ctx, []byte("k2"), time.Now().Add(time.Second), 2)
+			keys, _ = s.AddTokenKey(ctx, []byte("k3"), time.Now().Add(time.Second), 2)
+			if len(keys) != 2 || string(keys[0].Key) != "k3" || string(keys[1].Key) != "k2" {
+				t.Fatalf("keys after rotation = %+v", keys)
+			}
+			if again, _ := s.TokenKeys(ctx); len(again) != 2 || string(again[0].Key) != "k3" {
+				t.Errorf("TokenKeys = %+v", again)
+			}
+		})
+	}
+}
+
+func TestSpendToken(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			keys, _ := s.AddTokenKey(ctx, []byte("k1"), time.Now().Add(-time.Hour), 2)
+			k1 := keys[0].ID
+			if ok, err := s.SpendToken(ctx, k1, "a"); err != nil || !ok {
+				t.Fatalf("first spend = %v, %v", ok, err)
+			}
+			if ok, _ := s.SpendToken(ctx, k1, "a"); ok {
+				t.Error("token spent twice")
+			}
+			// The spend is kept while its key can still verify tokens.
+			_, _ = s.AddTokenKey(ctx, []byte("k2"), time.Now().Add(time.Second), 2)
+			if ok, _ := s.SpendToken(ctx, k1, "a"); ok {
+				t.Error("spend forgotten while its key is kept")
+			}
+			_, _ = s.AddTokenKey(ctx, []byte("k3"), time.Now().Add(time.Second), 2)
+			if ok, _ := s.SpendToken(ctx, k1, "a"); !ok {
+				t.Error("spend kept after its key was dropped")
+			}
+		})
+	}
+}
+
+func TestSQLiteAddsReportColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "moderation.db")
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE reports (hash TEXT NOT NULL, reporter TEXT NOT NULL DEFAULT '', at INTEGER NOT NULL);
+		INSERT INTO reports (hash, reporter, at) VALUES ('old', 'ip', 1)`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	if _, err := s.AddReport(ctx, "old", Report{Reporter: "ip2", Category: "spam", At: time.Unix(0, 2)}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	list, err := s.Reports(ctx, "old", time.Unix(0, 0))
+	if err != nil || len(list) != 2 || list[0].Category != "" || list[1].Category != "spam" {
+		t.Fatalf("Reports = %+v, %v", list, err)
+	}
+}
+
+func TestSQLiteAddsNotifyKeyColumn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "moderation.db")
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE pr_subscriptions (hash TEXT PRIMARY KEY, token_hash TEXT NOT NULL);
+		INSERT INTO pr_subscriptions (hash, token_hash) VALUES ('old', 'h')`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if sub, ok, err := s.PRSubscription(context.Background(), "old"); err != nil || !ok || sub != (Subscription{TokenHash: "h"}) {
+		t.Fatalf("PRSubscription = %+v, %v, %v", sub, ok, err)
+	}
+}
+
+func TestSQLiteSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "moderation.db")
+	ctx := context.Background()
+	s, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.SetBlocked(ctx, "banned", true)
+	_ = s.SaveAppeal(ctx, Appeal{ID: "t", Hash: "banned", CreatedAt: time.Now()})
+	keys, _ := s.SecretKeys(ctx)
+	s.Close()
+
+	s, err = OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if b, _ := s.IsBlocked(ctx, "banned"); !b {
+		t.Error("ban lost across reopen")
+	}
+	if _, ok, _ := s.Appeal(ctx, "t"); !ok {
+		t.Error("appeal lost across reopen")
+	}
+	again, _ := s.SecretKeys(ctx)
+	if len(again) != 1 || !bytes.Equal(again[0].Key, keys[0].Key) {
+		t.Error("secret key changed across reopen")
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			base := time.Now()
+			for i, action := range []string{"panic", "unblock", "panic"} {
+				e := AuditEntry{At: base.Add(time.Duration(i) * time.Second), Actor: "alice", Role: "operator", Action: action, Reason: "incident"}
+				if err := s.AppendAudit(ctx, e); err != nil {
+					t.Fatal(err)
+				}
+			}
+			all, err := s.AuditLog(ctx, AuditQuery{})
+			if err != nil || len(all) != 3 || all[0].Action != "panic" || all[1].Action != "unblock" || all[0].ID <= all[2].ID {
+				t.Fatalf("AuditLog = %+v, %v", all, err)
+			}
+			if got, _ := s.AuditLog(ctx, AuditQuery{Action: "panic", Limit: 1}); len(got) != 1 || got[0].ID != all[0].ID {
+				t.Errorf("filtered = %+v", got)
+			}
+			if got, _ := s.AuditLog(ctx, AuditQuery{Since: base.Add(time.Second), Until: base.Add(2 * time.Second)}); len(got) != 1 || got[0].Action != "unblock" {
+				t.Errorf("window = %+v", got)
+			}
+		})
+	}
+}
+
+func TestPRRecords(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			base := time.Now()
+			for i, pr := range []PR{
+				{Hash: "aaaa1111", Provider: "gh", Owner: "Acme", Repo: "site", Number: 1, URL: "https://github.com/Acme/site/pull/1"},
+				{Hash: "aaaa2222", Provider: "gh", Owner: "acme", Repo: "site", Number: 2, URL: "https://github.com/acme/site/pull/2"},
+				{Hash: "bbbb3333", Provider: "gl", Owner: "acme", Repo: "api", Number: 3, URL: "https://gitlab.com/acme/api/-/merge_requests/3"},
+			} {
+				pr.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+				if err := s.AddPR(ctx, pr); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Re-recording an update keeps the original creation time.
+			_ = s.AddPR(ctx, PR{Hash: "aaaa1111", Provider: "gh", Owner: "Acme", Repo: "site", Number: 1, URL: "https://github.com/Acme/site/pull/1", CreatedAt: base.Add(time.Hour)})
+
+			all, err := s.PRs(ctx, PRQuery{})
+			if err != nil || len(all) != 3 || all[0].Number != 3 || all[2].Number != 1 {
+				t.Fatalf("PRs = %+v, %v", all, err)
+			}
+			if got, _ := s.PRs(ctx, PRQuery{Owner: "ACME", Repo: "Site"}); len(got) != 2 {
+				t.Errorf("by repo = %+v", got)
+			}
+			if got, _ := s.PRs(ctx, PRQuery{HashPrefix: "aaaa2"}); len(got) != 1 || got[0].Number != 2 {
+				t.Errorf("by hash prefix = %+v", got)
+			}
+			if got, _ := s.PRs(ctx, PRQuery{HashPrefix: "aaaa"}); len(got) != 2 {
+				t.Errorf("by short hash prefix = %+v", got)
+			}
+			if got, _ := s.PRs(ctx, PRQuery{Hash: "aaaa2222"}); len(got) != 1 || got[0].Number != 2 {
+				t.Errorf("by hash = %+v", got)
+			}
+			if got, _ := s.PRs(ctx, PRQuery{Hash: "aaaa"}); len(got) != 0 {
+				t.Errorf("hash matched a prefix: %+v", got)
+			}
+			if got, _ := s.PRs(ctx, PRQuery{Provider: "gl"}); len(got) != 1 || got[0].Number != 3 {
+				t.Errorf("by provider = %+v", got)
+			}
+			if got, _ := s.PRs(ctx, PRQuery{Since: base.Add(time.Minute), Until: base.Add(2 * time.Minute)}); len(got) != 1 || got[0].Number != 2 {
+				t.Errorf("window = %+v", got)
+			}
+
+			_ = s.MarkPRClosed(ctx, "https://github.com/acme/site/pull/2", base)
+			open, _ := s.PRs(ctx, PRQuery{Owner: "acme", Repo: "site", OpenOnly: true})
+			if len(open) != 1 || open[0].Number != 1 {
+				t.Errorf("open = %+v", open)
+			}
+			if got, _ := s.PRs(ctx, PRQuery{HashPrefix: "aaaa2"}); len(got) != 1 || got[0].ClosedAt.IsZero() {
+				t.Errorf("closed PR = %+v", got)
+			}
+			// Seeing it closed again later must not push retention out.
+			_ = s.MarkPRClosed(ctx, "https://github.com/acme/site/pull/2", base.Add(time.Hour))
+			if got, _ := s.PRs(ctx, PRQuery{HashPrefix: "aaaa2"}); len(got) != 1 || !got[0].ClosedAt.Equal(base) {
+				t.Errorf("close time moved: %+v", got)
+			}
+
+			// A reopened PR is kept however long ago it was first closed.
+			_ = s.MarkPRClosed(ctx, "https://github.com/Acme/site/pull/1", base)
+			if got, _ := s.PRs(ctx, PRQuery{HashPrefix: "aaaa1"}); len(got) != 1 || got[0].ClosedAt.IsZero() {
+				t.Errorf("closed PR = %+v", got)
+			}
+			_ = s.ReopenPR(ctx, "https://github.com/Acme/site/pull/1")
+			if got, _ := s.PRs(ctx, PRQuery{HashPrefix: "aaaa1"}); len(got) != 1 || !got[0].ClosedAt.IsZero() {
+				t.Errorf("reopened PR = %+v", got)
+			}
+
+			if n, err := s.PurgePRs(ctx, base.Add(time.Minute)); err != nil || n != 1 {
+				t.Errorf("PurgePRs = %d, %v", n, err)
+			}
+			if got, _ := s.PRs(ctx, PRQuery{HashPrefix: "aaaa2"}); len(got) != 0 {
+				t.Errorf("purged PR still listed: %+v", got)
+			}
+			if got, _ := s.PRs(ctx, PRQuery{Owner: "acme", Repo: "site"}); len(got) != 1 {
+				t.Errorf("open PR purged: %+v", got)
+			}
+		})
+	}
+}
+
+func TestSQLiteAuditLogIsAppendOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "moderation.db")
+	s, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	_ = s.AppendAudit(context.Background(), AuditEntry{At: time.Now(), Actor: "a", Role: "operator", Action: "panic"})
+	db := s.(*sqliteStore).db
+	if _, err := db.Exec(`UPDATE audit_log SET actor = 'b'`); err == nil {
+		t.Error("audit entry updated")
+	}
+	if _, err := db.Exec(`DELETE FROM audit_log`); err == nil {
+		t.Error("audit entry deleted")
+	}
+}
+
+func TestPREvents(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			base := time.Now()
+			_ = s.AddPR(ctx, PR{Hash: "cccc1111", Provider: "gh", Owner: "o", Repo: "r", Number: 9, URL: "https://github.com/o/r/pull/9", CreatedAt: base})
+			for i, typ := range []string{"commented", "approved", "merged"} {
+				if err := s.AddPREvent(ctx, PREvent{Hash: "cccc1111", Type: typ, Author: "maint", CreatedAt: base.Add(time.Duration(i) * time.Second)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_ = s.AddPREvent(ctx, PREvent{Hash: "dddd2222", Type: "closed", CreatedAt: base})
+
+			got, err := s.PREvents(ctx, PREventQuery{Hash: "cccc1111"})
+			if err != nil || len(got) != 3 || got[0].Type != "merged" || got[2].Type != "commented" {
+				t.Fatalf("PREvents = %+v, %v", got, err)
+			}
+			if !(got[0].ID > got[1].ID && got[1].ID > got[2].ID) {
+				t.Errorf("IDs not increasing: %+v", got)
+			}
+			if after, _ := s.PREvents(ctx, PREventQuery{Hash: "cccc1111", AfterID: got[1].ID}); len(after) != 1 || after[0].Type != "merged" {
+				t.Errorf("after %d = %+v", got[1].ID, after)
+			}
+			if got, _ := s.PRs(ctx, PRQuery{Owner: "O", Repo: "r", Number: 9}); len(got) != 1 {
+				t.Errorf("by number = %+v", got)
+			}
+
+			sub := Subscription{TokenHash: "h1", NotifyKey: "x25519:k1"}
+			if ok, err := s.SetPRSubscription(ctx, "cccc1111", sub); err != nil || !ok {
+				t.Fatalf("SetPRSubscription = %v, %v", ok, err)
+			}
+			if ok, _ := s.SetPRSubscription(ctx, "cccc1111", Subscription{TokenHash: "h2"}); ok {
+				t.Error("second subscription replaced the first")
+			}
+			if got, ok, _ := s.PRSubscription(ctx, "cccc1111"); !ok || got != sub {
+				t.Errorf("PRSubscription = %+v, %v", got, ok)
+			}
+
+			_ = s.MarkPRClosed(ctx, "https://github.com/o/r/pull/9", base)
+			_, _ = s.PurgePRs(ctx, base.Add(time.Minute))
+			if got, _ := s.PREvents(ctx, PREventQuery{Hash: "cccc1111"}); len(got) != 0 {
+				t.Errorf("events of a purged PR kept: %+v", got)
+			}
+			if _, ok, _ := s.PRSubscription(ctx, "cccc1111"); ok {
+				t.Error("subscription of a purged PR kept")
+			}
+			if got, _ := s.PREvents(ctx, PREventQuery{}); len(got) != 1 {
+				t.Errorf("other events = %+v", got)
+			}
+		})
+	}
+}
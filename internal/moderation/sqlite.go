@@ -0,0 +1,691 @@
+package moderation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS blocked (
+    hash TEXT PRIMARY KEY,
+    blocked_at INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS flagged (
+    hash TEXT PRIMARY KEY,
+    at INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS karma (
+    hash TEXT PRIMARY KEY,
+    karma INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS reports (
+    hash TEXT NOT NULL,
+    reporter TEXT NOT NULL DEFAULT '',
+    category TEXT NOT NULL DEFAULT '',
+    detail TEXT NOT NULL DEFAULT '',
+    at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS reports_hash_at ON reports (hash, at);
+CREATE TABLE IF NOT EXISTS appeals (
+    id TEXT PRIMARY KEY,
+    hash TEXT NOT NULL,
+    message TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL,
+    resolved INTEGER NOT NULL DEFAULT 0,
+    unbanned INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS secret_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    key BLOB NOT NULL,
+    created_at INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS token_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    key BLOB NOT NULL,
+    created_at INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS token_spends (
+    key_id INTEGER NOT NULL,
+    spend_id TEXT NOT NULL,
+    PRIMARY KEY (key_id, spend_id)
+);
+CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    at INTEGER NOT NULL,
+    actor TEXT NOT NULL,
+    role TEXT NOT NULL,
+    action TEXT NOT NULL,
+    target TEXT NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',
+    detail TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS audit_log_at ON audit_log (at);
+CREATE TABLE IF NOT EXISTS prs (
+    url TEXT PRIMARY KEY,
+    hash TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    owner TEXT NOT NULL,
+    repo TEXT NOT NULL,
+    number INTEGER NOT NULL,
+    created_at INTEGER NOT NULL,
+    closed_at INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS prs_created_at ON prs (created_at);
+CREATE INDEX IF NOT EXISTS prs_repo ON prs (owner COLLATE NOCASE, repo COLLATE NOCASE);
+CREATE INDEX IF NOT EXISTS prs_hash ON prs (hash);
+CREATE TABLE IF NOT EXISTS pr_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    hash TEXT NOT NULL,
+    type TEXT NOT NULL,
+    author TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS pr_events_hash ON pr_events (hash, id);
+CREATE TABLE IF NOT EXISTS pr_subscriptions (
+    hash TEXT PRIMARY KEY,
+    token_hash TEXT NOT NULL,
+    notify_key TEXT NOT NULL DEFAULT ''
+);
+CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN SELECT RAISE(ABORT, 'audit log is append-only'); END;
+CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN SELECT RAISE(ABORT, 'audit log is append-only'); END;`
+
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens (or creates) a moderation database at path.
+func OpenSQLite(path string) (Store, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("create moderation data dir: %w", err)
+		}
+	}
+	dsn := "file:" + path + "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// A single connection serializes writers, which SQLite does anyway,
+	// and keeps the read-modify-write in AddReport atomic.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create moderation schema: %w", err)
+	}
+	// Databases created before reports carried a reason lack these columns.
+	for _, col := range []string{"category", "detail"} {
+		if err := addColumn(db, "reports", col, "TEXT NOT NULL DEFAULT ''"); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("migrate moderation schema: %w", err)
+		}
+	}
+	// Subscriptions from before encrypted notifications lack a key.
+	if err := addColumn(db, "pr_subscriptions", "notify_key", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate moderation schema: %w", err)
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+// addColumn adds column to table unless it is already there.
+func addColumn(db *sql.DB, table, column, def string) error {
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	_, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, def))
+	return err
+}
+
+func (s *sqliteStore) IsBlocked(ctx context.Context, hash string) (bool, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM blocked WHERE hash = ?`, hash).Scan(&n)
+	return n > 0, err
+}
+
+func (s *sqliteStore) SetBlocked(ctx context.Context, hash string, blocked bool) error {
+	if !blocked {
+		_, err := s.db.ExecContext(ctx, `DELETE FROM blocked WHERE hash = ?`, hash)
+		return err
+	}
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO blocked (hash, blocked_at) VALUES (?, ?) ON CONFLICT (hash) DO NOTHING`,
+		hash, time.Now().UnixNano())
+	return err
+}
+
+func (s *sqliteStore) LastFlagged(ctx context.Context, hash string) (time.Time, bool, error) {
+	var at int64
+	err := s.db.QueryRowContext(ctx, `SELECT at FROM flagged WHERE hash = ?`, hash).Scan(&at)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return time.Unix(0, at), true, nil
+}
+
+func (s *sqliteStore) MarkFlagged(ctx context.Context, hash string, at time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO flagged (hash, at) VALUES (?, ?) ON CONFLICT (hash) DO UPDATE SET at = excluded.at`,
+		hash, at.UnixNano())
+	return err
+}
+
+func (s *sqliteStore) Karma(ctx context.Context, hash string) (int, bool, error) {
+	var k int
+	err := s.db.QueryRowContext(ctx, `SELECT karma FROM karma WHERE hash = ?`, hash).Scan(&k)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return k, err == nil, err
+}
+
+func (s *sqliteStore) SetKarma(ctx context.Context, hash string, karma int) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO karma (hash, karma, updated_at) VALUES (?, ?, ?)
+		 ON CONFLICT (hash) DO UPDATE SET karma = excluded.karma, updated_at = excluded.updated_at`,
+		hash, karma, time.Now().UnixNano())
+	return err
+}
+
+func (s *sqliteStore) AddReport(ctx context.Context, hash string, r Report, window time.Duration) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	cutoff := r.At.Add(-window).UnixNano()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM reports WHERE hash = ? AND at < ?`, hash, cutoff); err != nil {
+		return 0, err
+	}
+	duplicate := false
+	if r.Reporter != "" {
+		var n int
+		if err := tx.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM reports WHERE hash = ? AND reporter = ?`, hash, r.Reporter).Scan(&n); err != nil {
+			return 0, err
+		}
+		duplicate = n > 0
+	}
+	if !duplicate {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO reports (hash, reporter, category, detail, at) VALUES (?, ?, ?, ?, ?)`,
+			hash, r.Reporter, r.Category, r.Detail, r.At.UnixNano()); err != nil {
+			return 0, err
+		}
+	}
+	var count int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM reports WHERE hash = ?`, hash).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, tx.Commit()
+}
+
+func (s *sqliteStore) ReportCount(ctx context.Context, hash string, since time.Time) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM reports WHERE hash = ? AND at >= ?`, hash, since.UnixNano()).Scan(&n)
+	return n, err
+}
+
+func (s *sqliteStore) Reports(ctx context.Context, hash string, since time.Time) ([]Report, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT reporter, category, detail, at FROM reports WHERE hash = ? AND at >= ? ORDER BY at`,
+		hash, since.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Report
+	for rows.Next() {
+		var (
+			r  Report
+			at int64
+		)
+		if err := rows.Scan(&r.Reporter, &r.Category, &r.Detail, &at); err != nil {
+			return nil, err
+		}
+		r.At = time.Unix(0, at)
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (s *sqliteStore) SaveAppeal(ctx context.Context, a Appeal) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO appeals (id, hash, message, created_at, resolved, unbanned) VALUES (?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (id) DO UPDATE SET message = excluded.message, resolved = excluded.resolved, unbanned = excluded.unbanned`,
+		a.ID, a.Hash, a.Message, a.CreatedAt.UnixNano(), a.Resolved, a.Unbanned)
+	return err
+}
+
+func (s *sqliteStore) Appeal(ctx context.Context, id string) (Appeal, bool, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT id, hash, message, created_at, resolved, unbanned FROM appeals WHERE id = ?`, id)
+	a, err := scanAppeal(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Appeal{}, false, nil
+	}
+	return a, err == nil, err
+}
+
+func (s *sqliteStore) Appeals(ctx context.Context, since time.Time) ([]Appeal, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, hash, message, created_at, resolved, unbanned FROM appeals
+		 WHERE created_at >= ? ORDER BY created_at`, since.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Appeal
+	for rows.Next() {
+		a, err := scanAppeal(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAppeal(row rowScanner) (Appeal, error) {
+	var (
+		a       Appeal
+		created int64
+	)
+	if err := row.Scan(&a.ID, &a.Hash, &a.Message, &created, &a.Resolved, &a.Unbanned); err != nil {
+		return Appeal{}, err
+	}
+	a.CreatedAt = time.Unix(0, created)
+	return a, nil
+}
+
+func (s *sqliteStore) SecretKeys(ctx context.Context) ([]SecretKey, error) {
+	keys, err := s.listKeys(ctx)
+	if err != nil || len(keys) > 0 {
+		return keys, err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	// Another process may have created the first key meanwhile.
+	var n int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM secret_keys`).Scan(&n); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		if _, err := insertKey(ctx, tx); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.listKeys(ctx)
+}
+
+func (s *sqliteStore) RotateSecretKey(ctx context.Context, keep int) (SecretKey, error) {
+	if keep < 1 {
+		return SecretKey{}, ErrKeepTooSmall
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return SecretKey{}, err
+	}
+	defer tx.Rollback()
+	k, err := insertKey(ctx, tx)
+	if err != nil {
+		return SecretKey{}, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM secret_keys WHERE id NOT IN (SELECT id FROM secret_keys ORDER BY id DESC LIMIT ?)`, keep); err != nil {
+		return SecretKey{}, err
+	}
+	return k, tx.Commit()
+}
+
+func (s *sqliteStore) TokenKeys(ctx context.Context) ([]SecretKey, error) {
+	return s.listKeysIn(ctx, s.db, "token_keys")
+}
+
+func (s *sqliteStore) AddTokenKey(ctx context.Context, key []byte, staleBefore time.Time, keep int) ([]SecretKey, error) {
+	if keep < 1 {
+		return nil, ErrKeepTooSmall
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	var newest int64
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(created_at), 0) FROM token_keys`).Scan(&newest); err != nil {
+		return nil, err
+	}
+	if newest == 0 || newest <= staleBefore.UnixNano() {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO token_keys (key, created_at) VALUES (?, ?)`, key, time.Now().UnixNano()); err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM token_keys WHERE id NOT IN (SELECT id FROM token_keys ORDER BY id DESC LIMIT ?)`, keep); err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM token_spends WHERE key_id NOT IN (SELECT id FROM token_keys)`); err != nil {
+			return nil, err
+		}
+	}
+	keys, err := s.listKeysIn(ctx, tx, "token_keys")
+	if err != nil {
+		return nil, err
+	}
+	return keys, tx.Commit()
+}
+
+func (s *sqliteStore) SpendToken(ctx context.Context, keyID int64, spendID string) (bool, error) {
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO token_spends (key_id, spend_id) VALUES (?, ?) ON CONFLICT DO NOTHING`, keyID, spendID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func insertKey(ctx context.Context, tx *sql.Tx) (SecretKey, error) {
+	b, err := newKeyBytes()
+	if err != nil {
+		return SecretKey{}, err
+	}
+	now := time.Now()
+	res, err := tx.ExecContext(ctx, `INSERT INTO secret_keys (key, created_at) VALUES (?, ?)`, b, now.UnixNano())
+	if err != nil {
+		return SecretKey{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return SecretKey{}, err
+	}
+	return SecretKey{ID: id, Key: b, CreatedAt: now}, nil
+}
+
+func (s *sqliteStore) listKeys(ctx context.Context) ([]SecretKey, error) {
+	return s.listKeysIn(ctx, s.db, "secret_keys")
+}
+
+// listKeysIn lists the keys in table, which is secret_keys or token_keys.
+func (s *sqliteStore) listKeysIn(ctx context.Context, q interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}, table string) ([]SecretKey, error) {
+	rows, err := q.QueryContext(ctx, `SELECT id, key, created_at FROM `+table+` ORDER BY id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []SecretKey
+	for rows.Next() {
+		var (
+			k       SecretKey
+			created int64
+		)
+		if err := rows.Scan(&k.ID, &k.Key, &created); err != nil {
+			return nil, err
+		}
+		k.CreatedAt = time.Unix(0, created)
+		out = append(out, k)
+	}
+	return out, rows.Err()
+}
+
+func (s *sqliteStore) AppendAudit(ctx context.Context, e AuditEntry) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO audit_log (at, actor, role, action, target, reason, detail) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		e.At.UnixNano(), e.Actor, e.Role, e.Action, e.Target, e.Reason, e.Detail)
+	return err
+}
+
+func (s *sqliteStore) AuditLog(ctx context.Context, q AuditQuery) ([]AuditEntry, error) {
+	query := `SELECT id, at, actor, role, action, target, reason, detail FROM audit_log WHERE 1 = 1`
+	var args []any
+	if !q.Since.IsZero() {
+		query += ` AND at >= ?`
+		args = append(args, q.Since.UnixNano())
+	}
+	if !q.Until.IsZero() {
+		query += ` AND at < ?`
+		args = append(args, q.Until.UnixNano())
+	}
+	if q.Actor != "" {
+		query += ` AND actor = ?`
+		args = append(args, q.Actor)
+	}
+	if q.Action != "" {
+		query += ` AND action = ?`
+		args = append(args, q.Action)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, q.limit())
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AuditEntry
+	for rows.Next() {
+		var (
+			e  AuditEntry
+			at int64
+		)
+		if err := rows.Scan(&e.ID, &at, &e.Actor, &e.Role, &e.Action, &e.Target, &e.Reason, &e.Detail); err != nil {
+			return nil, err
+		}
+		e.At = time.Unix(0, at)
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *sqliteStore) AddPR(ctx context.Context, pr PR) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO prs (url, hash, provider, owner, repo, number, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (url) DO NOTHING`,
+		pr.URL, pr.Hash, pr.Provider, pr.Owner, pr.Repo, pr.Number, pr.CreatedAt.UnixNano())
+	return err
+}
+
+func (s *sqliteStore) PRs(ctx context.Context, q PRQuery) ([]PR, error) {
+	query := `SELECT url, hash, provider, owner, repo, number, created_at, closed_at FROM prs WHERE 1 = 1`
+	var args []any
+	if q.Provider != "" {
+		query += ` AND provider = ?`
+		args = append(args, q.Provider)
+	}
+	if q.Owner != "" {
+		query += ` AND owner = ? COLLATE NOCASE`
+		args = append(args, q.Owner)
+	}
+	if q.Repo != "" {
+		query += ` AND repo = ? COLLATE NOCASE`
+		args = append(args, q.Repo)
+	}
+	if q.Number != 0 {
+		query += ` AND number = ?`
+		args = append(args, q.Number)
+	}
+	if q.Hash != "" {
+		query += ` AND hash = ?`
+		args = append(args, q.Hash)
+	}
+	if q.HashPrefix != "" {
+		// A range rather than substr() so prs_hash serves the lookup.
+		query += ` AND hash >= ? AND hash < ?`
+		args = append(args, q.HashPrefix, prefixEnd(q.HashPrefix))
+	}
+	if !q.Since.IsZero() {
+		query += ` AND created_at >= ?`
+		args = append(args, q.Since.UnixNano())
+	}
+	if !q.Until.IsZero() {
+		query += ` AND created_at < ?`
+		args = append(args, q.Until.UnixNano())
+	}
+	if q.OpenOnly {
+		query += ` AND closed_at = 0`
+	}
+	query += ` ORDER BY created_at DESC LIMIT ?`
+	args = append(args, q.limit())
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []PR
+	for rows.Next() {
+		var (
+			pr              PR
+			created, closed int64
+		)
+		if err := rows.Scan(&pr.URL, &pr.Hash, &pr.Provider, &pr.Owner, &pr.Repo, &pr.Number, &created, &closed); err != nil {
+			return nil, err
+		}
+		pr.CreatedAt = time.Unix(0, created)
+		if closed != 0 {
+			pr.ClosedAt = time.Unix(0, closed)
+		}
+		out = append(out, pr)
+	}
+	return out, rows.Err()
+}
+
+// prefixEnd returns the smallest string greater than every string that
+// starts with prefix.
+func prefixEnd(prefix string) string {
+	b := []byte(prefix)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < 0xff {
+			b[i]++
+			return string(b[:i+1])
+		}
+	}
+	// Only 0xff bytes: nothing sorts after the prefix but its extensions.
+	return prefix + "\xff"
+}
+
+func (s *sqliteStore) MarkPRClosed(ctx context.Context, url string, at time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE prs SET closed_at = ? WHERE url = ? AND closed_at = 0`, at.UnixNano(), url)
+	return err
+}
+
+func (s *sqliteStore) ReopenPR(ctx context.Context, url string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE prs SET closed_at = 0 WHERE url = ? AND closed_at > 0`, url)
+	return err
+}
+
+func (s *sqliteStore) PurgePRs(ctx context.Context, closedBefore time.Time) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	cutoff := closedBefore.UnixNano()
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM pr_events WHERE hash IN (SELECT hash FROM prs WHERE closed_at > 0 AND closed_at < ?)`, cutoff); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM pr_subscriptions WHERE hash IN (SELECT hash FROM prs WHERE closed_at > 0 AND closed_at < ?)`, cutoff); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM prs WHERE closed_at > 0 AND closed_at < ?`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), tx.Commit()
+}
+
+func (s *sqliteStore) AddPREvent(ctx context.Context, e PREvent) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO pr_events (hash, type, author, body, created_at) VALUES (?, ?, ?, ?, ?)`,
+		e.Hash, e.Type, e.Author, e.Body, e.CreatedAt.UnixNano())
+	return err
+}
+
+func (s *sqliteStore) PREvents(ctx context.Context, q PREventQuery) ([]PREvent, error) {
+	query := `SELECT id, hash, type, author, body, created_at FROM pr_events WHERE id > ?`
+	args := []any{q.AfterID}
+	if q.Hash != "" {
+		query += ` AND hash = ?`
+		args = append(args, q.Hash)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, q.limit())
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []PREvent
+	for rows.Next() {
+		var (
+			e  PREvent
+			at int64
+		)
+		if err := rows.Scan(&e.ID, &e.Hash, &e.Type, &e.Author, &e.Body, &at); err != nil {
+			return nil, err
+		}
+		e.CreatedAt = time.Unix(0, at)
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func (s *sqliteStore) SetPRSubscription(ctx context.Context, hash string, sub Subscription) (bool, error) {
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO pr_subscriptions (hash, token_hash, notify_key) VALUES (?, ?, ?) ON CONFLICT (hash) DO NOTHING`,
+		hash, sub.TokenHash, sub.NotifyKey)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *sqliteStore) PRSubscription(ctx context.Context, hash string) (Subscription, bool, error) {
+	var sub Subscription
+	err := s.db.QueryRowContext(ctx, `SELECT token_hash, notify_key FROM pr_subscriptions WHERE hash = ?`, hash).Scan(&sub.TokenHash, &sub.NotifyKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Subscription{}, false, nil
+	}
+	return sub, err == nil, err
+}
@@ -0,0 +1,138 @@
+package pow
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxBits caps the difficulty a challenge may ask for, so a tampered or
+// misconfigured challenge can't make clients spin forever.
+const MaxBits = 30
+
+var (
+	ErrMalformed = errors.New("malformed proof of work")
+	ErrForged    = errors.New("proof of work challenge was not issued by this server")
+	ErrExpired   = errors.New("proof of work challenge expired")
+	ErrTooWeak   = errors.New("proof of work does not meet the difficulty")
+)
+
+// Issuer hands out stateless hashcash challenges authenticated with an HMAC
+// key. A challenge reads "1:<bits>:<expiry>:<nonce>:<mac>"; a solution
+// appends ":<counter>" such that SHA-256 of the whole string starts with
+// <bits> zero bits.
+type Issuer struct {
+	Key []byte
+	TTL time.Duration
+}
+
+// Issue returns a challenge of the given difficulty.
+func (is *Issuer) Issue(difficulty int, now time.Time) (string, error) {
+	if difficulty < 0 || difficulty > MaxBits {
+		return "", fmt.Errorf("difficulty %d out of range", difficulty)
+	}
+	nonce := make([]byte, 12)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	body := fmt.Sprintf("1:%d:%d:%s", difficulty, now.Add(is.TTL).Unix(), hex.EncodeToString(nonce))
+	return body + ":" + is.mac(body), nil
+}
+
+// Verify checks a solution and returns the challenge it solves, which
+// callers use to reject replays until it expires. minBits is the
+// difficulty currently required; older, easier challenges are refused.
+func (is *Issuer) Verify(solution string, minBits int, now time.Time) (string, error) {
+	i := strings.LastIndexByte(solution, ':')
+	if i < 0 {
+		return "", ErrMalformed
+	}
+	challenge := solution[:i]
+	c, err := parse(challenge)
+	if err != nil {
+		return "", err
+	}
+	if !hmac.Equal([]byte(c.mac), []byte(is.mac(c.body))) {
+		return "", ErrForged
+	}
+	if now.Unix() > c.expiry {
+		return "", ErrExpired
+	}
+	if c.bits < minBits || leadingZeroBits(solution) < c.bits {
+		return "", ErrTooWeak
+	}
+	return challenge, nil
+}
+
+func (is *Issuer) mac(body string) string {
+	h := hmac.New(sha256.New, is.Key)
+	h.Write([]byte(body))
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+type challenge struct {
+	body   string
+	bits   int
+	expiry int64
+	mac    string
+}
+
+func parse(s string) (challenge, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 5 || parts[0] != "1" {
+		return challenge{}, ErrMalformed
+	}
+	b, err := strconv.Atoi(parts[1])
+	if err != nil || b < 0 || b > MaxBits {
+		return challenge{}, ErrMalformed
+	}
+	exp, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return challenge{}, ErrMalformed
+	}
+	return challenge{body: strings.Join(parts[:4], ":"), bits: b, expiry: exp, mac: parts[4]}, nil
+}
+
+// Bits returns the difficulty of a challenge.
+func Bits(challenge string) (int, error) {
+	c, err := parse(challenge)
+	return c.bits, err
+}
+
+// Solve finds a counter for challenge and returns the full solution.
+func Solve(ctx context.Context, challenge string) (string, error) {
+	c, err := parse(challenge)
+	if err != nil {
+		return "", err
+	}
+	prefix := challenge + ":"
+	for n := uint64(0); ; n++ {
+		if n%(1<<16) == 0 && ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		candidate := prefix + strconv.FormatUint(n, 36)
+		if leadingZeroBits(candidate) >= c.bits {
+			return candidate, nil
+		}
+	}
+}
+
+func leadingZeroBits(s string) int {
+	sum := sha256.Sum256([]byte(s))
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
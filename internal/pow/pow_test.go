@@ -0,0 +1,69 @@
+package pow
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIssueSolveVerify(t *testing.T) {
+	is := &Issuer{Key: []byte("k"), TTL: time.Minute}
+	now := time.Unix(1700000000, 0)
+
+	ch, err := is.Issue(10, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := Bits(ch); b != 10 {
+		t.Fatalf("Bits = %d", b)
+	}
+	sol, err := Solve(context.Background(), ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := is.Verify(sol, 10, now)
+	if err != nil || got != ch {
+		t.Fatalf("Verify = %q, %v", got, err)
+	}
+
+	if _, err := is.Verify(sol, 12, now); !errors.Is(err, ErrTooWeak) {
+		t.Errorf("easier challenge accepted at higher difficulty: %v", err)
+	}
+	if _, err := is.Verify(sol, 10, now.Add(2*time.Minute)); !errors.Is(err, ErrExpired) {
+		t.Errorf("expired challenge accepted: %v", err)
+	}
+	other := &Issuer{Key: []byte("other"), TTL: time.Minute}
+	if _, err := other.Verify(sol, 10, now); !errors.Is(err, ErrForged) {
+		t.Errorf("foreign challenge accepted: %v", err)
+	}
+	raised := strings.Replace(sol, "1:10:", "1:0:", 1)
+	if _, err := is.Verify(raised, 0, now); !errors.Is(err, ErrForged) {
+		t.Errorf("tampered difficulty accepted: %v", err)
+	}
+}
+
+func TestVerifyRejectsUnsolved(t *testing.T) {
+	is := &Issuer{Key: []byte("k"), TTL: time.Minute}
+	now := time.Now()
+	ch, _ := is.Issue(MaxBits, now)
+	if _, err := is.Verify(ch+":0", MaxBits, now); !errors.Is(err, ErrTooWeak) {
+		t.Errorf("unsolved challenge accepted: %v", err)
+	}
+	for _, s := range []string{"", "nonsense", "2:1:1:aa:bb:0"} {
+		if _, err := is.Verify(s, 0, now); !errors.Is(err, ErrMalformed) {
+			t.Errorf("Verify(%q) = %v", s, err)
+		}
+	}
+}
+
+func TestSolveHonorsContext(t *testing.T) {
+	is := &Issuer{Key: []byte("k"), TTL: time.Minute}
+	ch, _ := is.Issue(MaxBits, time.Now())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Solve(ctx, ch); !errors.Is(err, context.Canceled) {
+		t.Errorf("Solve = %v, want context.Canceled", err)
+	}
+}
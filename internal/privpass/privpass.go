@@ -0,0 +1,191 @@
+package privpass
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Tokens are RSA blind signatures over a random nonce, in the style of
+// Privacy Pass: the client blinds hash(nonce), the server signs without
+// seeing it, and the unblinded signature verifies against the nonce. The
+// server can check a token and refuse to accept it twice, but can't tell
+// which issuance it came from.
+
+const (
+	// KeyBits is the modulus size of issuing keys.
+	KeyBits = 2048
+	// NonceSize is the length of a token's random nonce.
+	NonceSize = 32
+
+	tokenPrefix = "v1"
+	hashDomain  = "gitgost privpass v1"
+)
+
+var (
+	ErrMalformed    = errors.New("malformed token")
+	ErrUnknownKey   = errors.New("token signed by an unknown or retired key")
+	ErrBadSignature = errors.New("token signature is invalid")
+)
+
+var b64 = base64.RawURLEncoding
+
+// Token is a redeemable credential.
+type Token struct {
+	KeyID     string
+	Nonce     []byte
+	Signature []byte
+}
+
+// String encodes the token as "v1.<key id>.<nonce>.<signature>".
+func (t Token) String() string {
+	return strings.Join([]string{tokenPrefix, t.KeyID, b64.EncodeToString(t.Nonce), b64.EncodeToString(t.Signature)}, ".")
+}
+
+// ParseToken decodes the output of Token.String.
+func ParseToken(s string) (Token, error) {
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	if len(parts) != 4 || parts[0] != tokenPrefix || parts[1] == "" {
+		return Token{}, ErrMalformed
+	}
+	nonce, err := b64.DecodeString(parts[2])
+	if err != nil || len(nonce) != NonceSize {
+		return Token{}, ErrMalformed
+	}
+	sig, err := b64.DecodeString(parts[3])
+	if err != nil || len(sig) == 0 {
+		return Token{}, ErrMalformed
+	}
+	return Token{KeyID: parts[1], Nonce: nonce, Signature: sig}, nil
+}
+
+// KeyID identifies a public key.
+func KeyID(pub *rsa.PublicKey) string {
+	sum := sha256.Sum256(pub.N.Bytes())
+	return hex.EncodeToString(sum[:8])
+}
+
+// EncodePublicKey returns the modulus and exponent in the form the API
+// publishes them.
+func EncodePublicKey(pub *rsa.PublicKey) (n string, e int) {
+	return b64.EncodeToString(pub.N.Bytes()), pub.E
+}
+
+// DecodePublicKey reverses EncodePublicKey.
+func DecodePublicKey(n string, e int) (*rsa.PublicKey, error) {
+	raw, err := b64.DecodeString(n)
+	if err != nil || len(raw)*8 < KeyBits-8 || e < 3 || e%2 == 0 {
+		return nil, fmt.Errorf("invalid issuer key")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(raw), E: e}, nil
+}
+
+// hashToInt maps a nonce onto Z_N with an MGF1-style expansion (RSA-FDH).
+func hashToInt(pub *rsa.PublicKey, nonce []byte) *big.Int {
+	size := (pub.N.BitLen() + 7) / 8
+	out := make([]byte, 0, size+sha256.Size)
+	var ctr [4]byte
+	for i := uint32(0); len(out) < size; i++ {
+		binary.BigEndian.PutUint32(ctr[:], i)
+		h := sha256.New()
+		h.Write([]byte(hashDomain))
+		h.Write(pub.N.Bytes())
+		h.Write(nonce)
+		h.Write(ctr[:])
+		out = h.Sum(out)
+	}
+	return new(big.Int).Mod(new(big.Int).SetBytes(out[:size]), pub.N)
+}
+
+// Pending is a token the client has blinded but not yet had signed.
+type Pending struct {
+	pub   *rsa.PublicKey
+	nonce []byte
+	rInv  *big.Int
+}
+
+// Blind draws a fresh nonce and returns the blinded message to send to the
+// issuer along with the state needed to finish the token.
+func Blind(pub *rsa.PublicKey) ([]byte, *Pending, error) {
+	nonce := make([]byte, NonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	m := hashToInt(pub, nonce)
+	var r, rInv *big.Int
+	for {
+		var err error
+		r, err = rand.Int(rand.Reader, pub.N)
+		if err != nil {
+			return nil, nil, err
+		}
+		if rInv = new(big.Int).ModInverse(r, pub.N); r.Sign() > 0 && rInv != nil {
+			break
+		}
+	}
+	blinded := new(big.Int).Exp(r, big.NewInt(int64(pub.E)), pub.N)
+	blinded.Mul(blinded, m).Mod(blinded, pub.N)
+	return blinded.FillBytes(make([]byte, modulusSize(pub))), &Pending{pub: pub, nonce: nonce, rInv: rInv}, nil
+}
+
+// Finish unblinds the issuer's signature and checks it, so a misbehaving
+// issuer can't hand out tokens that would later be refused or that carry a
+// per-client tag.
+func (p *Pending) Finish(blindSig []byte) (Token, error) {
+	s := new(big.Int).SetBytes(blindSig)
+	if s.Sign() == 0 || s.Cmp(p.pub.N) >= 0 {
+		return Token{}, ErrBadSignature
+	}
+	s.Mul(s, p.rInv).Mod(s, p.pub.N)
+	t := Token{KeyID: KeyID(p.pub), Nonce: p.nonce, Signature: s.FillBytes(make([]byte, modulusSize(p.pub)))}
+	if err := Verify(p.pub, t); err != nil {
+		return Token{}, err
+	}
+	return t, nil
+}
+
+// Sign signs a blinded message. The issuer learns nothing about the nonce.
+func Sign(priv *rsa.PrivateKey, blinded []byte) ([]byte, error) {
+	m := new(big.Int).SetBytes(blinded)
+	if len(blinded) != modulusSize(&priv.PublicKey) || m.Sign() == 0 || m.Cmp(priv.N) >= 0 {
+		return nil, ErrMalformed
+	}
+	s := new(big.Int).Exp(m, priv.D, priv.N)
+	// Check the result before releasing it; a faulty signature can leak the key.
+	if new(big.Int).Exp(s, big.NewInt(int64(priv.E)), priv.N).Cmp(m) != 0 {
+		return nil, errors.New("blind signature self-check failed")
+	}
+	return s.FillBytes(make([]byte, modulusSize(&priv.PublicKey))), nil
+}
+
+// Verify checks that t carries a valid signature under pub.
+func Verify(pub *rsa.PublicKey, t Token) error {
+	if t.KeyID != KeyID(pub) {
+		return ErrUnknownKey
+	}
+	s := new(big.Int).SetBytes(t.Signature)
+	if len(t.Nonce) != NonceSize || s.Cmp(pub.N) >= 0 {
+		return ErrBadSignature
+	}
+	if new(big.Int).Exp(s, big.NewInt(int64(pub.E)), pub.N).Cmp(hashToInt(pub, t.Nonce)) != 0 {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// SpendID identifies a token for double-spend checks.
+func SpendID(t Token) string {
+	sum := sha256.Sum256(append([]byte(t.KeyID+":"), t.Nonce...))
+	return hex.EncodeToString(sum[:])
+}
+
+func modulusSize(pub *rsa.PublicKey) int {
+	return (pub.N.BitLen() + 7) / 8
+}
@@ -0,0 +1,82 @@
+package privpass
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+)
+
+func testKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv
+}
+
+func TestBlindSignRoundTrip(t *testing.T) {
+	priv := testKey(t)
+	pub := &priv.PublicKey
+
+	blinded, pending, err := Blind(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := Sign(priv, blinded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := pending.Finish(sig)
+	if err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+
+	parsed, err := ParseToken(tok.String())
+	if err != nil {
+		t.Fatalf("ParseToken: %v", err)
+	}
+	if err := Verify(pub, parsed); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if SpendID(parsed) != SpendID(tok) {
+		t.Error("spend id changed across encoding")
+	}
+
+	// The issuer only ever saw the blinded value, which is unrelated to the
+	// signature it later sees redeemed.
+	if string(blinded) == string(tok.Signature) || string(sig) == string(tok.Signature) {
+		t.Error("redeemed signature matches what the issuer saw")
+	}
+
+	parsed.Nonce[0] ^= 1
+	if err := Verify(pub, parsed); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("tampered nonce accepted: %v", err)
+	}
+	if err := Verify(&testKey(t).PublicKey, tok); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("token accepted under another key: %v", err)
+	}
+}
+
+func TestFinishRejectsBadSignature(t *testing.T) {
+	priv := testKey(t)
+	_, pending, err := Blind(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := testKey(t)
+	blinded, _, _ := Blind(&other.PublicKey)
+	sig, _ := Sign(other, blinded)
+	if _, err := pending.Finish(sig); err == nil {
+		t.Error("signature from the wrong key accepted")
+	}
+}
+
+func TestParseTokenRejectsGarbage(t *testing.T) {
+	for _, s := range []string{"", "v1", "v2.a.b.c", "v1..AAAA.AAAA", "v1.k.AAAA.AAAA"} {
+		if _, err := ParseToken(s); !errors.Is(err, ErrMalformed) {
+			t.Errorf("ParseToken(%q) = %v", s, err)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package prmsg
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Text prefixes of the keys maintainers and contributors handle.
+const (
+	maintainerKeyPrefix  = "ed25519:"
+	maintainerSeedPrefix = "ed25519-private:"
+	recipientKeyPrefix   = "x25519:"
+)
+
+// HKDF labels keeping mailbox replies and notifications apart, even when
+// sealed to the same key.
+const (
+	replyInfo        = "gitgost reply "
+	notificationInfo = "gitgost notification "
+)
+
+// SealedNotificationPrefix starts every SealNotification result.
+const SealedNotificationPrefix = "gitgost-e2e1:"
+
+// MaxPlaintext caps a single reply.
+const MaxPlaintext = 8 << 10
+
+var (
+	ErrBadKey       = errors.New("malformed key")
+	ErrBadSignature = errors.New("message is not signed by the maintainer key")
+	ErrDecrypt      = errors.New("message can't be decrypted with this secret")
+)
+
+// Message is an encrypted mailbox message about one PR. Only the holder of
+// the recipient's secret can read it. Maintainer messages may also carry a
+// signature anyone can check against the key the repo publishes in
+// .gitgost.yml.
+type Message struct {
+	Ephemeral  []byte `json:"ephemeral"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+	SentAt     int64  `json:"sent_at"`
+	Signature  []byte `json:"signature,omitempty"`
+}
+
+// ParsePublicKey parses a maintainer key as written in .gitgost.yml.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	raw, err := decodeKey(s, maintainerKeyPrefix, ed25519.PublicKeySize)
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// FormatPublicKey renders a maintainer key for .gitgost.yml.
+func FormatPublicKey(pub ed25519.PublicKey) string {
+	return maintainerKeyPrefix + base64.StdEncoding.EncodeToString(pub)
+}
+
+// GenerateMaintainerKey returns a new key pair as text: the private half
+// for the maintainer's key file and the public half for .gitgost.yml.
+func GenerateMaintainerKey() (private, public string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return maintainerSeedPrefix + base64.StdEncoding.EncodeToString(priv.Seed()), FormatPublicKey(pub), nil
+}
+
+// ParsePrivateKey parses a key file written by GenerateMaintainerKey.
+func ParsePrivateKey(s string) (ed25519.PrivateKey, error) {
+	seed, err := decodeKey(s, maintainerSeedPrefix, ed25519.SeedSize)
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// NewSecret returns a mailbox secret and the public key others encrypt to.
+// Contributors get one per PR at push time and maintainers keep one for their
+// repo; gitGost only ever stores the public key and the AuthToken hash.
+func NewSecret() (secret, public string, err error) {
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	secret = base64.RawURLEncoding.EncodeToString(priv.Bytes())
+	return secret, formatRecipientKey(priv.PublicKey()), nil
+}
+
+// PublicKeyOf returns the public key of a mailbox secret.
+func PublicKeyOf(secret string) (string, error) {
+	priv, err := parseSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return formatRecipientKey(priv.PublicKey()), nil
+}
+
+// AuthToken derives the bearer token that proves possession of secret to
+// gitGost. It reveals nothing about the decryption key.
+func AuthToken(secret string) (string, error) {
+	priv, err := parseSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	tok, err := hkdf.Key(sha256.New, priv.Bytes(), nil, "gitgost mailbox auth", 32)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(tok), nil
+}
+
+// TokenHash is what gets published or stored in place of an AuthToken.
+func TokenHash(token string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	return hex.EncodeToString(sum[:])
+}
+
+// TokenMatches compares token against a TokenHash in constant time.
+func TokenMatches(token, hash string) bool {
+	if token == "" || hash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(TokenHash(token)), []byte(strings.ToLower(hash))) == 1
+}
+
+// ParseRecipientKey parses a public key returned by NewSecret.
+func ParseRecipientKey(s string) (*ecdh.PublicKey, error) {
+	raw, err := decodeKey(s, recipientKeyPrefix, 32)
+	if err != nil {
+		return nil, err
+	}
+	return ecdh.X25519().NewPublicKey(raw)
+}
+
+// Encrypt encrypts plaintext about prHash to recipientKey.
+func Encrypt(recipientKey, prHash string, plaintext []byte, sentAt int64) (*Message, error) {
+	m, err := encrypt(recipientKey, replyInfo+prHash, prHash, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	m.SentAt = sentAt
+	return m, nil
+}
+
+func encrypt(recipientKey, info, prHash string, plaintext []byte) (*Message, error) {
+	if len(plaintext) > MaxPlaintext {
+		return nil, fmt.Errorf("message longer than %d bytes", MaxPlaintext)
+	}
+	recipient, err := ParseRecipientKey(recipientKey)
+	if err != nil {
+		return nil, err
+	}
+	eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := eph.ECDH(recipient)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := messageAEAD(shared, eph.PublicKey().Bytes(), recipient.Bytes(), info)
+	if err != nil {
+		return nil, err
+	}
+	m := &Message{Ephemeral: eph.PublicKey().Bytes(), Nonce: make([]byte, aead.NonceSize())}
+	if _, err := rand.Read(m.Nonce); err != nil {
+		return nil, err
+	}
+	m.Ciphertext = aead.Seal(nil, m.Nonce, plaintext, []byte(prHash))
+	return m, nil
+}
+
+// Seal encrypts plaintext to recipientKey and signs it with the
+// maintainer's key.
+func Seal(signer ed25519.PrivateKey, recipientKey, prHash string, plaintext []byte, sentAt int64) (*Message, error) {
+	m, err := Encrypt(recipientKey, prHash, plaintext, sentAt)
+	if err != nil {
+		return nil, err
+	}
+	m.Signature = ed25519.Sign(signer, signedBytes(prHash, m))
+	return m, nil
+}
+
+// Verify checks that m was signed by pub for prHash.
+func Verify(pub ed25519.PublicKey, prHash string, m *Message) error {
+	if !ed25519.Verify(pub, signedBytes(prHash, m), m.Signature) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// Open decrypts m with the recipient's mailbox secret.
+func Open(secret, prHash string, m *Message) ([]byte, error) {
+	return open(secret, replyInfo+prHash, prHash, m)
+}
+
+func open(secret, info, prHash string, m *Message) ([]byte, error) {
+	priv, err := parseSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	eph, err := ecdh.X25519().NewPublicKey(m.Ephemeral)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	shared, err := priv.ECDH(eph)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	aead, err := messageAEAD(shared, m.Ephemeral, priv.PublicKey().Bytes(), info)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Nonce) != aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plain, err := aead.Open(nil, m.Nonce, m.Ciphertext, []byte(prHash))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plain, nil
+}
+
+// SealNotification encrypts a PR notification to recipientKey. The result is
+// a single line of text, so it can travel wherever the plaintext would have:
+// an ntfy message, an SSE event or a feed entry.
+func SealNotification(recipientKey, prHash string, plaintext []byte) (string, error) {
+	m, err := encrypt(recipientKey, notificationInfo+prHash, prHash, plaintext)
+	if err != nil {
+		return "", err
+	}
+	raw := append(append(append([]byte{}, m.Ephemeral...), m.Nonce...), m.Ciphertext...)
+	return SealedNotificationPrefix + base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// OpenNotification decrypts a SealNotification result with the secret of the
+// recipient key.
+func OpenNotification(secret, prHash, sealed string) ([]byte, error) {
+	enc, ok := strings.CutPrefix(strings.TrimSpace(sealed), SealedNotificationPrefix)
+	if !ok {
+		return nil, ErrDecrypt
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(enc)
+	if err != nil || len(raw) < 32+12 {
+		return nil, ErrDecrypt
+	}
+	return open(secret, notificationInfo+prHash, prHash, &Message{Ephemeral: raw[:32], Nonce: raw[32:44], Ciphertext: raw[44:]})
+}
+
+func parseSecret(secret string) (*ecdh.PrivateKey, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(secret))
+	if err != nil {
+		return nil, ErrBadKey
+	}
+	priv, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return nil, ErrBadKey
+	}
+	return priv, nil
+}
+
+func formatRecipientKey(pub *ecdh.PublicKey) string {
+	return recipientKeyPrefix + base64.StdEncoding.EncodeToString(pub.Bytes())
+}
+
+func messageAEAD(shared, ephemeral, recipient []byte, info string) (cipher.AEAD, error) {
+	salt := append(append([]byte{}, ephemeral...), recipient...)
+	key, err := hkdf.Key(sha256.New, shared, salt, info, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// signedBytes binds the signature to the PR and every field of the message.
+func signedBytes(prHash string, m *Message) []byte {
+	var b []byte
+	b = append(b, "gitgost-reply-v1\x00"...)
+	b = append(b, prHash...)
+	b = append(b, 0)
+	b = binary.BigEndian.AppendUint64(b, uint64(m.SentAt))
+	for _, part := range [][]byte{m.Ephemeral, m.Nonce, m.Ciphertext} {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(part)))
+		b = append(b, part...)
+	}
+	return b
+}
+
+func decodeKey(s, prefix string, size int) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, prefix) {
+		return nil, fmt.Errorf("%w: expected %q prefix", ErrBadKey, prefix)
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, prefix))
+	if err != nil || len(raw) != size {
+		return nil, fmt.Errorf("%w: expected %d base64-encoded bytes", ErrBadKey, size)
+	}
+	return raw, nil
+}
@@ -0,0 +1,124 @@
+package prmsg
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	privText, pubText, err := GenerateMaintainerKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ParsePrivateKey(privText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ParsePublicKey(pubText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, contributorKey, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Seal(signer, contributorKey, "abcd1234", []byte("please email security@"), 1700000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(pub, "abcd1234", m); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	plain, err := Open(secret, "abcd1234", m)
+	if err != nil || string(plain) != "please email security@" {
+		t.Fatalf("Open = %q, %v", plain, err)
+	}
+
+	if err := Verify(pub, "ffff0000", m); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("signature accepted for another PR: %v", err)
+	}
+	if _, err := Open(secret, "ffff0000", m); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("message opened for another PR: %v", err)
+	}
+	other, _, _ := NewSecret()
+	if _, err := Open(other, "abcd1234", m); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("message opened with another secret: %v", err)
+	}
+
+	m.SentAt++
+	if err := Verify(pub, "abcd1234", m); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("tampered timestamp accepted: %v", err)
+	}
+}
+
+func TestParseKeysRejectsGarbage(t *testing.T) {
+	for _, s := range []string{"", "ed25519:", "ed25519:AAAA", "x25519:" + "AAAA", "rsa:abc"} {
+		if _, err := ParsePublicKey(s); !errors.Is(err, ErrBadKey) {
+			t.Errorf("ParsePublicKey(%q) = %v", s, err)
+		}
+	}
+	if _, err := ParseRecipientKey("ed25519:AAAA"); !errors.Is(err, ErrBadKey) {
+		t.Errorf("ParseRecipientKey accepted a maintainer key: %v", err)
+	}
+}
+
+func TestAuthToken(t *testing.T) {
+	secret, public, _ := NewSecret()
+	if pub, err := PublicKeyOf(secret); err != nil || pub != public {
+		t.Fatalf("PublicKeyOf = %q, %v", pub, err)
+	}
+	tok, err := AuthToken(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !TokenMatches(tok, TokenHash(tok)) {
+		t.Error("token doesn't match its own hash")
+	}
+	other, _, _ := NewSecret()
+	otherTok, _ := AuthToken(other)
+	if TokenMatches(otherTok, TokenHash(tok)) || TokenMatches("", TokenHash(tok)) {
+		t.Error("foreign or empty token accepted")
+	}
+
+	m, err := Encrypt(public, "abcd1234", []byte("reply"), 1)
+	if err != nil || m.Signature != nil {
+		t.Fatalf("Encrypt = %+v, %v", m, err)
+	}
+	if plain, err := Open(secret, "abcd1234", m); err != nil || string(plain) != "reply" {
+		t.Errorf("Open = %q, %v", plain, err)
+	}
+}
+
+func TestSealedNotifications(t *testing.T) {
+	secret, key, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := SealNotification(key, "abcd1234", []byte(`{"title":"PR Merged"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sealed, SealedNotificationPrefix) || strings.Contains(sealed, "Merged") {
+		t.Fatalf("sealed = %q", sealed)
+	}
+	plain, err := OpenNotification(secret, "abcd1234", sealed)
+	if err != nil || string(plain) != `{"title":"PR Merged"}` {
+		t.Fatalf("OpenNotification = %q, %v", plain, err)
+	}
+	if _, err := OpenNotification(secret, "ffff0000", sealed); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("notification opened for another PR: %v", err)
+	}
+	if _, err := OpenNotification(secret, "abcd1234", "gitgost-e2e1:AAAA"); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("truncated notification: %v", err)
+	}
+
+	// A mailbox message sealed to the same key is not a notification.
+	m, _ := Encrypt(key, "abcd1234", []byte("hi"), 1)
+	raw := append(append(append([]byte{}, m.Ephemeral...), m.Nonce...), m.Ciphertext...)
+	if _, err := OpenNotification(secret, "abcd1234", SealedNotificationPrefix+base64.RawURLEncoding.EncodeToString(raw)); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("mailbox message opened as a notification: %v", err)
+	}
+}
@@ -2,22 +2,24 @@ package codeberg
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/livrasand/gitGost/internal/provider"
+	"github.com/livrasand/gitGost/internal/tokenpool"
 )
 
-const (
-	apiBase = "https://codeberg.org/api/v1"
-	host    = "codeberg.org"
-)
+const host = "codeberg.org"
+
+// apiBase is the Forgejo REST root; tests point it at a fake server.
+var apiBase = "https://codeberg.org/api/v1"
 
 var httpClient = &http.Client{Timeout: 60 * time.Second}
 
@@ -27,15 +29,33 @@ func New() *CodebergProvider {
 	return &CodebergProvider{}
 }
 
+// codebergToken picks the next Codeberg token from the pool. Callers pick
+// once per operation and pass it down so every request in that operation
+// runs as the same account.
 func codebergToken() string {
-	return os.Getenv("CODEBERG_TOKEN")
+	return tokenpool.Next(tokenpool.Codeberg)
 }
 
-func authHeader(req *http.Request) {
-	t := codebergToken()
+// codebergTokenFor returns the token of the service account that owns
+// forkOwner's forks, falling back to the pool when forkOwner isn't one of ours.
+func codebergTokenFor(forkOwner string) string {
+	if t := tokenpool.TokenForLogin(tokenpool.Codeberg, forkOwner); t != "" {
+		return t
+	}
+	return codebergToken()
+}
+
+// do sends req authenticated with t and reports the response back to the
+// pool so a throttled token cools down.
+func do(req *http.Request, t string) (*http.Response, error) {
 	if t != "" {
 		req.Header.Set("Authorization", "token "+t)
 	}
+	resp, err := httpClient.Do(req)
+	if err == nil {
+		tokenpool.Observe(tokenpool.Codeberg, t, resp)
+	}
+	return resp, err
 }
 
 func repoPath(owner, repo string) string {
@@ -50,6 +70,10 @@ func (p *CodebergProvider) TokenEnvVar() string {
 	return "CODEBERG_TOKEN"
 }
 
+func (p *CodebergProvider) GitToken(ctx context.Context, owner string) string {
+	return codebergTokenFor(owner)
+}
+
 func (p *CodebergProvider) CloneURL(owner, repo string) string {
 	return fmt.Sprintf("https://%s/%s/%s.git", host, owner, repo)
 }
@@ -58,25 +82,27 @@ func (p *CodebergProvider) PushURL(forkOwner, repo string) string {
 	return fmt.Sprintf("https://%s/%s/%s.git", host, forkOwner, repo)
 }
 
-func (p *CodebergProvider) currentUser() (string, error) {
-	if codebergToken() == "" {
+func (p *CodebergProvider) currentUser(ctx context.Context, t string) (string, error) {
+	if t == "" {
 		return "", fmt.Errorf("CODEBERG_TOKEN not set")
 	}
+	if login := tokenpool.Login(tokenpool.Codeberg, t); login != "" {
+		return login, nil
+	}
 
-	req, err := http.NewRequest("GET", apiBase+"/user", nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", apiBase+"/user", nil)
 	if err != nil {
 		return "", err
 	}
-	authHeader(req)
 
-	resp, err := httpClient.Do(req)
+	resp, err := do(req, t)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to get Codeberg user: %s", resp.Status)
+		return "", provider.ErrorFromResponse(resp, "failed to get Codeberg user")
 	}
 
 	var user struct {
@@ -86,30 +112,31 @@ func (p *CodebergProvider) currentUser() (string, error) {
 	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
 		return "", err
 	}
-	if user.Login != "" {
-		return user.Login, nil
+	login := user.Login
+	if login == "" {
+		login = user.UserName
 	}
-	if user.UserName != "" {
-		return user.UserName, nil
+	if login == "" {
+		return "", fmt.Errorf("could not get Codeberg username")
 	}
-	return "", fmt.Errorf("could not get Codeberg username")
+	tokenpool.SetLogin(tokenpool.Codeberg, t, login)
+	return login, nil
 }
 
-func (p *CodebergProvider) getDefaultBranch(owner, repo string) (string, error) {
-	req, err := http.NewRequest("GET", repoPath(owner, repo), nil)
+func (p *CodebergProvider) getDefaultBranch(ctx context.Context, t, owner, repo string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", repoPath(owner, repo), nil)
 	if err != nil {
 		return "", err
 	}
-	authHeader(req)
 
-	resp, err := httpClient.Do(req)
+	resp, err := do(req, t)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to get Codeberg repo info: %s", resp.Status)
+		return "", provider.ErrorFromResponse(resp, "failed to get Codeberg repo info")
 	}
 
 	var r struct {
@@ -124,18 +151,42 @@ func (p *CodebergProvider) getDefaultBranch(owner, repo string) (string, error)
 	return r.DefaultBranch, nil
 }
 
-func (p *CodebergProvider) ForkRepo(owner, repo string) (string, error) {
-	forkOwner, err := p.currentUser()
+func (p *CodebergProvider) ForkRepo(ctx context.Context, owner, repo string) (string, error) {
+	candidates := tokenpool.Candidates(tokenpool.Codeberg, owner, repo)
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("CODEBERG_TOKEN not set")
+	}
+	var lastErr error
+	for _, t := range candidates {
+		forkOwner, err := p.forkAs(ctx, owner, repo, t)
+		if err == nil {
+			tokenpool.Assign(tokenpool.Codeberg, owner, repo, t)
+			return forkOwner, nil
+		}
+		lastErr = err
+		switch {
+		case errors.Is(err, provider.ErrForbidden):
+			tokenpool.MarkBlocked(tokenpool.Codeberg, owner, repo, t)
+		case errors.Is(err, provider.ErrRateLimited):
+		default:
+			return "", err
+		}
+	}
+	return "", lastErr
+}
+
+// forkAs finds or creates the fork of owner/repo under the account of t.
+func (p *CodebergProvider) forkAs(ctx context.Context, owner, repo, t string) (string, error) {
+	forkOwner, err := p.currentUser(ctx, t)
 	if err != nil {
 		return "", err
 	}
 
-	checkReq, err := http.NewRequest("GET", repoPath(forkOwner, repo), nil)
+	checkReq, err := http.NewRequestWithContext(ctx, "GET", repoPath(forkOwner, repo), nil)
 	if err != nil {
 		return "", err
 	}
-	authHeader(checkReq)
-	checkResp, err := httpClient.Do(checkReq)
+	checkResp, err := do(checkReq, t)
 	if err != nil {
 		return "", err
 	}
@@ -144,30 +195,35 @@ func (p *CodebergProvider) ForkRepo(owner, repo string) (string, error) {
 		return forkOwner, nil
 	}
 
-	forkReq, err := http.NewRequest("POST", repoPath(owner, repo)+"/forks", nil)
+	forkReq, err := http.NewRequestWithContext(ctx, "POST", repoPath(owner, repo)+"/forks", nil)
 	if err != nil {
 		return "", err
 	}
-	authHeader(forkReq)
 	forkReq.Header.Set("Content-Type", "application/json")
 
-	forkResp, err := httpClient.Do(forkReq)
+	forkResp, err := do(forkReq, t)
 	if err != nil {
 		return "", err
 	}
 	defer forkResp.Body.Close()
 
 	if forkResp.StatusCode != http.StatusOK && forkResp.StatusCode != http.StatusCreated && forkResp.StatusCode != http.StatusAccepted {
-		return "", fmt.Errorf("failed to fork Codeberg repo: %s", forkResp.Status)
+		return "", provider.ErrorFromResponse(forkResp, "failed to fork Codeberg repo")
 	}
 
 	if forkResp.StatusCode == http.StatusAccepted {
 		deadline := time.Now().Add(30 * time.Second)
+		poll := time.NewTimer(time.Second)
+		defer poll.Stop()
 		for time.Now().Before(deadline) {
-			time.Sleep(1 * time.Second)
-			pollReq, _ := http.NewRequest("GET", repoPath(forkOwner, repo), nil)
-			authHeader(pollReq)
-			pollResp, err := httpClient.Do(pollReq)
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-poll.C:
+			}
+			poll.Reset(time.Second)
+			pollReq, _ := http.NewRequestWithContext(ctx, "GET", repoPath(forkOwner, repo), nil)
+			pollResp, err := do(pollReq, t)
 			if err != nil {
 				continue
 			}
@@ -181,14 +237,19 @@ func (p *CodebergProvider) ForkRepo(owner, repo string) (string, error) {
 	return forkOwner, nil
 }
 
-func (p *CodebergProvider) CreateMR(owner, repo, branch, forkOwner, commitMessage string) (string, error) {
-	if codebergToken() == "" {
+func (p *CodebergProvider) CreateMR(ctx context.Context, owner, repo, branch, forkOwner, commitMessage string, opts provider.MROptions) (string, error) {
+	t := codebergTokenFor(forkOwner)
+	if t == "" {
 		return "", fmt.Errorf("CODEBERG_TOKEN not set")
 	}
 
-	base, err := p.getDefaultBranch(owner, repo)
-	if err != nil {
-		return "", err
+	base := opts.Base
+	if base == "" {
+		var err error
+		base, err = p.getDefaultBranch(ctx, t, owner, repo)
+		if err != nil {
+			return "", err
+		}
 	}
 
 	title := "Anonymous contribution via gitGost"
@@ -202,34 +263,52 @@ func (p *CodebergProvider) CreateMR(owner, repo, branch, forkOwner, commitMessag
 	}
 
 	body += "\n\n---\n\n*This is an anonymous contribution made via [gitGost](https://gitgost.livrasand.com).*\n\n*The original author's identity has been anonymized to protect their privacy. This is a service account that allows real humans to contribute anonymously.*"
+	if opts.Body != "" {
+		body = opts.Body
+	}
+	body = opts.AppendReportLink(body)
+	if opts.Draft {
+		// Forgejo treats a "WIP:" title prefix as a draft.
+		title = "WIP: " + title
+	}
 
+	// Forgejo only resolves owner:branch heads against forks, so a
+	// forkless branch in the target repo is named on its own.
+	head := forkOwner + ":" + branch
+	if strings.EqualFold(forkOwner, owner) {
+		head = branch
+	}
 	payload := map[string]interface{}{
 		"title": title,
-		"head":  forkOwner + ":" + branch,
+		"head":  head,
 		"base":  base,
 		"body":  body,
 	}
+	if len(opts.Labels) > 0 {
+		if ids, err := p.getLabelIDs(ctx, t, owner, repo, opts.Labels); err == nil && len(ids) > 0 {
+			payload["labels"] = ids
+		}
+	}
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
 		return "", err
 	}
 
-	req, err := http.NewRequest("POST", repoPath(owner, repo)+"/pulls", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", repoPath(owner, repo)+"/pulls", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", err
 	}
-	authHeader(req)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := httpClient.Do(req)
+	resp, err := do(req, t)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return "", fmt.Errorf("failed to create Codeberg pull request: %s", resp.Status)
+		return "", provider.ErrorFromResponse(resp, "failed to create Codeberg pull request")
 	}
 
 	var result struct {
@@ -245,21 +324,21 @@ func (p *CodebergProvider) CreateMR(owner, repo, branch, forkOwner, commitMessag
 	return fmt.Sprintf("https://%s/%s/%s/pulls/%d", host, owner, repo, result.Number), nil
 }
 
-func (p *CodebergProvider) GetRefs(owner, repo string) ([]provider.Ref, error) {
-	req, err := http.NewRequest("GET", repoPath(owner, repo)+"/git/refs", nil)
+func (p *CodebergProvider) GetRefs(ctx context.Context, owner, repo string) ([]provider.Ref, error) {
+	t := codebergToken()
+	req, err := http.NewRequestWithContext(ctx, "GET", repoPath(owner, repo)+"/git/refs", nil)
 	if err != nil {
 		return nil, err
 	}
-	authHeader(req)
 
-	resp, err := httpClient.Do(req)
+	resp, err := do(req, t)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get Codeberg refs: %s", resp.Status)
+		return nil, provider.ErrorFromResponse(resp, "failed to get Codeberg refs")
 	}
 
 	var refs []struct {
@@ -279,18 +358,18 @@ func (p *CodebergProvider) GetRefs(owner, repo string) ([]provider.Ref, error) {
 	return out, nil
 }
 
-func (p *CodebergProvider) GetExistingMR(owner, repo, forkOwner, branchName string) (string, bool, error) {
-	if codebergToken() == "" {
+func (p *CodebergProvider) GetExistingMR(ctx context.Context, owner, repo, forkOwner, branchName string) (string, bool, error) {
+	t := codebergTokenFor(forkOwner)
+	if t == "" {
 		return "", false, fmt.Errorf("CODEBERG_TOKEN not set")
 	}
 
 	branchURL := repoPath(forkOwner, repo) + "/branches/" + url.PathEscape(branchName)
-	branchReq, err := http.NewRequest("GET", branchURL, nil)
+	branchReq, err := http.NewRequestWithContext(ctx, "GET", branchURL, nil)
 	if err != nil {
 		return "", false, err
 	}
-	authHeader(branchReq)
-	branchResp, err := httpClient.Do(branchReq)
+	branchResp, err := do(branchReq, t)
 	if err != nil {
 		return "", false, err
 	}
@@ -299,18 +378,17 @@ func (p *CodebergProvider) GetExistingMR(owner, repo, forkOwner, branchName stri
 		return "", false, nil
 	}
 
-	base, err := p.getDefaultBranch(owner, repo)
+	base, err := p.getDefaultBranch(ctx, t, owner, repo)
 	if err != nil {
 		return "", true, err
 	}
 
 	lookupURL := repoPath(owner, repo) + "/pulls/" + url.PathEscape(base) + "/" + url.PathEscape(forkOwner+":"+branchName)
-	lookupReq, err := http.NewRequest("GET", lookupURL, nil)
+	lookupReq, err := http.NewRequestWithContext(ctx, "GET", lookupURL, nil)
 	if err != nil {
 		return "", true, err
 	}
-	authHeader(lookupReq)
-	lookupResp, err := httpClient.Do(lookupReq)
+	lookupResp, err := do(lookupReq, t)
 	if err != nil {
 		return "", true, err
 	}
@@ -331,19 +409,18 @@ func (p *CodebergProvider) GetExistingMR(owner, repo, forkOwner, branchName stri
 	}
 
 	listURL := repoPath(owner, repo) + "/pulls?state=open&limit=100"
-	listReq, err := http.NewRequest("GET", listURL, nil)
+	listReq, err := http.NewRequestWithContext(ctx, "GET", listURL, nil)
 	if err != nil {
 		return "", true, err
 	}
-	authHeader(listReq)
-	listResp, err := httpClient.Do(listReq)
+	listResp, err := do(listReq, t)
 	if err != nil {
 		return "", true, err
 	}
 	defer listResp.Body.Close()
 
 	if listResp.StatusCode != http.StatusOK {
-		return "", true, fmt.Errorf("failed to list Codeberg pull requests: %s", listResp.Status)
+		return "", true, provider.ErrorFromResponse(listResp, "failed to list Codeberg pull requests")
 	}
 
 	var prs []struct {
@@ -366,8 +443,9 @@ func (p *CodebergProvider) GetExistingMR(owner, repo, forkOwner, branchName stri
 	return "", true, nil
 }
 
-func (p *CodebergProvider) CloseMRByURL(mrURL string) error {
-	if codebergToken() == "" {
+func (p *CodebergProvider) CloseMRByURL(ctx context.Context, mrURL string) error {
+	t := codebergToken()
+	if t == "" {
 		return fmt.Errorf("CODEBERG_TOKEN not set")
 	}
 
@@ -381,21 +459,20 @@ func (p *CodebergProvider) CloseMRByURL(mrURL string) error {
 		return err
 	}
 
-	req, err := http.NewRequest("PATCH", repoPath(owner, repo)+"/pulls/"+strconv.Itoa(number), bytes.NewBuffer(payload))
+	req, err := http.NewRequestWithContext(ctx, "PATCH", repoPath(owner, repo)+"/pulls/"+strconv.Itoa(number), bytes.NewBuffer(payload))
 	if err != nil {
 		return err
 	}
-	authHeader(req)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := httpClient.Do(req)
+	resp, err := do(req, t)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("failed to close Codeberg pull request: %s", resp.Status)
+		return provider.ErrorFromResponse(resp, "failed to close Codeberg pull request")
 	}
 	return nil
 }
@@ -424,50 +501,39 @@ func parsePullURL(mrURL string) (owner, repo string, number int, err error) {
 	return parts[0], parts[1], n, nil
 }
 
-func (p *CodebergProvider) GetRepoPolicy(owner, repo string) (*provider.RepoPolicy, error) {
-	req, err := http.NewRequest("GET", repoPath(owner, repo)+"/raw/.gitgost.yml", nil)
-	if err != nil {
-		return &provider.RepoPolicy{}, nil
-	}
-	authHeader(req)
-
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return &provider.RepoPolicy{}, nil
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return &provider.RepoPolicy{}, nil
-	}
-
-	var buf bytes.Buffer
-	_, _ = buf.ReadFrom(resp.Body)
-	content := buf.String()
-
-	if strings.Contains(content, "DENY_ALL: true") {
-		return &provider.RepoPolicy{DenyAll: true}, nil
+// fetchPolicyFile fetches the raw .gitgost.yml of owner/repo, revalidating
+// with etag.
+func fetchPolicyFile(owner, repo string) provider.PolicyFetcher {
+	return func(ctx context.Context, etag string) (provider.PolicyFileResult, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", repoPath(owner, repo)+"/raw/"+provider.PolicyFile, nil)
+		if err != nil {
+			return provider.PolicyFileResult{}, err
+		}
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		resp, err := do(req, codebergToken())
+		if err != nil {
+			return provider.PolicyFileResult{}, err
+		}
+		defer resp.Body.Close()
+		return provider.ReadPolicyResponse(resp)
 	}
-	return &provider.RepoPolicy{}, nil
 }
 
-func (p *CodebergProvider) IsRepoVerified(owner, repo string) bool {
-	req, err := http.NewRequest("GET", repoPath(owner, repo)+"/raw/.gitgost.yml", nil)
-	if err != nil {
-		return false
-	}
-	authHeader(req)
+func (p *CodebergProvider) GetRepoPolicy(ctx context.Context, owner, repo string) (*provider.RepoPolicy, error) {
+	policy, _, err := provider.Policies.Load(ctx, provider.PolicyKey(tokenpool.Codeberg, owner, repo), fetchPolicyFile(owner, repo))
+	return policy, err
+}
 
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return false
-	}
-	defer resp.Body.Close()
-	return resp.StatusCode == http.StatusOK
+func (p *CodebergProvider) IsRepoVerified(ctx context.Context, owner, repo string) bool {
+	_, exists, _ := provider.Policies.Load(ctx, provider.PolicyKey(tokenpool.Codeberg, owner, repo), fetchPolicyFile(owner, repo))
+	return exists
 }
 
-func (p *CodebergProvider) CreateAnonymousIssue(owner, repo, title, body string, labels []string) (string, int, error) {
-	if codebergToken() == "" {
+func (p *CodebergProvider) CreateAnonymousIssue(ctx context.Context, owner, repo, title, body string, labels []string) (string, int, error) {
+	t := codebergToken()
+	if t == "" {
 		return "", 0, fmt.Errorf("CODEBERG_TOKEN not set")
 	}
 
@@ -476,7 +542,7 @@ func (p *CodebergProvider) CreateAnonymousIssue(owner, repo, title, body string,
 		"body":  body,
 	}
 	if len(labels) > 0 {
-		labelIDs, err := p.getLabelIDs(owner, repo, labels)
+		labelIDs, err := p.getLabelIDs(ctx, t, owner, repo, labels)
 		if err != nil {
 			return "", 0, err
 		}
@@ -490,21 +556,20 @@ func (p *CodebergProvider) CreateAnonymousIssue(owner, repo, title, body string,
 		return "", 0, err
 	}
 
-	req, err := http.NewRequest("POST", repoPath(owner, repo)+"/issues", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", repoPath(owner, repo)+"/issues", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", 0, err
 	}
-	authHeader(req)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := httpClient.Do(req)
+	resp, err := do(req, t)
 	if err != nil {
 		return "", 0, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return "", 0, fmt.Errorf("failed to create Codeberg issue: %s", resp.Status)
+		return "", 0, provider.ErrorFromResponse(resp, "failed to create Codeberg issue")
 	}
 
 	var result struct {
@@ -517,21 +582,20 @@ func (p *CodebergProvider) CreateAnonymousIssue(owner, repo, title, body string,
 	return result.HTMLURL, result.Number, nil
 }
 
-func (p *CodebergProvider) getLabelIDs(owner, repo string, labels []string) ([]int64, error) {
-	req, err := http.NewRequest("GET", repoPath(owner, repo)+"/labels?limit=50", nil)
+func (p *CodebergProvider) getLabelIDs(ctx context.Context, t, owner, repo string, labels []string) ([]int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", repoPath(owner, repo)+"/labels?limit=50", nil)
 	if err != nil {
 		return nil, err
 	}
-	authHeader(req)
 
-	resp, err := httpClient.Do(req)
+	resp, err := do(req, t)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get Codeberg issue labels: %s", resp.Status)
+		return nil, provider.ErrorFromResponse(resp, "failed to get Codeberg issue labels")
 	}
 
 	var available []struct {
@@ -558,16 +622,17 @@ func (p *CodebergProvider) getLabelIDs(owner, repo string, labels []string) ([]i
 	return ids, nil
 }
 
-func (p *CodebergProvider) CreateAnonymousComment(owner, repo string, number int, body string) (string, error) {
-	return p.createIssueComment(owner, repo, number, body)
+func (p *CodebergProvider) CreateAnonymousComment(ctx context.Context, owner, repo string, number int, body string) (string, error) {
+	return p.createIssueComment(ctx, owner, repo, number, body)
 }
 
-func (p *CodebergProvider) CreateAnonymousPRComment(owner, repo string, number int, body string) (string, error) {
-	return p.createIssueComment(owner, repo, number, body)
+func (p *CodebergProvider) CreateAnonymousPRComment(ctx context.Context, owner, repo string, number int, body string) (string, error) {
+	return p.createIssueComment(ctx, owner, repo, number, body)
 }
 
-func (p *CodebergProvider) createIssueComment(owner, repo string, number int, body string) (string, error) {
-	if codebergToken() == "" {
+func (p *CodebergProvider) createIssueComment(ctx context.Context, owner, repo string, number int, body string) (string, error) {
+	t := codebergToken()
+	if t == "" {
 		return "", fmt.Errorf("CODEBERG_TOKEN not set")
 	}
 
@@ -577,21 +642,20 @@ func (p *CodebergProvider) createIssueComment(owner, repo string, number int, bo
 		return "", err
 	}
 
-	req, err := http.NewRequest("POST", repoPath(owner, repo)+"/issues/"+strconv.Itoa(number)+"/comments", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", repoPath(owner, repo)+"/issues/"+strconv.Itoa(number)+"/comments", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", err
 	}
-	authHeader(req)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := httpClient.Do(req)
+	resp, err := do(req, t)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return "", fmt.Errorf("failed to create Codeberg comment: %s", resp.Status)
+		return "", provider.ErrorFromResponse(resp, "failed to create Codeberg comment")
 	}
 
 	var result struct {
@@ -607,30 +671,30 @@ func (p *CodebergProvider) createIssueComment(owner, repo string, number int, bo
 	return fmt.Sprintf("https://%s/%s/%s/issues/%d#issuecomment-%d", host, owner, repo, number, result.ID), nil
 }
 
-func (p *CodebergProvider) CreateAnonymousDiscussionComment(owner, repo string, number int, body string) (string, error) {
-	return "", fmt.Errorf("codeberg does not support GitHub-style Discussions")
+func (p *CodebergProvider) CreateAnonymousDiscussionComment(ctx context.Context, owner, repo string, number int, body string) (string, error) {
+	return "", fmt.Errorf("codeberg does not support GitHub-style Discussions: %w", provider.ErrNotFound)
 }
 
-func (p *CodebergProvider) GetMRStatus(owner, repo string, number int) (*provider.MRStatus, error) {
-	if codebergToken() == "" {
+func (p *CodebergProvider) GetMRStatus(ctx context.Context, owner, repo string, number int) (*provider.MRStatus, error) {
+	t := codebergToken()
+	if t == "" {
 		return nil, fmt.Errorf("CODEBERG_TOKEN not set")
 	}
 
 	prURL := repoPath(owner, repo) + "/pulls/" + strconv.Itoa(number)
-	prReq, err := http.NewRequest("GET", prURL, nil)
+	prReq, err := http.NewRequestWithContext(ctx, "GET", prURL, nil)
 	if err != nil {
 		return nil, err
 	}
-	authHeader(prReq)
 
-	prResp, err := httpClient.Do(prReq)
+	prResp, err := do(prReq, t)
 	if err != nil {
 		return nil, err
 	}
 	defer prResp.Body.Close()
 
 	if prResp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get Codeberg pull request: %s", prResp.Status)
+		return nil, provider.ErrorFromResponse(prResp, "failed to get Codeberg pull request")
 	}
 
 	var pr struct {
@@ -645,13 +709,12 @@ func (p *CodebergProvider) GetMRStatus(owner, repo string, number int) (*provide
 	}
 
 	commentsURL := repoPath(owner, repo) + "/issues/" + strconv.Itoa(number) + "/comments"
-	commentsReq, err := http.NewRequest("GET", commentsURL, nil)
+	commentsReq, err := http.NewRequestWithContext(ctx, "GET", commentsURL, nil)
 	if err != nil {
 		return nil, err
 	}
-	authHeader(commentsReq)
 
-	commentsResp, err := httpClient.Do(commentsReq)
+	commentsResp, err := do(commentsReq, t)
 	if err != nil {
 		return &provider.MRStatus{
 			State: pr.State, Title: pr.Title, Number: number,
@@ -0,0 +1,79 @@
+package codeberg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/livrasand/gitGost/internal/provider"
+)
+
+func TestCreateMRHead(t *testing.T) {
+	var head string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.EscapedPath() != "/repos/acme/widgets/pulls" {
+			http.NotFound(w, r)
+			return
+		}
+		var payload struct {
+			Head string `json:"head"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		head = payload.Head
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"html_url":"https://codeberg.org/acme/widgets/pulls/1","number":1}`))
+	}))
+	defer srv.Close()
+
+	oldBase := apiBase
+	apiBase = srv.URL
+	defer func() { apiBase = oldBase }()
+	t.Setenv("CODEBERG_TOKEN", "tok")
+
+	for _, tc := range []struct{ forkOwner, want string }{
+		{"ghost", "ghost:gitgost-1"},
+		{"acme", "gitgost-1"},
+	} {
+		if _, err := New().CreateMR(context.Background(), "acme", "widgets", "gitgost-1", tc.forkOwner, "Fix", provider.MROptions{Base: "main"}); err != nil {
+			t.Fatalf("CreateMR from %s: %v", tc.forkOwner, err)
+		}
+		if head != tc.want {
+			t.Errorf("head from %s = %q, want %q", tc.forkOwner, head, tc.want)
+		}
+	}
+}
+
+func TestForkRepoStopsPollingWhenCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/user":
+			w.Write([]byte(`{"login":"ghost"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/acme/widgets/forks":
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			// The fork never becomes ready.
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	oldBase := apiBase
+	apiBase = srv.URL
+	defer func() { apiBase = oldBase }()
+	t.Setenv("CODEBERG_TOKEN", "tok")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := New().ForkRepo(ctx, "acme", "widgets")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ForkRepo = %v, want the context's error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("ForkRepo kept polling for %s after its context ended", elapsed)
+	}
+}
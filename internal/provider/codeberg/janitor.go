@@ -0,0 +1,182 @@
+package codeberg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/livrasand/gitGost/internal/provider"
+	"github.com/livrasand/gitGost/internal/tokenpool"
+)
+
+// pageSize is Forgejo's default maximum for list endpoints.
+const pageSize = 50
+
+func (p *CodebergProvider) ServiceAccounts(ctx context.Context) []provider.Account {
+	var accounts []provider.Account
+	for _, t := range tokenpool.Tokens(tokenpool.Codeberg) {
+		login, err := p.currentUser(ctx, t)
+		if err != nil {
+			continue
+		}
+		accounts = append(accounts, provider.Account{Token: t, Owner: login})
+	}
+	return accounts
+}
+
+func getJSON(ctx context.Context, t, u, op string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := do(req, t)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return provider.ErrorFromResponse(resp, op)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (p *CodebergProvider) ListForks(ctx context.Context, acct provider.Account) ([]provider.Fork, error) {
+	var forks []provider.Fork
+	for page := 1; ; page++ {
+		var repos []struct {
+			Name      string    `json:"name"`
+			Fork      bool      `json:"fork"`
+			UpdatedAt time.Time `json:"updated_at"`
+			Owner     struct {
+				Login string `json:"login"`
+			} `json:"owner"`
+			Parent *struct {
+				FullName string `json:"full_name"`
+			} `json:"parent"`
+		}
+		u := fmt.Sprintf("%s/user/repos?limit=%d&page=%d", apiBase, pageSize, page)
+		if err := getJSON(ctx, acct.Token, u, "failed to list Codeberg repos", &repos); err != nil {
+			return nil, err
+		}
+		for _, r := range repos {
+			if !r.Fork || r.Parent == nil || !strings.EqualFold(r.Owner.Login, acct.Owner) {
+				continue
+			}
+			parentOwner, parentRepo, ok := strings.Cut(r.Parent.FullName, "/")
+			if !ok {
+				continue
+			}
+			forks = append(forks, provider.Fork{
+				Owner:       acct.Owner,
+				Repo:        r.Name,
+				ParentOwner: parentOwner,
+				ParentRepo:  parentRepo,
+				PushedAt:    r.UpdatedAt,
+			})
+		}
+		if len(repos) < pageSize {
+			return forks, nil
+		}
+	}
+}
+
+func (p *CodebergProvider) ListBranches(ctx context.Context, acct provider.Account, owner, repo string) ([]string, error) {
+	var names []string
+	for page := 1; ; page++ {
+		var branches []struct {
+			Name string `json:"name"`
+		}
+		u := fmt.Sprintf("%s/branches?limit=%d&page=%d", repoPath(owner, repo), pageSize, page)
+		if err := getJSON(ctx, acct.Token, u, "failed to list Codeberg branches", &branches); err != nil {
+			return nil, err
+		}
+		for _, b := range branches {
+			names = append(names, b.Name)
+		}
+		if len(branches) < pageSize {
+			return names, nil
+		}
+	}
+}
+
+// maxPRPages bounds how far back BranchPR looks; gitGost PRs older than a
+// few hundred PRs are past any grace period anyway.
+const maxPRPages = 5
+
+func (p *CodebergProvider) BranchPR(ctx context.Context, acct provider.Account, owner, repo, forkOwner, branch string) (*provider.BranchPR, error) {
+	// Forgejo can't filter pulls by head, so scan the newest ones.
+	for page := 1; page <= maxPRPages; page++ {
+		var pulls []struct {
+			Number   int        `json:"number"`
+			State    string     `json:"state"`
+			Merged   bool       `json:"merged"`
+			MergedAt *time.Time `json:"merged_at"`
+			ClosedAt *time.Time `json:"closed_at"`
+			Head     struct {
+				Ref  string `json:"ref"`
+				Repo *struct {
+					Owner struct {
+						Login string `json:"login"`
+					} `json:"owner"`
+				} `json:"repo"`
+			} `json:"head"`
+		}
+		u := fmt.Sprintf("%s/pulls?state=all&sort=recentupdate&limit=%d&page=%d", repoPath(owner, repo), pageSize, page)
+		if err := getJSON(ctx, acct.Token, u, "failed to list Codeberg PRs", &pulls); err != nil {
+			return nil, err
+		}
+		for _, pr := range pulls {
+			if pr.Head.Ref != branch || pr.Head.Repo == nil || !strings.EqualFold(pr.Head.Repo.Owner.Login, forkOwner) {
+				continue
+			}
+			out := &provider.BranchPR{Number: pr.Number, State: provider.PRStateOpen}
+			switch {
+			case pr.Merged:
+				out.State = provider.PRStateMerged
+				if pr.MergedAt != nil {
+					out.ClosedAt = *pr.MergedAt
+				}
+			case pr.State == "closed":
+				out.State = provider.PRStateClosed
+				if pr.ClosedAt != nil {
+					out.ClosedAt = *pr.ClosedAt
+				}
+			}
+			return out, nil
+		}
+		if len(pulls) < pageSize {
+			break
+		}
+	}
+	return nil, nil
+}
+
+func deleteRequest(ctx context.Context, t, u, op string) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := do(req, t)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return provider.ErrorFromResponse(resp, op)
+	}
+	return nil
+}
+
+func (p *CodebergProvider) DeleteBranch(ctx context.Context, acct provider.Account, owner, repo, branch string) error {
+	return deleteRequest(ctx, acct.Token, repoPath(owner, repo)+"/branches/"+url.PathEscape(branch), "failed to delete Codeberg branch")
+}
+
+func (p *CodebergProvider) DeleteRepo(ctx context.Context, acct provider.Account, owner, repo string) error {
+	return deleteRequest(ctx, acct.Token, repoPath(owner, repo), "failed to delete Codeberg repo")
+}
@@ -0,0 +1,118 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sentinel errors returned (wrapped) by every Provider implementation so
+// callers can branch with errors.Is instead of parsing status strings.
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrForbidden   = errors.New("forbidden")
+	ErrConflict    = errors.New("conflict")
+	ErrRateLimited = errors.New("rate limited")
+)
+
+// RateLimitError reports that the forge throttled the request. Reset is the
+// moment the forge said the quota comes back; it is zero when unknown.
+type RateLimitError struct {
+	Op    string
+	Reset time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Reset.IsZero() {
+		return fmt.Sprintf("%s: rate limited", e.Op)
+	}
+	return fmt.Sprintf("%s: rate limited until %s", e.Op, e.Reset.UTC().Format(time.RFC3339))
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// StatusError is a non-2xx forge response. Err holds the matching sentinel,
+// if any, so errors.Is(err, ErrNotFound) works through it.
+type StatusError struct {
+	Op         string
+	StatusCode int
+	Status     string
+	Err        error
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Op, e.Status)
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorFromResponse classifies a failed forge response into the typed errors
+// above. op is the human prefix used in the error message.
+func ErrorFromResponse(resp *http.Response, op string) error {
+	if reset, limited := rateLimitReset(resp); limited {
+		return &RateLimitError{Op: op, Reset: reset}
+	}
+	se := &StatusError{Op: op, StatusCode: resp.StatusCode, Status: resp.Status}
+	switch resp.StatusCode {
+	case http.StatusNotFound, http.StatusGone:
+		se.Err = ErrNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		se.Err = ErrForbidden
+	case http.StatusConflict, http.StatusUnprocessableEntity:
+		se.Err = ErrConflict
+	}
+	return se
+}
+
+// rateLimitReset reports whether resp is a throttling response and, if the
+// forge told us, when the quota resets. GitHub signals primary limits with a
+// 403 and X-RateLimit-Remaining: 0, GitLab and Gitea use 429.
+func rateLimitReset(resp *http.Response) (time.Time, bool) {
+	limited := resp.StatusCode == http.StatusTooManyRequests
+	if resp.StatusCode == http.StatusForbidden {
+		limited = resp.Header.Get("X-RateLimit-Remaining") == "0" || resp.Header.Get("Retry-After") != ""
+	}
+	if !limited {
+		return time.Time{}, false
+	}
+	if ra := strings.TrimSpace(resp.Header.Get("Retry-After")); ra != "" {
+		if secs, err := strconv.Atoi(ra); err == nil {
+			return time.Now().Add(time.Duration(secs) * time.Second), true
+		}
+		if t, err := http.ParseTime(ra); err == nil {
+			return t, true
+		}
+	}
+	for _, h := range []string{"X-RateLimit-Reset", "RateLimit-Reset"} {
+		if v := strings.TrimSpace(resp.Header.Get(h)); v != "" {
+			if unix, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return time.Unix(unix, 0), true
+			}
+		}
+	}
+	return time.Time{}, true
+}
+
+// RetryAfter returns how long a caller should wait before retrying err, and
+// whether err was a rate-limit error at all.
+func RetryAfter(err error) (time.Duration, bool) {
+	var rl *RateLimitError
+	if !errors.As(err, &rl) {
+		return 0, false
+	}
+	if rl.Reset.IsZero() {
+		return time.Minute, true
+	}
+	d := time.Until(rl.Reset)
+	if d < time.Second {
+		d = time.Second
+	}
+	return d, true
+}
@@ -0,0 +1,61 @@
+package provider
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newResp(code int, headers map[string]string) *http.Response {
+	resp := &http.Response{StatusCode: code, Status: strconv.Itoa(code) + " " + http.StatusText(code), Header: http.Header{}}
+	for k, v := range headers {
+		resp.Header.Set(k, v)
+	}
+	return resp
+}
+
+func TestErrorFromResponse(t *testing.T) {
+	reset := time.Now().Add(10 * time.Minute).Unix()
+	tests := []struct {
+		name    string
+		resp    *http.Response
+		want    error
+		limited bool
+	}{
+		{"not found", newResp(404, nil), ErrNotFound, false},
+		{"forbidden", newResp(403, nil), ErrForbidden, false},
+		{"conflict", newResp(409, nil), ErrConflict, false},
+		{"github primary limit", newResp(403, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": strconv.FormatInt(reset, 10)}), ErrRateLimited, true},
+		{"gitlab 429", newResp(429, map[string]string{"RateLimit-Reset": strconv.FormatInt(reset, 10)}), ErrRateLimited, true},
+		{"retry-after", newResp(429, map[string]string{"Retry-After": "30"}), ErrRateLimited, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ErrorFromResponse(tt.resp, "op")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("errors.Is(%v, %v) = false", err, tt.want)
+			}
+			wait, ok := RetryAfter(err)
+			if ok != tt.limited {
+				t.Fatalf("RetryAfter ok = %v, want %v", ok, tt.limited)
+			}
+			if ok && (wait <= 0 || wait > 11*time.Minute) {
+				t.Errorf("unexpected wait %v", wait)
+			}
+		})
+	}
+}
+
+func TestErrorFromResponse_Untyped(t *testing.T) {
+	err := ErrorFromResponse(newResp(500, nil), "failed to create fork")
+	if err.Error() != "failed to create fork: 500 Internal Server Error" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+	for _, sentinel := range []error{ErrNotFound, ErrForbidden, ErrConflict, ErrRateLimited} {
+		if errors.Is(err, sentinel) {
+			t.Errorf("500 should not match %v", sentinel)
+		}
+	}
+}
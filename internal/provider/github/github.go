@@ -1,6 +1,8 @@
 package github
 
 import (
+	"context"
+
 	"github.com/livrasand/gitGost/internal/github"
 	"github.com/livrasand/gitGost/internal/provider"
 )
@@ -11,16 +13,16 @@ func New() *GitHubProvider {
 	return &GitHubProvider{}
 }
 
-func (p *GitHubProvider) ForkRepo(owner, repo string) (string, error) {
-	return github.ForkRepo(owner, repo)
+func (p *GitHubProvider) ForkRepo(ctx context.Context, owner, repo string) (string, error) {
+	return github.ForkRepo(ctx, owner, repo)
 }
 
-func (p *GitHubProvider) CreateMR(owner, repo, branch, forkOwner, commitMessage string) (string, error) {
-	return github.CreatePR(owner, repo, branch, forkOwner, commitMessage)
+func (p *GitHubProvider) CreateMR(ctx context.Context, owner, repo, branch, forkOwner, commitMessage string, opts provider.MROptions) (string, error) {
+	return github.CreatePR(ctx, owner, repo, branch, forkOwner, commitMessage, opts)
 }
 
-func (p *GitHubProvider) GetRefs(owner, repo string) ([]provider.Ref, error) {
-	ghRefs, err := github.GetRefs(owner, repo)
+func (p *GitHubProvider) GetRefs(ctx context.Context, owner, repo string) ([]provider.Ref, error) {
+	ghRefs, err := github.GetRefs(ctx, owner, repo)
 	if err != nil {
 		return nil, err
 	}
@@ -31,27 +33,20 @@ func (p *GitHubProvider) GetRefs(owner, repo string) ([]provider.Ref, error) {
 	return refs, nil
 }
 
-func (p *GitHubProvider) GetExistingMR(owner, repo, forkOwner, branchName string) (string, bool, error) {
-	return github.GetExistingPR(owner, repo, forkOwner, branchName)
+func (p *GitHubProvider) GetExistingMR(ctx context.Context, owner, repo, forkOwner, branchName string) (string, bool, error) {
+	return github.GetExistingPR(ctx, owner, repo, forkOwner, branchName)
 }
 
-func (p *GitHubProvider) CloseMRByURL(mrURL string) error {
-	return github.ClosePRByURL(mrURL)
+func (p *GitHubProvider) CloseMRByURL(ctx context.Context, mrURL string) error {
+	return github.ClosePRByURL(ctx, mrURL)
 }
 
-func (p *GitHubProvider) GetRepoPolicy(owner, repo string) (*provider.RepoPolicy, error) {
-	ghPolicy, err := github.GetRepoPolicy(owner, repo)
-	if err != nil {
-		return nil, err
-	}
-	if ghPolicy == nil {
-		return &provider.RepoPolicy{}, nil
-	}
-	return &provider.RepoPolicy{DenyAll: ghPolicy.DenyAll}, nil
+func (p *GitHubProvider) GetRepoPolicy(ctx context.Context, owner, repo string) (*provider.RepoPolicy, error) {
+	return github.GetRepoPolicy(ctx, owner, repo)
 }
 
-func (p *GitHubProvider) IsRepoVerified(owner, repo string) bool {
-	return github.IsRepoVerified(owner, repo)
+func (p *GitHubProvider) IsRepoVerified(ctx context.Context, owner, repo string) bool {
+	return github.IsRepoVerified(ctx, owner, repo)
 }
 
 func (p *GitHubProvider) CloneURL(owner, repo string) string {
@@ -66,33 +61,39 @@ func (p *GitHubProvider) TokenEnvVar() string {
 	return "GITHUB_TOKEN"
 }
 
+// GitToken prefers the GitHub App installation on owner and falls back to
+// the PAT pool.
+func (p *GitHubProvider) GitToken(ctx context.Context, owner string) string {
+	return github.GitToken(ctx, owner)
+}
+
 func (p *GitHubProvider) Name() string {
 	return "GitHub"
 }
 
-func (p *GitHubProvider) CreateAnonymousIssue(owner, repo, title, body string, labels []string) (string, int, error) {
-	return github.CreateAnonymousIssue(owner, repo, title, body, labels)
+func (p *GitHubProvider) CreateAnonymousIssue(ctx context.Context, owner, repo, title, body string, labels []string) (string, int, error) {
+	return github.CreateAnonymousIssue(ctx, owner, repo, title, body, labels)
 }
 
-func (p *GitHubProvider) CreateAnonymousComment(owner, repo string, number int, body string) (string, error) {
-	return github.CreateAnonymousComment(owner, repo, number, body)
+func (p *GitHubProvider) CreateAnonymousComment(ctx context.Context, owner, repo string, number int, body string) (string, error) {
+	return github.CreateAnonymousComment(ctx, owner, repo, number, body)
 }
 
-func (p *GitHubProvider) CreateAnonymousPRComment(owner, repo string, number int, body string) (string, error) {
-	return github.CreateAnonymousPRComment(owner, repo, number, body)
+func (p *GitHubProvider) CreateAnonymousPRComment(ctx context.Context, owner, repo string, number int, body string) (string, error) {
+	return github.CreateAnonymousPRComment(ctx, owner, repo, number, body)
 }
 
-func (p *GitHubProvider) CreateAnonymousDiscussionComment(owner, repo string, number int, body string) (string, error) {
-	return github.CreateAnonymousDiscussionComment(owner, repo, number, body)
+func (p *GitHubProvider) CreateAnonymousDiscussionComment(ctx context.Context, owner, repo string, number int, body string) (string, error) {
+	return github.CreateAnonymousDiscussionComment(ctx, owner, repo, number, body)
 }
 
-func (p *GitHubProvider) GetMRStatus(owner, repo string, number int) (*provider.MRStatus, error) {
-	state, title, comments, updatedAt, err := github.FetchPRInfo(owner, repo, number)
+func (p *GitHubProvider) GetMRStatus(ctx context.Context, owner, repo string, number int) (*provider.MRStatus, error) {
+	state, title, comments, updatedAt, err := github.FetchPRInfo(ctx, owner, repo, number)
 	if err != nil {
 		return nil, err
 	}
 
-	events, _, _, err := github.FetchPRTimeline(owner, repo, number, "")
+	events, _, _, err := github.FetchPRTimeline(ctx, owner, repo, number, "")
 	if err != nil {
 		return &provider.MRStatus{
 			State: state, Title: title, Number: number,
@@ -116,3 +117,27 @@ func (p *GitHubProvider) GetMRStatus(owner, repo string, number int) (*provider.
 		Comments: comments, UpdatedAt: updatedAt, Events: providerEvents,
 	}, nil
 }
+
+func (p *GitHubProvider) ServiceAccounts(ctx context.Context) []provider.Account {
+	return github.ServiceAccounts(ctx)
+}
+
+func (p *GitHubProvider) ListForks(ctx context.Context, acct provider.Account) ([]provider.Fork, error) {
+	return github.ListForks(ctx, acct)
+}
+
+func (p *GitHubProvider) ListBranches(ctx context.Context, acct provider.Account, owner, repo string) ([]string, error) {
+	return github.ListBranches(ctx, acct, owner, repo)
+}
+
+func (p *GitHubProvider) BranchPR(ctx context.Context, acct provider.Account, owner, repo, forkOwner, branch string) (*provider.BranchPR, error) {
+	return github.BranchPR(ctx, acct, owner, repo, forkOwner, branch)
+}
+
+func (p *GitHubProvider) DeleteBranch(ctx context.Context, acct provider.Account, owner, repo, branch string) error {
+	return github.DeleteBranch(ctx, acct, owner, repo, branch)
+}
+
+func (p *GitHubProvider) DeleteRepo(ctx context.Context, acct provider.Account, owner, repo string) error {
+	return github.DeleteRepo(ctx, acct, owner, repo)
+}
@@ -2,20 +2,25 @@ package gitlab
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/livrasand/gitGost/internal/provider"
+	"github.com/livrasand/gitGost/internal/tokenpool"
 )
 
 var httpClient = &http.Client{Timeout: 60 * time.Second}
 
+// apiBase is the GitLab REST root; tests point it at a fake server.
+var apiBase = "https://gitlab.com/api/v4"
+
 func ExtractMRIID(mrURL string) int {
 	trimmed := strings.TrimPrefix(mrURL, "https://gitlab.com/")
 	parts := strings.Split(trimmed, "/-/merge_requests/")
@@ -43,6 +48,10 @@ func (p *GitLabProvider) TokenEnvVar() string {
 	return "GITLAB_TOKEN"
 }
 
+func (p *GitLabProvider) GitToken(ctx context.Context, owner string) string {
+	return tokenFor(owner)
+}
+
 func (p *GitLabProvider) CloneURL(owner, repo string) string {
 	return "https://gitlab.com/" + owner + "/" + repo + ".git"
 }
@@ -55,34 +64,79 @@ func projectID(owner, repo string) string {
 	return url.PathEscape(owner + "/" + repo)
 }
 
+// token picks the next GitLab token from the pool. Each provider call picks
+// once and reuses it for all its requests so a multi-step operation (fork,
+// then check, then MR) runs as a single account.
 func token() string {
-	return os.Getenv("GITLAB_TOKEN")
+	return tokenpool.Next(tokenpool.GitLab)
 }
 
-func authHeader(req *http.Request) {
-	t := token()
+// tokenFor returns the token of the service account that owns forkOwner's
+// forks, falling back to the pool when forkOwner isn't one of ours.
+func tokenFor(forkOwner string) string {
+	if t := tokenpool.TokenForLogin(tokenpool.GitLab, forkOwner); t != "" {
+		return t
+	}
+	return token()
+}
+
+// do sends req authenticated with t and reports the rate-limit headers back
+// to the pool so a throttled token cools down.
+func do(req *http.Request, t string) (*http.Response, error) {
 	if t != "" {
 		req.Header.Set("PRIVATE-TOKEN", t)
 	}
+	resp, err := httpClient.Do(req)
+	if err == nil {
+		tokenpool.Observe(tokenpool.GitLab, t, resp)
+	}
+	return resp, err
 }
 
-func (p *GitLabProvider) ForkRepo(owner, repo string) (string, error) {
-	t := token()
-	if t == "" {
+func (p *GitLabProvider) ForkRepo(ctx context.Context, owner, repo string) (string, error) {
+	candidates := tokenpool.Candidates(tokenpool.GitLab, owner, repo)
+	if len(candidates) == 0 {
 		return "", fmt.Errorf("GITLAB_TOKEN not set")
 	}
+	var lastErr error
+	for _, t := range candidates {
+		forkOwner, err := forkAs(ctx, owner, repo, t)
+		if err == nil {
+			tokenpool.Assign(tokenpool.GitLab, owner, repo, t)
+			return forkOwner, nil
+		}
+		lastErr = err
+		switch {
+		case errors.Is(err, provider.ErrForbidden):
+			tokenpool.MarkBlocked(tokenpool.GitLab, owner, repo, t)
+		case errors.Is(err, provider.ErrRateLimited):
+		default:
+			return "", err
+		}
+	}
+	return "", lastErr
+}
 
-	userReq, err := http.NewRequest("GET", "https://gitlab.com/api/v4/user", nil)
+// username returns the login t authenticates as, cached in the pool.
+func username(ctx context.Context, t string) (string, error) {
+	if login := tokenpool.Login(tokenpool.GitLab, t); login != "" {
+		return login, nil
+	}
+
+	userReq, err := http.NewRequestWithContext(ctx, "GET", apiBase+"/user", nil)
 	if err != nil {
 		return "", err
 	}
-	authHeader(userReq)
-	userResp, err := httpClient.Do(userReq)
+	userResp, err := do(userReq, t)
 	if err != nil {
 		return "", err
 	}
 	defer userResp.Body.Close()
 
+	if userResp.StatusCode != http.StatusOK {
+		return "", provider.ErrorFromResponse(userResp, "failed to get GitLab user")
+	}
+
 	var user struct {
 		Username string `json:"username"`
 	}
@@ -92,16 +146,23 @@ func (p *GitLabProvider) ForkRepo(owner, repo string) (string, error) {
 	if user.Username == "" {
 		return "", fmt.Errorf("could not get GitLab username")
 	}
+	tokenpool.SetLogin(tokenpool.GitLab, t, user.Username)
+	return user.Username, nil
+}
 
-	forkOwner := user.Username
+// forkAs finds or creates the fork of owner/repo under the account of t.
+func forkAs(ctx context.Context, owner, repo, t string) (string, error) {
+	forkOwner, err := username(ctx, t)
+	if err != nil {
+		return "", err
+	}
 
-	checkURL := fmt.Sprintf("https://gitlab.com/api/v4/projects/%s", projectID(forkOwner, repo))
-	checkReq, err := http.NewRequest("GET", checkURL, nil)
+	checkURL := fmt.Sprintf(apiBase+"/projects/%s", projectID(forkOwner, repo))
+	checkReq, err := http.NewRequestWithContext(ctx, "GET", checkURL, nil)
 	if err != nil {
 		return "", err
 	}
-	authHeader(checkReq)
-	checkResp, err := httpClient.Do(checkReq)
+	checkResp, err := do(checkReq, t)
 	if err != nil {
 		return "", err
 	}
@@ -110,59 +171,78 @@ func (p *GitLabProvider) ForkRepo(owner, repo string) (string, error) {
 		return forkOwner, nil
 	}
 
-	forkURL := fmt.Sprintf("https://gitlab.com/api/v4/projects/%s/fork", projectID(owner, repo))
-	forkReq, err := http.NewRequest("POST", forkURL, nil)
+	forkURL := fmt.Sprintf(apiBase+"/projects/%s/fork", projectID(owner, repo))
+	forkReq, err := http.NewRequestWithContext(ctx, "POST", forkURL, nil)
 	if err != nil {
 		return "", err
 	}
-	authHeader(forkReq)
 	forkReq.Header.Set("Content-Type", "application/json")
 
-	forkResp, err := httpClient.Do(forkReq)
+	forkResp, err := do(forkReq, t)
 	if err != nil {
 		return "", err
 	}
 	defer forkResp.Body.Close()
 
 	if forkResp.StatusCode != http.StatusCreated && forkResp.StatusCode != http.StatusAccepted {
-		return "", fmt.Errorf("failed to fork GitLab project: %s", forkResp.Status)
+		return "", provider.ErrorFromResponse(forkResp, "failed to fork GitLab project")
 	}
 
 	return forkOwner, nil
 }
 
-func (p *GitLabProvider) CreateMR(owner, repo, branch, forkOwner, commitMessage string) (string, error) {
-	t := token()
+func (p *GitLabProvider) CreateMR(ctx context.Context, owner, repo, branch, forkOwner, commitMessage string, opts provider.MROptions) (string, error) {
+	t := tokenFor(forkOwner)
 	if t == "" {
 		return "", fmt.Errorf("GITLAB_TOKEN not set")
 	}
 
-	apiURL := fmt.Sprintf("https://gitlab.com/api/v4/projects/%s/merge_requests", projectID(owner, repo))
+	apiURL := fmt.Sprintf(apiBase+"/projects/%s/merge_requests", projectID(owner, repo))
 
 	mrBody := fmt.Sprintf("%s\n\n---\n\n*This is an anonymous contribution made via [gitGost](https://gitgost.livrasand.com).*\n\n*The original author's identity has been anonymized to protect their privacy. This is a service account that allows real humans to contribute anonymously.*", commitMessage)
 
+	if opts.Body != "" {
+		mrBody = opts.Body
+	}
+	mrBody = opts.AppendReportLink(mrBody)
+	base := opts.Base
+	if base == "" {
+		base = "main"
+	}
+	title := "Anonymous contribution via gitGost"
+	if opts.Draft {
+		title = "Draft: " + title
+	}
+
 	payload := map[string]interface{}{
 		"source_branch":       branch,
-		"target_branch":       "main",
-		"title":               "Anonymous contribution via gitGost",
+		"target_branch":       base,
+		"title":               title,
 		"description":         mrBody,
 		"source_project_id":   fmt.Sprintf("%s/%s", forkOwner, repo),
 		"allow_collaboration": true,
 	}
+	if len(opts.Labels) > 0 {
+		payload["labels"] = strings.Join(opts.Labels, ",")
+	}
+	if strings.EqualFold(forkOwner, owner) {
+		// Forkless mode: the branch lives in the target project itself.
+		delete(payload, "source_project_id")
+		delete(payload, "allow_collaboration")
+	}
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
 		return "", err
 	}
 
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", err
 	}
-	authHeader(req)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := httpClient.Do(req)
+	resp, err := do(req, t)
 	if err != nil {
 		return "", err
 	}
@@ -171,7 +251,7 @@ func (p *GitLabProvider) CreateMR(owner, repo, branch, forkOwner, commitMessage
 	if resp.StatusCode != http.StatusCreated {
 		var errResp map[string]interface{}
 		json.NewDecoder(resp.Body).Decode(&errResp)
-		return "", fmt.Errorf("failed to create GitLab MR: %s", resp.Status)
+		return "", provider.ErrorFromResponse(resp, "failed to create GitLab MR")
 	}
 
 	var result struct {
@@ -184,22 +264,22 @@ func (p *GitLabProvider) CreateMR(owner, repo, branch, forkOwner, commitMessage
 	return result.WebURL, nil
 }
 
-func (p *GitLabProvider) GetRefs(owner, repo string) ([]provider.Ref, error) {
-	apiURL := fmt.Sprintf("https://gitlab.com/api/v4/projects/%s/repository/branches", projectID(owner, repo))
-	req, err := http.NewRequest("GET", apiURL, nil)
+func (p *GitLabProvider) GetRefs(ctx context.Context, owner, repo string) ([]provider.Ref, error) {
+	t := token()
+	apiURL := fmt.Sprintf(apiBase+"/projects/%s/repository/branches", projectID(owner, repo))
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
-	authHeader(req)
 
-	resp, err := httpClient.Do(req)
+	resp, err := do(req, t)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get GitLab refs: %s", resp.Status)
+		return nil, provider.ErrorFromResponse(resp, "failed to get GitLab refs")
 	}
 
 	var branches []struct {
@@ -222,21 +302,20 @@ func (p *GitLabProvider) GetRefs(owner, repo string) ([]provider.Ref, error) {
 	return refs, nil
 }
 
-func (p *GitLabProvider) GetExistingMR(owner, repo, forkOwner, branchName string) (string, bool, error) {
-	t := token()
+func (p *GitLabProvider) GetExistingMR(ctx context.Context, owner, repo, forkOwner, branchName string) (string, bool, error) {
+	t := tokenFor(forkOwner)
 	if t == "" {
 		return "", false, fmt.Errorf("GITLAB_TOKEN not set")
 	}
 
-	branchURL := fmt.Sprintf("https://gitlab.com/api/v4/projects/%s/repository/branches/%s",
+	branchURL := fmt.Sprintf(apiBase+"/projects/%s/repository/branches/%s",
 		projectID(forkOwner, repo), url.PathEscape(branchName))
-	branchReq, err := http.NewRequest("GET", branchURL, nil)
+	branchReq, err := http.NewRequestWithContext(ctx, "GET", branchURL, nil)
 	if err != nil {
 		return "", false, err
 	}
-	authHeader(branchReq)
 
-	branchResp, err := httpClient.Do(branchReq)
+	branchResp, err := do(branchReq, t)
 	if err != nil {
 		return "", false, err
 	}
@@ -247,23 +326,22 @@ func (p *GitLabProvider) GetExistingMR(owner, repo, forkOwner, branchName string
 	}
 
 	mrListURL := fmt.Sprintf(
-		"https://gitlab.com/api/v4/projects/%s/merge_requests?state=opened&source_branch=%s&per_page=1",
+		apiBase+"/projects/%s/merge_requests?state=opened&source_branch=%s&per_page=1",
 		projectID(owner, repo), url.QueryEscape(branchName),
 	)
-	mrReq, err := http.NewRequest("GET", mrListURL, nil)
+	mrReq, err := http.NewRequestWithContext(ctx, "GET", mrListURL, nil)
 	if err != nil {
 		return "", true, err
 	}
-	authHeader(mrReq)
 
-	mrResp, err := httpClient.Do(mrReq)
+	mrResp, err := do(mrReq, t)
 	if err != nil {
 		return "", true, err
 	}
 	defer mrResp.Body.Close()
 
 	if mrResp.StatusCode != http.StatusOK {
-		return "", true, fmt.Errorf("failed to list GitLab MRs: %s", mrResp.Status)
+		return "", true, provider.ErrorFromResponse(mrResp, "failed to list GitLab MRs")
 	}
 
 	var mrs []struct {
@@ -279,7 +357,7 @@ func (p *GitLabProvider) GetExistingMR(owner, repo, forkOwner, branchName string
 	return mrs[0].WebURL, true, nil
 }
 
-func (p *GitLabProvider) CloseMRByURL(mrURL string) error {
+func (p *GitLabProvider) CloseMRByURL(ctx context.Context, mrURL string) error {
 	t := token()
 	if t == "" {
 		return fmt.Errorf("GITLAB_TOKEN not set")
@@ -293,7 +371,7 @@ func (p *GitLabProvider) CloseMRByURL(mrURL string) error {
 	projectPath := parts[0]
 	iid := parts[1]
 
-	apiURL := fmt.Sprintf("https://gitlab.com/api/v4/projects/%s/merge_requests/%s",
+	apiURL := fmt.Sprintf(apiBase+"/projects/%s/merge_requests/%s",
 		url.PathEscape(projectPath), iid)
 
 	payload, err := json.Marshal(map[string]string{"state_event": "close"})
@@ -301,65 +379,58 @@ func (p *GitLabProvider) CloseMRByURL(mrURL string) error {
 		return err
 	}
 
-	req, err := http.NewRequest("PUT", apiURL, bytes.NewBuffer(payload))
+	req, err := http.NewRequestWithContext(ctx, "PUT", apiURL, bytes.NewBuffer(payload))
 	if err != nil {
 		return err
 	}
-	authHeader(req)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := httpClient.Do(req)
+	resp, err := do(req, t)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to close GitLab MR %s: status %s", mrURL, resp.Status)
+		return provider.ErrorFromResponse(resp, "failed to close GitLab MR "+mrURL)
 	}
 	return nil
 }
 
-func (p *GitLabProvider) GetRepoPolicy(owner, repo string) (*provider.RepoPolicy, error) {
-	t := token()
-	apiURL := fmt.Sprintf("https://gitlab.com/api/v4/projects/%s/repository/files/.gitgost.yml/raw",
-		projectID(owner, repo))
-
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		return &provider.RepoPolicy{}, nil
-	}
-	if t != "" {
-		authHeader(req)
-	}
-
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return &provider.RepoPolicy{}, nil
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return &provider.RepoPolicy{}, nil
+// fetchPolicyFile fetches the raw .gitgost.yml of owner/repo, revalidating
+// with etag.
+func fetchPolicyFile(owner, repo string) provider.PolicyFetcher {
+	return func(ctx context.Context, etag string) (provider.PolicyFileResult, error) {
+		apiURL := fmt.Sprintf(apiBase+"/projects/%s/repository/files/%s/raw",
+			projectID(owner, repo), url.PathEscape(provider.PolicyFile))
+		req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+		if err != nil {
+			return provider.PolicyFileResult{}, err
+		}
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		resp, err := do(req, token())
+		if err != nil {
+			return provider.PolicyFileResult{}, err
+		}
+		defer resp.Body.Close()
+		return provider.ReadPolicyResponse(resp)
 	}
+}
 
-	var buf bytes.Buffer
-	buf.ReadFrom(resp.Body)
-	content := buf.String()
-
-	if strings.Contains(content, "DENY_ALL: true") {
-		return &provider.RepoPolicy{DenyAll: true}, nil
-	}
-	return &provider.RepoPolicy{}, nil
+func (p *GitLabProvider) GetRepoPolicy(ctx context.Context, owner, repo string) (*provider.RepoPolicy, error) {
+	policy, _, err := provider.Policies.Load(ctx, provider.PolicyKey(tokenpool.GitLab, owner, repo), fetchPolicyFile(owner, repo))
+	return policy, err
 }
 
-func (p *GitLabProvider) CreateAnonymousIssue(owner, repo, title, body string, labels []string) (string, int, error) {
+func (p *GitLabProvider) CreateAnonymousIssue(ctx context.Context, owner, repo, title, body string, labels []string) (string, int, error) {
 	t := token()
 	if t == "" {
 		return "", 0, fmt.Errorf("GITLAB_TOKEN not set")
 	}
 
-	apiURL := fmt.Sprintf("https://gitlab.com/api/v4/projects/%s/issues", projectID(owner, repo))
+	apiURL := fmt.Sprintf(apiBase+"/projects/%s/issues", projectID(owner, repo))
 
 	payload := map[string]interface{}{
 		"title":       title,
@@ -374,24 +445,23 @@ func (p *GitLabProvider) CreateAnonymousIssue(owner, repo, title, body string, l
 		return "", 0, err
 	}
 
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", 0, err
 	}
-	authHeader(req)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := httpClient.Do(req)
+	resp, err := do(req, t)
 	if err != nil {
 		return "", 0, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusForbidden {
-		return "", 0, fmt.Errorf("issues are disabled or not accessible in this GitLab repository")
+		return "", 0, fmt.Errorf("issues are disabled or not accessible in this GitLab repository: %w", provider.ErrorFromResponse(resp, "failed to create GitLab issue"))
 	}
 	if resp.StatusCode != http.StatusCreated {
-		return "", 0, fmt.Errorf("failed to create GitLab issue: %s", resp.Status)
+		return "", 0, provider.ErrorFromResponse(resp, "failed to create GitLab issue")
 	}
 
 	var result struct {
@@ -404,34 +474,33 @@ func (p *GitLabProvider) CreateAnonymousIssue(owner, repo, title, body string, l
 	return result.WebURL, result.IID, nil
 }
 
-func (p *GitLabProvider) CreateAnonymousComment(owner, repo string, number int, body string) (string, error) {
+func (p *GitLabProvider) CreateAnonymousComment(ctx context.Context, owner, repo string, number int, body string) (string, error) {
 	t := token()
 	if t == "" {
 		return "", fmt.Errorf("GITLAB_TOKEN not set")
 	}
 
-	apiURL := fmt.Sprintf("https://gitlab.com/api/v4/projects/%s/issues/%d/notes", projectID(owner, repo), number)
+	apiURL := fmt.Sprintf(apiBase+"/projects/%s/issues/%d/notes", projectID(owner, repo), number)
 
 	jsonData, err := json.Marshal(map[string]string{"body": body})
 	if err != nil {
 		return "", err
 	}
 
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", err
 	}
-	authHeader(req)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := httpClient.Do(req)
+	resp, err := do(req, t)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated {
-		return "", fmt.Errorf("failed to create GitLab issue note: %s", resp.Status)
+		return "", provider.ErrorFromResponse(resp, "failed to create GitLab issue note")
 	}
 
 	var result struct {
@@ -443,38 +512,37 @@ func (p *GitLabProvider) CreateAnonymousComment(owner, repo string, number int,
 	return fmt.Sprintf("https://gitlab.com/%s/%s/-/issues/%d#note_%d", owner, repo, number, result.ID), nil
 }
 
-func (p *GitLabProvider) CreateAnonymousDiscussionComment(owner, repo string, number int, body string) (string, error) {
-	return "", fmt.Errorf("GitLab does not support GitHub Discussions")
+func (p *GitLabProvider) CreateAnonymousDiscussionComment(ctx context.Context, owner, repo string, number int, body string) (string, error) {
+	return "", fmt.Errorf("GitLab does not support GitHub Discussions: %w", provider.ErrNotFound)
 }
 
-func (p *GitLabProvider) CreateAnonymousPRComment(owner, repo string, number int, body string) (string, error) {
+func (p *GitLabProvider) CreateAnonymousPRComment(ctx context.Context, owner, repo string, number int, body string) (string, error) {
 	t := token()
 	if t == "" {
 		return "", fmt.Errorf("GITLAB_TOKEN not set")
 	}
 
-	apiURL := fmt.Sprintf("https://gitlab.com/api/v4/projects/%s/merge_requests/%d/notes", projectID(owner, repo), number)
+	apiURL := fmt.Sprintf(apiBase+"/projects/%s/merge_requests/%d/notes", projectID(owner, repo), number)
 
 	jsonData, err := json.Marshal(map[string]string{"body": body})
 	if err != nil {
 		return "", err
 	}
 
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", err
 	}
-	authHeader(req)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := httpClient.Do(req)
+	resp, err := do(req, t)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated {
-		return "", fmt.Errorf("failed to create GitLab MR note: %s", resp.Status)
+		return "", provider.ErrorFromResponse(resp, "failed to create GitLab MR note")
 	}
 
 	var result struct {
@@ -486,7 +554,7 @@ func (p *GitLabProvider) CreateAnonymousPRComment(owner, repo string, number int
 	return fmt.Sprintf("https://gitlab.com/%s/%s/-/merge_requests/%d#note_%d", owner, repo, number, result.ID), nil
 }
 
-func (p *GitLabProvider) GetMRStatus(owner, repo string, number int) (*provider.MRStatus, error) {
+func (p *GitLabProvider) GetMRStatus(ctx context.Context, owner, repo string, number int) (*provider.MRStatus, error) {
 	t := token()
 	if t == "" {
 		return nil, fmt.Errorf("GITLAB_TOKEN not set")
@@ -494,17 +562,16 @@ func (p *GitLabProvider) GetMRStatus(owner, repo string, number int) (*provider.
 
 	pid := projectID(owner, repo)
 
-	mrURL := fmt.Sprintf("https://gitlab.com/api/v4/projects/%s/merge_requests/%d", pid, number)
-	mrReq, _ := http.NewRequest("GET", mrURL, nil)
-	authHeader(mrReq)
-	mrResp, err := httpClient.Do(mrReq)
+	mrURL := fmt.Sprintf(apiBase+"/projects/%s/merge_requests/%d", pid, number)
+	mrReq, _ := http.NewRequestWithContext(ctx, "GET", mrURL, nil)
+	mrResp, err := do(mrReq, t)
 	if err != nil {
 		return nil, err
 	}
 	defer mrResp.Body.Close()
 
 	if mrResp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("GitLab API returned status %d", mrResp.StatusCode)
+		return nil, provider.ErrorFromResponse(mrResp, "GitLab merge request")
 	}
 
 	var mr struct {
@@ -544,11 +611,10 @@ func (p *GitLabProvider) GetMRStatus(owner, repo string, number int) (*provider.
 	}
 
 	var allNotes []note
-	notesURL := fmt.Sprintf("https://gitlab.com/api/v4/projects/%s/merge_requests/%d/notes?per_page=100&sort=desc", pid, number)
+	notesURL := fmt.Sprintf(apiBase+"/projects/%s/merge_requests/%d/notes?per_page=100&sort=desc", pid, number)
 	for notesURL != "" {
-		notesReq, _ := http.NewRequest("GET", notesURL, nil)
-		authHeader(notesReq)
-		notesResp, err := httpClient.Do(notesReq)
+		notesReq, _ := http.NewRequestWithContext(ctx, "GET", notesURL, nil)
+		notesResp, err := do(notesReq, t)
 		if err != nil {
 			if len(allNotes) == 0 {
 				return &provider.MRStatus{
@@ -596,13 +662,7 @@ func (p *GitLabProvider) GetMRStatus(owner, repo string, number int) (*provider.
 	}, nil
 }
 
-func (p *GitLabProvider) IsRepoVerified(owner, repo string) bool {
-	apiURL := fmt.Sprintf("https://gitlab.com/api/v4/projects/%s/repository/files/.gitgost.yml/raw",
-		projectID(owner, repo))
-	resp, err := http.Get(apiURL)
-	if err != nil {
-		return false
-	}
-	defer resp.Body.Close()
-	return resp.StatusCode == http.StatusOK
+func (p *GitLabProvider) IsRepoVerified(ctx context.Context, owner, repo string) bool {
+	_, exists, _ := provider.Policies.Load(ctx, provider.PolicyKey(tokenpool.GitLab, owner, repo), fetchPolicyFile(owner, repo))
+	return exists
 }
@@ -0,0 +1,171 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/livrasand/gitGost/internal/provider"
+	"github.com/livrasand/gitGost/internal/tokenpool"
+)
+
+func (p *GitLabProvider) ServiceAccounts(ctx context.Context) []provider.Account {
+	var accounts []provider.Account
+	for _, t := range tokenpool.Tokens(tokenpool.GitLab) {
+		login, err := username(ctx, t)
+		if err != nil {
+			continue
+		}
+		accounts = append(accounts, provider.Account{Token: t, Owner: login})
+	}
+	return accounts
+}
+
+// getJSON decodes one page of a GET into out and returns the next page
+// number GitLab reports, or "" on the last page.
+func getJSON(ctx context.Context, t, u, op string, out interface{}) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := do(req, t)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", provider.ErrorFromResponse(resp, op)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return "", err
+	}
+	return resp.Header.Get("X-Next-Page"), nil
+}
+
+func (p *GitLabProvider) ListForks(ctx context.Context, acct provider.Account) ([]provider.Fork, error) {
+	var forks []provider.Fork
+	for page := "1"; page != ""; {
+		var projects []struct {
+			Path           string    `json:"path"`
+			LastActivityAt time.Time `json:"last_activity_at"`
+			Namespace      struct {
+				FullPath string `json:"full_path"`
+			} `json:"namespace"`
+			ForkedFrom *struct {
+				PathWithNamespace string `json:"path_with_namespace"`
+			} `json:"forked_from_project"`
+		}
+		u := apiBase + "/projects?owned=true&per_page=100&page=" + page
+		var err error
+		page, err = getJSON(ctx, acct.Token, u, "failed to list GitLab projects", &projects)
+		if err != nil {
+			return nil, err
+		}
+		for _, pr := range projects {
+			if pr.ForkedFrom == nil || !strings.EqualFold(pr.Namespace.FullPath, acct.Owner) {
+				continue
+			}
+			i := strings.LastIndex(pr.ForkedFrom.PathWithNamespace, "/")
+			if i < 0 {
+				continue
+			}
+			forks = append(forks, provider.Fork{
+				Owner:       acct.Owner,
+				Repo:        pr.Path,
+				ParentOwner: pr.ForkedFrom.PathWithNamespace[:i],
+				ParentRepo:  pr.ForkedFrom.PathWithNamespace[i+1:],
+				PushedAt:    pr.LastActivityAt,
+			})
+		}
+	}
+	return forks, nil
+}
+
+func (p *GitLabProvider) ListBranches(ctx context.Context, acct provider.Account, owner, repo string) ([]string, error) {
+	var names []string
+	for page := "1"; page != ""; {
+		var branches []struct {
+			Name string `json:"name"`
+		}
+		u := fmt.Sprintf(apiBase+"/projects/%s/repository/branches?per_page=100&page=%s", projectID(owner, repo), page)
+		var err error
+		page, err = getJSON(ctx, acct.Token, u, "failed to list GitLab branches", &branches)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range branches {
+			names = append(names, b.Name)
+		}
+	}
+	return names, nil
+}
+
+func (p *GitLabProvider) BranchPR(ctx context.Context, acct provider.Account, owner, repo, forkOwner, branch string) (*provider.BranchPR, error) {
+	u := fmt.Sprintf(apiBase+"/projects/%s/merge_requests?state=all&source_branch=%s&per_page=100",
+		projectID(owner, repo), url.QueryEscape(branch))
+	var mrs []struct {
+		IID      int        `json:"iid"`
+		State    string     `json:"state"`
+		MergedAt *time.Time `json:"merged_at"`
+		ClosedAt *time.Time `json:"closed_at"`
+		Author   struct {
+			Username string `json:"username"`
+		} `json:"author"`
+	}
+	if _, err := getJSON(ctx, acct.Token, u, "failed to list GitLab MRs", &mrs); err != nil {
+		return nil, err
+	}
+	for _, mr := range mrs {
+		// source_branch alone also matches same-named branches of other forks.
+		if !strings.EqualFold(mr.Author.Username, forkOwner) {
+			continue
+		}
+		pr := &provider.BranchPR{Number: mr.IID, State: provider.PRStateOpen}
+		switch mr.State {
+		case "merged":
+			pr.State = provider.PRStateMerged
+			if mr.MergedAt != nil {
+				pr.ClosedAt = *mr.MergedAt
+			}
+		case "closed":
+			pr.State = provider.PRStateClosed
+			if mr.ClosedAt != nil {
+				pr.ClosedAt = *mr.ClosedAt
+			}
+		}
+		return pr, nil
+	}
+	return nil, nil
+}
+
+func deleteRequest(ctx context.Context, t, u, op string) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := do(req, t)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusAccepted, http.StatusOK, http.StatusNotFound:
+		return nil
+	}
+	return provider.ErrorFromResponse(resp, op)
+}
+
+func (p *GitLabProvider) DeleteBranch(ctx context.Context, acct provider.Account, owner, repo, branch string) error {
+	u := fmt.Sprintf(apiBase+"/projects/%s/repository/branches/%s", projectID(owner, repo), url.PathEscape(branch))
+	return deleteRequest(ctx, acct.Token, u, "failed to delete GitLab branch")
+}
+
+func (p *GitLabProvider) DeleteRepo(ctx context.Context, acct provider.Account, owner, repo string) error {
+	return deleteRequest(ctx, acct.Token, apiBase+"/projects/"+projectID(owner, repo), "failed to delete GitLab project")
+}
@@ -0,0 +1,52 @@
+package provider
+
+import (
+	"context"
+	"time"
+)
+
+// Account is a service account whose forks gitGost manages. Owner is the
+// user or organization the forks live under.
+type Account struct {
+	Token string
+	Owner string
+}
+
+// Fork is a repository owned by a service account that was forked from
+// ParentOwner/ParentRepo.
+type Fork struct {
+	Owner       string
+	Repo        string
+	ParentOwner string
+	ParentRepo  string
+	PushedAt    time.Time
+}
+
+// Pull request states reported by BranchPR.
+const (
+	PRStateOpen   = "open"
+	PRStateMerged = "merged"
+	PRStateClosed = "closed"
+)
+
+// BranchPR is the pull request opened from a fork branch.
+type BranchPR struct {
+	Number   int
+	State    string
+	ClosedAt time.Time
+}
+
+// ForkJanitor is implemented by providers that can enumerate and delete the
+// forks and branches owned by gitGost's service accounts. The reaper uses
+// it to clean up after merged or closed contributions.
+type ForkJanitor interface {
+	Name() string
+	ServiceAccounts(ctx context.Context) []Account
+	ListForks(ctx context.Context, acct Account) ([]Fork, error)
+	ListBranches(ctx context.Context, acct Account, owner, repo string) ([]string, error)
+	// BranchPR returns the most recent PR from forkOwner:branch into
+	// owner/repo, or nil when none was ever opened.
+	BranchPR(ctx context.Context, acct Account, owner, repo, forkOwner, branch string) (*BranchPR, error)
+	DeleteBranch(ctx context.Context, acct Account, owner, repo, branch string) error
+	DeleteRepo(ctx context.Context, acct Account, owner, repo string) error
+}
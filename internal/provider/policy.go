@@ -0,0 +1,313 @@
+package provider
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/livrasand/gitGost/internal/prmsg"
+	"gopkg.in/yaml.v3"
+)
+
+// PolicyFile is the path of the maintainer policy in a repository root.
+const PolicyFile = ".gitgost.yml"
+
+// CurrentPolicyVersion is the newest .gitgost.yml schema ParsePolicy knows.
+// Files without a version key are the legacy v0 schema (DENY_ALL/FORKLESS).
+const CurrentPolicyVersion = 1
+
+// RateLimits caps anonymous activity on one repository per hour. Zero means
+// only the service-wide limits apply.
+type RateLimits struct {
+	PRsPerHour      int `yaml:"prs_per_hour" json:"prs_per_hour,omitempty"`
+	IssuesPerHour   int `yaml:"issues_per_hour" json:"issues_per_hour,omitempty"`
+	CommentsPerHour int `yaml:"comments_per_hour" json:"comments_per_hour,omitempty"`
+}
+
+// Mailbox lets maintainers exchange end-to-end encrypted messages with the
+// anonymous authors of their PRs. Key is the x25519 key contributors encrypt
+// to; TokenSHA256 is the hash of the token maintainers authenticate with.
+type Mailbox struct {
+	Key         string `yaml:"key" json:"key"`
+	TokenSHA256 string `yaml:"token_sha256" json:"token_sha256"`
+}
+
+// Webhook lets the repo's forge report PR events to gitGost. SecretSHA256 is
+// the hash of the maintainer's webhook secret, which gitGost exchanges for
+// the signing secret the forge is configured with.
+type Webhook struct {
+	SecretSHA256 string `yaml:"secret_sha256" json:"secret_sha256"`
+}
+
+// Kinds of anonymous activity a policy can allow or rate limit.
+const (
+	ActivityPR      = "pull_requests"
+	ActivityIssue   = "issues"
+	ActivityComment = "comments"
+)
+
+// RepoPolicy is a parsed .gitgost.yml. The zero value allows everything.
+type RepoPolicy struct {
+	Version int  `json:"version"`
+	DenyAll bool `json:"deny_all,omitempty"`
+	// Forkless means the maintainer lets gitGost push gitgost/* branches
+	// straight into the repo and open same-repo PRs instead of forking.
+	Forkless bool `json:"forkless,omitempty"`
+
+	DisablePRs      bool `json:"disable_pull_requests,omitempty"`
+	DisableIssues   bool `json:"disable_issues,omitempty"`
+	DisableComments bool `json:"disable_comments,omitempty"`
+
+	// TargetBranches lists the branches anonymous PRs may target; the first
+	// one is used when the pushed branch isn't a branch of the target repo.
+	TargetBranches  []string   `json:"target_branches,omitempty"`
+	Labels          []string   `json:"labels,omitempty"`
+	PRBodyTemplate  string     `json:"pr_body_template,omitempty"`
+	RateLimits      RateLimits `json:"rate_limits"`
+	MaxChangedFiles int        `json:"max_changed_files,omitempty"`
+	RequireSquash   bool       `json:"require_squash,omitempty"`
+	Message         string     `json:"message,omitempty"`
+	// PublicKey is the maintainer's ed25519 key. Repos publishing one are
+	// shown as maintainer-verified and can send private replies to the
+	// anonymous authors of their PRs.
+	PublicKey string   `json:"public_key,omitempty"`
+	Mailbox   *Mailbox `json:"mailbox,omitempty"`
+	Webhook   *Webhook `json:"webhook,omitempty"`
+}
+
+// Allows reports whether the policy accepts the given kind of activity.
+func (p *RepoPolicy) Allows(activity string) bool {
+	if p == nil {
+		return true
+	}
+	if p.DenyAll {
+		return false
+	}
+	switch activity {
+	case ActivityPR:
+		return !p.DisablePRs
+	case ActivityIssue:
+		return !p.DisableIssues
+	case ActivityComment:
+		return !p.DisableComments
+	}
+	return true
+}
+
+// HourlyLimit returns the per-repo hourly cap for activity, or 0.
+func (p *RepoPolicy) HourlyLimit(activity string) int {
+	if p == nil {
+		return 0
+	}
+	switch activity {
+	case ActivityPR:
+		return p.RateLimits.PRsPerHour
+	case ActivityIssue:
+		return p.RateLimits.IssuesPerHour
+	case ActivityComment:
+		return p.RateLimits.CommentsPerHour
+	}
+	return 0
+}
+
+// AllowsTargetBranch reports whether an anonymous PR may target branch.
+func (p *RepoPolicy) AllowsTargetBranch(branch string) bool {
+	if p == nil || len(p.TargetBranches) == 0 {
+		return true
+	}
+	for _, b := range p.TargetBranches {
+		if b == branch {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderPRBody fills the PR body template. {{message}} is the commit message
+// and {{disclaimer}} the standard gitGost notice. An empty template yields "",
+// meaning the provider's default body.
+func (p *RepoPolicy) RenderPRBody(message, disclaimer string) string {
+	if p == nil || p.PRBodyTemplate == "" {
+		return ""
+	}
+	return strings.NewReplacer("{{message}}", message, "{{disclaimer}}", disclaimer).Replace(p.PRBodyTemplate)
+}
+
+// PolicyError lists everything wrong with a .gitgost.yml so maintainers can
+// fix it in one go.
+type PolicyError struct {
+	Problems []string
+}
+
+func (e *PolicyError) Error() string {
+	return "invalid " + PolicyFile + ": " + strings.Join(e.Problems, "; ")
+}
+
+// ErrInvalidPolicy is matched by every *PolicyError.
+var ErrInvalidPolicy = errors.New("invalid " + PolicyFile)
+
+func (e *PolicyError) Is(target error) bool { return target == ErrInvalidPolicy }
+
+type policyV0 struct {
+	DenyAll  bool `yaml:"DENY_ALL"`
+	Forkless bool `yaml:"FORKLESS"`
+}
+
+type policyV1 struct {
+	Version       int  `yaml:"version"`
+	DenyAll       bool `yaml:"deny_all"`
+	Forkless      bool `yaml:"forkless"`
+	Contributions struct {
+		PullRequests *bool `yaml:"pull_requests"`
+		Issues       *bool `yaml:"issues"`
+		Comments     *bool `yaml:"comments"`
+	} `yaml:"contributions"`
+	TargetBranches  []string   `yaml:"target_branches"`
+	Labels          []string   `yaml:"labels"`
+	PRBodyTemplate  string     `yaml:"pr_body_template"`
+	RateLimits      RateLimits `yaml:"rate_limits"`
+	MaxChangedFiles int        `yaml:"max_changed_files"`
+	RequireSquash   bool       `yaml:"require_squash"`
+	Message         string     `yaml:"message"`
+	PublicKey       string     `yaml:"public_key"`
+	Mailbox         *Mailbox   `yaml:"mailbox"`
+	Webhook         *Webhook   `yaml:"webhook"`
+}
+
+// Limits that keep a policy from being used to abuse the sideband or the
+// forge API on gitGost's behalf.
+const (
+	maxPolicyMessage  = 500
+	maxPolicyTemplate = 10000
+	maxPolicyLabels   = 10
+)
+
+// ParsePolicy parses and validates a .gitgost.yml. An empty file is the
+// permissive default. Unknown keys, unknown versions and out-of-range values
+// are reported together in a *PolicyError.
+func ParsePolicy(raw []byte) (*RepoPolicy, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return &RepoPolicy{}, nil
+	}
+
+	var probe struct {
+		Version *int `yaml:"version"`
+	}
+	if err := yaml.Unmarshal(raw, &probe); err != nil {
+		return nil, &PolicyError{Problems: []string{yamlProblem(err)}}
+	}
+
+	if probe.Version == nil {
+		// Legacy files predate validation; stay lenient so existing opt-outs
+		// keep working.
+		var v0 policyV0
+		if err := yaml.Unmarshal(raw, &v0); err != nil {
+			return nil, &PolicyError{Problems: []string{yamlProblem(err)}}
+		}
+		return &RepoPolicy{DenyAll: v0.DenyAll, Forkless: v0.Forkless}, nil
+	}
+
+	switch *probe.Version {
+	case 1:
+		return parsePolicyV1(raw)
+	default:
+		return nil, &PolicyError{Problems: []string{
+			fmt.Sprintf("unsupported version %d (this gitGost understands up to %d)", *probe.Version, CurrentPolicyVersion),
+		}}
+	}
+}
+
+func parsePolicyV1(raw []byte) (*RepoPolicy, error) {
+	var v1 policyV1
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(&v1); err != nil {
+		return nil, &PolicyError{Problems: []string{yamlProblem(err)}}
+	}
+
+	var problems []string
+	for i, b := range v1.TargetBranches {
+		b = strings.TrimSpace(b)
+		if b == "" || strings.ContainsAny(b, " ~^:?*[\\") || strings.HasPrefix(b, "refs/") {
+			problems = append(problems, fmt.Sprintf("target_branches[%d]: %q is not a valid branch name", i, v1.TargetBranches[i]))
+		}
+		v1.TargetBranches[i] = b
+	}
+	if len(v1.Labels) > maxPolicyLabels {
+		problems = append(problems, fmt.Sprintf("labels: at most %d labels are allowed", maxPolicyLabels))
+	}
+	for i, l := range v1.Labels {
+		if strings.TrimSpace(l) == "" {
+			problems = append(problems, fmt.Sprintf("labels[%d]: must not be empty", i))
+		}
+	}
+	if len(v1.PRBodyTemplate) > maxPolicyTemplate {
+		problems = append(problems, fmt.Sprintf("pr_body_template: longer than %d characters", maxPolicyTemplate))
+	}
+	if v1.RateLimits.PRsPerHour < 0 || v1.RateLimits.IssuesPerHour < 0 || v1.RateLimits.CommentsPerHour < 0 {
+		problems = append(problems, "rate_limits: values must not be negative")
+	}
+	if v1.MaxChangedFiles < 0 {
+		problems = append(problems, "max_changed_files: must not be negative")
+	}
+	if len(v1.Message) > maxPolicyMessage {
+		problems = append(problems, fmt.Sprintf("message: longer than %d characters", maxPolicyMessage))
+	}
+	if v1.PublicKey != "" {
+		if _, err := prmsg.ParsePublicKey(v1.PublicKey); err != nil {
+			problems = append(problems, "public_key: "+err.Error())
+		}
+	}
+	if mb := v1.Mailbox; mb != nil {
+		mb.Key, mb.TokenSHA256 = strings.TrimSpace(mb.Key), strings.ToLower(strings.TrimSpace(mb.TokenSHA256))
+		if _, err := prmsg.ParseRecipientKey(mb.Key); err != nil {
+			problems = append(problems, "mailbox.key: "+err.Error())
+		}
+		if !isSHA256Hex(mb.TokenSHA256) {
+			problems = append(problems, "mailbox.token_sha256: must be a hex SHA-256 digest")
+		}
+	}
+	if wh := v1.Webhook; wh != nil {
+		wh.SecretSHA256 = strings.ToLower(strings.TrimSpace(wh.SecretSHA256))
+		if !isSHA256Hex(wh.SecretSHA256) {
+			problems = append(problems, "webhook.secret_sha256: must be a hex SHA-256 digest")
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &PolicyError{Problems: problems}
+	}
+
+	enabled := func(b *bool) bool { return b == nil || *b }
+	return &RepoPolicy{
+		Version:         1,
+		DenyAll:         v1.DenyAll,
+		Forkless:        v1.Forkless,
+		DisablePRs:      !enabled(v1.Contributions.PullRequests),
+		DisableIssues:   !enabled(v1.Contributions.Issues),
+		DisableComments: !enabled(v1.Contributions.Comments),
+		TargetBranches:  v1.TargetBranches,
+		Labels:          v1.Labels,
+		PRBodyTemplate:  v1.PRBodyTemplate,
+		RateLimits:      v1.RateLimits,
+		MaxChangedFiles: v1.MaxChangedFiles,
+		RequireSquash:   v1.RequireSquash,
+		Message:         strings.TrimSpace(v1.Message),
+		PublicKey:       strings.TrimSpace(v1.PublicKey),
+		Mailbox:         v1.Mailbox,
+		Webhook:         v1.Webhook,
+	}, nil
+}
+
+func isSHA256Hex(s string) bool {
+	return len(s) == 64 && strings.Trim(s, "0123456789abcdef") == ""
+}
+
+// yamlProblem flattens yaml.v3's multi-line type errors.
+func yamlProblem(err error) string {
+	var te *yaml.TypeError
+	if errors.As(err, &te) {
+		return strings.Join(te.Errors, "; ")
+	}
+	return err.Error()
+}
@@ -0,0 +1,85 @@
+package provider_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/livrasand/gitGost/internal/provider"
+	cbprovider "github.com/livrasand/gitGost/internal/provider/codeberg"
+	ghprovider "github.com/livrasand/gitGost/internal/provider/github"
+	glprovider "github.com/livrasand/gitGost/internal/provider/gitlab"
+)
+
+// fakeForgeTransport sends every request to srv, keeping its Host header and
+// path, so the forges' real API URLs reach one fake server.
+type fakeForgeTransport struct {
+	srv  *url.URL
+	next http.RoundTripper
+}
+
+func (f fakeForgeTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme, r.URL.Host = f.srv.Scheme, f.srv.Host
+	return f.next.RoundTrip(r)
+}
+
+func TestRepoPolicyFromFakeForges(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		prov provider.Provider
+		host string
+		path string
+	}{
+		{"github", ghprovider.New(), "api.github.com", "/repos/acme/widgets/contents/.gitgost.yml"},
+		{"codeberg", cbprovider.New(), "codeberg.org", "/api/v1/repos/acme/widgets/raw/.gitgost.yml"},
+		{"gitlab", glprovider.New(), "gitlab.com", "/api/v4/projects/acme%2Fwidgets/repository/files/.gitgost.yml/raw"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			const etag = `"abc"`
+			hits := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Host != tc.host || r.URL.EscapedPath() != tc.path {
+					http.NotFound(w, r)
+					return
+				}
+				hits++
+				if r.Header.Get("If-None-Match") == etag {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Header().Set("ETag", etag)
+				w.Write([]byte("version: 1\ntarget_branches: [develop]\nlabels: [anonymous]\n"))
+			}))
+			defer srv.Close()
+			srvURL, _ := url.Parse(srv.URL)
+
+			oldTransport, oldLoader := http.DefaultTransport, provider.Policies
+			http.DefaultTransport = fakeForgeTransport{srvURL, oldTransport}
+			provider.Policies = provider.NewPolicyLoader(time.Nanosecond, time.Hour)
+			defer func() { http.DefaultTransport, provider.Policies = oldTransport, oldLoader }()
+
+			ctx := context.Background()
+			want := &provider.RepoPolicy{Version: 1, TargetBranches: []string{"develop"}, Labels: []string{"anonymous"}}
+			for i := 0; i < 2; i++ {
+				got, err := tc.prov.GetRepoPolicy(ctx, "acme", "widgets")
+				if err != nil || !reflect.DeepEqual(got, want) {
+					t.Fatalf("GetRepoPolicy = %+v, %v", got, err)
+				}
+			}
+			if hits != 2 {
+				t.Errorf("server hit %d times, want a fetch and a revalidation", hits)
+			}
+			if !tc.prov.IsRepoVerified(ctx, "acme", "widgets") {
+				t.Error("repo with .gitgost.yml not verified")
+			}
+			if tc.prov.IsRepoVerified(ctx, "acme", "other") {
+				t.Error("repo without .gitgost.yml verified")
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PolicyFileResult is what a forge returned for a conditional fetch of
+// .gitgost.yml.
+type PolicyFileResult struct {
+	Content     []byte
+	ETag        string
+	NotModified bool
+	Missing     bool
+}
+
+// PolicyFetcher fetches .gitgost.yml of one repo, sending etag as
+// If-None-Match when it is set. Missing files are reported through
+// Missing, not as an error.
+type PolicyFetcher func(ctx context.Context, etag string) (PolicyFileResult, error)
+
+const (
+	// maxPolicyFileSize caps how much of a .gitgost.yml is read.
+	maxPolicyFileSize = 64 << 10
+	// maxPolicyEntries caps how many repos' policies are cached.
+	maxPolicyEntries = 10000
+)
+
+// ReadPolicyResponse turns a forge's answer to a raw .gitgost.yml request
+// into a PolicyFileResult, so every forge maps statuses the same way.
+func ReadPolicyResponse(resp *http.Response) (PolicyFileResult, error) {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		raw, err := io.ReadAll(io.LimitReader(resp.Body, maxPolicyFileSize))
+		if err != nil {
+			return PolicyFileResult{}, err
+		}
+		return PolicyFileResult{Content: raw, ETag: resp.Header.Get("ETag")}, nil
+	case http.StatusNotModified:
+		return PolicyFileResult{NotModified: true}, nil
+	case http.StatusNotFound:
+		return PolicyFileResult{Missing: true}, nil
+	}
+	return PolicyFileResult{}, ErrorFromResponse(resp, "failed to fetch "+PolicyFile)
+}
+
+type policyEntry struct {
+	policy    *RepoPolicy
+	err       error
+	exists    bool
+	etag      string
+	checkedAt time.Time
+}
+
+// PolicyLoader caches parsed policies for every forge. A policy is reused
+// for TTL, then revalidated with its ETag; repos without the file are
+// remembered for NegativeTTL. When the forge can't be reached a stale entry
+// is served rather than dropping the maintainer's settings. Once the cache
+// holds maxPolicyEntries repos, the least recently checked one makes room.
+type PolicyLoader struct {
+	TTL         time.Duration
+	NegativeTTL time.Duration
+
+	mu         sync.Mutex
+	entries    map[string]*policyEntry
+	maxEntries int
+	now        func() time.Time
+}
+
+// NewPolicyLoader returns an empty loader.
+func NewPolicyLoader(ttl, negativeTTL time.Duration) *PolicyLoader {
+	return &PolicyLoader{
+		TTL:         ttl,
+		NegativeTTL: negativeTTL,
+		entries:     map[string]*policyEntry{},
+		maxEntries:  maxPolicyEntries,
+		now:         time.Now,
+	}
+}
+
+// Policies is the loader shared by all providers.
+var Policies = NewPolicyLoader(5*time.Minute, 15*time.Minute)
+
+// PolicyKey identifies a repo across forges.
+func PolicyKey(forge, owner, repo string) string {
+	return strings.ToLower(forge + ":" + owner + "/" + repo)
+}
+
+// Load returns the policy for key and whether the file exists. The error is
+// a *PolicyError for an invalid file, or the fetch error when nothing was
+// cached yet; in both cases the returned policy is nil.
+func (l *PolicyLoader) Load(ctx context.Context, key string, fetch PolicyFetcher) (*RepoPolicy, bool, error) {
+	now := l.now()
+
+	l.mu.Lock()
+	cached := l.entries[key]
+	var etag string
+	if cached != nil {
+		ttl := l.TTL
+		if !cached.exists {
+			ttl = l.NegativeTTL
+		}
+		if now.Sub(cached.checkedAt) < ttl {
+			l.mu.Unlock()
+			return cached.policy, cached.exists, cached.err
+		}
+		etag = cached.etag
+	}
+	l.mu.Unlock()
+
+	res, err := fetch(ctx, etag)
+	if err != nil {
+		if cached != nil {
+			return cached.policy, cached.exists, cached.err
+		}
+		return nil, false, err
+	}
+
+	entry := &policyEntry{checkedAt: now}
+	switch {
+	case res.NotModified && cached != nil:
+		entry.policy, entry.err, entry.exists, entry.etag = cached.policy, cached.err, cached.exists, cached.etag
+	case res.Missing:
+		entry.policy = &RepoPolicy{}
+	default:
+		entry.exists = true
+		entry.etag = res.ETag
+		entry.policy, entry.err = ParsePolicy(res.Content)
+	}
+
+	l.mu.Lock()
+	if _, ok := l.entries[key]; !ok {
+		l.makeRoomLocked()
+	}
+	l.entries[key] = entry
+	l.mu.Unlock()
+	return entry.policy, entry.exists, entry.err
+}
+
+// makeRoomLocked drops the least recently checked entries until a new one
+// fits.
+func (l *PolicyLoader) makeRoomLocked() {
+	for l.maxEntries > 0 && len(l.entries) >= l.maxEntries {
+		var oldestKey string
+		var oldest time.Time
+		for k, e := range l.entries {
+			if oldestKey == "" || e.checkedAt.Before(oldest) {
+				oldestKey, oldest = k, e.checkedAt
+			}
+		}
+		delete(l.entries, oldestKey)
+	}
+}
+
+// Invalidate drops the cached policy of key so the next Load refetches it.
+func (l *PolicyLoader) Invalidate(key string) {
+	l.mu.Lock()
+	delete(l.entries, key)
+	l.mu.Unlock()
+}
@@ -0,0 +1,158 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakePolicySource struct {
+	content string
+	etag    string
+	missing bool
+	err     error
+	calls   int
+	sent    []string
+}
+
+func (f *fakePolicySource) fetch(ctx context.Context, etag string) (PolicyFileResult, error) {
+	f.calls++
+	f.sent = append(f.sent, etag)
+	if f.err != nil {
+		return PolicyFileResult{}, f.err
+	}
+	if f.missing {
+		return PolicyFileResult{Missing: true}, nil
+	}
+	if etag != "" && etag == f.etag {
+		return PolicyFileResult{NotModified: true}, nil
+	}
+	return PolicyFileResult{Content: []byte(f.content), ETag: f.etag}, nil
+}
+
+func newTestLoader() (*PolicyLoader, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	l := NewPolicyLoader(time.Minute, 10*time.Minute)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestPolicyLoaderCachesAndRevalidates(t *testing.T) {
+	l, now := newTestLoader()
+	src := &fakePolicySource{content: "version: 1\nmessage: hi\n", etag: `"v1"`}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		p, exists, err := l.Load(ctx, "gh:o/r", src.fetch)
+		if err != nil || !exists || p.Message != "hi" {
+			t.Fatalf("Load = %+v, %v, %v", p, exists, err)
+		}
+	}
+	if src.calls != 1 {
+		t.Fatalf("fetched %d times within TTL, want 1", src.calls)
+	}
+
+	*now = now.Add(2 * time.Minute)
+	p, _, err := l.Load(ctx, "gh:o/r", src.fetch)
+	if err != nil || p.Message != "hi" {
+		t.Fatalf("revalidated Load = %+v, %v", p, err)
+	}
+	if src.calls != 2 || src.sent[1] != `"v1"` {
+		t.Errorf("revalidation sent %q after %d calls", src.sent, src.calls)
+	}
+
+	src.content, src.etag = "version: 1\nmessage: bye\n", `"v2"`
+	*now = now.Add(2 * time.Minute)
+	if p, _, _ := l.Load(ctx, "gh:o/r", src.fetch); p.Message != "bye" {
+		t.Errorf("changed file not picked up: %+v", p)
+	}
+}
+
+func TestPolicyLoaderNegativeCache(t *testing.T) {
+	l, now := newTestLoader()
+	src := &fakePolicySource{missing: true}
+	ctx := context.Background()
+
+	p, exists, err := l.Load(ctx, "gl:o/r", src.fetch)
+	if err != nil || exists || !p.Allows(ActivityPR) {
+		t.Fatalf("missing file = %+v, %v, %v", p, exists, err)
+	}
+	*now = now.Add(5 * time.Minute)
+	l.Load(ctx, "gl:o/r", src.fetch)
+	if src.calls != 1 {
+		t.Errorf("missing file refetched within negative TTL")
+	}
+	*now = now.Add(6 * time.Minute)
+	l.Load(ctx, "gl:o/r", src.fetch)
+	if src.calls != 2 {
+		t.Errorf("missing file not refetched after negative TTL")
+	}
+}
+
+func TestPolicyLoaderServesStaleOnError(t *testing.T) {
+	l, now := newTestLoader()
+	src := &fakePolicySource{content: "DENY_ALL: true\n", etag: `"a"`}
+	ctx := context.Background()
+
+	l.Load(ctx, "cb:o/r", src.fetch)
+	src.err = errors.New("forge down")
+	*now = now.Add(time.Hour)
+	p, exists, err := l.Load(ctx, "cb:o/r", src.fetch)
+	if err != nil || !exists || !p.DenyAll {
+		t.Errorf("stale entry not served: %+v, %v, %v", p, exists, err)
+	}
+
+	if _, _, err := l.Load(ctx, "cb:o/other", src.fetch); err == nil {
+		t.Error("uncached repo should surface the fetch error")
+	}
+}
+
+func TestPolicyLoaderInvalidPolicyAndInvalidate(t *testing.T) {
+	l, _ := newTestLoader()
+	src := &fakePolicySource{content: "version: 9\n", etag: `"x"`}
+	ctx := context.Background()
+
+	p, exists, err := l.Load(ctx, "gh:o/r", src.fetch)
+	if !errors.Is(err, ErrInvalidPolicy) || !exists || p != nil {
+		t.Fatalf("invalid file = %+v, %v, %v", p, exists, err)
+	}
+
+	src.content, src.etag = "version: 1\n", `"y"`
+	l.Invalidate("gh:o/r")
+	if _, _, err := l.Load(ctx, "gh:o/r", src.fetch); err != nil {
+		t.Errorf("Invalidate didn't force a refetch: %v", err)
+	}
+	if src.sent[1] != "" {
+		t.Errorf("refetch after Invalidate sent etag %q", src.sent[1])
+	}
+}
+
+func TestPolicyLoaderEvictsLeastRecentlyChecked(t *testing.T) {
+	l, now := newTestLoader()
+	l.maxEntries = 2
+	src := &fakePolicySource{content: "version: 1\n", etag: `"v"`}
+	ctx := context.Background()
+
+	for _, key := range []string{"gh:o/a", "gh:o/b", "gh:o/c"} {
+		l.Load(ctx, key, src.fetch)
+		*now = now.Add(time.Second)
+	}
+	if len(l.entries) != 2 {
+		t.Fatalf("cache holds %d entries, want 2", len(l.entries))
+	}
+	if _, ok := l.entries["gh:o/a"]; ok {
+		t.Error("oldest entry kept")
+	}
+	calls := src.calls
+	l.Load(ctx, "gh:o/c", src.fetch)
+	if src.calls != calls {
+		t.Error("newest entry evicted")
+	}
+}
+
+func TestPolicyKeyIsCaseInsensitive(t *testing.T) {
+	if PolicyKey("GitHub", "Owner", "Repo") != PolicyKey("github", "owner", "repo") {
+		t.Error("PolicyKey should ignore case")
+	}
+}
@@ -0,0 +1,91 @@
+package provider
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParsePolicyLegacy(t *testing.T) {
+	p, err := ParsePolicy([]byte("DENY_ALL: true\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.DenyAll || p.Allows(ActivityComment) {
+		t.Errorf("legacy DENY_ALL not honored: %+v", p)
+	}
+
+	p, err = ParsePolicy(nil)
+	if err != nil || !p.Allows(ActivityPR) || !p.Allows(ActivityIssue) {
+		t.Errorf("empty policy should allow everything: %+v, %v", p, err)
+	}
+}
+
+func TestParsePolicyV1(t *testing.T) {
+	raw := `
+version: 1
+contributions:
+  issues: false
+target_branches: [develop, main]
+labels: [anonymous]
+pr_body_template: "{{message}}\n\n{{disclaimer}}"
+rate_limits:
+  prs_per_hour: 3
+max_changed_files: 20
+require_squash: true
+message: "Please read CONTRIBUTING.md"
+`
+	p, err := ParsePolicy([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Version != 1 || !p.Allows(ActivityPR) || p.Allows(ActivityIssue) || !p.Allows(ActivityComment) {
+		t.Errorf("contribution toggles wrong: %+v", p)
+	}
+	if !p.AllowsTargetBranch("develop") || p.AllowsTargetBranch("release") {
+		t.Errorf("target branches wrong: %v", p.TargetBranches)
+	}
+	if p.HourlyLimit(ActivityPR) != 3 || p.HourlyLimit(ActivityIssue) != 0 {
+		t.Errorf("rate limits wrong: %+v", p.RateLimits)
+	}
+	if p.MaxChangedFiles != 20 || !p.RequireSquash || p.Message != "Please read CONTRIBUTING.md" {
+		t.Errorf("unexpected policy: %+v", p)
+	}
+	if got := p.RenderPRBody("Fix typo", "(anon)"); got != "Fix typo\n\n(anon)" {
+		t.Errorf("RenderPRBody = %q", got)
+	}
+}
+
+func TestParsePolicyValidation(t *testing.T) {
+	cases := []struct {
+		name, raw, want string
+	}{
+		{"unknown key", "version: 1\nmax_files: 3\n", "max_files"},
+		{"future version", "version: 9\n", "unsupported version 9"},
+		{"negative limit", "version: 1\nrate_limits:\n  issues_per_hour: -1\n", "rate_limits"},
+		{"bad branch", "version: 1\ntarget_branches: [\"a b\"]\n", "target_branches[0]"},
+		{"syntax", "version: 1\nlabels: [\n", "yaml"},
+		{"bad mailbox", "version: 1\nmailbox:\n  key: \"x25519:AAAA\"\n  token_sha256: abc\n", "mailbox.token_sha256"},
+		{"bad webhook", "version: 1\nwebhook:\n  secret_sha256: xyz\n", "webhook.secret_sha256"},
+		{"bad key", "version: 1\npublic_key: \"ed25519:nope\"\n", "public_key"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParsePolicy([]byte(tc.raw))
+			if !errors.Is(err, ErrInvalidPolicy) {
+				t.Fatalf("err = %v, want ErrInvalidPolicy", err)
+			}
+			if !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("err = %q, want it to mention %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestParsePolicyReportsAllProblems(t *testing.T) {
+	_, err := ParsePolicy([]byte("version: 1\nmax_changed_files: -1\nlabels: [\"\"]\n"))
+	var pe *PolicyError
+	if !errors.As(err, &pe) || len(pe.Problems) != 2 {
+		t.Fatalf("err = %v, want two problems", err)
+	}
+}
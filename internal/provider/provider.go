@@ -1,12 +1,31 @@
 package provider
 
+import "context"
+
 type Ref struct {
 	Ref string
 	SHA string
 }
 
-type RepoPolicy struct {
-	DenyAll bool
+// MROptions customizes a PR/MR as the repo policy asks. The zero value
+// targets main with the default gitGost body and no labels.
+type MROptions struct {
+	Base   string
+	Body   string
+	Labels []string
+	// ReportURL, when set, is linked at the end of the body so readers can
+	// report the contribution to gitGost moderation.
+	ReportURL string
+	// Draft opens the PR/MR as a draft so it can't be merged by accident.
+	Draft bool
+}
+
+// AppendReportLink adds the report link to body when ReportURL is set.
+func (o MROptions) AppendReportLink(body string) string {
+	if o.ReportURL == "" {
+		return body
+	}
+	return body + "\n\n[Report this contribution](" + o.ReportURL + ")"
 }
 
 type MRStatus struct {
@@ -27,21 +46,25 @@ type Event struct {
 	CreatedAt string `json:"created_at"`
 }
 
+// Provider is the forge abstraction used by the HTTP handlers. Every method
+// that talks to the network takes the request context so a client hang-up
+// cancels the forge call, and failures wrap the typed errors in errors.go.
 type Provider interface {
-	ForkRepo(owner, repo string) (forkOwner string, err error)
-	CreateMR(owner, repo, branch, forkOwner, commitMessage string) (url string, err error)
-	GetRefs(owner, repo string) ([]Ref, error)
-	GetExistingMR(owner, repo, forkOwner, branchName string) (mrURL string, branchExists bool, err error)
-	CloseMRByURL(mrURL string) error
-	GetRepoPolicy(owner, repo string) (*RepoPolicy, error)
-	IsRepoVerified(owner, repo string) bool
+	ForkRepo(ctx context.Context, owner, repo string) (forkOwner string, err error)
+	CreateMR(ctx context.Context, owner, repo, branch, forkOwner, commitMessage string, opts MROptions) (url string, err error)
+	GetRefs(ctx context.Context, owner, repo string) ([]Ref, error)
+	GetExistingMR(ctx context.Context, owner, repo, forkOwner, branchName string) (mrURL string, branchExists bool, err error)
+	CloseMRByURL(ctx context.Context, mrURL string) error
+	GetRepoPolicy(ctx context.Context, owner, repo string) (*RepoPolicy, error)
+	IsRepoVerified(ctx context.Context, owner, repo string) bool
 	CloneURL(owner, repo string) string
 	PushURL(forkOwner, repo string) string
 	TokenEnvVar() string
+	GitToken(ctx context.Context, owner string) string
 	Name() string
-	CreateAnonymousIssue(owner, repo, title, body string, labels []string) (string, int, error)
-	CreateAnonymousComment(owner, repo string, number int, body string) (string, error)
-	CreateAnonymousPRComment(owner, repo string, number int, body string) (string, error)
-	CreateAnonymousDiscussionComment(owner, repo string, number int, body string) (string, error)
-	GetMRStatus(owner, repo string, number int) (*MRStatus, error)
+	CreateAnonymousIssue(ctx context.Context, owner, repo, title, body string, labels []string) (string, int, error)
+	CreateAnonymousComment(ctx context.Context, owner, repo string, number int, body string) (string, error)
+	CreateAnonymousPRComment(ctx context.Context, owner, repo string, number int, body string) (string, error)
+	CreateAnonymousDiscussionComment(ctx context.Context, owner, repo string, number int, body string) (string, error)
+	GetMRStatus(ctx context.Context, owner, repo string, number int) (*MRStatus, error)
 }
@@ -0,0 +1,274 @@
+package reaper
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livrasand/gitGost/internal/provider"
+	"github.com/livrasand/gitGost/internal/tokenpool"
+	"github.com/livrasand/gitGost/internal/utils"
+)
+
+// BranchPrefix marks branches pushed by gitGost (gitgost-<unix timestamp>).
+const BranchPrefix = "gitgost-"
+
+// ForklessBranchPrefix marks the branches gitGost pushes straight into
+// repositories that opted into forkless mode (gitgost/<unix>-<random>).
+const ForklessBranchPrefix = "gitgost/"
+
+// Action kinds in a Report.
+const (
+	KindKeep         = "keep"
+	KindDeleteBranch = "delete-branch"
+	KindDeleteFork   = "delete-fork"
+)
+
+// Action is one decision the reaper made about a branch or a fork.
+type Action struct {
+	Provider string `json:"provider"`
+	Account  string `json:"account"`
+	Fork     string `json:"fork"`
+	Parent   string `json:"parent"`
+	Branch   string `json:"branch,omitempty"`
+	PR       int    `json:"pr,omitempty"`
+	Kind     string `json:"kind"`
+	Reason   string `json:"reason"`
+	Done     bool   `json:"done"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Report summarizes one reaper pass. In a dry run nothing is deleted and
+// every Action has Done false.
+type Report struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	DryRun     bool      `json:"dry_run"`
+	Grace      string    `json:"grace"`
+	Actions    []Action  `json:"actions"`
+	Errors     []string  `json:"errors,omitempty"`
+}
+
+// Target is a repository that opted into forkless mode, reached through
+// Janitor with Account, an account that can push to its gitgost/* branches.
+type Target struct {
+	Janitor provider.ForkJanitor
+	Account provider.Account
+	Owner   string
+	Repo    string
+}
+
+// Options controls a reaper pass.
+type Options struct {
+	// Grace is how long a merged or closed PR's branch is kept, and how
+	// long a fork must have gone without pushes before it can be deleted.
+	Grace  time.Duration
+	DryRun bool
+	// Targets lists the forkless repositories to clean up, if any.
+	Targets func(ctx context.Context) []Target
+}
+
+// branchTime extracts the push time encoded in a gitgost-<unix> or
+// gitgost/<unix>-<random> branch name.
+func branchTime(branch string) (time.Time, bool) {
+	stamp := strings.TrimPrefix(strings.TrimPrefix(branch, BranchPrefix), ForklessBranchPrefix)
+	stamp, _, _ = strings.Cut(stamp, "-")
+	n, err := strconv.ParseInt(stamp, 10, 64)
+	// Anything before 2001 is a PR hash that happens to be all digits.
+	if err != nil || n < 1e9 {
+		return time.Time{}, false
+	}
+	return time.Unix(n, 0), true
+}
+
+// Run makes one pass over every fork owned by the service accounts of each
+// janitor, deleting gitGost branches whose PR was merged or closed more
+// than Grace ago and forks left without any gitGost branch. The gitgost/*
+// branches of opts.Targets are reaped the same way.
+func Run(ctx context.Context, janitors []provider.ForkJanitor, opts Options, now time.Time) *Report {
+	report := &Report{StartedAt: now, DryRun: opts.DryRun, Grace: opts.Grace.String(), Actions: []Action{}}
+	for _, j := range janitors {
+		for _, acct := range j.ServiceAccounts(ctx) {
+			forks, err := j.ListForks(ctx, acct)
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s/%s: %v", j.Name(), acct.Owner, err))
+				continue
+			}
+			for _, fork := range forks {
+				if ctx.Err() != nil {
+					report.Errors = append(report.Errors, ctx.Err().Error())
+					report.FinishedAt = time.Now()
+					return report
+				}
+				reapFork(ctx, j, acct, fork, opts, now, report)
+			}
+		}
+	}
+	if opts.Targets != nil {
+		for _, t := range opts.Targets(ctx) {
+			if ctx.Err() != nil {
+				report.Errors = append(report.Errors, ctx.Err().Error())
+				break
+			}
+			reapTarget(ctx, t, opts, now, report)
+		}
+	}
+	report.FinishedAt = time.Now()
+	return report
+}
+
+func reapFork(ctx context.Context, j provider.ForkJanitor, acct provider.Account, fork provider.Fork, opts Options, now time.Time, report *Report) {
+	base := Action{
+		Provider: j.Name(),
+		Account:  tokenpool.Fingerprint(acct.Token),
+		Fork:     fork.Owner + "/" + fork.Repo,
+		Parent:   fork.ParentOwner + "/" + fork.ParentRepo,
+	}
+	branches, err := j.ListBranches(ctx, acct, fork.Owner, fork.Repo)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("%s %s: %v", j.Name(), base.Fork, err))
+		return
+	}
+
+	remaining := 0
+	for _, branch := range branches {
+		if !strings.HasPrefix(branch, BranchPrefix) {
+			continue
+		}
+		a := reapBranch(ctx, j, acct, base, fork.ParentOwner, fork.ParentRepo, fork.Owner, fork.Repo, branch, fork.PushedAt, opts, now)
+		if a.Kind == KindKeep || a.Error != "" {
+			remaining++
+		}
+		report.Actions = append(report.Actions, a)
+	}
+
+	// A fork only goes once nothing of ours is left on it and nobody has
+	// pushed for a full grace period, so a fork created for an in-flight
+	// push is never deleted under it.
+	if remaining > 0 || now.Sub(fork.PushedAt) < opts.Grace {
+		return
+	}
+	a := base
+	a.Kind, a.Reason = KindDeleteFork, "no open gitGost branches"
+	if !opts.DryRun {
+		if err := j.DeleteRepo(ctx, acct, fork.Owner, fork.Repo); err != nil {
+			a.Error = err.Error()
+		} else {
+			a.Done = true
+		}
+	}
+	report.Actions = append(report.Actions, a)
+}
+
+// reapTarget deletes the stale gitgost/* branches of a forkless repository.
+// The repository itself is never touched.
+func reapTarget(ctx context.Context, t Target, opts Options, now time.Time, report *Report) {
+	j := t.Janitor
+	base := Action{
+		Provider: j.Name(),
+		Account:  tokenpool.Fingerprint(t.Account.Token),
+		Fork:     t.Owner + "/" + t.Repo,
+		Parent:   t.Owner + "/" + t.Repo,
+	}
+	branches, err := j.ListBranches(ctx, t.Account, t.Owner, t.Repo)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("%s %s: %v", j.Name(), base.Fork, err))
+		return
+	}
+	for _, branch := range branches {
+		if !strings.HasPrefix(branch, ForklessBranchPrefix) {
+			continue
+		}
+		// Without a time in the name there is nothing to age a PR-less
+		// branch by, so it is kept.
+		report.Actions = append(report.Actions, reapBranch(ctx, j, t.Account, base, t.Owner, t.Repo, t.Owner, t.Repo, branch, now, opts, now))
+	}
+}
+
+// reapBranch decides what to do with branch of owner/repo, whose PRs go to
+// parentOwner/parentRepo, and deletes it unless this is a dry run. pushed
+// stands in for the push time when the branch name has none.
+func reapBranch(ctx context.Context, j provider.ForkJanitor, acct provider.Account, base Action, parentOwner, parentRepo, owner, repo, branch string, pushed time.Time, opts Options, now time.Time) Action {
+	a := base
+	a.Branch = branch
+	a.Kind = KindKeep
+
+	pr, err := j.BranchPR(ctx, acct, parentOwner, parentRepo, owner, branch)
+	switch {
+	case err != nil:
+		a.Reason = "pr lookup failed"
+		a.Error = err.Error()
+	case pr == nil:
+		if at, ok := branchTime(branch); ok {
+			pushed = at
+		}
+		if now.Sub(pushed) >= opts.Grace {
+			a.Kind, a.Reason = KindDeleteBranch, "no pr"
+		} else {
+			a.Reason = "no pr, within grace period"
+		}
+	case pr.State == provider.PRStateOpen:
+		a.PR, a.Reason = pr.Number, "pr open"
+	default:
+		a.PR = pr.Number
+		if now.Sub(pr.ClosedAt) >= opts.Grace {
+			a.Kind, a.Reason = KindDeleteBranch, "pr "+pr.State
+		} else {
+			a.Reason = "pr " + pr.State + ", within grace period"
+		}
+	}
+
+	if a.Kind == KindDeleteBranch && !opts.DryRun {
+		if err := j.DeleteBranch(ctx, acct, owner, repo, branch); err != nil {
+			a.Error = err.Error()
+		} else {
+			a.Done = true
+		}
+	}
+	return a
+}
+
+var (
+	lastMu     sync.Mutex
+	lastReport *Report
+	startOnce  sync.Once
+)
+
+// Last returns the report of the most recent scheduled pass, or nil.
+func Last() *Report {
+	lastMu.Lock()
+	defer lastMu.Unlock()
+	return lastReport
+}
+
+// Start runs a pass every interval in the background. A zero interval
+// disables the reaper.
+func Start(janitors []provider.ForkJanitor, interval time.Duration, opts Options) {
+	if interval <= 0 {
+		return
+	}
+	startOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for range ticker.C {
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				r := Run(ctx, janitors, opts, time.Now())
+				cancel()
+				deleted := 0
+				for _, a := range r.Actions {
+					if a.Done {
+						deleted++
+					}
+				}
+				utils.Log("Reaper pass: %d actions, %d deletions, %d errors (dry run: %v)", len(r.Actions), deleted, len(r.Errors), r.DryRun)
+				lastMu.Lock()
+				lastReport = r
+				lastMu.Unlock()
+			}
+		}()
+	})
+}
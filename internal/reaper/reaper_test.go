@@ -0,0 +1,150 @@
+package reaper
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/livrasand/gitGost/internal/provider"
+)
+
+type fakeJanitor struct {
+	forks           []provider.Fork
+	branches        map[string][]string
+	prs             map[string]*provider.BranchPR
+	deletedBranches []string
+	deletedRepos    []string
+}
+
+func (f *fakeJanitor) Name() string { return "Fake" }
+
+func (f *fakeJanitor) ServiceAccounts(ctx context.Context) []provider.Account {
+	return []provider.Account{{Token: "tok", Owner: "ghost"}}
+}
+
+func (f *fakeJanitor) ListForks(ctx context.Context, acct provider.Account) ([]provider.Fork, error) {
+	return f.forks, nil
+}
+
+func (f *fakeJanitor) ListBranches(ctx context.Context, acct provider.Account, owner, repo string) ([]string, error) {
+	return f.branches[repo], nil
+}
+
+func (f *fakeJanitor) BranchPR(ctx context.Context, acct provider.Account, owner, repo, forkOwner, branch string) (*provider.BranchPR, error) {
+	return f.prs[branch], nil
+}
+
+func (f *fakeJanitor) DeleteBranch(ctx context.Context, acct provider.Account, owner, repo, branch string) error {
+	f.deletedBranches = append(f.deletedBranches, repo+":"+branch)
+	return nil
+}
+
+func (f *fakeJanitor) DeleteRepo(ctx context.Context, acct provider.Account, owner, repo string) error {
+	f.deletedRepos = append(f.deletedRepos, repo)
+	return nil
+}
+
+func newFake(now time.Time) *fakeJanitor {
+	old := now.Add(-10 * 24 * time.Hour)
+	oldBranch := fmt.Sprintf("gitgost-%d", old.Unix())
+	freshBranch := fmt.Sprintf("gitgost-%d", now.Add(-time.Hour).Unix())
+	return &fakeJanitor{
+		forks: []provider.Fork{
+			{Owner: "ghost", Repo: "done", ParentOwner: "acme", ParentRepo: "done", PushedAt: old},
+			{Owner: "ghost", Repo: "busy", ParentOwner: "acme", ParentRepo: "busy", PushedAt: old},
+			{Owner: "ghost", Repo: "fresh", ParentOwner: "acme", ParentRepo: "fresh", PushedAt: now.Add(-time.Minute)},
+		},
+		branches: map[string][]string{
+			"done":  {"main", "gitgost-merged", oldBranch},
+			"busy":  {"gitgost-open", "gitgost-recent", freshBranch},
+			"fresh": {"main"},
+		},
+		prs: map[string]*provider.BranchPR{
+			"gitgost-merged": {Number: 1, State: provider.PRStateMerged, ClosedAt: old},
+			"gitgost-open":   {Number: 2, State: provider.PRStateOpen},
+			"gitgost-recent": {Number: 3, State: provider.PRStateClosed, ClosedAt: now.Add(-time.Hour)},
+		},
+	}
+}
+
+func TestRunDeletesStaleBranchesAndEmptyForks(t *testing.T) {
+	now := time.Now()
+	f := newFake(now)
+	report := Run(context.Background(), []provider.ForkJanitor{f}, Options{Grace: 72 * time.Hour}, now)
+
+	if len(f.deletedBranches) != 2 {
+		t.Errorf("deleted branches = %v, want the merged and the PR-less old branch of done", f.deletedBranches)
+	}
+	for _, b := range f.deletedBranches {
+		if b[:5] != "done:" {
+			t.Errorf("deleted %s, which is open or within grace", b)
+		}
+	}
+	if len(f.deletedRepos) != 1 || f.deletedRepos[0] != "done" {
+		t.Errorf("deleted repos = %v, want [done] (busy has open branches, fresh was just pushed)", f.deletedRepos)
+	}
+	if len(report.Errors) != 0 {
+		t.Errorf("errors = %v", report.Errors)
+	}
+}
+
+func TestRunDryRunDeletesNothing(t *testing.T) {
+	now := time.Now()
+	f := newFake(now)
+	report := Run(context.Background(), []provider.ForkJanitor{f}, Options{Grace: 72 * time.Hour, DryRun: true}, now)
+
+	if len(f.deletedBranches) != 0 || len(f.deletedRepos) != 0 {
+		t.Fatalf("dry run deleted %v / %v", f.deletedBranches, f.deletedRepos)
+	}
+	var branches, forks int
+	for _, a := range report.Actions {
+		if a.Done {
+			t.Errorf("dry run action marked done: %+v", a)
+		}
+		switch a.Kind {
+		case KindDeleteBranch:
+			branches++
+		case KindDeleteFork:
+			forks++
+		}
+	}
+	if branches != 2 || forks != 1 {
+		t.Errorf("report plans %d branch and %d fork deletions, want 2 and 1", branches, forks)
+	}
+}
+
+func TestRunReapsForklessTargets(t *testing.T) {
+	now := time.Now()
+	oldBranch := fmt.Sprintf("gitgost/%d-ab12cd34", now.Add(-10*24*time.Hour).Unix())
+	freshBranch := fmt.Sprintf("gitgost/%d-ef56ab78", now.Add(-time.Hour).Unix())
+	f := &fakeJanitor{
+		branches: map[string][]string{
+			"widgets": {"main", "gitgost-1", "gitgost/merged", "gitgost/open", "gitgost/unnamed", oldBranch, freshBranch},
+		},
+		prs: map[string]*provider.BranchPR{
+			"gitgost/merged": {Number: 1, State: provider.PRStateMerged, ClosedAt: now.Add(-10 * 24 * time.Hour)},
+			"gitgost/open":   {Number: 2, State: provider.PRStateOpen},
+		},
+	}
+	targets := func(context.Context) []Target {
+		return []Target{{Janitor: f, Account: provider.Account{Token: "app", Owner: "acme"}, Owner: "acme", Repo: "widgets"}}
+	}
+	report := Run(context.Background(), nil, Options{Grace: 72 * time.Hour, Targets: targets}, now)
+
+	want := map[string]bool{"widgets:gitgost/merged": true, "widgets:" + oldBranch: true}
+	if len(f.deletedBranches) != len(want) {
+		t.Errorf("deleted branches = %v, want the merged and the old PR-less gitgost/* branch", f.deletedBranches)
+	}
+	for _, b := range f.deletedBranches {
+		if !want[b] {
+			t.Errorf("deleted %s, which is open, fresh, undated or not ours", b)
+		}
+	}
+	if len(f.deletedRepos) != 0 {
+		t.Errorf("deleted target repos %v", f.deletedRepos)
+	}
+	if len(report.Errors) != 0 {
+		t.Errorf("errors = %v", report.Errors)
+	}
+}
@@ -0,0 +1,234 @@
+package screen
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/livrasand/gitGost/internal/git"
+	"gopkg.in/yaml.v3"
+)
+
+// Action is what a push's score calls for.
+type Action int
+
+const (
+	Allow Action = iota
+	// Warn opens the PR as a draft with Rules.WarnLabel.
+	Warn
+	Reject
+)
+
+func (a Action) String() string {
+	switch a {
+	case Warn:
+		return "warn"
+	case Reject:
+		return "reject"
+	default:
+		return "allow"
+	}
+}
+
+// Rules weighs each heuristic. A weight of 0 disables the rule; a threshold
+// of 0 disables that action.
+type Rules struct {
+	WarnScore   int    `yaml:"warn_score"`
+	RejectScore int    `yaml:"reject_score"`
+	WarnLabel   string `yaml:"warn_label"`
+
+	// MassDeletion fires when at least MassDeletionLines lines are removed
+	// and removals make up MassDeletionRatio of all changed lines.
+	MassDeletion      int     `yaml:"mass_deletion"`
+	MassDeletionLines int     `yaml:"mass_deletion_lines"`
+	MassDeletionRatio float64 `yaml:"mass_deletion_ratio"`
+
+	// CIWorkflow fires on any change under these path prefixes.
+	CIWorkflow      int      `yaml:"ci_workflow"`
+	CIWorkflowPaths []string `yaml:"ci_workflow_paths"`
+
+	// Obfuscated fires on minified files, added lines longer than
+	// ObfuscatedLineLength, and packer or eval-of-decoded-string idioms.
+	Obfuscated           int `yaml:"obfuscated"`
+	ObfuscatedLineLength int `yaml:"obfuscated_line_length"`
+
+	// Binary fires on added or changed binaries other than media files,
+	// and on files with executable extensions.
+	Binary               int      `yaml:"binary"`
+	BinaryAllowedExts    []string `yaml:"binary_allowed_exts"`
+	ExecutableExtensions []string `yaml:"executable_exts"`
+
+	// Miner fires when an added line contains one of MinerPatterns.
+	Miner         int      `yaml:"miner"`
+	MinerPatterns []string `yaml:"miner_patterns"`
+
+	// Shortener fires when an added line links to one of ShortenerHosts.
+	Shortener      int      `yaml:"shortener"`
+	ShortenerHosts []string `yaml:"shortener_hosts"`
+
+	// ReadmeLinksOnly fires when a push touches nothing but README files
+	// and every added line carries a link.
+	ReadmeLinksOnly int `yaml:"readme_links_only"`
+}
+
+// DefaultRules is what a server without a rules file uses.
+func DefaultRules() Rules {
+	return Rules{
+		WarnScore:   4,
+		RejectScore: 10,
+		WarnLabel:   "gitgost:needs-review",
+
+		MassDeletion:      4,
+		MassDeletionLines: 500,
+		MassDeletionRatio: 0.9,
+
+		CIWorkflow:      4,
+		CIWorkflowPaths: []string{".github/workflows/", ".gitlab-ci.yml", ".forgejo/workflows/", ".gitea/workflows/", ".woodpecker"},
+
+		Obfuscated:           4,
+		ObfuscatedLineLength: 1000,
+
+		Binary:               4,
+		BinaryAllowedExts:    []string{".png", ".jpg", ".jpeg", ".gif", ".webp", ".ico", ".bmp", ".pdf", ".woff", ".woff2", ".ttf", ".otf", ".eot", ".mp3", ".mp4", ".webm", ".ogg", ".wav"},
+		ExecutableExtensions: []string{".exe", ".dll", ".so", ".dylib", ".bin", ".msi", ".scr", ".com", ".jar", ".class", ".apk", ".elf", ".deb", ".rpm"},
+
+		Miner:         10,
+		MinerPatterns: []string{"stratum+tcp://", "stratum+ssl://", "xmrig", "coinhive", "coin-hive", "cryptonight", "minexmr", "supportxmr", "nicehash", "webminepool", "cryptoloot"},
+
+		Shortener:      3,
+		ShortenerHosts: []string{"bit.ly", "tinyurl.com", "t.co", "goo.gl", "is.gd", "ow.ly", "rebrand.ly", "cutt.ly", "shorturl.at", "rb.gy", "tiny.cc", "s.id"},
+
+		ReadmeLinksOnly: 3,
+	}
+}
+
+// LoadRules reads a YAML rules file. Keys it leaves out keep their
+// DefaultRules values.
+func LoadRules(file string) (Rules, error) {
+	rules := DefaultRules()
+	if file == "" {
+		return rules, nil
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return rules, fmt.Errorf("read screening rules: %w", err)
+	}
+	if err := yaml.Unmarshal(b, &rules); err != nil {
+		return rules, fmt.Errorf("parse screening rules: %w", err)
+	}
+	return rules, nil
+}
+
+// Finding is one rule that fired.
+type Finding struct {
+	Rule   string `json:"rule"`
+	Weight int    `json:"weight"`
+	Detail string `json:"detail"`
+}
+
+// Verdict is the outcome of scoring a push.
+type Verdict struct {
+	Score    int       `json:"score"`
+	Action   Action    `json:"-"`
+	Findings []Finding `json:"findings"`
+}
+
+var packedJS = regexp.MustCompile(`eval\s*\(\s*(function\s*\(\s*p\s*,\s*a\s*,\s*c\s*,\s*k|atob\s*\(|unescape\s*\(|String\.fromCharCode|Buffer\.from\s*\()`)
+
+var skipLongLines = map[string]bool{".svg": true, ".map": true, ".lock": true, ".sum": true, ".csv": true}
+
+// Score runs every enabled rule over diffs. Each rule counts once per push.
+func (r Rules) Score(diffs []git.FileDiff) Verdict {
+	var v Verdict
+	add := func(rule string, weight int, detail string) {
+		if weight <= 0 {
+			return
+		}
+		for _, f := range v.Findings {
+			if f.Rule == rule {
+				return
+			}
+		}
+		v.Findings = append(v.Findings, Finding{Rule: rule, Weight: weight, Detail: detail})
+		v.Score += weight
+	}
+
+	added, removed := 0, 0
+	readmeOnly, linksOnly := len(diffs) > 0, true
+	for _, d := range diffs {
+		added += d.Added
+		removed += d.Removed
+		ext := strings.ToLower(path.Ext(d.Path))
+		base := strings.ToLower(path.Base(d.Path))
+
+		for _, p := range r.CIWorkflowPaths {
+			if strings.HasPrefix(d.Path, p) {
+				add("ci_workflow", r.CIWorkflow, d.Path)
+			}
+		}
+
+		if !d.Deleted {
+			if contains(r.ExecutableExtensions, ext) || (d.Binary && !contains(r.BinaryAllowedExts, ext)) {
+				add("binary", r.Binary, d.Path)
+			}
+			if strings.HasSuffix(base, ".min.js") || strings.HasSuffix(base, ".min.css") {
+				add("obfuscated", r.Obfuscated, d.Path+" is minified")
+			}
+		}
+
+		if !strings.HasPrefix(base, "readme") {
+			readmeOnly = false
+		}
+		for _, line := range d.AddedLines {
+			lower := strings.ToLower(line)
+			if r.ObfuscatedLineLength > 0 && len(line) > r.ObfuscatedLineLength && !skipLongLines[ext] {
+				add("obfuscated", r.Obfuscated, fmt.Sprintf("%s has a %d-character line", d.Path, len(line)))
+			}
+			if packedJS.MatchString(line) {
+				add("obfuscated", r.Obfuscated, d.Path+" evaluates decoded code")
+			}
+			for _, p := range r.MinerPatterns {
+				if strings.Contains(lower, strings.ToLower(p)) {
+					add("miner", r.Miner, fmt.Sprintf("%s mentions %q", d.Path, p))
+				}
+			}
+			for _, h := range r.ShortenerHosts {
+				h = strings.ToLower(h)
+				if strings.Contains(lower, "://"+h+"/") || strings.Contains(lower, "://www."+h+"/") {
+					add("shortener", r.Shortener, fmt.Sprintf("%s links to %s", d.Path, h))
+				}
+			}
+			if strings.TrimSpace(line) != "" && !strings.Contains(lower, "http://") && !strings.Contains(lower, "https://") {
+				linksOnly = false
+			}
+		}
+	}
+
+	if r.MassDeletionLines > 0 && removed >= r.MassDeletionLines && float64(removed) >= r.MassDeletionRatio*float64(added+removed) {
+		add("mass_deletion", r.MassDeletion, fmt.Sprintf("%d lines removed, %d added", removed, added))
+	}
+	if readmeOnly && linksOnly && added > 0 {
+		add("readme_links_only", r.ReadmeLinksOnly, "only README links changed")
+	}
+
+	sort.SliceStable(v.Findings, func(i, j int) bool { return v.Findings[i].Weight > v.Findings[j].Weight })
+	switch {
+	case r.RejectScore > 0 && v.Score >= r.RejectScore:
+		v.Action = Reject
+	case r.WarnScore > 0 && v.Score >= r.WarnScore:
+		v.Action = Warn
+	}
+	return v
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if strings.EqualFold(x, s) {
+			return true
+		}
+	}
+	return false
+}
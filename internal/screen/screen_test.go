@@ -0,0 +1,118 @@
+package screen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/livrasand/gitGost/internal/git"
+)
+
+func rules(v Verdict) []string {
+	var out []string
+	for _, f := range v.Findings {
+		out = append(out, f.Rule)
+	}
+	return out
+}
+
+func TestScoreRules(t *testing.T) {
+	r := DefaultRules()
+	cases := []struct {
+		name   string
+		diffs  []git.FileDiff
+		want   []string
+		action Action
+	}{
+		{
+			name:   "ordinary change",
+			diffs:  []git.FileDiff{{Path: "main.go", Added: 3, Removed: 1, AddedLines: []string{"func a() {", "\treturn", "}"}}},
+			action: Allow,
+		},
+		{
+			name:   "workflow",
+			diffs:  []git.FileDiff{{Path: ".github/workflows/ci.yml", Added: 1, AddedLines: []string{"run: curl x | sh"}}},
+			want:   []string{"ci_workflow"},
+			action: Warn,
+		},
+		{
+			name:   "miner",
+			diffs:  []git.FileDiff{{Path: "start.sh", Added: 1, AddedLines: []string{"./xmrig -o stratum+tcp://pool:3333"}}},
+			want:   []string{"miner"},
+			action: Reject,
+		},
+		{
+			name:   "mass deletion",
+			diffs:  []git.FileDiff{{Path: "src/big.c", Deleted: true, Removed: 900}},
+			want:   []string{"mass_deletion"},
+			action: Warn,
+		},
+		{
+			name:   "binary",
+			diffs:  []git.FileDiff{{Path: "tools/helper.exe", Created: true, Binary: true}, {Path: "logo.png", Created: true, Binary: true}},
+			want:   []string{"binary"},
+			action: Warn,
+		},
+		{
+			name:   "obfuscated",
+			diffs:  []git.FileDiff{{Path: "lib.js", Added: 1, AddedLines: []string{`eval(atob("ZG9jdW1lbnQ="))`}}},
+			want:   []string{"obfuscated"},
+			action: Warn,
+		},
+		{
+			name:   "long line",
+			diffs:  []git.FileDiff{{Path: "lib.js", Added: 1, AddedLines: []string{strings.Repeat("a", 1200)}}},
+			want:   []string{"obfuscated"},
+			action: Warn,
+		},
+		{
+			name:   "readme shortener",
+			diffs:  []git.FileDiff{{Path: "README.md", Added: 1, Removed: 1, AddedLines: []string{"Docs: https://bit.ly/abc"}}},
+			want:   []string{"shortener", "readme_links_only"},
+			action: Warn,
+		},
+		{
+			name:   "workflow with binary and packed code",
+			diffs:  []git.FileDiff{{Path: ".github/workflows/x.yml"}, {Path: "a.so", Binary: true}, {Path: "x.min.js"}},
+			want:   []string{"ci_workflow", "binary", "obfuscated"},
+			action: Reject,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := r.Score(tc.diffs)
+			if got := rules(v); strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("findings = %v, want %v", got, tc.want)
+			}
+			if v.Action != tc.action {
+				t.Errorf("action = %s (score %d), want %s", v.Action, v.Score, tc.action)
+			}
+		})
+	}
+}
+
+func TestLoadRulesOverridesDefaults(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.yml")
+	yml := "reject_score: 0\nci_workflow: 0\nminer_patterns: [\"evilpool\"]\n"
+	if err := os.WriteFile(file, []byte(yml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := LoadRules(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.WarnScore != DefaultRules().WarnScore || r.RejectScore != 0 || len(r.MinerPatterns) != 1 {
+		t.Fatalf("rules = %+v", r)
+	}
+	v := r.Score([]git.FileDiff{
+		{Path: ".github/workflows/ci.yml"},
+		{Path: "x.sh", AddedLines: []string{"connect evilpool", "xmrig"}},
+	})
+	if strings.Join(rules(v), ",") != "miner" || v.Action != Warn {
+		t.Errorf("verdict = %+v", v)
+	}
+	if _, err := LoadRules(filepath.Join(t.TempDir(), "missing.yml")); err == nil {
+		t.Error("missing rules file accepted")
+	}
+}
@@ -0,0 +1,133 @@
+package state
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+	written time.Time
+}
+
+type memoryStore struct {
+	mu     sync.Mutex
+	data   map[string]map[string]memoryEntry
+	limits map[string]int
+}
+
+// NewMemory returns a Store that lives only as long as the process.
+func NewMemory() Store {
+	return &memoryStore{
+		data:   make(map[string]map[string]memoryEntry),
+		limits: make(map[string]int),
+	}
+}
+
+func (s *memoryStore) SetLimit(ns string, max int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limits[ns] = max
+}
+
+// liveLocked returns the entry for key, dropping it when it has expired.
+func (s *memoryStore) liveLocked(ns, key string, now time.Time) (memoryEntry, bool) {
+	e, ok := s.data[ns][key]
+	if !ok {
+		return memoryEntry{}, false
+	}
+	if expired(e.expires, now) {
+		delete(s.data[ns], key)
+		return memoryEntry{}, false
+	}
+	return e, true
+}
+
+func (s *memoryStore) putLocked(ns, key string, value []byte, ttl time.Duration, now time.Time) {
+	m := s.data[ns]
+	if m == nil {
+		m = make(map[string]memoryEntry)
+		s.data[ns] = m
+	}
+	if _, exists := m[key]; !exists {
+		s.makeRoomLocked(ns, m, now)
+	}
+	m[key] = memoryEntry{value: value, expires: expiry(now, ttl), written: now}
+}
+
+// makeRoomLocked frees a slot in a full namespace, expired entries first,
+// then the least recently written.
+func (s *memoryStore) makeRoomLocked(ns string, m map[string]memoryEntry, now time.Time) {
+	max := s.limits[ns]
+	if max <= 0 || len(m) < max {
+		return
+	}
+	for k, e := range m {
+		if expired(e.expires, now) {
+			delete(m, k)
+		}
+	}
+	for len(m) >= max {
+		var oldestKey string
+		var oldest time.Time
+		for k, e := range m {
+			if oldestKey == "" || e.written.Before(oldest) {
+				oldestKey, oldest = k, e.written
+			}
+		}
+		delete(m, oldestKey)
+	}
+}
+
+func (s *memoryStore) Get(_ context.Context, ns, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.liveLocked(ns, key, time.Now())
+	return e.value, ok, nil
+}
+
+func (s *memoryStore) Set(_ context.Context, ns, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.putLocked(ns, key, value, ttl, time.Now())
+	return nil
+}
+
+func (s *memoryStore) Update(_ context.Context, ns, key string, ttl time.Duration, fn UpdateFunc) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	e, ok := s.liveLocked(ns, key, now)
+	value, write := fn(e.value, ok)
+	if !write {
+		return e.value, ok, nil
+	}
+	s.putLocked(ns, key, value, ttl, now)
+	return value, true, nil
+}
+
+func (s *memoryStore) Delete(_ context.Context, ns, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data[ns], key)
+	return nil
+}
+
+func (s *memoryStore) Range(_ context.Context, ns string, fn func(key string, value []byte) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, e := range s.data[ns] {
+		if expired(e.expires, now) {
+			continue
+		}
+		if !fn(k, e.value) {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) Close() error { return nil }
@@ -0,0 +1,338 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The shared state protocol, served by Handler and spoken by NewRemote:
+//
+//	GET    /{ns}/{key}        value, with its version in X-State-Version; 404 if unset
+//	PUT    /{ns}/{key}?ttl=ms store the body; If-Match: <version> or
+//	                          If-None-Match: * make it conditional (412 on conflict);
+//	                          X-State-Limit: <n> caps the namespace at n keys
+//	DELETE /{ns}/{key}
+//	GET    /{ns}              every live key as a JSON object of base64 values
+//
+// ns and key are path-escaped; requests carry "Authorization: Bearer <token>".
+const (
+	versionHeader = "X-State-Version"
+	limitHeader   = "X-State-Limit"
+	// remoteMaxValue bounds one value on the wire.
+	remoteMaxValue = 8 << 20
+	// remoteUpdateAttempts bounds compare-and-swap retries in Update.
+	remoteUpdateAttempts = 16
+)
+
+// ErrContention is returned when an Update keeps losing races to other
+// writers.
+var ErrContention = errors.New("state: too much contention on key")
+
+func version(value []byte) string {
+	sum := sha256.Sum256(value)
+	return hex.EncodeToString(sum[:])
+}
+
+type remoteStore struct {
+	base   string
+	token  string
+	client *http.Client
+
+	limitsMu sync.Mutex
+	limits   map[string]int
+}
+
+// NewRemote returns a Store backed by the shared state service at base.
+// Update is a compare-and-swap loop, so fn may run more than once.
+func NewRemote(base, token string) Store {
+	return &remoteStore{
+		base:   strings.TrimRight(base, "/"),
+		token:  token,
+		client: &http.Client{Timeout: 10 * time.Second},
+		limits: make(map[string]int),
+	}
+}
+
+// SetLimit records the cap sent with every write to ns; the service
+// enforces it.
+func (s *remoteStore) SetLimit(ns string, max int) {
+	s.limitsMu.Lock()
+	defer s.limitsMu.Unlock()
+	s.limits[ns] = max
+}
+
+func (s *remoteStore) do(ctx context.Context, method, path string, body []byte, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.base+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	return s.client.Do(req)
+}
+
+func keyPath(ns, key string) string {
+	return "/" + url.PathEscape(ns) + "/" + url.PathEscape(key)
+}
+
+func statusError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("state service: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// get returns the value of key and its version.
+func (s *remoteStore) get(ctx context.Context, ns, key string) ([]byte, string, bool, error) {
+	resp, err := s.do(ctx, http.MethodGet, keyPath(ns, key), nil, nil)
+	if err != nil {
+		return nil, "", false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		value, err := io.ReadAll(io.LimitReader(resp.Body, remoteMaxValue))
+		return value, resp.Header.Get(versionHeader), err == nil, err
+	case http.StatusNotFound:
+		return nil, "", false, nil
+	default:
+		return nil, "", false, statusError(resp)
+	}
+}
+
+// put stores value; match is the version key must have, "*" for unset,
+// or "" for unconditional. It reports false on a version conflict.
+func (s *remoteStore) put(ctx context.Context, ns, key string, value []byte, ttl time.Duration, match string) (bool, error) {
+	path := keyPath(ns, key)
+	if ttl > 0 {
+		path += "?ttl=" + strconv.FormatInt(ttl.Milliseconds(), 10)
+	}
+	header := http.Header{}
+	s.limitsMu.Lock()
+	if max := s.limits[ns]; max > 0 {
+		header.Set(limitHeader, strconv.Itoa(max))
+	}
+	s.limitsMu.Unlock()
+	switch match {
+	case "":
+	case "*":
+		header.Set("If-None-Match", "*")
+	default:
+		header.Set("If-Match", match)
+	}
+	resp, err := s.do(ctx, http.MethodPut, path, value, header)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusOK:
+		return true, nil
+	case http.StatusPreconditionFailed:
+		return false, nil
+	default:
+		return false, statusError(resp)
+	}
+}
+
+func (s *remoteStore) Get(ctx context.Context, ns, key string) ([]byte, bool, error) {
+	value, _, ok, err := s.get(ctx, ns, key)
+	return value, ok, err
+}
+
+func (s *remoteStore) Set(ctx context.Context, ns, key string, value []byte, ttl time.Duration) error {
+	_, err := s.put(ctx, ns, key, value, ttl, "")
+	return err
+}
+
+func (s *remoteStore) Update(ctx context.Context, ns, key string, ttl time.Duration, fn UpdateFunc) ([]byte, bool, error) {
+	for attempt := 0; attempt < remoteUpdateAttempts; attempt++ {
+		old, ver, ok, err := s.get(ctx, ns, key)
+		if err != nil {
+			return nil, false, err
+		}
+		value, write := fn(old, ok)
+		if !write {
+			return old, ok, nil
+		}
+		match := ver
+		if !ok {
+			match = "*"
+		}
+		stored, err := s.put(ctx, ns, key, value, ttl, match)
+		if err != nil {
+			return nil, false, err
+		}
+		if stored {
+			return value, true, nil
+		}
+		// Back off a random while so racing writers spread out.
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case <-time.After(time.Duration(rand.Int64N(int64(attempt+1) * int64(2*time.Millisecond)))):
+		}
+	}
+	return nil, false, ErrContention
+}
+
+func (s *remoteStore) Delete(ctx context.Context, ns, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, keyPath(ns, key), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return statusError(resp)
+	}
+	return nil
+}
+
+func (s *remoteStore) Range(ctx context.Context, ns string, fn func(key string, value []byte) bool) error {
+	resp, err := s.do(ctx, http.MethodGet, "/"+url.PathEscape(ns), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return statusError(resp)
+	}
+	var all map[string][]byte
+	if err := json.NewDecoder(resp.Body).Decode(&all); err != nil {
+		return err
+	}
+	for k, v := range all {
+		if !fn(k, v) {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *remoteStore) Close() error { return nil }
+
+// Handler serves store over the shared state protocol to clients that
+// present token. Mount it with http.StripPrefix.
+func Handler(store Store, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/")
+		if len(parts) > 2 {
+			http.Error(w, "bad path", http.StatusBadRequest)
+			return
+		}
+		ns, err := url.PathUnescape(parts[0])
+		if err != nil || ns == "" {
+			http.Error(w, "bad namespace", http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+		if len(parts) == 1 {
+			if r.Method != http.MethodGet {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			all := map[string][]byte{}
+			if err := store.Range(ctx, ns, func(k string, v []byte) bool {
+				all[k] = v
+				return true
+			}); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(all)
+			return
+		}
+		key, err := url.PathUnescape(parts[1])
+		if err != nil {
+			http.Error(w, "bad key", http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			value, ok, err := store.Get(ctx, ns, key)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !ok {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+			w.Header().Set(versionHeader, version(value))
+			_, _ = w.Write(value)
+		case http.MethodPut:
+			value, err := io.ReadAll(io.LimitReader(r.Body, remoteMaxValue+1))
+			if err != nil || len(value) > remoteMaxValue {
+				http.Error(w, "value too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			var ttl time.Duration
+			if v := r.URL.Query().Get("ttl"); v != "" {
+				ms, err := strconv.ParseInt(v, 10, 64)
+				if err != nil || ms < 0 {
+					http.Error(w, "bad ttl", http.StatusBadRequest)
+					return
+				}
+				ttl = time.Duration(ms) * time.Millisecond
+			}
+			if v := r.Header.Get(limitHeader); v != "" {
+				max, err := strconv.Atoi(v)
+				if err != nil || max < 0 {
+					http.Error(w, "bad limit", http.StatusBadRequest)
+					return
+				}
+				if l, ok := store.(Limiter); ok {
+					l.SetLimit(ns, max)
+				}
+			}
+			ifMatch, ifNoneMatch := r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+			if ifMatch == "" && ifNoneMatch == "" {
+				err = store.Set(ctx, ns, key, value, ttl)
+			} else {
+				var stored bool
+				_, _, err = store.Update(ctx, ns, key, ttl, func(old []byte, ok bool) ([]byte, bool) {
+					stored = (ifNoneMatch == "*" && !ok) || (ifMatch != "" && ok && version(old) == ifMatch)
+					return value, stored
+				})
+				if err == nil && !stored {
+					http.Error(w, "version conflict", http.StatusPreconditionFailed)
+					return
+				}
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			if err := store.Delete(ctx, ns, key); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
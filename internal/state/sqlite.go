@@ -0,0 +1,254 @@
+package state
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS kv (
+    ns TEXT NOT NULL,
+    key TEXT NOT NULL,
+    value BLOB NOT NULL,
+    expires_at INTEGER NOT NULL DEFAULT 0,
+    written_at INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (ns, key)
+);
+CREATE INDEX IF NOT EXISTS kv_expires_at ON kv (expires_at) WHERE expires_at > 0;`
+
+// sqliteIndexes need columns older files get from migrate.
+const sqliteIndexes = `CREATE INDEX IF NOT EXISTS kv_written_at ON kv (ns, written_at);`
+
+// sqlitePurgeEvery is how many writes pass between sweeps of expired rows.
+const sqlitePurgeEvery = 1000
+
+type sqliteStore struct {
+	db     *sql.DB
+	writes atomic.Int64
+
+	limitsMu sync.Mutex
+	limits   map[string]int
+}
+
+// OpenSQLite opens (or creates) a state database at path. Processes on the
+// same host can share the file; Update takes the write lock up front so
+// concurrent read-modify-writes don't interleave.
+func OpenSQLite(path string) (Store, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("create state data dir: %w", err)
+		}
+	}
+	dsn := "file:" + path + "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create state schema: %w", err)
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate state schema: %w", err)
+	}
+	if _, err := db.Exec(sqliteIndexes); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create state indexes: %w", err)
+	}
+	return &sqliteStore{db: db, limits: make(map[string]int)}, nil
+}
+
+// migrate adds the columns files created by older versions lack.
+func migrate(db *sql.DB) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('kv')`)
+	if err != nil {
+		return err
+	}
+	has := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		has[name] = true
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if !has["written_at"] {
+		_, err = db.Exec(`ALTER TABLE kv ADD COLUMN written_at INTEGER NOT NULL DEFAULT 0`)
+	}
+	return err
+}
+
+func (s *sqliteStore) SetLimit(ns string, max int) {
+	s.limitsMu.Lock()
+	defer s.limitsMu.Unlock()
+	s.limits[ns] = max
+}
+
+func (s *sqliteStore) limit(ns string) int {
+	s.limitsMu.Lock()
+	defer s.limitsMu.Unlock()
+	return s.limits[ns]
+}
+
+func expiresAt(now time.Time, ttl time.Duration) int64 {
+	if e := expiry(now, ttl); !e.IsZero() {
+		return e.UnixNano()
+	}
+	return 0
+}
+
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func getRow(ctx context.Context, q queryer, ns, key string, now time.Time) ([]byte, bool, error) {
+	var value []byte
+	err := q.QueryRowContext(ctx,
+		`SELECT value FROM kv WHERE ns = ? AND key = ? AND (expires_at = 0 OR expires_at > ?)`,
+		ns, key, now.UnixNano()).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// put stores value. Callers hold a transaction, so the room made in a full
+// namespace is not taken by another writer first.
+func (s *sqliteStore) put(ctx context.Context, q queryer, ns, key string, value []byte, ttl time.Duration, now time.Time, exists bool) error {
+	if value == nil {
+		value = []byte{}
+	}
+	if !exists {
+		if err := s.makeRoom(ctx, q, ns, now); err != nil {
+			return err
+		}
+	}
+	_, err := q.ExecContext(ctx,
+		`INSERT INTO kv (ns, key, value, expires_at, written_at) VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT (ns, key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at, written_at = excluded.written_at`,
+		ns, key, value, expiresAt(now, ttl), now.UnixNano())
+	if err == nil && s.writes.Add(1)%sqlitePurgeEvery == 0 {
+		_, _ = q.ExecContext(ctx, `DELETE FROM kv WHERE expires_at > 0 AND expires_at <= ?`, now.UnixNano())
+	}
+	return err
+}
+
+// makeRoom frees a slot in a full namespace, expired entries first, then the
+// least recently written.
+func (s *sqliteStore) makeRoom(ctx context.Context, q queryer, ns string, now time.Time) error {
+	max := s.limit(ns)
+	if max <= 0 {
+		return nil
+	}
+	var n int
+	if err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM kv WHERE ns = ?`, ns).Scan(&n); err != nil || n < max {
+		return err
+	}
+	res, err := q.ExecContext(ctx, `DELETE FROM kv WHERE ns = ? AND expires_at > 0 AND expires_at <= ?`, ns, now.UnixNano())
+	if err != nil {
+		return err
+	}
+	gone, _ := res.RowsAffected()
+	if over := n - int(gone) - max + 1; over > 0 {
+		_, err = q.ExecContext(ctx,
+			`DELETE FROM kv WHERE ns = ? AND key IN (SELECT key FROM kv WHERE ns = ? ORDER BY written_at LIMIT ?)`, ns, ns, over)
+	}
+	return err
+}
+
+func (s *sqliteStore) Get(ctx context.Context, ns, key string) ([]byte, bool, error) {
+	return getRow(ctx, s.db, ns, key, time.Now())
+}
+
+func (s *sqliteStore) Set(ctx context.Context, ns, key string, value []byte, ttl time.Duration) error {
+	_, _, err := s.Update(ctx, ns, key, ttl, func([]byte, bool) ([]byte, bool) { return value, true })
+	return err
+}
+
+func (s *sqliteStore) Update(ctx context.Context, ns, key string, ttl time.Duration, fn UpdateFunc) ([]byte, bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+	now := time.Now()
+	old, ok, err := getRow(ctx, tx, ns, key, now)
+	if err != nil {
+		return nil, false, err
+	}
+	value, write := fn(old, ok)
+	if !write {
+		return old, ok, nil
+	}
+	// An expired row still holds its slot until it is overwritten.
+	exists := ok
+	if !exists {
+		err := tx.QueryRowContext(ctx, `SELECT 1 FROM kv WHERE ns = ? AND key = ?`, ns, key).Scan(new(int))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, false, err
+		}
+		exists = err == nil
+	}
+	if err := s.put(ctx, tx, ns, key, value, ttl, now, exists); err != nil {
+		return nil, false, err
+	}
+	return value, true, tx.Commit()
+}
+
+func (s *sqliteStore) Delete(ctx context.Context, ns, key string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM kv WHERE ns = ? AND key = ?`, ns, key)
+	return err
+}
+
+func (s *sqliteStore) Range(ctx context.Context, ns string, fn func(key string, value []byte) bool) error {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT key, value FROM kv WHERE ns = ? AND (expires_at = 0 OR expires_at > ?)`, ns, time.Now().UnixNano())
+	if err != nil {
+		return err
+	}
+	type kv struct {
+		key   string
+		value []byte
+	}
+	// Collect first: fn may call back into the store, which needs the one
+	// connection this query holds.
+	var all []kv
+	for rows.Next() {
+		var e kv
+		if err := rows.Scan(&e.key, &e.value); err != nil {
+			rows.Close()
+			return err
+		}
+		all = append(all, e)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	for _, e := range all {
+		if !fn(e.key, e.value) {
+			break
+		}
+	}
+	return rows.Err()
+}
+
+func (s *sqliteStore) Close() error { return s.db.Close() }
@@ -0,0 +1,67 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Store holds the runtime state gitGost instances share: rate-limit windows,
+// one-shot tokens, remote jobs, tracked PRs, caches and flags such as panic
+// mode. Keys live in namespaces and each value carries its own expiry.
+//
+// NewMemory keeps state in the process, OpenSQLite in a file several
+// processes on one host can share, and NewRemote in a shared service that
+// serves Handler, so any number of instances see the same state.
+type Store interface {
+	Get(ctx context.Context, ns, key string) ([]byte, bool, error)
+	// Set stores value under key. ttl <= 0 means it never expires.
+	Set(ctx context.Context, ns, key string, value []byte, ttl time.Duration) error
+	// Update calls fn with the current value of key and stores what it
+	// returns with a fresh ttl, atomically with respect to other writers.
+	// When fn returns write=false nothing is stored. Update returns the
+	// value key holds afterwards.
+	Update(ctx context.Context, ns, key string, ttl time.Duration, fn UpdateFunc) ([]byte, bool, error)
+	Delete(ctx context.Context, ns, key string) error
+	// Range calls fn for each live key in ns until fn returns false.
+	Range(ctx context.Context, ns string, fn func(key string, value []byte) bool) error
+	Close() error
+}
+
+// UpdateFunc computes the new value of a key from its current one. fn may
+// run more than once when another writer wins a race, so it must not have
+// side effects.
+type UpdateFunc func(old []byte, ok bool) (value []byte, write bool)
+
+// Limiter is implemented by stores that cap how many keys a namespace may
+// hold, dropping the least recently written key first.
+type Limiter interface {
+	SetLimit(ns string, max int)
+}
+
+// Open picks a store from spec: "" or "memory", "sqlite:<path>", or the
+// http(s) URL of a shared state service, which token authenticates to.
+func Open(spec, token string) (Store, error) {
+	switch {
+	case spec == "" || spec == "memory":
+		return NewMemory(), nil
+	case strings.HasPrefix(spec, "sqlite:"):
+		return OpenSQLite(strings.TrimPrefix(spec, "sqlite:"))
+	case strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://"):
+		return NewRemote(spec, token), nil
+	default:
+		return nil, fmt.Errorf("state: unknown backend %q", spec)
+	}
+}
+
+func expiry(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+func expired(exp, now time.Time) bool {
+	return !exp.IsZero() && !now.Before(exp)
+}
@@ -0,0 +1,179 @@
+package state
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stores returns every backend; the remote one talks to a local stand-in
+// for the shared service.
+func stores(t *testing.T) map[string]Store {
+	t.Helper()
+	sq, err := OpenSQLite(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sq.Close() })
+	srv := httptest.NewServer(http.StripPrefix("/state", Handler(NewMemory(), "tok")))
+	t.Cleanup(srv.Close)
+	return map[string]Store{
+		"memory": NewMemory(),
+		"sqlite": sq,
+		"remote": NewRemote(srv.URL+"/state", "tok"),
+	}
+}
+
+func TestStores(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if _, ok, err := s.Get(ctx, "ns", "a"); ok || err != nil {
+				t.Fatalf("fresh key: %v %v", ok, err)
+			}
+			// Keys may hold anything a URL or IP does.
+			const key = "https://api.github.com/repos/o/r?x=1|ab"
+			if err := s.Set(ctx, "ns", key, []byte("v1"), 0); err != nil {
+				t.Fatal(err)
+			}
+			if v, ok, _ := s.Get(ctx, "ns", key); !ok || string(v) != "v1" {
+				t.Fatalf("Get = %q %v", v, ok)
+			}
+			if _, ok, _ := s.Get(ctx, "other", key); ok {
+				t.Fatal("namespaces leak")
+			}
+
+			v, ok, err := s.Update(ctx, "ns", key, 0, func(old []byte, ok bool) ([]byte, bool) {
+				return append(old, '!'), ok
+			})
+			if err != nil || !ok || string(v) != "v1!" {
+				t.Fatalf("Update = %q %v %v", v, ok, err)
+			}
+			v, ok, _ = s.Update(ctx, "ns", "missing", 0, func(old []byte, ok bool) ([]byte, bool) {
+				return []byte("x"), false
+			})
+			if ok || v != nil {
+				t.Fatalf("skipped Update stored %q", v)
+			}
+
+			_ = s.Set(ctx, "ns", "short", []byte("x"), 20*time.Millisecond)
+			time.Sleep(40 * time.Millisecond)
+			if _, ok, _ := s.Get(ctx, "ns", "short"); ok {
+				t.Error("expired key still readable")
+			}
+
+			seen := map[string]string{}
+			_ = s.Range(ctx, "ns", func(k string, v []byte) bool {
+				seen[k] = string(v)
+				return true
+			})
+			if len(seen) != 1 || seen[key] != "v1!" {
+				t.Errorf("Range = %v", seen)
+			}
+
+			_ = s.Delete(ctx, "ns", key)
+			if _, ok, _ := s.Get(ctx, "ns", key); ok {
+				t.Error("Delete kept the key")
+			}
+		})
+	}
+}
+
+func TestUpdateIsAtomic(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 5; j++ {
+						_, _, err := s.Update(ctx, "n", "counter", time.Minute, func(old []byte, ok bool) ([]byte, bool) {
+							n, _ := strconv.Atoi(string(old))
+							return []byte(strconv.Itoa(n + 1)), true
+						})
+						if err != nil {
+							t.Error(err)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			if v, _, _ := s.Get(ctx, "n", "counter"); string(v) != "40" {
+				t.Errorf("counter = %s, want 40", v)
+			}
+		})
+	}
+}
+
+func TestLimit(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			s.(Limiter).SetLimit("ns", 2)
+			ctx := context.Background()
+			for _, k := range []string{"a", "b", "c"} {
+				_ = s.Set(ctx, "ns", k, []byte(k), 0)
+				time.Sleep(time.Millisecond)
+			}
+			if _, ok, _ := s.Get(ctx, "ns", "a"); ok {
+				t.Error("oldest key not evicted")
+			}
+			if _, ok, _ := s.Get(ctx, "ns", "c"); !ok {
+				t.Error("newest key evicted")
+			}
+			// Rewriting a key does not count against the cap.
+			_ = s.Set(ctx, "ns", "b", []byte("b2"), 0)
+			if _, ok, _ := s.Get(ctx, "ns", "c"); !ok {
+				t.Error("overwrite evicted another key")
+			}
+			if _, ok, _ := s.Get(ctx, "other", "a"); ok {
+				t.Error("limit leaked into another namespace")
+			}
+		})
+	}
+}
+
+func TestHandlerRejectsBadToken(t *testing.T) {
+	srv := httptest.NewServer(Handler(NewMemory(), "tok"))
+	defer srv.Close()
+	if err := NewRemote(srv.URL, "wrong").Set(context.Background(), "ns", "k", []byte("v"), 0); err == nil {
+		t.Error("wrong token accepted")
+	}
+	if _, err := Open("redis://x", ""); err == nil {
+		t.Error("unknown backend accepted")
+	}
+}
+
+func TestVolatileNamespacesStayInMemory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	sq, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := Volatile(sq, "secret")
+	ctx := context.Background()
+	_ = s.Set(ctx, "secret", "k", []byte("v"), 0)
+	_ = s.Set(ctx, "plain", "k", []byte("v"), 0)
+	if v, ok, _ := s.Get(ctx, "secret", "k"); !ok || string(v) != "v" {
+		t.Fatalf("volatile key = %q, %v", v, ok)
+	}
+	s.Close()
+
+	sq, err = OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sq.Close()
+	if _, ok, _ := sq.Get(ctx, "secret", "k"); ok {
+		t.Error("volatile namespace was written to disk")
+	}
+	if _, ok, _ := sq.Get(ctx, "plain", "k"); !ok {
+		t.Error("other namespaces lost")
+	}
+}
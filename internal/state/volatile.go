@@ -0,0 +1,56 @@
+package state
+
+import (
+	"context"
+	"time"
+)
+
+type volatileStore struct {
+	Store
+	mem Store
+	ns  map[string]bool
+}
+
+// Volatile returns store with the given namespaces kept in process memory
+// instead, for values that must never be written to disk. A state service
+// serving the result still shares them with its peers, from memory.
+func Volatile(store Store, namespaces ...string) Store {
+	v := &volatileStore{Store: store, mem: NewMemory(), ns: map[string]bool{}}
+	for _, ns := range namespaces {
+		v.ns[ns] = true
+	}
+	return v
+}
+
+func (v *volatileStore) pick(ns string) Store {
+	if v.ns[ns] {
+		return v.mem
+	}
+	return v.Store
+}
+
+func (v *volatileStore) SetLimit(ns string, max int) {
+	if l, ok := v.pick(ns).(Limiter); ok {
+		l.SetLimit(ns, max)
+	}
+}
+
+func (v *volatileStore) Get(ctx context.Context, ns, key string) ([]byte, bool, error) {
+	return v.pick(ns).Get(ctx, ns, key)
+}
+
+func (v *volatileStore) Set(ctx context.Context, ns, key string, value []byte, ttl time.Duration) error {
+	return v.pick(ns).Set(ctx, ns, key, value, ttl)
+}
+
+func (v *volatileStore) Update(ctx context.Context, ns, key string, ttl time.Duration, fn UpdateFunc) ([]byte, bool, error) {
+	return v.pick(ns).Update(ctx, ns, key, ttl, fn)
+}
+
+func (v *volatileStore) Delete(ctx context.Context, ns, key string) error {
+	return v.pick(ns).Delete(ctx, ns, key)
+}
+
+func (v *volatileStore) Range(ctx context.Context, ns string, fn func(key string, value []byte) bool) error {
+	return v.pick(ns).Range(ctx, ns, fn)
+}
@@ -0,0 +1,196 @@
+package tokenpool
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Every pooled token is a separate service account. Forks of a target repo
+// are sharded across those accounts so one suspension or one account's fork
+// quota doesn't take the whole service down.
+//
+// GITGOST_SHARDING selects the assignment strategy:
+//   - "hash" (default): rendezvous hashing of owner/repo over the accounts,
+//     stable across restarts and independent of env order.
+//   - "least-loaded": the account hosting the fewest repos this process has
+//     seen, ties broken by the hash order.
+//
+// Once a repo lands on an account it stays pinned there until that account
+// is blocked for the repo, at which point the next candidate takes over.
+
+const (
+	ShardingHash        = "hash"
+	ShardingLeastLoaded = "least-loaded"
+)
+
+// blockedTTL is how long an account stays excluded for a repo after the
+// forge refused it. Maintainers rarely lift blocks, but a day lets a
+// mistaken 403 heal on its own.
+const blockedTTL = 24 * time.Hour
+
+var (
+	logins      = map[string]map[string]string{}               // provider -> token -> login
+	assignments = map[string]map[string]string{}               // provider -> repoKey -> token
+	blocked     = map[string]map[string]map[string]time.Time{} // provider -> repoKey -> token -> until
+)
+
+func shardingMode() string {
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("GITGOST_SHARDING")), ShardingLeastLoaded) {
+		return ShardingLeastLoaded
+	}
+	return ShardingHash
+}
+
+func repoKey(owner, repo string) string {
+	return strings.ToLower(owner + "/" + repo)
+}
+
+func nested(m map[string]map[string]string, provider string) map[string]string {
+	inner, ok := m[provider]
+	if !ok {
+		inner = map[string]string{}
+		m[provider] = inner
+	}
+	return inner
+}
+
+// SetLogin records the forge login a pooled token authenticates as. Tokens
+// outside the pool are ignored so user-supplied tokens never host forks.
+func SetLogin(provider, token, login string) {
+	if token == "" || login == "" || !inPool(provider, token) {
+		return
+	}
+	mu.Lock()
+	nested(logins, provider)[token] = login
+	mu.Unlock()
+}
+
+// Login returns the cached login of token, or "" when it hasn't been resolved.
+func Login(provider, token string) string {
+	mu.Lock()
+	defer mu.Unlock()
+	return logins[provider][token]
+}
+
+// TokenForLogin returns the pooled token of the service account login, or ""
+// when login isn't one of ours (or hasn't been resolved yet).
+func TokenForLogin(provider, login string) string {
+	if login == "" {
+		return ""
+	}
+	pool := Tokens(provider)
+	mu.Lock()
+	defer mu.Unlock()
+	for _, t := range pool {
+		if strings.EqualFold(logins[provider][t], login) {
+			return t
+		}
+	}
+	return ""
+}
+
+func rendezvousScore(key, token string) uint64 {
+	sum := sha256.Sum256([]byte(key + "|" + Fingerprint(token)))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// Candidates returns the pooled tokens in the order they should be tried to
+// host the fork of owner/repo: the pinned account first, then the sharding
+// order. Accounts blocked for this repo are left out and accounts in
+// rate-limit cooldown go last.
+func Candidates(provider, owner, repo string) []string {
+	tokens := Tokens(provider)
+	if len(tokens) == 0 {
+		return nil
+	}
+	key := repoKey(owner, repo)
+	now := time.Now()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	load := map[string]int{}
+	for _, t := range assignments[provider] {
+		load[t]++
+	}
+
+	available := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		if until, ok := blocked[provider][key][t]; ok && now.Before(until) {
+			continue
+		}
+		available = append(available, t)
+	}
+
+	mode := shardingMode()
+	pinned := assignments[provider][key]
+	sort.SliceStable(available, func(i, j int) bool {
+		a, b := available[i], available[j]
+		if (a == pinned) != (b == pinned) {
+			return a == pinned
+		}
+		coolA := now.Before(state(provider, a).cooldownUntil)
+		coolB := now.Before(state(provider, b).cooldownUntil)
+		if coolA != coolB {
+			return !coolA
+		}
+		if mode == ShardingLeastLoaded && load[a] != load[b] {
+			return load[a] < load[b]
+		}
+		return rendezvousScore(key, a) > rendezvousScore(key, b)
+	})
+	return available
+}
+
+// Assign pins owner/repo to token after its fork was created or found.
+func Assign(provider, owner, repo, token string) {
+	if token == "" {
+		return
+	}
+	mu.Lock()
+	nested(assignments, provider)[repoKey(owner, repo)] = token
+	mu.Unlock()
+}
+
+// MarkBlocked excludes token from hosting owner/repo, typically after the
+// maintainer blocked its account, and drops the pin so the next candidate
+// takes over.
+func MarkBlocked(provider, owner, repo, token string) {
+	if token == "" {
+		return
+	}
+	key := repoKey(owner, repo)
+	mu.Lock()
+	defer mu.Unlock()
+	byRepo, ok := blocked[provider]
+	if !ok {
+		byRepo = map[string]map[string]time.Time{}
+		blocked[provider] = byRepo
+	}
+	if byRepo[key] == nil {
+		byRepo[key] = map[string]time.Time{}
+	}
+	byRepo[key][token] = time.Now().Add(blockedTTL)
+	if assignments[provider][key] == token {
+		delete(assignments[provider], key)
+	}
+}
+
+// MarkLoginBlocked is MarkBlocked for callers that only know the fork owner.
+func MarkLoginBlocked(provider, owner, repo, login string) {
+	MarkBlocked(provider, owner, repo, TokenForLogin(provider, login))
+}
+
+func repoCount(provider, token string) int {
+	n := 0
+	for _, t := range assignments[provider] {
+		if t == token {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,69 @@
+package tokenpool
+
+import (
+	"testing"
+)
+
+func TestCandidatesHashIndependentOfEnvOrder(t *testing.T) {
+	reset()
+	t.Setenv("GITLAB_TOKEN", "gl-a")
+	t.Setenv("GITLAB2_TOKEN", "gl-b")
+	t.Setenv("GITLAB3_TOKEN", "gl-c")
+	first := Candidates(GitLab, "Acme", "Widget")
+
+	t.Setenv("GITLAB_TOKEN", "gl-c")
+	t.Setenv("GITLAB3_TOKEN", "gl-a")
+	second := Candidates(GitLab, "acme", "widget")
+
+	if len(first) != 3 || len(second) != 3 {
+		t.Fatalf("candidates = %v / %v", first, second)
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("order depends on env order: %v vs %v", first, second)
+		}
+	}
+}
+
+func TestCandidatesLeastLoaded(t *testing.T) {
+	reset()
+	t.Setenv("GITGOST_SHARDING", "least-loaded")
+	t.Setenv("CODEBERG_TOKEN", "cb-a")
+	t.Setenv("CODEBERG2_TOKEN", "cb-b")
+
+	Assign(Codeberg, "o", "one", "cb-a")
+	Assign(Codeberg, "o", "two", "cb-a")
+	if got := Candidates(Codeberg, "o", "three")[0]; got != "cb-b" {
+		t.Errorf("first candidate = %q, want least-loaded cb-b", got)
+	}
+	// A pinned repo stays on its account regardless of load.
+	if got := Candidates(Codeberg, "o", "one")[0]; got != "cb-a" {
+		t.Errorf("first candidate = %q, want pinned cb-a", got)
+	}
+}
+
+func TestMarkLoginBlockedFailsOver(t *testing.T) {
+	reset()
+	t.Setenv("GITLAB_TOKEN", "gl-a")
+	t.Setenv("GITLAB2_TOKEN", "gl-b")
+	SetLogin(GitLab, "gl-a", "ghost-a")
+	SetLogin(GitLab, "user-token", "someone")
+	Assign(GitLab, "o", "r", "gl-a")
+
+	if TokenForLogin(GitLab, "someone") != "" {
+		t.Error("login of a token outside the pool was recorded")
+	}
+
+	MarkLoginBlocked(GitLab, "O", "R", "GHOST-A")
+	got := Candidates(GitLab, "o", "r")
+	if len(got) != 1 || got[0] != "gl-b" {
+		t.Fatalf("candidates after block = %v, want [gl-b]", got)
+	}
+	if stats := Stats(GitLab); stats[0].Repos != 0 {
+		t.Errorf("blocked account still hosts %d repos", stats[0].Repos)
+	}
+	// Other repos are unaffected.
+	if got := Candidates(GitLab, "o", "other"); len(got) != 2 {
+		t.Errorf("candidates for other repo = %v", got)
+	}
+}
@@ -1,24 +1,75 @@
 package tokenpool
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+// Provider keys. Each pool reads <PREFIX>_TOKEN, <PREFIX>2_TOKEN, ... from
+// the environment, stopping at the first gap.
+const (
+	GitHub   = "github"
+	GitLab   = "gitlab"
+	Codeberg = "codeberg"
+)
+
+var envPrefixes = map[string]string{
+	GitHub:   "GITHUB",
+	GitLab:   "GITLAB",
+	Codeberg: "CODEBERG",
+}
+
+// defaultCooldown applies when a forge throttles us without saying when the
+// quota comes back.
+const defaultCooldown = 60 * time.Second
+
+type tokenState struct {
+	cooldownUntil time.Time
+	remaining     int
+	limit         int
+	resetAt       time.Time
+	requests      uint64
+	lastUsed      time.Time
+}
+
 var (
-	mu       sync.Mutex
-	index    uint64
-	cooldown = map[string]time.Time{}
+	mu     sync.Mutex
+	index  = map[string]uint64{}
+	states = map[string]map[string]*tokenState{}
 )
 
-func GitHubTokens() []string {
+func state(provider, token string) *tokenState {
+	byToken, ok := states[provider]
+	if !ok {
+		byToken = map[string]*tokenState{}
+		states[provider] = byToken
+	}
+	st, ok := byToken[token]
+	if !ok {
+		st = &tokenState{remaining: -1, limit: -1}
+		byToken[token] = st
+	}
+	return st
+}
+
+// Tokens returns the configured tokens for provider in env order.
+func Tokens(provider string) []string {
+	prefix, ok := envPrefixes[provider]
+	if !ok {
+		return nil
+	}
 	var tokens []string
 	for i := 1; ; i++ {
-		name := "GITHUB_TOKEN"
+		name := prefix + "_TOKEN"
 		if i > 1 {
-			name = fmt.Sprintf("GITHUB%d_TOKEN", i)
+			name = fmt.Sprintf("%s%d_TOKEN", prefix, i)
 		}
 		t := os.Getenv(name)
 		if t == "" {
@@ -29,10 +80,10 @@ func GitHubTokens() []string {
 	return tokens
 }
 
-// NextGitHubToken returns the next available GitHub token in round-robin order.
+// Next returns the next available token for provider in round-robin order.
 // Tokens in cooldown (rate-limited) are skipped. Returns "" if none are configured.
-func NextGitHubToken() string {
-	tokens := GitHubTokens()
+func Next(provider string) string {
+	tokens := Tokens(provider)
 	if len(tokens) == 0 {
 		return ""
 	}
@@ -41,19 +92,19 @@ func NextGitHubToken() string {
 	n := uint64(len(tokens))
 	now := time.Now()
 	for i := uint64(0); i < n; i++ {
-		idx := (index + i) % n
+		idx := (index[provider] + i) % n
 		t := tokens[idx]
-		if until, ok := cooldown[t]; ok && now.Before(until) {
+		if now.Before(state(provider, t).cooldownUntil) {
 			continue
 		}
-		index = idx + 1
+		index[provider] = idx + 1
 		return t
 	}
 	// All tokens are in cooldown; return the one that resets first.
 	var best string
 	var bestUntil time.Time
 	for _, t := range tokens {
-		until := cooldown[t]
+		until := state(provider, t).cooldownUntil
 		if best == "" || until.Before(bestUntil) {
 			best, bestUntil = t, until
 		}
@@ -61,20 +112,168 @@ func NextGitHubToken() string {
 	return best
 }
 
-// MarkGitHubRateLimited puts token in cooldown until resetAt (unix seconds).
-// If resetAt is empty, a short default cooldown is applied.
-func MarkGitHubRateLimited(token, resetAt string) {
+// MarkRateLimited puts token in cooldown until the given time. A zero time
+// applies the default cooldown.
+func MarkRateLimited(provider, token string, until time.Time) {
 	if token == "" {
 		return
 	}
-	until := time.Now().Add(60 * time.Second)
+	if until.IsZero() {
+		until = time.Now().Add(defaultCooldown)
+	}
+	mu.Lock()
+	state(provider, token).cooldownUntil = until
+	mu.Unlock()
+}
+
+// Observe records the rate-limit headers of a response made with token and
+// puts the token in cooldown when the forge throttled it. GitHub sends
+// X-RateLimit-*, GitLab RateLimit-*, and Codeberg (Forgejo) only a
+// Retry-After on 429, so all three are understood. Tokens that are not part
+// of the configured pool (user-supplied or app installation tokens) are ignored.
+func Observe(provider, token string, resp *http.Response) {
+	if token == "" || resp == nil || !inPool(provider, token) {
+		return
+	}
+	h := resp.Header
+	remaining := headerInt(h, "X-RateLimit-Remaining", "RateLimit-Remaining")
+	limit := headerInt(h, "X-RateLimit-Limit", "RateLimit-Limit")
+	reset := headerInt(h, "X-RateLimit-Reset", "RateLimit-Reset")
+
+	now := time.Now()
+	mu.Lock()
+	defer mu.Unlock()
+	st := state(provider, token)
+	st.requests++
+	st.lastUsed = now
+	if remaining >= 0 {
+		st.remaining = remaining
+	}
+	if limit >= 0 {
+		st.limit = limit
+	}
+	if reset > 0 {
+		st.resetAt = time.Unix(int64(reset), 0)
+	}
+
+	throttled := resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode == http.StatusForbidden && remaining == 0)
+	if !throttled {
+		return
+	}
+	until := now.Add(defaultCooldown)
+	if secs := headerInt(h, "Retry-After"); secs > 0 {
+		until = now.Add(time.Duration(secs) * time.Second)
+	} else if reset > 0 {
+		until = time.Unix(int64(reset), 0)
+	}
+	st.cooldownUntil = until
+}
+
+func inPool(provider, token string) bool {
+	for _, t := range Tokens(provider) {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
+
+func headerInt(h http.Header, names ...string) int {
+	for _, name := range names {
+		if v := strings.TrimSpace(h.Get(name)); v != "" {
+			if n, err := strconv.Atoi(v); err == nil {
+				return n
+			}
+		}
+	}
+	return -1
+}
+
+// Fingerprint identifies a token in logs and admin views without revealing it.
+func Fingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// TokenStats is the admin view of one pooled token (service account).
+// Remaining and Limit are -1 until the forge has reported them; Repos counts
+// the target repos currently pinned to the account.
+type TokenStats struct {
+	Provider      string     `json:"provider"`
+	Slot          int        `json:"slot"`
+	Fingerprint   string     `json:"fingerprint"`
+	Login         string     `json:"login,omitempty"`
+	Repos         int        `json:"repos"`
+	Remaining     int        `json:"remaining"`
+	Limit         int        `json:"limit"`
+	ResetAt       *time.Time `json:"reset_at,omitempty"`
+	CooldownUntil *time.Time `json:"cooldown_until,omitempty"`
+	Requests      uint64     `json:"requests"`
+	LastUsed      *time.Time `json:"last_used,omitempty"`
+}
+
+// Stats reports quota, cooldown and fork load for every configured token of provider.
+// Slot is the 1-based position in the env (GITLAB_TOKEN is 1, GITLAB2_TOKEN 2).
+func Stats(provider string) []TokenStats {
+	tokens := Tokens(provider)
+	now := time.Now()
+	mu.Lock()
+	defer mu.Unlock()
+	out := make([]TokenStats, 0, len(tokens))
+	for i, t := range tokens {
+		st := state(provider, t)
+		ts := TokenStats{
+			Provider:    provider,
+			Slot:        i + 1,
+			Fingerprint: Fingerprint(t),
+			Login:       logins[provider][t],
+			Repos:       repoCount(provider, t),
+			Remaining:   st.remaining,
+			Limit:       st.limit,
+			Requests:    st.requests,
+		}
+		if !st.resetAt.IsZero() {
+			r := st.resetAt
+			ts.ResetAt = &r
+		}
+		if now.Before(st.cooldownUntil) {
+			c := st.cooldownUntil
+			ts.CooldownUntil = &c
+		}
+		if !st.lastUsed.IsZero() {
+			l := st.lastUsed
+			ts.LastUsed = &l
+		}
+		out = append(out, ts)
+	}
+	return out
+}
+
+// Providers lists the pool keys in a stable order.
+func Providers() []string {
+	return []string{GitHub, GitLab, Codeberg}
+}
+
+func GitHubTokens() []string {
+	return Tokens(GitHub)
+}
+
+// NextGitHubToken returns the next available GitHub token in round-robin order.
+// Tokens in cooldown (rate-limited) are skipped. Returns "" if none are configured.
+func NextGitHubToken() string {
+	return Next(GitHub)
+}
+
+// MarkGitHubRateLimited puts token in cooldown until resetAt (unix seconds).
+// If resetAt is empty, a short default cooldown is applied.
+func MarkGitHubRateLimited(token, resetAt string) {
+	var until time.Time
 	if resetAt != "" {
 		var ts int64
 		if _, err := fmt.Sscanf(resetAt, "%d", &ts); err == nil && ts > 0 {
 			until = time.Unix(ts, 0)
 		}
 	}
-	mu.Lock()
-	cooldown[token] = until
-	mu.Unlock()
+	MarkRateLimited(GitHub, token, until)
 }
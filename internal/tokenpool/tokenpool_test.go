@@ -0,0 +1,91 @@
+package tokenpool
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func reset() {
+	mu.Lock()
+	index = map[string]uint64{}
+	states = map[string]map[string]*tokenState{}
+	logins = map[string]map[string]string{}
+	assignments = map[string]map[string]string{}
+	blocked = map[string]map[string]map[string]time.Time{}
+	mu.Unlock()
+}
+
+func TestNextRoundRobinPerProvider(t *testing.T) {
+	reset()
+	t.Setenv("GITLAB_TOKEN", "gl-a")
+	t.Setenv("GITLAB2_TOKEN", "gl-b")
+	t.Setenv("CODEBERG_TOKEN", "cb-a")
+
+	got := []string{Next(GitLab), Next(GitLab), Next(GitLab), Next(Codeberg)}
+	want := []string{"gl-a", "gl-b", "gl-a", "cb-a"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("rotation = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestObserveCoolsDownThrottledToken(t *testing.T) {
+	reset()
+	t.Setenv("GITLAB_TOKEN", "gl-a")
+	t.Setenv("GITLAB2_TOKEN", "gl-b")
+
+	resetAt := time.Now().Add(time.Hour).Unix()
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	resp.Header.Set("RateLimit-Remaining", "0")
+	resp.Header.Set("RateLimit-Limit", "2000")
+	resp.Header.Set("RateLimit-Reset", strconv.FormatInt(resetAt, 10))
+	Observe(GitLab, "gl-a", resp)
+
+	for i := 0; i < 3; i++ {
+		if tok := Next(GitLab); tok != "gl-b" {
+			t.Fatalf("Next = %q, want gl-b while gl-a cools down", tok)
+		}
+	}
+
+	stats := Stats(GitLab)
+	if len(stats) != 2 {
+		t.Fatalf("stats len = %d", len(stats))
+	}
+	a := stats[0]
+	if a.Remaining != 0 || a.Limit != 2000 || a.CooldownUntil == nil || a.CooldownUntil.Unix() != resetAt {
+		t.Errorf("unexpected stats for throttled token: %+v", a)
+	}
+	if a.Fingerprint == "gl-a" || len(a.Fingerprint) != 12 {
+		t.Errorf("fingerprint leaks or has wrong shape: %q", a.Fingerprint)
+	}
+}
+
+func TestObserveIgnoresTokensOutsidePool(t *testing.T) {
+	reset()
+	t.Setenv("GITHUB_TOKEN", "gh-a")
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	Observe(GitHub, "user-supplied", resp)
+
+	mu.Lock()
+	_, tracked := states[GitHub]["user-supplied"]
+	mu.Unlock()
+	if tracked {
+		t.Error("user-supplied token should not be tracked")
+	}
+}
+
+func TestObserveRetryAfterWithoutLimitHeaders(t *testing.T) {
+	reset()
+	t.Setenv("CODEBERG_TOKEN", "cb-a")
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	resp.Header.Set("Retry-After", "120")
+	Observe(Codeberg, "cb-a", resp)
+
+	st := Stats(Codeberg)[0]
+	if st.CooldownUntil == nil || time.Until(*st.CooldownUntil) < 100*time.Second {
+		t.Errorf("cooldown = %v, want ~2m", st.CooldownUntil)
+	}
+}